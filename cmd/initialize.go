@@ -2,69 +2,618 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
-	"go.uber.org/zap/zapcore"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
 	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/auth"
 	"github.com/pavelmemory/faceit-users/internal/config"
+	"github.com/pavelmemory/faceit-users/internal/featureflags"
+	"github.com/pavelmemory/faceit-users/internal/health"
 	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/metrics"
+	"github.com/pavelmemory/faceit-users/internal/policy"
+	"github.com/pavelmemory/faceit-users/internal/ratelimit"
+	"github.com/pavelmemory/faceit-users/internal/secret"
+	"github.com/pavelmemory/faceit-users/internal/session"
 	"github.com/pavelmemory/faceit-users/internal/storage"
+	"github.com/pavelmemory/faceit-users/internal/tlsconfig"
+	"github.com/pavelmemory/faceit-users/internal/tracing"
 	"github.com/pavelmemory/faceit-users/internal/user"
 	"github.com/pavelmemory/faceit-users/internal/webhttp"
 )
 
-func run([]string) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	ctx = interrupt(ctx)
-
-	settings, err := config.NewEnvSettings(os.Getenv("ENV_PREFIX"))
-	if err != nil {
-		logger := logging.NewZapLogger(zapcore.InfoLevel.String())
-		logger.WithError(err).Error("settings initialization")
-		logger.Sync()
-		return err
-	}
-
+func run(loader *config.Loader, settings config.Settings) error {
 	logger := logging.NewZapLogger(settings.LogLevel())
 	defer logger.Sync()
 
+	// background workers are stopped after the public server finishes in-flight requests that may depend on them
+	workers := newWorkers()
+
 	logger.WithString("version", internal.Version).
 		WithString("commit_sha", internal.CommitSHA).
 		WithString("build_timestamp", internal.BuildTimestamp).
 		Info("executable build info")
 
-	pgstorage, err := storage.NewPostgres(settings.StorageAddr(), settings.StoragePwd())
+	metrics.Default.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "faceit_users_build_info",
+		Help: "Build information of the executable.",
+		ConstLabels: prometheus.Labels{
+			"version": internal.Version, "commit_sha": internal.CommitSHA, "build_timestamp": internal.BuildTimestamp,
+		},
+	}, func() float64 { return 1 }))
+
+	tracer, err := newTracer(settings, logger)
+	if err != nil {
+		logger.WithError(err).Error("tracer initialization")
+		return err
+	}
+	tracing.SetDefault(tracer)
+	metrics.Default.MustRegister(tracer)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := tracer.Shutdown(ctx); err != nil {
+			logger.WithError(err).Error("tracer shutdown")
+		}
+	}()
+
+	storagePwd, jwtSecret, adminToken, debugSecret := settings.StoragePwd(), settings.AuthJWTSecret(), settings.AdminToken(), settings.LogDebugSecret()
+	pgstorage, err := storage.NewPostgres(settings.StorageAddr(), storagePwd,
+		storage.WithPool(settings.StorageMaxOpenConns(), settings.StorageMaxIdleConns(), settings.StorageConnMaxLifetime()))
 	if err != nil {
 		logger.WithError(err).Error("postgres connection establishment")
 		return err
 	}
-	defer pgstorage.Close()
+	// the secrets are read on each use, so the rotated ones take effect without restart:
+	// rotated password is used for the new connections, the established ones are replaced as they expire
+	workers.Go(func(ctx context.Context) {
+		refreshSecrets(ctx, logger, settings.SecretsRefresh(), map[string]*secret.Value{
+			"STORAGE_PWD":      storagePwd,
+			"AUTH_JWT_SECRET":  jwtSecret,
+			"ADMIN_TOKEN":      adminToken,
+			"LOG_DEBUG_SECRET": debugSecret,
+		})
+	})
+	// the connections are closed once nothing uses them
+	defer func() {
+		pgstorage.Close()
+		logger.Info("postgres connections closed")
+	}()
+	metrics.Default.MustRegister(pgstorage)
+
+	healthRegistry := health.NewRegistry(settings.HealthCacheTTL())
+	healthRegistry.Register(pgstorage.HealthChecks()...)
+
+	eventsBroker := user.NewBroker(settings.EventsReplaySize())
+	defer eventsBroker.Close()
+
+	// events are published through the database, so they reach subscribers of all instances
+	eventsReplicator := user.NewReplicator(pgstorage, eventsBroker, func(err error) {
+		logger.WithError(err).Error("user events replication")
+	})
+	workers.Go(eventsReplicator.Run)
+	workers.Go(func(ctx context.Context) {
+		pgstorage.ListenUserEvents(ctx, time.Second, time.Minute, eventsReplicator, func(err error) {
+			logger.WithError(err).Error("user events listener")
+		})
+	})
+
+	var usersStorage user.Storage = pgstorage
+	if settings.UsersCacheSize() > 0 {
+		cachedStorage := user.NewCachedStorage(pgstorage, settings.UsersCacheSize(), settings.UsersCacheTTL())
+		metrics.Default.MustRegister(cachedStorage)
+		usersStorage = cachedStorage
+
+		// users modified by other instances are removed from the cache on notifications
+		workers.Go(func(ctx context.Context) {
+			pgstorage.ListenUserChanges(ctx, time.Second, time.Minute, cachedStorage, func(err error) {
+				logger.WithError(err).Error("users changes listener")
+			})
+		})
+	}
+
+	usersService := user.NewService(usersStorage, eventsReplicator, user.WithSettings(settings.Users()))
+	idempotencyKeys := storage.NewIdempotencyKeys(pgstorage)
+	workers.Go(func(ctx context.Context) {
+		repeat(ctx, time.Hour, func() {
+			purged, err := idempotencyKeys.Purge(ctx)
+			if err != nil {
+				logger.WithError(err).Error("purge expired idempotency keys")
+				return
+			}
+			logger.WithInt64("purged", purged).Debug("expired idempotency keys purged")
+		})
+	})
+
+	levels := logging.NewLevelController(logger, debugSecret)
+
+	reloader := config.NewReloader(loader, settings)
+	metrics.Default.MustRegister(reloader)
+	reloader.Subscribe(func(old, new config.Settings) {
+		if old.LogLevel() == new.LogLevel() {
+			return
+		}
+		if err := levels.SetLevel(new.LogLevel()); err != nil {
+			logger.WithError(err).Error("reload logging level")
+		}
+	})
+
+	flags, err := newFeatureFlags(settings)
+	if err != nil {
+		logger.WithError(err).Error("feature flags initialization")
+		return err
+	}
+	reloader.Subscribe(func(_, new config.Settings) {
+		if new.FeatureFlagsFile() == "" {
+			return
+		}
+		definitions, err := featureflags.Load(new.FeatureFlagsFile())
+		if err == nil {
+			err = flags.Replace(definitions)
+		}
+		if err != nil {
+			logger.WithError(err).Error("reload feature flags")
+		}
+	})
+
+	var apiKeys *auth.APIKeyAuthenticator
+	if definitions := settings.AuthAPIKeys(); len(definitions) > 0 {
+		keys, err := parseAPIKeys(definitions)
+		if err != nil {
+			logger.WithError(err).Error("api keys initialization")
+			return err
+		}
+		apiKeys = auth.NewAPIKeyAuthenticator(keys)
+	}
+	reloader.Subscribe(func(_, new config.Settings) {
+		if apiKeys == nil {
+			if len(new.AuthAPIKeys()) > 0 {
+				logger.Info("api keys authentication can't be enabled without restart")
+			}
+			return
+		}
+		// the keys are validated before the reload is applied
+		keys, err := parseAPIKeys(new.AuthAPIKeys())
+		if err != nil {
+			logger.WithError(err).Error("reload api keys")
+			return
+		}
+		apiKeys.SetKeys(keys)
+	})
+
+	authenticator := newAuthenticator(settings, jwtSecret, apiKeys)
+
+	userHandlerOptions := []webhttp.UserHandlerOption{
+		webhttp.WithIdempotency(idempotencyKeys, settings.IdempotencyTTL(), settings.IdempotencyLease()),
+		webhttp.WithBatchLimit(settings.BatchMaxOperations()),
+		webhttp.WithCacheControl(settings.HTTPCacheControlUsers()),
+	}
+	var eventsHandlerOptions []webhttp.EventsHandlerOption
+	if authenticator != nil {
+		authorizer, err := newAuthorizer(settings)
+		if err != nil {
+			logger.WithError(err).Error("authorizer initialization")
+			return err
+		}
+		userHandlerOptions = append(userHandlerOptions, webhttp.WithAuthorization(authorizer))
+		eventsHandlerOptions = append(eventsHandlerOptions, webhttp.WithEventsAuthorization(authorizer))
+	}
+	usersHandler := webhttp.NewUsersHandler(usersService, userHandlerOptions...)
+	eventsHandler, err := webhttp.NewEventsHandler(eventsBroker, settings.EventsClientQueue(), settings.EventsHeartbeat(), eventsHandlerOptions...)
+	if err != nil {
+		logger.WithError(err).Error("events handler initialization")
+		return err
+	}
+
+	var rateLimits ratelimit.Store
+	switch settings.RateLimitStore() {
+	case "memory", "":
+		rateLimits = ratelimit.NewMemoryStore()
+	case "postgres":
+		pgRateLimits := storage.NewRateLimits(pgstorage)
+		workers.Go(func(ctx context.Context) {
+			repeat(ctx, time.Hour, func() {
+				purged, err := pgRateLimits.Purge(ctx)
+				if err != nil {
+					logger.WithError(err).Error("purge expired rate limits")
+					return
+				}
+				logger.WithInt64("purged", purged).Debug("expired rate limits purged")
+			})
+		})
+		rateLimits = pgRateLimits
+	default:
+		err := fmt.Errorf("unknown rate limit store %q", settings.RateLimitStore())
+		logger.WithError(err).Error("rate limit store initialization")
+		return err
+	}
+
+	var authenticated, limited []func(http.Handler) http.Handler
+	if authenticator != nil {
+		authenticated = append(authenticated, webhttp.Authenticate(authenticator))
+	} else {
+		logger.Info("authentication is disabled as neither AUTH_* settings nor HTTP_TLS_CLIENT_CA_FILE are set, the users API is accessible by anyone")
+	}
+	if settings.RateLimitRequests() > 0 {
+		limiter := ratelimit.NewLimiter(rateLimits, settings.RateLimit())
+		rateLimit, err := newRateLimit(settings, limiter)
+		if err != nil {
+			logger.WithError(err).Error("rate limit initialization")
+			return err
+		}
+		limited = append(limited, rateLimit)
+
+		reloader.Subscribe(func(_, new config.Settings) {
+			if new.RateLimitRequests() == 0 {
+				logger.Info("rate limiting can't be disabled without restart")
+				return
+			}
+			limiter.SetLimit(new.RateLimit())
+		})
+	} else {
+		logger.Info("rate limiting is disabled as RATE_LIMIT_REQUESTS is not set")
+		reloader.Subscribe(func(_, new config.Settings) {
+			if new.RateLimitRequests() > 0 {
+				logger.Info("rate limiting can't be enabled without restart")
+			}
+		})
+	}
+
+	routerOptions := []webhttp.RouterOption{webhttp.WithLogLevels(levels)}
+	accessLog, closeAccessLog, err := newAccessLogger(settings)
+	if err != nil {
+		logger.WithError(err).Error("access log initialization")
+		return err
+	}
+	// the access log is closed once the public server finishes in-flight requests
+	defer closeAccessLog()
+	if accessLog != nil {
+		routerOptions = append(routerOptions, webhttp.WithAccessLog(accessLog))
+	} else {
+		logger.Info("access log is disabled as ACCESS_LOG_OUTPUT is 'none'")
+	}
+
+	// the principal is required to evaluate the feature flags and limit requests by it
+	flagged := webhttp.FeatureFlags(flags, settings.FeatureFlagsCountryHeader())
+	router := webhttp.NewRouter(logger, routerOptions...)
+	// the probes are served on the public port, as the orchestrator can't reach the admin server bound to the local interface
+	infoHandler := webhttp.NewInfoHandler(healthRegistry)
+	infoHandler.RegisterProbes(router)
+	protected := router.With(append(append(authenticated, flagged), limited...)...)
+	public := router.With(append([]func(http.Handler) http.Handler{flagged}, limited...)...)
+	usersHandler.Register(protected)
+	eventsHandler.Register(protected)
+
+	// sessions are issued with the same secret the access tokens are verified with
+	if jwtSecret.Get() != "" {
+		sessionService := session.NewService(pgstorage, auth.NewHS256Signer(jwtSecret),
+			session.WithTTL(settings.SessionAccessTTL(), settings.SessionRefreshTTL()),
+			session.WithIssuer(settings.AuthJWTIssuer(), settings.AuthJWTAudience()),
+		)
+		workers.Go(func(ctx context.Context) {
+			repeat(ctx, time.Hour, func() {
+				purged, err := sessionService.Purge(ctx)
+				if err != nil {
+					logger.WithError(err).Error("purge expired refresh tokens")
+					return
+				}
+				logger.WithInt64("purged", purged).Debug("expired refresh tokens purged")
+			})
+		})
+		loginLimiter := ratelimit.NewFailureLimiter(rateLimits, settings.LoginFailurePolicy())
+		webhttp.NewSessionHandler(sessionService, webhttp.WithLoginLimiter(loginLimiter)).Register(public)
+	} else {
+		logger.Info("sessions are disabled as AUTH_JWT_SECRET is not set")
+	}
+
+	srvOptions := []webhttp.ServerOption{webhttp.WithTimeouts(settings.HTTPReadHeaderTimeout(), settings.HTTPIdleTimeout())}
+	var tlsServer *tlsconfig.Server
+	if files := settings.HTTPTLSFiles(); files.CertFile != "" {
+		tlsServer, err = tlsconfig.NewServer(files,
+			tlsconfig.WithMinVersion(settings.HTTPTLSMinVersion()),
+			tlsconfig.WithCipherSuites(settings.HTTPTLSCipherSuites()),
+			tlsconfig.WithClientAuth(settings.HTTPTLSClientAuth()),
+		)
+		if err != nil {
+			logger.WithError(err).Error("tls initialization")
+			return err
+		}
+		metrics.Default.MustRegister(tlsServer)
+		srvOptions = append(srvOptions, webhttp.WithTLS(tlsServer.TLSConfig()))
+	} else {
+		logger.Info("public server accepts plaintext connections as HTTP_TLS_CERT_FILE is not set")
+	}
+	srv := webhttp.NewServer(router, srvOptions...)
+	// event streams are endless, they need to be terminated to let the server stop gracefully
+	srv.RegisterOnShutdown(eventsBroker.Close)
+
+	adminRouter := webhttp.NewAdminRouter(logger)
+	infoHandler.Register(adminRouter)
+	webhttp.NewDiagnosticsHandler(adminToken, settings.DumpDir()).Register(adminRouter)
+	if adminToken.Get() != "" {
+		webhttp.NewAdminHandler(adminToken, levels).Register(adminRouter)
+		webhttp.NewFeatureFlagsHandler(adminToken, flags).Register(adminRouter)
+	} else {
+		logger.Info("logging level and feature flags control and runtime diagnostics are disabled as ADMIN_TOKEN is not set")
+	}
+	adminSrv := webhttp.NewServer(adminRouter, webhttp.WithTimeouts(settings.HTTPReadHeaderTimeout(), settings.HTTPIdleTimeout()))
+
+	// requests are not routed to the service that is going to stop, but the ones already routed are served
+	// during the delay, then the servers finish in-flight requests
+	ctx := shutdownOnSignal(logger, settings.ShutdownDelay(), func() {
+		healthRegistry.SetState(health.StateDraining)
+	})
+	healthRegistry.SetState(health.StateReady)
+
+	// rotated certificates are used for the new connections
+	if tlsServer != nil {
+		reloadTLS := func(trigger string) {
+			logger := logger.WithString("trigger", trigger)
+			if err := tlsServer.Reload(); err != nil {
+				logger.WithError(err).Error("reload tls certificates")
+				return
+			}
+			logger.Info("tls certificates reloaded")
+		}
+		reloader.Subscribe(func(_, _ config.Settings) { reloadTLS("settings reload") })
+		files := settings.HTTPTLSFiles()
+		for _, path := range []string{files.CertFile, files.KeyFile, files.ClientCAFile} {
+			if path == "" || settings.ConfigWatchInterval() <= 0 {
+				continue
+			}
+			path := path
+			workers.Go(func(ctx context.Context) {
+				config.WatchFile(ctx, path, settings.ConfigWatchInterval(), func() { reloadTLS("file") })
+			})
+		}
+	}
 
-	usersService := user.NewService(pgstorage)
-	usersHandler := webhttp.NewUsersHandler(usersService)
+	// subscribers are registered, so the settings could be reloaded
+	reload := func(trigger string) {
+		logger := logger.WithString("trigger", trigger)
+		ignored, err := reloader.Reload()
+		if err != nil {
+			logger.WithError(err).Error("reload settings")
+			return
+		}
+		if len(ignored) > 0 {
+			logger = logger.WithString("ignored", strings.Join(ignored, ","))
+		}
+		logger.Info("settings reloaded")
+	}
+	workers.Go(func(ctx context.Context) { onSignal(ctx, syscall.SIGHUP, func() { reload("signal") }) })
+	if path := loader.File(); path != "" && settings.ConfigWatchInterval() > 0 {
+		workers.Go(func(ctx context.Context) {
+			config.WatchFile(ctx, path, settings.ConfigWatchInterval(), func() { reload("file") })
+		})
+	}
+	if path := settings.FeatureFlagsFile(); path != "" && settings.ConfigWatchInterval() > 0 {
+		workers.Go(func(ctx context.Context) {
+			config.WatchFile(ctx, path, settings.ConfigWatchInterval(), func() { reload("feature flags file") })
+		})
+	}
 
-	router := webhttp.NewRouter(logger)
-	usersHandler.Register(router)
-	srv := webhttp.NewServer(router)
+	stopWorkers := func() {
+		if workers.Stop(settings.ShutdownGracePeriod()) {
+			logger.Info("background workers stopped")
+		} else {
+			logger.WithString("grace_period", settings.ShutdownGracePeriod().String()).Info("background workers forced to stop")
+		}
+	}
 
-	return webhttp.Serve(ctx, logger, srv, settings.HTTPPort())
+	// the admin server is stopped last, so the health and metrics are available while the service drains
+	return webhttp.ServeAll(ctx, logger,
+		webhttp.Binding{Name: "public", Addr: ":" + strconv.Itoa(settings.HTTPPort()), Server: srv, GracePeriod: settings.ShutdownGracePeriod(), AfterStop: stopWorkers},
+		webhttp.Binding{Name: "admin", Addr: net.JoinHostPort(settings.AdminHTTPHost(), strconv.Itoa(settings.AdminHTTPPort())), Server: adminSrv, GracePeriod: settings.ShutdownGracePeriod()},
+	)
 }
 
-// interrupt listens for SIGINT and cancels context.
-func interrupt(ctx context.Context) context.Context {
-	cctx, cancel := context.WithCancel(ctx)
+// newTracer returns a tracer provider with the exporter chosen by settings.
+// Failures of the export are logged.
+func newTracer(settings config.Settings, logger logging.Logger) (*tracing.Provider, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch settings.TracingExporter() {
+	case "none", "":
+	case "stdout":
+		exporter, err = tracing.NewStdoutExporter(os.Stdout)
+	case "otlp":
+		exporter, err = tracing.NewOTLPExporter(context.Background(), settings.TracingOTLPEndpoint())
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", settings.TracingExporter())
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.WithError(err).Error("export spans")
+	}))
+	return tracing.NewProvider(exporter,
+		tracing.WithSampleRatio(settings.TracingSampleRatio()),
+		tracing.WithServiceName(settings.TracingServiceName()),
+	), nil
+}
 
-	go func() {
-		<-c
-		cancel()
-	}()
+// newAccessLogger returns an access logger writing into the output set by settings and a function
+// that closes the output. It returns nil logger if the access log is disabled.
+func newAccessLogger(settings config.Settings) (*webhttp.AccessLogger, func(), error) {
+	var (
+		w       io.Writer
+		closeFn = func() {}
+	)
+	switch output := settings.AccessLogOutput(); output {
+	case "none":
+		return nil, closeFn, nil
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open access log: %w", err)
+		}
+		w, closeFn = file, func() { _ = file.Close() }
+	}
+
+	return webhttp.NewAccessLogger(w, settings.AccessLogFormat(),
+		webhttp.WithSuccessSampling(settings.AccessLogSuccessSamplePercent()),
+		webhttp.WithTrustedProxies(settings.HTTPTrustedProxies()),
+	), closeFn, nil
+}
+
+// newAuthenticator returns an authenticator of the requests configured by settings.
+// It returns nil if neither tokens nor API keys are configured.
+func newAuthenticator(settings config.Settings, jwtSecret *secret.Value, apiKeys *auth.APIKeyAuthenticator) auth.Authenticator {
+	var authenticators []auth.Authenticator
+
+	var keys []auth.KeySet
+	if jwtSecret.Get() != "" {
+		keys = append(keys, auth.NewSecretKeySet(jwtSecret))
+	}
+	if settings.AuthJWKSURL() != "" {
+		keys = append(keys, auth.NewJWKS(auth.JWKSURL(settings.AuthJWKSURL(), &http.Client{Timeout: settings.AuthJWKSTimeout(), Transport: tracing.Transport(nil)}), settings.AuthJWKSRefresh()))
+	}
+	if settings.AuthJWKSFile() != "" {
+		keys = append(keys, auth.NewJWKS(auth.JWKSFile(settings.AuthJWKSFile()), settings.AuthJWKSRefresh()))
+	}
+	if len(keys) > 0 {
+		authenticators = append(authenticators, auth.NewJWTAuthenticator(
+			auth.MultiKeySet(keys...),
+			auth.WithIssuer(settings.AuthJWTIssuer()),
+			auth.WithAudience(settings.AuthJWTAudience()),
+		))
+	}
+
+	if apiKeys != nil {
+		authenticators = append(authenticators, apiKeys)
+	}
 
-	return cctx
+	// the client certificate identifies the caller only if the request has no other credentials,
+	// e.g. a token of the user the request is made on behalf of
+	if settings.HTTPTLSFiles().ClientCAFile != "" {
+		authenticators = append(authenticators, auth.NewClientCertAuthenticator())
+	}
+
+	if len(authenticators) == 0 {
+		return nil
+	}
+	return auth.Chain(authenticators...)
+}
+
+// parseAPIKeys parses definitions of the API keys.
+func parseAPIKeys(definitions []string) ([]auth.APIKey, error) {
+	keys := make([]auth.APIKey, 0, len(definitions))
+	for _, definition := range definitions {
+		key, err := auth.ParseAPIKey(definition)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// newAuthorizer returns a policy engine with the rules from the configured file or the default ones.
+func newAuthorizer(settings config.Settings) (*policy.Engine, error) {
+	if settings.AuthPolicyFile() == "" {
+		return policy.Parse([]byte(policy.Default))
+	}
+	return policy.Load(settings.AuthPolicyFile())
+}
+
+// newFeatureFlags returns feature flags defined in the file set by settings.
+func newFeatureFlags(settings config.Settings) (*featureflags.Flags, error) {
+	if settings.FeatureFlagsFile() == "" {
+		return featureflags.New(nil)
+	}
+
+	definitions, err := featureflags.Load(settings.FeatureFlagsFile())
+	if err != nil {
+		return nil, err
+	}
+	return featureflags.New(definitions)
+}
+
+// newRateLimit returns a middleware that limits requests with `limiter` by the keys chosen by settings.
+func newRateLimit(settings config.Settings, limiter *ratelimit.Limiter) (func(http.Handler) http.Handler, error) {
+	var keys []webhttp.RateLimitKey
+	for _, by := range settings.RateLimitBy() {
+		switch by {
+		case "ip":
+			keys = append(keys, webhttp.RateLimitByIP(settings.HTTPTrustedProxies()))
+		case "principal":
+			keys = append(keys, webhttp.RateLimitByPrincipal(settings.HTTPTrustedProxies()))
+		case "route":
+			keys = append(keys, webhttp.RateLimitByRoute)
+		default:
+			return nil, fmt.Errorf("unknown rate limit key %q", by)
+		}
+	}
+
+	return webhttp.RateLimit(limiter, keys...), nil
+}
+
+// refreshSecrets re-reads the `secrets` keyed by names of their settings with `interval` until context is cancelled.
+func refreshSecrets(ctx context.Context, logger logging.Logger, interval time.Duration, secrets map[string]*secret.Value) {
+	repeat(ctx, interval, func() {
+		for name, value := range secrets {
+			changed, err := value.Refresh(ctx)
+			if err != nil {
+				logger.WithString("secret", name).WithError(err).Error("refresh secret")
+				continue
+			}
+			if changed {
+				logger.WithString("secret", name).Info("secret rotated")
+			}
+		}
+	})
+}
+
+// repeat calls `action` with `interval` until context is cancelled.
+func repeat(ctx context.Context, interval time.Duration, action func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			action()
+		}
+	}
+}
+
+// onSignal calls `action` each time the `sig` is received until context is cancelled.
+func onSignal(ctx context.Context, sig os.Signal, action func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sig)
+	defer signal.Stop(c)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c:
+			action()
+		}
+	}
 }
@@ -3,22 +3,64 @@ package main
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
 
+	"go.uber.org/zap/zapcore"
+
 	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/config"
+	"github.com/pavelmemory/faceit-users/internal/logging"
 )
 
 func main() {
-	var showVersionLong = flag.Bool("version", false, "")
-	var showVersionShort = flag.Bool("v", false, "")
-	flag.Parse()
+	args := os.Args[1:]
+
+	// 'config print' command shows effective settings without starting the service
+	printConfig := len(args) >= 2 && args[0] == "config" && args[1] == "print"
+	if printConfig {
+		args = args[2:]
+	}
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	var showVersionLong = flags.Bool("version", false, "")
+	var showVersionShort = flags.Bool("v", false, "")
+	loader := config.NewLoader(os.Getenv("ENV_PREFIX"), flags)
+	_ = flags.Parse(args)
 
 	if *showVersionLong || *showVersionShort {
 		_ = internal.WriteVersion(json.NewEncoder(os.Stdout))
 		os.Exit(0)
 	}
 
-	if err := run(os.Args); err != nil {
+	settings, err := loader.Load()
+	if err != nil {
+		if printConfig {
+			if errs, ok := err.(config.Errors); ok {
+				for _, err := range errs {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			} else {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			os.Exit(1)
+		}
+
+		logger := logging.NewZapLogger(zapcore.InfoLevel.String())
+		logger.WithError(err).Error("settings initialization")
+		logger.Sync()
+		os.Exit(1)
+	}
+
+	if printConfig {
+		if err := config.Print(os.Stdout, settings); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if err := run(loader, settings); err != nil {
 		os.Exit(1)
 	}
 }
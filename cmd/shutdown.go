@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pavelmemory/faceit-users/internal/logging"
+)
+
+// shutdownOnSignal returns a context that is cancelled once `delay` passes since SIGTERM or SIGINT is received.
+// `onSignal` is called right away, so the service could report it is not ready while it still serves requests
+// routed to it before the load balancer notices that. The process exits immediately on the second signal.
+func shutdownOnSignal(logger logging.ZapWrapper, delay time.Duration, onSignal func()) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, syscall.SIGTERM, os.Interrupt)
+
+	go func() {
+		sig := <-c
+		logger.WithString("signal", sig.String()).WithString("delay", delay.String()).Info("shutdown started")
+		onSignal()
+
+		select {
+		case <-time.After(delay):
+			cancel()
+		case sig := <-c:
+			forceExit(logger, sig)
+		}
+
+		forceExit(logger, <-c)
+	}()
+
+	return ctx
+}
+
+// forceExit terminates the process without waiting for in-flight requests and background workers.
+func forceExit(logger logging.ZapWrapper, sig os.Signal) {
+	logger.WithString("signal", sig.String()).Info("shutdown forced")
+	logger.Sync()
+	os.Exit(1)
+}
+
+// newWorkers returns a group of background workers that run until the group is stopped.
+func newWorkers() *workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workers{ctx: ctx, cancel: cancel}
+}
+
+// workers are background goroutines the service stops once the servers don't accept requests anymore.
+type workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Go runs `work` in a separate goroutine, the context is cancelled once the group is stopped.
+func (w *workers) Go(work func(ctx context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		work(w.ctx)
+	}()
+}
+
+// Stop cancels the context of the workers and waits for them to finish at most `timeout`.
+// It returns false if some of them are still running.
+func (w *workers) Stop(timeout time.Duration) bool {
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -1,14 +1,22 @@
 module github.com/pavelmemory/faceit-users
 
-go 1.14
+go 1.15
 
 require (
 	github.com/go-chi/chi v4.1.2+incompatible
 	github.com/golang/mock v1.4.4
 	github.com/goware/emailx v0.2.0
-	github.com/kelseyhightower/envconfig v1.4.0
 	github.com/lib/pq v1.8.0
-	github.com/stretchr/testify v1.4.0
+	github.com/prometheus/client_golang v1.12.2
+	github.com/stretchr/testify v1.7.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	go.opentelemetry.io/otel v1.2.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.2.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.2.0
+	go.opentelemetry.io/otel/sdk v1.2.0
+	go.opentelemetry.io/otel/trace v1.2.0
 	go.uber.org/zap v1.16.0
-	golang.org/x/tools v0.0.0-20191029190741-b9c20aec41a5
+	golang.org/x/tools v0.0.0-20200825202427-b303f430e36d
+	google.golang.org/protobuf v1.28.1
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,77 @@
+// +build integration
+
+package test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/secret"
+	"github.com/pavelmemory/faceit-users/internal/storage"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	pg, err := storage.NewPostgres("localhost:5432", secret.NewValue("", nil))
+	require.NoError(t, err)
+	defer pg.Close()
+
+	store := storage.NewIdempotencyKeys(pg)
+	ctx := context.Background()
+	// keys are unique for each run, so the test doesn't depend on the state left by previous runs
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	// fingerprints are SHA-256 digests in hex
+	first, retry := strings.Repeat("1", 64), strings.Repeat("2", 64)
+
+	t.Run("outlived lease", func(t *testing.T) {
+		key := run + "-lease"
+		expired, reserved, err := store.Reserve(ctx, key, first, -time.Second)
+		require.NoError(t, err)
+		require.True(t, reserved)
+		require.NotEmpty(t, expired.Token)
+
+		retried, reserved, err := store.Reserve(ctx, key, retry, time.Minute)
+		require.NoError(t, err)
+		require.True(t, reserved, "expired reservation is taken over")
+		require.NotEqual(t, expired.Token, retried.Token)
+
+		require.NoError(t, store.Complete(ctx, key, expired.Token, http.StatusCreated, "/users/1", time.Hour))
+		require.NoError(t, store.Release(ctx, key, expired.Token))
+
+		rec, reserved, err := store.Reserve(ctx, key, retry, time.Minute)
+		require.NoError(t, err)
+		require.False(t, reserved)
+		require.Empty(t, rec.Token, "token of other reservation is not exposed")
+		require.Equal(t, retry, rec.Fingerprint)
+		require.Zero(t, rec.Status, "outdated reservation can't complete or release the key")
+
+		require.NoError(t, store.Complete(ctx, key, retried.Token, http.StatusCreated, "/users/2", time.Hour))
+		rec, _, err = store.Reserve(ctx, key, retry, time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, rec.Status)
+		require.Equal(t, "/users/2", rec.Location)
+	})
+
+	t.Run("concurrent reserve and release", func(t *testing.T) {
+		key := run + "-concurrent"
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rec, reserved, err := store.Reserve(ctx, key, first, time.Minute)
+				require.NoError(t, err, "released key is reserved again or reported as in progress")
+				if reserved {
+					require.NoError(t, store.Release(ctx, key, rec.Token))
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
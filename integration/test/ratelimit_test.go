@@ -0,0 +1,104 @@
+// +build integration
+
+package test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/ratelimit"
+	"github.com/pavelmemory/faceit-users/internal/secret"
+	"github.com/pavelmemory/faceit-users/internal/storage"
+)
+
+func TestRateLimits(t *testing.T) {
+	pg, err := storage.NewPostgres("localhost:5432", secret.NewValue("", nil))
+	require.NoError(t, err)
+	defer pg.Close()
+
+	store := storage.NewRateLimits(pg)
+	ctx := context.Background()
+	// keys are unique for each run, so the test doesn't depend on the state left by previous runs
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	limit := ratelimit.Every(time.Minute, 2)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	t.Run("take", func(t *testing.T) {
+		key := run + "-take"
+		take := func(at time.Time) (float64, bool) {
+			tokens, taken, err := store.Take(ctx, key, at, time.Minute, limit)
+			require.NoError(t, err)
+			return tokens, taken
+		}
+
+		tokens, taken := take(now)
+		require.True(t, taken)
+		require.Equal(t, 1.0, tokens)
+
+		tokens, taken = take(now)
+		require.True(t, taken)
+		require.Equal(t, 0.0, tokens)
+
+		tokens, taken = take(now.Add(15 * time.Second))
+		require.False(t, taken)
+		require.InDelta(t, 0.5, tokens, 1e-9, "tokens are refilled, but not taken")
+
+		tokens, taken = take(now.Add(30 * time.Second))
+		require.True(t, taken)
+		require.InDelta(t, 0.0, tokens, 1e-9)
+
+		tokens, taken = take(now.Add(time.Hour))
+		require.True(t, taken)
+		require.Equal(t, 1.0, tokens, "expired bucket is full")
+	})
+
+	t.Run("concurrent take", func(t *testing.T) {
+		key := run + "-concurrent"
+		var wg sync.WaitGroup
+		var mtx sync.Mutex
+		var taken int
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, ok, err := store.Take(ctx, key, now, time.Minute, ratelimit.Every(time.Hour, 5))
+				require.NoError(t, err)
+				if ok {
+					mtx.Lock()
+					taken++
+					mtx.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, 5, taken, "each token is taken once")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		key := run + "-update"
+		update := func(at time.Time) (value float64, updatedAt time.Time) {
+			err := store.Update(ctx, key, at, time.Minute, func(v float64, u time.Time) (float64, time.Time) {
+				value, updatedAt = v, u
+				return v + 1, at
+			})
+			require.NoError(t, err)
+			return value, updatedAt
+		}
+
+		value, updatedAt := update(now)
+		require.Zero(t, value)
+		require.True(t, updatedAt.IsZero(), "new key has zero state")
+
+		value, updatedAt = update(now.Add(time.Second))
+		require.Equal(t, 1.0, value)
+		require.True(t, now.Equal(updatedAt))
+
+		value, _ = update(now.Add(time.Hour))
+		require.Zero(t, value, "expired state is zero")
+	})
+}
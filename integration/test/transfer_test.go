@@ -0,0 +1,84 @@
+// +build integration
+
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserImportExport(t *testing.T) {
+	httpClient := http.DefaultClient
+	// nicknames and emails are unique for each run, so the test doesn't conflict with the users left by previous runs
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	// more users than fetched from the cursor at once, so the export reads several batches
+	const total = 1200
+
+	line := func(i int) string {
+		return fmt.Sprintf(`{"first_name":"f","last_name":"l","nickname":"%s-%d","email":"%s-%d@mail.com","password":"password","country":"XX"}`, run, i, run, i)
+	}
+
+	importUsers := func(payload string) map[string]interface{} {
+		resp, err := httpClient.Post("http://localhost:8080/users/import", "application/x-ndjson", strings.NewReader(payload))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var report map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		return report
+	}
+
+	var payload strings.Builder
+	for i := 0; i < total; i++ {
+		payload.WriteString(line(i) + "\n")
+	}
+	// duplicates of the payload lines are rejected, the first occurrence is imported
+	payload.WriteString(line(0) + "\n")
+	payload.WriteString(strings.Replace(line(1), "@mail.com", "@other.com", 1) + "\n")
+
+	report := importUsers(payload.String())
+	require.EqualValues(t, total, report["imported"])
+	require.EqualValues(t, 2, report["rejected"])
+	rejections := report["rejections"].([]interface{})
+	require.EqualValues(t, total+1, rejections[0].(map[string]interface{})["line"])
+	require.EqualValues(t, http.StatusConflict, rejections[0].(map[string]interface{})["status"])
+	require.EqualValues(t, total+2, rejections[1].(map[string]interface{})["line"])
+
+	// users that already exist are rejected as well
+	report = importUsers(line(5) + "\n")
+	require.EqualValues(t, 0, report["imported"])
+	require.EqualValues(t, 1, report["rejected"])
+
+	resp, err := httpClient.Get("http://localhost:8080/users/export")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	exported := map[string]int{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var u struct {
+			ID       string `json:"id"`
+			Nickname string `json:"nickname"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &u))
+		require.NotEmpty(t, u.ID)
+		if strings.HasPrefix(u.Nickname, run+"-") {
+			exported[u.Nickname]++
+		}
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, exported, total, "all imported users are exported")
+	for nickname, n := range exported {
+		require.Equal(t, 1, n, "user %s is exported once", nickname)
+	}
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// APIKeyHeader is a name of the HTTP header the API key is passed with.
+const APIKeyHeader = "X-API-Key"
+
+// APIKey is a key of the single client, only a hash of the key is kept.
+type APIKey struct {
+	// Subject identifies the owner of the key.
+	Subject string
+	// Hash is SHA-256 hash of the key.
+	Hash [sha256.Size]byte
+}
+
+// ParseAPIKey parses a definition of the key in '<subject>:<key>' or '<subject>:sha256:<hex encoded hash of the key>'
+// format. The hashed form allows to keep the keys out of the configuration.
+func ParseAPIKey(definition string) (APIKey, error) {
+	sep := strings.IndexByte(definition, ':')
+	if sep <= 0 || sep == len(definition)-1 {
+		return APIKey{}, errors.New("api key must be defined as '<subject>:<key>' or '<subject>:sha256:<hash>'")
+	}
+
+	key := APIKey{Subject: definition[:sep]}
+	secret := definition[sep+1:]
+
+	const hashPrefix = "sha256:"
+	if !strings.HasPrefix(secret, hashPrefix) {
+		key.Hash = sha256.Sum256([]byte(secret))
+		return key, nil
+	}
+
+	hash, err := hex.DecodeString(secret[len(hashPrefix):])
+	if err != nil || len(hash) != sha256.Size {
+		return APIKey{}, fmt.Errorf("api key of %q: hash must be hex encoded SHA-256 value", key.Subject)
+	}
+	copy(key.Hash[:], hash)
+	return key, nil
+}
+
+// NewAPIKeyAuthenticator returns an authenticator of requests with 'X-API-Key' header.
+func NewAPIKeyAuthenticator(keys []APIKey) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: keys}
+}
+
+// APIKeyAuthenticator authenticates requests with API keys. The keys could be replaced at runtime.
+type APIKeyAuthenticator struct {
+	mtx  sync.RWMutex
+	keys []APIKey
+}
+
+// SetKeys replaces the keys, the requests with the keys that are not in `keys` are rejected from now on.
+func (aka *APIKeyAuthenticator) SetKeys(keys []APIKey) {
+	aka.mtx.Lock()
+	defer aka.mtx.Unlock()
+
+	aka.keys = keys
+}
+
+// Authenticate looks for the key sent with the request.
+func (aka *APIKeyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	value := r.Header.Get(APIKeyHeader)
+	if value == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	hash := sha256.Sum256([]byte(value))
+
+	aka.mtx.RLock()
+	defer aka.mtx.RUnlock()
+
+	// all keys are compared, so the time of the lookup doesn't depend on the position of the key
+	found := -1
+	for i, key := range aka.keys {
+		if subtle.ConstantTimeCompare(hash[:], key.Hash[:]) == 1 {
+			found = i
+		}
+	}
+
+	if found < 0 {
+		return Principal{}, fmt.Errorf("unknown api key: %w", ErrInvalidCredentials)
+	}
+	return Principal{Subject: aka.keys[found].Subject, Method: MethodAPIKey}, nil
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAPIKey(t *testing.T) {
+	hash := sha256.Sum256([]byte("key"))
+
+	key, err := ParseAPIKey("service:key")
+	require.NoError(t, err)
+	require.Equal(t, APIKey{Subject: "service", Hash: hash}, key)
+
+	key, err = ParseAPIKey("service:sha256:" + hex.EncodeToString(hash[:]))
+	require.NoError(t, err)
+	require.Equal(t, APIKey{Subject: "service", Hash: hash}, key)
+
+	for _, definition := range []string{"", "service", "service:", ":key", "service:sha256:abc"} {
+		_, err := ParseAPIKey(definition)
+		require.Error(t, err, definition)
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	first, err := ParseAPIKey("first:key-1")
+	require.NoError(t, err)
+	second, err := ParseAPIKey("second:key-2")
+	require.NoError(t, err)
+	aka := NewAPIKeyAuthenticator([]APIKey{first, second})
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	_, err = aka.Authenticate(req)
+	require.True(t, errors.Is(err, ErrNoCredentials))
+
+	req.Header.Set(APIKeyHeader, "key-2")
+	principal, err := aka.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, Principal{Subject: "second", Method: MethodAPIKey}, principal)
+
+	req.Header.Set(APIKeyHeader, "key-3")
+	_, err = aka.Authenticate(req)
+	require.True(t, errors.Is(err, ErrInvalidCredentials))
+
+	third, err := ParseAPIKey("third:key-3")
+	require.NoError(t, err)
+	aka.SetKeys([]APIKey{first, third})
+	principal, err = aka.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, Principal{Subject: "third", Method: MethodAPIKey}, principal)
+
+	req.Header.Set(APIKeyHeader, "key-2")
+	_, err = aka.Authenticate(req)
+	require.True(t, errors.Is(err, ErrInvalidCredentials), "replaced key is rejected")
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrNoCredentials is returned if the request doesn't contain credentials supported by the authenticator.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned if the credentials are malformed, expired or not recognized.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authentication methods the principal could be authenticated with.
+const (
+	MethodJWT        = "jwt"
+	MethodAPIKey     = "api_key"
+	MethodClientCert = "client_cert"
+)
+
+// Principal is an authenticated caller.
+type Principal struct {
+	// Subject identifies the caller: 'sub' claim of the token, a name of the API key or an identity of the client certificate.
+	Subject string
+	// Method is a way the caller was authenticated with, e.g. MethodJWT.
+	Method string
+	// Roles granted to the caller, e.g. by 'roles' claim of the token.
+	Roles []string
+}
+
+// HasRole reports if the role is granted to the principal.
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type ctxKey struct{}
+
+// FromContext extracts the principal from the context, it returns false if the request is not authenticated.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
+
+// ToContext injects the principal into the context.
+func ToContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// Authenticator verifies credentials of the request.
+type Authenticator interface {
+	// Authenticate returns the principal the request is made by.
+	// ErrNoCredentials is returned if the request has no credentials the authenticator supports.
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// AuthenticatorFunc is an adapter to allow use of ordinary functions as authenticators.
+type AuthenticatorFunc func(r *http.Request) (Principal, error)
+
+// Authenticate calls f(r).
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (Principal, error) {
+	return f(r)
+}
+
+// Chain returns an authenticator that tries `authenticators` in order until one of them finds credentials.
+func Chain(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (Principal, error) {
+		for _, authenticator := range authenticators {
+			p, err := authenticator.Authenticate(r)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			return p, err
+		}
+		return Principal{}, ErrNoCredentials
+	})
+}
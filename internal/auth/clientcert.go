@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+)
+
+// NewClientCertAuthenticator returns an authenticator of requests sent over TLS connections with verified client certificates.
+func NewClientCertAuthenticator() *ClientCertAuthenticator {
+	return &ClientCertAuthenticator{}
+}
+
+// ClientCertAuthenticator authenticates callers with certificates verified by the server during TLS handshake.
+type ClientCertAuthenticator struct{}
+
+// Authenticate returns the principal identified by the client certificate of the connection.
+func (cca *ClientCertAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Principal{}, ErrNoCredentials
+	}
+
+	subject := CertificateSubject(r.TLS.VerifiedChains[0][0])
+	if subject == "" {
+		return Principal{}, fmt.Errorf("client certificate has no identity: %w", ErrInvalidCredentials)
+	}
+	return Principal{Subject: subject, Method: MethodClientCert}, nil
+}
+
+// CertificateSubject returns an identity of the certificate: the first URI SAN (e.g. SPIFFE ID),
+// the first DNS SAN or the common name, whichever is set first.
+func CertificateSubject(cert *x509.Certificate) string {
+	switch {
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	default:
+		return cert.Subject.CommonName
+	}
+}
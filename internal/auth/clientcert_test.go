@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCertAuthenticator(t *testing.T) {
+	cca := NewClientCertAuthenticator()
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	_, err := cca.Authenticate(req)
+	require.True(t, errors.Is(err, ErrNoCredentials), "plaintext connection")
+
+	req.TLS = &tls.ConnectionState{}
+	_, err = cca.Authenticate(req)
+	require.True(t, errors.Is(err, ErrNoCredentials), "no client certificate")
+
+	spiffeID, err := url.Parse("spiffe://cluster.local/ns/default/sa/billing")
+	require.NoError(t, err)
+
+	for subject, cert := range map[string]*x509.Certificate{
+		"spiffe://cluster.local/ns/default/sa/billing": {URIs: []*url.URL{spiffeID}, DNSNames: []string{"billing.default.svc"}, Subject: pkix.Name{CommonName: "billing"}},
+		"billing.default.svc":                          {DNSNames: []string{"billing.default.svc"}, Subject: pkix.Name{CommonName: "billing"}},
+		"billing":                                      {Subject: pkix.Name{CommonName: "billing"}},
+	} {
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		principal, err := cca.Authenticate(req)
+		require.NoError(t, err)
+		require.Equal(t, Principal{Subject: subject, Method: MethodClientCert}, principal)
+	}
+
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	_, err = cca.Authenticate(req)
+	require.True(t, errors.Is(err, ErrInvalidCredentials), "certificate without identity")
+}
@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+// ErrUnknownKey is returned if there is no key to verify the token with.
+var ErrUnknownKey = errors.New("unknown key")
+
+// KeySet provides keys to verify signatures of the tokens.
+type KeySet interface {
+	// Key returns a key suitable for the algorithm `alg`: []byte for HS256, *rsa.PublicKey for RS256
+	// and *ecdsa.PublicKey for ES256. `kid` is empty if the token doesn't refer to a particular key.
+	Key(ctx context.Context, kid, alg string) (interface{}, error)
+}
+
+// NewSecretKeySet returns a key set with a single shared secret for HS256 tokens.
+// The current value of the `key` is used, so the tokens signed before its rotation are not valid anymore.
+func NewSecretKeySet(key *secret.Value) KeySet {
+	return secretKeySet{key: key}
+}
+
+type secretKeySet struct {
+	key *secret.Value
+}
+
+func (sks secretKeySet) Key(_ context.Context, _, alg string) (interface{}, error) {
+	if alg != AlgHS256 {
+		return nil, fmt.Errorf("%s: %w", alg, ErrUnknownKey)
+	}
+
+	key := sks.key.Get()
+	if key == "" {
+		return nil, fmt.Errorf("%s: %w", alg, ErrUnknownKey)
+	}
+	return []byte(key), nil
+}
+
+// MultiKeySet returns a key set that looks for the key in each of `sets` in order.
+func MultiKeySet(sets ...KeySet) KeySet {
+	return multiKeySet(sets)
+}
+
+type multiKeySet []KeySet
+
+func (mks multiKeySet) Key(ctx context.Context, kid, alg string) (interface{}, error) {
+	for _, set := range mks {
+		key, err := set.Key(ctx, kid, alg)
+		if errors.Is(err, ErrUnknownKey) {
+			continue
+		}
+		return key, err
+	}
+	return nil, fmt.Errorf("kid %q, alg %s: %w", kid, alg, ErrUnknownKey)
+}
+
+// JWKSSource returns content of the JSON Web Key Set document (RFC 7517).
+type JWKSSource func(ctx context.Context) ([]byte, error)
+
+// JWKSFile returns a source that reads the document from the file.
+func JWKSFile(path string) JWKSSource {
+	return func(context.Context) ([]byte, error) {
+		return ioutil.ReadFile(path)
+	}
+}
+
+// JWKSURL returns a source that downloads the document with `client`.
+// http.DefaultClient is used if `client` is nil.
+func JWKSURL(url string, client *http.Client) JWKSSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+		}
+		return ioutil.ReadAll(resp.Body)
+	}
+}
+
+// minJWKSRefreshInterval limits how often the key set is reloaded because of the unknown keys or failures,
+// so the tokens with random key IDs can't overload the source.
+const minJWKSRefreshInterval = time.Minute
+
+// NewJWKS returns a key set loaded from `source`. The keys are cached and reloaded after `refresh` interval.
+// They are also reloaded if a token refers to an unknown key, as it may be signed by a rotated key.
+func NewJWKS(source JWKSSource, refresh time.Duration) *JWKS {
+	return &JWKS{source: source, refresh: refresh, now: time.Now}
+}
+
+// JWKS is a cached JSON Web Key Set.
+type JWKS struct {
+	source  JWKSSource
+	refresh time.Duration
+	now     func() time.Time
+
+	mtx         sync.Mutex
+	keys        []jwk
+	loadedAt    time.Time
+	attemptedAt time.Time
+}
+
+// Key returns a key with `kid` suitable for `alg`.
+// If `kid` is empty the first key suitable for `alg` is returned.
+func (ks *JWKS) Key(ctx context.Context, kid, alg string) (interface{}, error) {
+	ks.mtx.Lock()
+	defer ks.mtx.Unlock()
+
+	var err error
+	stale := ks.loadedAt.IsZero() || ks.now().Sub(ks.loadedAt) >= ks.refresh
+	if stale && ks.loadable() {
+		// the stale keys are still used if the source is temporary unavailable
+		err = ks.load(ctx)
+	}
+
+	if key, ok := ks.find(kid, alg); ok {
+		return key, nil
+	}
+
+	if !stale && ks.loadable() {
+		err = ks.load(ctx)
+		if key, ok := ks.find(kid, alg); ok {
+			return key, nil
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("kid %q, alg %s: %w", kid, alg, ErrUnknownKey)
+}
+
+// loadable reports if the keys were not loaded or tried to be loaded recently.
+func (ks *JWKS) loadable() bool {
+	return ks.attemptedAt.IsZero() || ks.now().Sub(ks.attemptedAt) >= minJWKSRefreshInterval
+}
+
+func (ks *JWKS) load(ctx context.Context) error {
+	ks.attemptedAt = ks.now()
+
+	data, err := ks.source(ctx)
+	if err != nil {
+		return fmt.Errorf("load key set: %w", err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("load key set: %w", err)
+	}
+
+	ks.keys = keys
+	ks.loadedAt = ks.attemptedAt
+	return nil
+}
+
+func (ks *JWKS) find(kid, alg string) (interface{}, bool) {
+	for _, key := range ks.keys {
+		if (kid == "" || key.kid == kid) && key.suits(alg) {
+			return key.key, true
+		}
+	}
+	return nil, false
+}
+
+// jwk is a parsed JSON Web Key.
+type jwk struct {
+	kid string
+	alg string
+	key interface{}
+}
+
+// suits reports if the key could verify signatures of the algorithm.
+func (k jwk) suits(alg string) bool {
+	if k.alg != "" && k.alg != alg {
+		return false
+	}
+
+	switch k.key.(type) {
+	case []byte:
+		return alg == AlgHS256
+	case *rsa.PublicKey:
+		return alg == AlgRS256
+	case *ecdsa.PublicKey:
+		return alg == AlgES256
+	default:
+		return false
+	}
+}
+
+// parseJWKS parses the key set document. Keys not used for signatures and of unsupported types are skipped.
+func parseJWKS(data []byte) ([]jwk, error) {
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			// RSA
+			N string `json:"n"`
+			E string `json:"e"`
+			// EC
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			// symmetric
+			K string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	keys := make([]jwk, 0, len(doc.Keys))
+	for _, raw := range doc.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+
+		key := jwk{kid: raw.Kid, alg: raw.Alg}
+		switch raw.Kty {
+		case "RSA":
+			n, err := decodeBigInt(raw.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: n: %w", raw.Kid, err)
+			}
+			e, err := decodeBigInt(raw.E)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: e: %w", raw.Kid, err)
+			}
+			if !e.IsInt64() || e.Int64() > 1<<31-1 {
+				return nil, fmt.Errorf("key %q: e is too big", raw.Kid)
+			}
+			key.key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			if raw.Crv != "P-256" {
+				continue
+			}
+			x, err := decodeBigInt(raw.X)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: x: %w", raw.Kid, err)
+			}
+			y, err := decodeBigInt(raw.Y)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: y: %w", raw.Kid, err)
+			}
+			if !elliptic.P256().IsOnCurve(x, y) {
+				return nil, fmt.Errorf("key %q: point is not on the curve", raw.Kid)
+			}
+			key.key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		case "oct":
+			k, err := base64.RawURLEncoding.DecodeString(raw.K)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: k: %w", raw.Kid, err)
+			}
+			key.key = k
+		default:
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
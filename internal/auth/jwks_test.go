@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) string {
+	return fmt.Sprintf(`{"kty":"RSA","kid":%q,"use":"sig","n":%q,"e":%q}`,
+		kid, b64(key.N.Bytes()), b64(big.NewInt(int64(key.E)).Bytes()))
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	t.Run("parse", func(t *testing.T) {
+		doc := fmt.Sprintf(`{"keys":[%s,{"kty":"EC","kid":"es","crv":"P-256","x":%q,"y":%q},`+
+			`{"kty":"oct","kid":"hs","alg":"HS256","k":%q},{"kty":"RSA","kid":"enc","use":"enc"},{"kty":"OKP","kid":"ed"}]}`,
+			rsaJWK("rs", &rsaKey.PublicKey), b64(ecKey.X.Bytes()), b64(ecKey.Y.Bytes()), b64([]byte("secret")))
+
+		ks := NewJWKS(func(context.Context) ([]byte, error) { return []byte(doc), nil }, time.Hour)
+
+		key, err := ks.Key(context.Background(), "rs", AlgRS256)
+		require.NoError(t, err)
+		require.Equal(t, &rsaKey.PublicKey, key)
+
+		key, err = ks.Key(context.Background(), "es", AlgES256)
+		require.NoError(t, err)
+		require.True(t, ecKey.PublicKey.X.Cmp(key.(*ecdsa.PublicKey).X) == 0)
+
+		key, err = ks.Key(context.Background(), "", AlgHS256)
+		require.NoError(t, err)
+		require.Equal(t, []byte("secret"), key)
+
+		_, err = ks.Key(context.Background(), "rs", AlgES256)
+		require.True(t, errors.Is(err, ErrUnknownKey), "key doesn't suit the algorithm")
+		_, err = ks.Key(context.Background(), "enc", AlgRS256)
+		require.True(t, errors.Is(err, ErrUnknownKey), "encryption keys are skipped")
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		doc := fmt.Sprintf(`{"keys":[%s]}`, rsaJWK("old", &rsaKey.PublicKey))
+		var requests int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests++
+			_, _ = w.Write([]byte(doc))
+		}))
+		defer srv.Close()
+
+		now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		ks := NewJWKS(JWKSURL(srv.URL, nil), time.Hour)
+		ks.now = func() time.Time { return now }
+
+		_, err = ks.Key(context.Background(), "old", AlgRS256)
+		require.NoError(t, err)
+		_, err = ks.Key(context.Background(), "old", AlgRS256)
+		require.NoError(t, err)
+		require.Equal(t, 1, requests, "keys are cached")
+
+		doc = fmt.Sprintf(`{"keys":[%s,%s]}`, rsaJWK("old", &rsaKey.PublicKey), rsaJWK("new", &otherKey.PublicKey))
+		_, err = ks.Key(context.Background(), "new", AlgRS256)
+		require.True(t, errors.Is(err, ErrUnknownKey), "keys were reloaded recently")
+		require.Equal(t, 1, requests)
+
+		now = now.Add(minJWKSRefreshInterval)
+		key, err := ks.Key(context.Background(), "new", AlgRS256)
+		require.NoError(t, err, "keys are reloaded for unknown key")
+		require.Equal(t, &otherKey.PublicKey, key)
+		require.Equal(t, 2, requests)
+
+		doc = "unavailable"
+		now = now.Add(time.Hour)
+		_, err = ks.Key(context.Background(), "old", AlgRS256)
+		require.NoError(t, err, "stale keys are used if reload fails")
+		require.Equal(t, 3, requests)
+	})
+}
@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Supported algorithms of the token signatures.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// JWTOption allows to customize validation of the tokens.
+type JWTOption func(ja *JWTAuthenticator)
+
+// WithIssuer requires the tokens to be issued by `issuer` ('iss' claim).
+func WithIssuer(issuer string) JWTOption {
+	return func(ja *JWTAuthenticator) {
+		ja.issuer = issuer
+	}
+}
+
+// WithAudience requires the tokens to be issued for `audience` ('aud' claim).
+func WithAudience(audience string) JWTOption {
+	return func(ja *JWTAuthenticator) {
+		ja.audience = audience
+	}
+}
+
+// WithLeeway allows a clock skew between the issuer and the service while validating time claims.
+func WithLeeway(leeway time.Duration) JWTOption {
+	return func(ja *JWTAuthenticator) {
+		ja.leeway = leeway
+	}
+}
+
+// NewJWTAuthenticator returns an authenticator of requests with 'Authorization: Bearer <JWT>' header.
+// Signatures of the tokens are verified with the keys of `keys`.
+func NewJWTAuthenticator(keys KeySet, options ...JWTOption) *JWTAuthenticator {
+	ja := &JWTAuthenticator{keys: keys, leeway: 30 * time.Second, now: time.Now}
+	for _, option := range options {
+		option(ja)
+	}
+	return ja
+}
+
+// JWTAuthenticator authenticates requests with JSON Web Tokens (RFC 7519) signed with HS256, RS256 or ES256.
+// The tokens must have 'sub' and 'exp' claims, roles of the principal are taken from 'roles' claim.
+type JWTAuthenticator struct {
+	keys     KeySet
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// Authenticate verifies the bearer token of the request.
+func (ja *JWTAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	const prefix = "bearer "
+	header := r.Header.Get("authorization")
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return Principal{}, ErrNoCredentials
+	}
+
+	return ja.Verify(r.Context(), header[len(prefix):])
+}
+
+// Verify validates the token and returns the principal it is issued for.
+// An error wrapping ErrInvalidCredentials is returned if the token is not valid.
+func (ja *JWTAuthenticator) Verify(ctx context.Context, token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, fmt.Errorf("malformed token: %w", ErrInvalidCredentials)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Principal{}, fmt.Errorf("header: %v: %w", err, ErrInvalidCredentials)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, fmt.Errorf("signature: %v: %w", err, ErrInvalidCredentials)
+	}
+
+	key, err := ja.keys.Key(ctx, header.Kid, header.Alg)
+	switch {
+	case errors.Is(err, ErrUnknownKey):
+		return Principal{}, fmt.Errorf("%v: %w", err, ErrInvalidCredentials)
+	case err != nil:
+		// the token can't be verified because keys are not available, it doesn't mean the token is invalid
+		return Principal{}, err
+	}
+
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return Principal{}, fmt.Errorf("%v: %w", err, ErrInvalidCredentials)
+	}
+
+	var claims struct {
+		Subject   string   `json:"sub"`
+		Issuer    string   `json:"iss"`
+		Audience  audience `json:"aud"`
+		ExpiresAt *float64 `json:"exp"`
+		NotBefore *float64 `json:"nbf"`
+		Roles     []string `json:"roles"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, fmt.Errorf("claims: %v: %w", err, ErrInvalidCredentials)
+	}
+
+	now := ja.now()
+	switch {
+	case claims.Subject == "":
+		return Principal{}, fmt.Errorf("no subject: %w", ErrInvalidCredentials)
+	case claims.ExpiresAt == nil:
+		return Principal{}, fmt.Errorf("no expiration time: %w", ErrInvalidCredentials)
+	case !now.Before(time.Unix(int64(*claims.ExpiresAt), 0).Add(ja.leeway)):
+		return Principal{}, fmt.Errorf("token expired: %w", ErrInvalidCredentials)
+	case claims.NotBefore != nil && now.Add(ja.leeway).Before(time.Unix(int64(*claims.NotBefore), 0)):
+		return Principal{}, fmt.Errorf("token is not valid yet: %w", ErrInvalidCredentials)
+	case ja.issuer != "" && claims.Issuer != ja.issuer:
+		return Principal{}, fmt.Errorf("unexpected issuer %q: %w", claims.Issuer, ErrInvalidCredentials)
+	case ja.audience != "" && !claims.Audience.contains(ja.audience):
+		return Principal{}, fmt.Errorf("unexpected audience: %w", ErrInvalidCredentials)
+	}
+
+	return Principal{Subject: claims.Subject, Method: MethodJWT, Roles: claims.Roles}, nil
+}
+
+// verifySignature returns an error if the signature of the `signed` content is not valid.
+func verifySignature(alg string, key interface{}, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case AlgHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("%s: unexpected key type %T", alg, key)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return fmt.Errorf("%s: signature mismatch", alg)
+		}
+	case AlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s: unexpected key type %T", alg, key)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("%s: %w", alg, err)
+		}
+	case AlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s: unexpected key type %T", alg, key)
+		}
+		// the signature is a concatenation of R and S values of the same length
+		if len(signature) != 64 {
+			return fmt.Errorf("%s: invalid signature length", alg)
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return fmt.Errorf("%s: signature mismatch", alg)
+		}
+	default:
+		// 'none' and other algorithms are rejected
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, dst interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// audience is a value of 'aud' claim, it could be a single string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+// sign returns a token with the claims signed with the key.
+func sign(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case AlgRS256:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case AlgES256:
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(signature[32-len(rb):32], rb)
+		copy(signature[64-len(sb):], sb)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+type staticKeySet map[string]interface{}
+
+func (sks staticKeySet) Key(_ context.Context, kid, _ string) (interface{}, error) {
+	key, ok := sks[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	secret := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ja := NewJWTAuthenticator(
+		staticKeySet{"hs": secret, "rs": &rsaKey.PublicKey, "es": &ecKey.PublicKey},
+		WithIssuer("https://issuer"),
+		WithAudience("faceit-users"),
+		WithLeeway(time.Second),
+	)
+	ja.now = func() time.Time { return now }
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":   "user-1",
+			"iss":   "https://issuer",
+			"aud":   []string{"other", "faceit-users"},
+			"exp":   now.Add(time.Minute).Unix(),
+			"roles": []string{"admin"},
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		for _, token := range []string{
+			sign(t, AlgHS256, "hs", secret, claims(nil)),
+			sign(t, AlgRS256, "rs", rsaKey, claims(nil)),
+			sign(t, AlgES256, "es", ecKey, claims(map[string]interface{}{"aud": "faceit-users"})),
+		} {
+			req := httptest.NewRequest("GET", "/users", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			principal, err := ja.Authenticate(req)
+			require.NoError(t, err)
+			require.Equal(t, Principal{Subject: "user-1", Method: MethodJWT, Roles: []string{"admin"}}, principal)
+		}
+	})
+
+	t.Run("no credentials", func(t *testing.T) {
+		_, err := ja.Authenticate(httptest.NewRequest("GET", "/users", nil))
+		require.True(t, errors.Is(err, ErrNoCredentials))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		for name, token := range map[string]string{
+			"malformed":       "not.a.token.at.all",
+			"wrong signature": sign(t, AlgRS256, "rs", otherKey, claims(nil)),
+			"wrong key type":  sign(t, AlgHS256, "rs", secret, claims(nil)),
+			"unknown key":     sign(t, AlgHS256, "unknown", secret, claims(nil)),
+			"none algorithm":  sign(t, "none", "hs", nil, claims(nil)),
+			"expired":         sign(t, AlgHS256, "hs", secret, claims(map[string]interface{}{"exp": now.Add(-time.Second).Unix()})),
+			"no expiration":   sign(t, AlgHS256, "hs", secret, claims(map[string]interface{}{"exp": nil})),
+			"not yet valid":   sign(t, AlgHS256, "hs", secret, claims(map[string]interface{}{"nbf": now.Add(time.Minute).Unix()})),
+			"no subject":      sign(t, AlgHS256, "hs", secret, claims(map[string]interface{}{"sub": nil})),
+			"wrong issuer":    sign(t, AlgHS256, "hs", secret, claims(map[string]interface{}{"iss": "https://other"})),
+			"wrong audience":  sign(t, AlgHS256, "hs", secret, claims(map[string]interface{}{"aud": "other"})),
+		} {
+			_, err := ja.Verify(context.Background(), token)
+			require.True(t, errors.Is(err, ErrInvalidCredentials), name)
+		}
+	})
+
+	t.Run("keys unavailable", func(t *testing.T) {
+		unavailable := errors.New("unavailable")
+		ja := NewJWTAuthenticator(NewJWKS(func(context.Context) ([]byte, error) { return nil, unavailable }, time.Hour))
+
+		_, err := ja.Verify(context.Background(), sign(t, AlgHS256, "hs", secret, claims(nil)))
+		require.True(t, errors.Is(err, unavailable))
+		require.False(t, errors.Is(err, ErrInvalidCredentials))
+	})
+}
+
+func TestChain(t *testing.T) {
+	apiKey, err := ParseAPIKey("service:key")
+	require.NoError(t, err)
+	authenticator := Chain(NewJWTAuthenticator(NewSecretKeySet(secret.NewValue("secret", nil))), NewAPIKeyAuthenticator([]APIKey{apiKey}))
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	_, err = authenticator.Authenticate(req)
+	require.True(t, errors.Is(err, ErrNoCredentials))
+
+	req.Header.Set(APIKeyHeader, "key")
+	principal, err := authenticator.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, Principal{Subject: "service", Method: MethodAPIKey}, principal)
+
+	req.Header.Set("Authorization", "Bearer invalid")
+	_, err = authenticator.Authenticate(req)
+	require.True(t, errors.Is(err, ErrInvalidCredentials), "invalid token is not ignored")
+}
+
+func TestHS256Signer(t *testing.T) {
+	current := "secret"
+	key := secret.NewValue(current, func(context.Context) (string, error) { return current, nil })
+	signer, authenticator := NewHS256Signer(key), NewJWTAuthenticator(NewSecretKeySet(key))
+	claims := map[string]interface{}{
+		"sub":   "user-1",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"roles": []string{"admin"},
+	}
+
+	token, err := signer.Sign(claims)
+	require.NoError(t, err)
+
+	principal, err := authenticator.Verify(context.Background(), token)
+	require.NoError(t, err)
+	require.Equal(t, Principal{Subject: "user-1", Method: MethodJWT, Roles: []string{"admin"}}, principal)
+
+	_, err = NewJWTAuthenticator(NewSecretKeySet(secret.NewValue("other", nil))).Verify(context.Background(), token)
+	require.True(t, errors.Is(err, ErrInvalidCredentials))
+
+	current = "rotated"
+	_, err = key.Refresh(context.Background())
+	require.NoError(t, err)
+	_, err = authenticator.Verify(context.Background(), token)
+	require.True(t, errors.Is(err, ErrInvalidCredentials), "signed with the previous secret")
+
+	token, err = signer.Sign(claims)
+	require.NoError(t, err)
+	_, err = authenticator.Verify(context.Background(), token)
+	require.NoError(t, err, "signed with the rotated secret")
+}
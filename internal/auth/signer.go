@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+// NewHS256Signer returns a signer of the tokens with HS256 algorithm.
+// The tokens could be verified by JWTAuthenticator with NewSecretKeySet of the same `key`.
+// The tokens are signed with the current value of the `key`, so it could be rotated.
+func NewHS256Signer(key *secret.Value) *HS256Signer {
+	return &HS256Signer{key: key}
+}
+
+// HS256Signer issues JSON Web Tokens signed with a shared secret.
+type HS256Signer struct {
+	key *secret.Value
+}
+
+// Sign returns a token with the claims.
+func (s *HS256Signer) Sign(claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": AlgHS256, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(s.key.Get()))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	lru := NewLRU(2, time.Minute)
+	lru.now = func() time.Time { return now }
+
+	require.True(t, lru.BeginLoad("a").Add(1))
+	require.True(t, lru.BeginLoad("b").Add(2))
+
+	v, ok := lru.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+
+	require.True(t, lru.BeginLoad("c").Add(3))
+	_, ok = lru.Get("b")
+	require.False(t, ok, "the least recently used entry is evicted")
+	require.Equal(t, 2, lru.Len())
+	require.Equal(t, uint64(1), lru.Evictions())
+
+	load := lru.BeginLoad("c")
+	lru.Remove("c")
+	_, ok = lru.Get("c")
+	require.False(t, ok)
+	require.False(t, load.Add(3), "value loaded before removal is not added")
+
+	now = now.Add(time.Minute)
+	_, ok = lru.Get("a")
+	require.False(t, ok, "entry is expired")
+	require.Zero(t, lru.Len())
+}
+
+func TestLRU_BeginLoad(t *testing.T) {
+	lru := NewLRU(10, time.Minute)
+
+	load := lru.BeginLoad("a")
+	lru.Remove("b")
+	lru.RemoveIf("c", func(interface{}) bool { return true })
+	require.True(t, load.Add(1), "removal of other keys doesn't affect the load")
+
+	before := lru.BeginLoad("a")
+	lru.RemoveIf("a", func(interface{}) bool { return false })
+	after := lru.BeginLoad("a")
+	require.False(t, before.Add(1), "value loaded concurrently with the removal is not added regardless of the predicate")
+	require.True(t, after.Add(2), "load began after the removal is not affected")
+
+	load = lru.BeginLoad("b")
+	lru.Purge()
+	require.False(t, load.Add(1), "value loaded before the purge is not added")
+
+	load = lru.BeginLoad("b")
+	load.Abort()
+	require.False(t, load.Add(1), "ended load adds nothing")
+	require.Empty(t, lru.loads, "ended loads are not tracked")
+}
+
+func TestGroup_Do(t *testing.T) {
+	var g Group
+	var calls int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, _ = g.Do("key", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return "value", nil
+			})
+		}(i)
+	}
+
+	// all callers are waiting for the first one
+	require.Eventually(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.calls["key"] != nil
+	}, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, result := range results {
+		require.Equal(t, "value", result)
+	}
+
+	_, _, shared := g.Do("key", func() (interface{}, error) { return nil, nil })
+	require.False(t, shared, "completed calls are not shared")
+}
@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// NewLRU returns a cache of at most `size` entries, each of them expires in `ttl` after it was added.
+func NewLRU(size int, ttl time.Duration) *LRU {
+	return &LRU{
+		size:    size,
+		ttl:     ttl,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+		loads:   map[string]*loads{},
+		now:     time.Now,
+	}
+}
+
+// LRU is a size-bounded cache that evicts the least recently used entries.
+// It is safe for concurrent use.
+type LRU struct {
+	mu        sync.Mutex
+	size      int
+	ttl       time.Duration
+	entries   map[string]*list.Element
+	order     *list.List // the most recently used entries are at the front
+	evictions uint64
+	// loads are the keys being loaded, their generation is increased on each removal of the key,
+	// so the values loaded before it are not added
+	loads map[string]*loads
+	// purges is a number of purges, none of the values loaded before the purge is added
+	purges uint64
+	now    func() time.Time
+}
+
+type loads struct {
+	generation uint64
+	pending    int
+}
+
+type entry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// Get returns a value of the key if it is cached and not expired yet.
+func (c *LRU) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if !c.now().Before(e.expiresAt) {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return e.value, true
+}
+
+// Load is a value of the key being loaded, see LRU.BeginLoad.
+type Load struct {
+	cache      *LRU
+	key        string
+	loads      *loads
+	generation uint64
+	purges     uint64
+	done       bool
+}
+
+// BeginLoad must be called before the value of the key is loaded. The loaded value is added with Load.Add,
+// the load that failed must be ended with Load.Abort.
+func (c *LRU) BeginLoad(key string) *Load {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.loads[key]
+	if !ok {
+		l = &loads{}
+		c.loads[key] = l
+	}
+	l.pending++
+	return &Load{cache: c, key: key, loads: l, generation: l.generation, purges: c.purges}
+}
+
+// Add ends the load and adds the value to the cache if the key wasn't removed and the cache wasn't purged
+// since the load began, so the value loaded concurrently with its invalidation is not cached.
+// It returns true if the value is added.
+func (l *Load) Add(value interface{}) bool {
+	c := l.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l.done {
+		return false
+	}
+	l.end()
+
+	if l.loads.generation != l.generation || c.purges != l.purges {
+		return false
+	}
+	c.add(l.key, value)
+	return true
+}
+
+// Abort ends the load without adding a value, it does nothing if the load is already ended.
+func (l *Load) Abort() {
+	l.cache.mu.Lock()
+	defer l.cache.mu.Unlock()
+
+	if !l.done {
+		l.end()
+	}
+}
+
+func (l *Load) end() {
+	l.done = true
+	if l.loads.pending--; l.loads.pending == 0 {
+		delete(l.cache.loads, l.key)
+	}
+}
+
+func (c *LRU) add(key string, value interface{}) {
+	if elem, ok := c.entries[key]; ok {
+		e := elem.Value.(*entry)
+		e.value, e.expiresAt = value, c.now().Add(c.ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{key: key, value: value, expiresAt: c.now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// Remove removes the key from the cache.
+func (c *LRU) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l, ok := c.loads[key]; ok {
+		l.generation++
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// RemoveIf removes the key from the cache if its value satisfies the `stale` predicate.
+// Values of the key loaded concurrently are not added regardless of the predicate.
+func (c *LRU) RemoveIf(key string, stale func(value interface{}) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l, ok := c.loads[key]; ok {
+		l.generation++
+	}
+	if elem, ok := c.entries[key]; ok && stale(elem.Value.(*entry).value) {
+		c.remove(elem)
+	}
+}
+
+// Purge removes all entries from the cache.
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purges++
+	c.entries = map[string]*list.Element{}
+	c.order.Init()
+}
+
+// Len returns a number of the cached entries including the expired ones.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// Evictions returns a number of entries removed to not exceed the size of the cache.
+func (c *LRU) Evictions() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.evictions
+}
+
+func (c *LRU) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).key)
+}
@@ -0,0 +1,142 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// Codec encodes and decodes payloads of a particular media type.
+type Codec interface {
+	// MediaTypes returns media types handled by the codec, the first one is canonical.
+	MediaTypes() []string
+	// ContentType returns a value for the 'content-type' header of the encoded payload.
+	ContentType() string
+	// Encode writes `src` encoded into the `w`.
+	Encode(w io.Writer, src interface{}) error
+	// Decode reads payload from `r` and decodes it into `dst`.
+	Decode(r io.Reader, dst interface{}) error
+}
+
+// Default is a registry of all supported codecs, JSON is a default one.
+var Default = NewRegistry(JSON{}, MessagePack{}, Protobuf{}, XML{})
+
+// NewRegistry returns registry of the provided codecs.
+// The first codec is a default one and is used when client has no preferences.
+func NewRegistry(codecs ...Codec) *Registry {
+	r := &Registry{byMediaType: map[string]Codec{}}
+	for _, c := range codecs {
+		r.codecs = append(r.codecs, c)
+		for _, mediaType := range c.MediaTypes() {
+			r.byMediaType[strings.ToLower(mediaType)] = c
+		}
+	}
+	return r
+}
+
+// Registry is a set of codecs keyed by media type.
+type Registry struct {
+	codecs      []Codec
+	byMediaType map[string]Codec
+}
+
+// Default returns a codec used when client has no preferences.
+func (r *Registry) Default() Codec {
+	return r.codecs[0]
+}
+
+// Lookup returns a codec for the media type (parameters are ignored).
+func (r *Registry) Lookup(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	c, ok := r.byMediaType[mediaType]
+	return c, ok
+}
+
+// MediaTypes returns all media types the registry has codecs for.
+// Canonical media types of codecs go first in order of codecs registration.
+func (r *Registry) MediaTypes() []string {
+	var canonical, aliases []string
+	for _, c := range r.codecs {
+		mediaTypes := c.MediaTypes()
+		canonical = append(canonical, mediaTypes[0])
+		aliases = append(aliases, mediaTypes[1:]...)
+	}
+	return append(canonical, aliases...)
+}
+
+// ContentTypes returns values of the 'content-type' header supported by the registry:
+// media types of all codecs along with parameters of codecs content types.
+func (r *Registry) ContentTypes() []string {
+	var contentTypes []string
+	for _, c := range r.codecs {
+		_, params, err := mime.ParseMediaType(c.ContentType())
+		if err != nil {
+			// this is fair enough as it will blow up at startup time
+			panic(err)
+		}
+
+		for _, mediaType := range c.MediaTypes() {
+			contentTypes = append(contentTypes, mime.FormatMediaType(mediaType, params))
+		}
+	}
+	return contentTypes
+}
+
+// toGeneric converts value into a tree of generic values: maps, slices, strings, numbers, booleans and nils.
+// The conversion is made through JSON representation, so names of the properties are defined by 'json' tags.
+func toGeneric(src interface{}) (interface{}, error) {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+// fromGeneric converts tree of generic values into `dst` through JSON representation.
+func fromGeneric(generic interface{}, dst interface{}) error {
+	data, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// JSON encodes and decodes payloads in JSON format.
+type JSON struct{}
+
+func (JSON) MediaTypes() []string {
+	return []string{"application/json"}
+}
+
+func (JSON) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (JSON) Encode(w io.Writer, src interface{}) error {
+	if err := json.NewEncoder(w).Encode(src); err != nil {
+		return fmt.Errorf("encode %T: %w", src, err)
+	}
+	return nil
+}
+
+func (JSON) Decode(r io.Reader, dst interface{}) error {
+	if err := json.NewDecoder(r).Decode(dst); err != nil {
+		return fmt.Errorf("decode into %T: %w", dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package codec
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pavelmemory/faceit-users/internal/webhttp/pb"
+)
+
+type testPayload struct {
+	Name    string                 `json:"name"`
+	Count   int                    `json:"count"`
+	Ratio   float64                `json:"ratio"`
+	Enabled bool                   `json:"enabled"`
+	Tags    []string               `json:"tags"`
+	Nested  *testPayload           `json:"nested"`
+	Details map[string]interface{} `json:"details"`
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	src := testPayload{
+		Name:    "name",
+		Count:   -300,
+		Ratio:   0.5,
+		Enabled: true,
+		Tags:    []string{"a", "b"},
+		Nested:  &testPayload{Name: "nested", Count: 70000},
+		Details: map[string]interface{}{"FirstName": "blank or empty"},
+	}
+
+	for _, c := range []Codec{JSON{}, MessagePack{}, Protobuf{}} {
+		t.Run(c.MediaTypes()[0], func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, c.Encode(&buf, src))
+
+			var dst testPayload
+			require.NoError(t, c.Decode(&buf, &dst))
+			require.Equal(t, src, dst)
+		})
+	}
+
+	t.Run("application/xml", func(t *testing.T) {
+		type strings struct {
+			Name  string   `json:"name"`
+			Tags  []string `json:"tags"`
+			Empty *string  `json:"empty"`
+		}
+		src := strings{Name: "<name>", Tags: []string{"a", "b"}}
+
+		var buf bytes.Buffer
+		require.NoError(t, XML{}.Encode(&buf, src))
+		require.Contains(t, buf.String(), `<response><empty nil="true"></empty><name>&lt;name&gt;</name><tags><item>a</item><item>b</item></tags></response>`)
+
+		var dst strings
+		require.NoError(t, XML{}.Decode(&buf, &dst))
+		require.Equal(t, src, dst)
+	})
+}
+
+func TestMessagePack(t *testing.T) {
+	t.Run("int64", func(t *testing.T) {
+		type ints struct {
+			Max int64 `json:"max"`
+			Min int64 `json:"min"`
+		}
+		src := ints{Max: math.MaxInt64, Min: math.MinInt64}
+
+		var buf bytes.Buffer
+		require.NoError(t, MessagePack{}.Encode(&buf, src))
+
+		var dst ints
+		require.NoError(t, MessagePack{}.Decode(&buf, &dst))
+		require.Equal(t, src, dst, "no precision is lost")
+	})
+
+	t.Run("nesting", func(t *testing.T) {
+		payload := append(bytes.Repeat([]byte{0x91}, maxNestingDepth+1), 0xc0)
+		var dst interface{}
+		require.Error(t, MessagePack{}.Decode(bytes.NewReader(payload), &dst))
+	})
+
+	t.Run("forged length", func(t *testing.T) {
+		var dst interface{}
+		require.Error(t, MessagePack{}.Decode(bytes.NewReader([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}), &dst))
+	})
+}
+
+// testImport is a payload that has a protobuf message defined for it.
+type testImport struct {
+	Imported   int64           `json:"imported"`
+	Rejected   int64           `json:"rejected"`
+	Rejections []testRejection `json:"rejections,omitempty"`
+}
+
+type testRejection struct {
+	Line    int                    `json:"line"`
+	Status  int                    `json:"status"`
+	Error   string                 `json:"error"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (testImport) NewProtoMessage() proto.Message {
+	return &pb.ImportResponse{}
+}
+
+func TestProtobuf(t *testing.T) {
+	t.Run("message", func(t *testing.T) {
+		src := testImport{
+			Imported: math.MaxInt64,
+			Rejected: 1,
+			Rejections: []testRejection{
+				{Line: 2, Status: 400, Error: "invalid", Details: map[string]interface{}{"email": "invalid format"}},
+			},
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, Protobuf{}.Encode(&buf, src))
+
+		var msg pb.ImportResponse
+		require.NoError(t, proto.Unmarshal(buf.Bytes(), &msg), "payload is encoded as the message")
+		require.Equal(t, int64(math.MaxInt64), msg.Imported)
+		require.Len(t, msg.Rejections, 1)
+		require.Equal(t, int32(2), msg.Rejections[0].Line)
+		require.Equal(t, "invalid format", msg.Rejections[0].Details.Fields["email"].GetStringValue())
+
+		var dst testImport
+		require.NoError(t, Protobuf{}.Decode(&buf, &dst))
+		require.Equal(t, src, dst, "no precision is lost")
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		type unknown struct {
+			testImport
+			Extra string `json:"extra"`
+		}
+		require.Error(t, Protobuf{}.Encode(ioutil.Discard, unknown{Extra: "extra"}), "payload doesn't match the message")
+	})
+
+	t.Run("too big", func(t *testing.T) {
+		var dst interface{}
+		payload := bytes.NewReader(make([]byte, maxProtobufSize+1))
+		require.Error(t, Protobuf{}.Decode(payload, &dst))
+	})
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	c, ok := Default.Lookup("application/x-msgpack")
+	require.True(t, ok)
+	require.Equal(t, MessagePack{}, c)
+
+	c, ok = Default.Lookup("application/protobuf")
+	require.True(t, ok)
+	require.Equal(t, Protobuf{}, c)
+
+	_, ok = Default.Lookup("text/html")
+	require.False(t, ok)
+
+	require.Equal(t, JSON{}, Default.Default())
+}
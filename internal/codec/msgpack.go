@@ -0,0 +1,166 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// maxMessagePackLen limits length of arrays and maps of the decoded payload.
+const maxMessagePackLen = 1 << 24
+
+// maxNestingDepth limits depth of the nested arrays and maps of the decoded payload.
+const maxNestingDepth = 64
+
+// MessagePack encodes and decodes payloads in MessagePack format (https://msgpack.org).
+// Properties of the structs are encoded as maps keyed by names defined with 'json' tags.
+type MessagePack struct{}
+
+func (MessagePack) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack"}
+}
+
+func (MessagePack) ContentType() string {
+	return "application/msgpack"
+}
+
+func (MessagePack) Encode(w io.Writer, src interface{}) error {
+	generic, err := toGeneric(src)
+	if err != nil {
+		return fmt.Errorf("encode %T: %w", src, err)
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeMessagePack(msgpack.NewEncoder(bw), generic); err != nil {
+		return fmt.Errorf("encode %T: %w", src, err)
+	}
+	return bw.Flush()
+}
+
+func (MessagePack) Decode(r io.Reader, dst interface{}) error {
+	generic, err := readMessagePack(msgpack.NewDecoder(bufio.NewReader(r)), 0)
+	if err != nil {
+		return fmt.Errorf("decode into %T: %w", dst, err)
+	}
+
+	if err := fromGeneric(generic, dst); err != nil {
+		return fmt.Errorf("decode into %T: %w", dst, err)
+	}
+	return nil
+}
+
+// writeMessagePack encodes the generic value, the numbers are encoded as integers if they have no fraction.
+func writeMessagePack(e *msgpack.Encoder, v interface{}) error {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return e.EncodeInt(i)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		return e.EncodeFloat64(f)
+	case []interface{}:
+		if err := e.EncodeArrayLen(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := writeMessagePack(e, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		if err := e.EncodeMapLen(len(v)); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := e.EncodeString(key); err != nil {
+				return err
+			}
+			if err := writeMessagePack(e, v[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return e.Encode(v)
+	}
+}
+
+// readMessagePack decodes a generic value. Arrays and maps are decoded here to limit their nesting,
+// the maps must be keyed by strings.
+func readMessagePack(d *msgpack.Decoder, depth int) (interface{}, error) {
+	if depth > maxNestingDepth {
+		return nil, errors.New("max nesting depth exceeded")
+	}
+
+	c, err := d.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case msgpcode.IsFixedArray(c), c == msgpcode.Array16, c == msgpcode.Array32:
+		n, err := d.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+		if n > maxMessagePackLen {
+			return nil, fmt.Errorf("array length %d exceeds the limit", n)
+		}
+
+		list := make([]interface{}, 0, preallocated(n))
+		for i := 0; i < n; i++ {
+			item, err := readMessagePack(d, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case msgpcode.IsFixedMap(c), c == msgpcode.Map16, c == msgpcode.Map32:
+		n, err := d.DecodeMapLen()
+		if err != nil {
+			return nil, err
+		}
+		if n > maxMessagePackLen {
+			return nil, fmt.Errorf("map length %d exceeds the limit", n)
+		}
+
+		m := make(map[string]interface{}, preallocated(n))
+		for i := 0; i < n; i++ {
+			key, err := d.DecodeString()
+			if err != nil {
+				return nil, fmt.Errorf("map key: %w", err)
+			}
+			if m[key], err = readMessagePack(d, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	default:
+		return d.DecodeInterfaceLoose()
+	}
+}
+
+// preallocated returns a capacity for `n` elements, it is limited as the length could be forged.
+func preallocated(n int) int {
+	if n > 1024 {
+		return 1024
+	}
+	return n
+}
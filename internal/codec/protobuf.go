@@ -0,0 +1,147 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// maxProtobufSize limits the size of the decoded payload.
+const maxProtobufSize = 16 << 20
+
+// ProtoMapped is implemented by the payloads that have a protobuf message defined for them.
+type ProtoMapped interface {
+	// NewProtoMessage returns an empty message the payload is encoded as.
+	NewProtoMessage() proto.Message
+}
+
+// Protobuf encodes and decodes payloads in Protocol Buffers format (https://developers.google.com/protocol-buffers).
+// Payloads that implement ProtoMapped are encoded as their messages, all others as `google.protobuf.Value` messages
+// (https://github.com/protocolbuffers/protobuf/blob/master/src/google/protobuf/struct.proto).
+// The payload is converted to and from its message through JSON representation, so fields of the message
+// must be named as defined with 'json' tags.
+type Protobuf struct{}
+
+func (Protobuf) MediaTypes() []string {
+	return []string{"application/x-protobuf", "application/protobuf"}
+}
+
+func (Protobuf) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (Protobuf) Encode(w io.Writer, src interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return fmt.Errorf("encode %T: %w", src, err)
+	}
+
+	msg := protoMessage(src)
+	if err := protojson.Unmarshal(data, msg); err != nil {
+		return fmt.Errorf("encode %T: %w", src, err)
+	}
+
+	data, err = proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encode %T: %w", src, err)
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func (Protobuf) Decode(r io.Reader, dst interface{}) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxProtobufSize+1))
+	if err != nil {
+		return fmt.Errorf("decode into %T: %w", dst, err)
+	}
+
+	if len(data) > maxProtobufSize {
+		return fmt.Errorf("decode into %T: payload is too big", dst)
+	}
+
+	msg := protoMessage(dst)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return fmt.Errorf("decode into %T: %w", dst, err)
+	}
+
+	data, err = protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("decode into %T: %w", dst, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return fmt.Errorf("decode into %T: %w", dst, err)
+	}
+
+	jsonNumbers(msg.ProtoReflect().Descriptor(), generic)
+	if err := fromGeneric(generic, dst); err != nil {
+		return fmt.Errorf("decode into %T: %w", dst, err)
+	}
+	return nil
+}
+
+// protoMessage returns an empty message the payload is encoded as.
+func protoMessage(v interface{}) proto.Message {
+	if mapped, ok := v.(ProtoMapped); ok {
+		return mapped.NewProtoMessage()
+	}
+	return &structpb.Value{}
+}
+
+// jsonNumbers replaces 64-bit integers of the message `md` in its JSON representation `v` with numbers,
+// as protojson encodes them as strings and they couldn't be decoded into Go integers.
+func jsonNumbers(md protoreflect.MessageDescriptor, v interface{}) {
+	obj, ok := v.(map[string]interface{})
+	// well-known types have their own JSON representation
+	if !ok || md.ParentFile().Package() == "google.protobuf" {
+		return
+	}
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		name := string(fd.Name())
+		switch value := obj[name].(type) {
+		case map[string]interface{}:
+			if !fd.IsMap() {
+				jsonNumbers(fd.Message(), value)
+				continue
+			}
+			for key, entry := range value {
+				value[key] = jsonNumber(fd.MapValue(), entry)
+			}
+		case []interface{}:
+			for j, item := range value {
+				value[j] = jsonNumber(fd, item)
+			}
+		case string:
+			obj[name] = jsonNumber(fd, value)
+		}
+	}
+}
+
+// jsonNumber returns the number if the field is a 64-bit integer.
+func jsonNumber(fd protoreflect.FieldDescriptor, v interface{}) interface{} {
+	switch fd.Kind() {
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if s, ok := v.(string); ok {
+			return json.Number(s)
+		}
+	case protoreflect.MessageKind:
+		jsonNumbers(fd.Message(), v)
+	}
+	return v
+}
@@ -0,0 +1,211 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	xmlRootElement = "response"
+	xmlListItem    = "item"
+	xmlEntry       = "entry"
+)
+
+// XML encodes and decodes payloads in XML format.
+// Properties of the structs are encoded as elements named as defined with 'json' tags,
+// elements of the lists are encoded as `item` elements and `null` values are marked with `nil="true"` attribute.
+// As XML has no types all scalar values are decoded as strings.
+type XML struct{}
+
+func (XML) MediaTypes() []string {
+	return []string{"application/xml", "text/xml"}
+}
+
+func (XML) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+func (XML) Encode(w io.Writer, src interface{}) error {
+	generic, err := toGeneric(src)
+	if err != nil {
+		return fmt.Errorf("encode %T: %w", src, err)
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	encoder := xml.NewEncoder(bw)
+	if err := writeXML(encoder, xml.StartElement{Name: xml.Name{Local: xmlRootElement}}, generic); err != nil {
+		return fmt.Errorf("encode %T: %w", src, err)
+	}
+
+	if err := encoder.Flush(); err != nil {
+		return fmt.Errorf("encode %T: %w", src, err)
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+func (XML) Decode(r io.Reader, dst interface{}) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("decode into %T: %w", dst, err)
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			generic, err := readXML(decoder, start, 0)
+			if err != nil {
+				return fmt.Errorf("decode into %T: %w", dst, err)
+			}
+
+			if err := fromGeneric(generic, dst); err != nil {
+				return fmt.Errorf("decode into %T: %w", dst, err)
+			}
+			return nil
+		}
+	}
+}
+
+func writeXML(encoder *xml.Encoder, start xml.StartElement, v interface{}) error {
+	if v == nil {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "nil"}, Value: "true"})
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		return encoder.EncodeToken(start.End())
+	}
+
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case bool, json.Number, string:
+		if err := encoder.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := writeXML(encoder, xml.StartElement{Name: xml.Name{Local: xmlListItem}}, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			child := xml.StartElement{Name: xml.Name{Local: key}}
+			if !isXMLName(key) || key == xmlListItem {
+				// keys that can't be used as element names are kept as attributes
+				child = xml.StartElement{
+					Name: xml.Name{Local: xmlEntry},
+					Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+				}
+			}
+
+			if err := writeXML(encoder, child, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+
+	return encoder.EncodeToken(start.End())
+}
+
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r == '-' || r == '.' || r >= '0' && r <= '9'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// readXML converts element into a generic value: an element with `nil="true"` attribute is a nil,
+// an element without child elements is a string, an element with `item` child elements only is a list
+// and the rest are maps keyed by names of the child elements.
+func readXML(decoder *xml.Decoder, start xml.StartElement, depth int) (interface{}, error) {
+	if depth > maxNestingDepth {
+		return nil, errors.New("max nesting depth exceeded")
+	}
+
+	isNil := false
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "nil" && attr.Value == "true" {
+			isNil = true
+		}
+	}
+
+	var text strings.Builder
+	var names []string
+	var values []interface{}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.CharData:
+			text.Write(token)
+		case xml.StartElement:
+			value, err := readXML(decoder, token, depth+1)
+			if err != nil {
+				return nil, err
+			}
+
+			name := token.Name.Local
+			if name == xmlEntry {
+				for _, attr := range token.Attr {
+					if attr.Name.Local == "key" {
+						name = attr.Value
+					}
+				}
+			}
+
+			names = append(names, name)
+			values = append(values, value)
+		case xml.EndElement:
+			switch {
+			case isNil:
+				return nil, nil
+			case len(names) == 0:
+				return text.String(), nil
+			}
+
+			list := true
+			for _, name := range names {
+				list = list && name == xmlListItem
+			}
+			if list {
+				return values, nil
+			}
+
+			m := make(map[string]interface{}, len(names))
+			for i, name := range names {
+				m[name] = values[i]
+			}
+			return m, nil
+		}
+	}
+}
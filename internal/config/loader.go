@@ -0,0 +1,335 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+// FileSetting is a name of the setting with a path to the YAML file of the settings.
+const FileSetting = "CONFIG_FILE"
+
+// NewLoader returns a loader of the settings that registers a flag for each of them in `flags`.
+// `prefix` allows to add an extra prefix that needs to be used with all env var names.
+func NewLoader(prefix string, flags *flag.FlagSet, options ...LoaderOption) *Loader {
+	l := &Loader{prefix: prefix, flags: map[string]*flagValue{}}
+	for _, option := range options {
+		option(l)
+	}
+	for _, name := range append([]string{FileSetting}, names()...) {
+		value := &flagValue{}
+		l.flags[name] = value
+		flags.Var(value, flagName(name), "overrides "+name+" setting")
+	}
+	return l
+}
+
+// Loader merges settings from the sources in the order of precedence, each next source overrides the previous ones:
+//   - defaults from the `default` tags of the Settings;
+//   - YAML file set with CONFIG_FILE, the keys are lower-cased names of the settings, e.g. 'http_port: 8080';
+//   - environment variables named as the settings, e.g. 'HTTP_PORT=8080' or '<prefix>_HTTP_PORT=8080';
+//   - command line flags, the names of the settings are lower-cased and dashed, e.g. '--http-port=8080'.
+//
+// Secret settings could be set to references resolved once all sources are merged: 'file://<path>' is replaced
+// with the content of the file and 'secret://<name>' with the secret of the provider (files of SECRETS_DIR by default).
+// Environment variable of the secret setting with '_FILE' suffix is a shortcut for the file reference,
+// e.g. 'STORAGE_PWD_FILE=/run/secrets/db-password'.
+type Loader struct {
+	prefix   string
+	flags    map[string]*flagValue
+	provider secret.Provider
+}
+
+// LoaderOption allows to customize behaviour of the Loader.
+type LoaderOption func(l *Loader)
+
+// WithSecretProvider sets a provider of the secrets referred as 'secret://<name>'.
+func WithSecretProvider(provider secret.Provider) LoaderOption {
+	return func(l *Loader) {
+		l.provider = provider
+	}
+}
+
+// Load returns validated settings. All malformed and invalid values are reported at once with Errors.
+func (l *Loader) Load() (Settings, error) {
+	var s Settings
+	var errs Errors
+
+	fields := settingFields(&s)
+	for _, f := range fields {
+		if def, ok := f.field.Tag.Lookup("default"); ok {
+			errs = errs.add(f.set(def), "default of "+f.name)
+		}
+	}
+
+	if path, ok := l.lookup(FileSetting); ok && path != "" {
+		errs = append(errs, l.loadFile(path, fields)...)
+	}
+
+	for _, f := range fields {
+		value, ok := l.env(f.name)
+		if path, fromFile := l.env(f.name + "_FILE"); fromFile && f.secret() {
+			if ok {
+				errs = append(errs, fmt.Errorf("env %s: must not be set together with %s_FILE", l.envName(f.name), l.envName(f.name)))
+				continue
+			}
+			value, ok = secret.FileScheme+path, true
+		}
+		if ok {
+			errs = errs.add(f.set(value), "env "+l.envName(f.name))
+		}
+	}
+
+	for _, f := range fields {
+		if value := l.flags[f.name]; value != nil && value.set {
+			errs = errs.add(f.set(value.value), "flag --"+flagName(f.name))
+		}
+	}
+
+	errs = append(errs, l.resolveSecrets(&s, fields)...)
+
+	// settings that failed to parse keep the values of the previous sources, so the rest are still validated
+	if err := s.Validate(); err != nil {
+		errs = append(errs, err.(Errors)...)
+	}
+
+	if len(errs) > 0 {
+		return Settings{}, errs
+	}
+	return s, nil
+}
+
+// File returns a path to the config file, it is empty if the file is not used.
+func (l *Loader) File() string {
+	path, _ := l.lookup(FileSetting)
+	return path
+}
+
+// resolveSecrets replaces references of the secret settings with the secrets and keeps the references to re-read them.
+func (l *Loader) resolveSecrets(s *Settings, fields []settingField) Errors {
+	s.secretProvider = l.provider
+	if s.secretProvider == nil {
+		s.secretProvider = secret.NewDirProvider(s.EnvSecretsDir)
+	}
+
+	var errs Errors
+	for _, f := range fields {
+		if !f.secret() || f.value.Kind() != reflect.String || !secret.IsReference(f.value.String()) {
+			continue
+		}
+
+		ref := f.value.String()
+		value, err := secret.Resolve(context.Background(), ref, s.secretProvider)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+			continue
+		}
+
+		if s.secretRefs == nil {
+			s.secretRefs = map[string]string{}
+		}
+		s.secretRefs[f.name] = ref
+		f.value.SetString(value)
+	}
+	return errs
+}
+
+// lookup returns a value of the setting from the flags or environment variables.
+func (l *Loader) lookup(name string) (string, bool) {
+	if value := l.flags[name]; value != nil && value.set {
+		return value.value, true
+	}
+	return l.env(name)
+}
+
+// env returns a value of the environment variable of the setting. The variable without prefix
+// is used if the prefixed one is not set.
+func (l *Loader) env(name string) (string, bool) {
+	if value, ok := os.LookupEnv(l.envName(name)); ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+func (l *Loader) envName(name string) string {
+	if l.prefix == "" {
+		return name
+	}
+	return strings.ToUpper(l.prefix + "_" + name)
+}
+
+func (l *Loader) loadFile(path string, fields []settingField) Errors {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Errors{fmt.Errorf("read config file: %w", err)}
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return Errors{fmt.Errorf("parse config file %s: %w", path, err)}
+	}
+
+	byKey := make(map[string]settingField, len(fields))
+	for _, f := range fields {
+		byKey[strings.ToLower(f.name)] = f
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs Errors
+	for _, key := range keys {
+		f, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %q", path, key))
+			continue
+		}
+
+		source := "config file " + path + ": " + key
+		switch value := values[key].(type) {
+		case nil:
+		case []interface{}:
+			if f.value.Kind() != reflect.Slice {
+				errs = append(errs, fmt.Errorf("%s: list is not expected", source))
+				continue
+			}
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			f.value.Set(reflect.ValueOf(items))
+		case map[interface{}]interface{}:
+			errs = append(errs, fmt.Errorf("%s: mapping is not expected", source))
+		default:
+			errs = errs.add(f.set(fmt.Sprint(value)), source)
+		}
+	}
+	return errs
+}
+
+// Errors are all errors found while settings are loaded.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e Errors) add(err error, source string) Errors {
+	if err == nil {
+		return e
+	}
+	return append(e, fmt.Errorf("%s: %w", source, err))
+}
+
+// settingField is a field of the Settings bound to the name of the setting.
+type settingField struct {
+	name  string
+	field reflect.StructField
+	value reflect.Value
+}
+
+// secret returns true if the value of the setting must not be exposed.
+func (f settingField) secret() bool {
+	return f.field.Tag.Get("secret") == "true"
+}
+
+// set parses `raw` according to the type of the field and assigns it.
+func (f settingField) set(raw string) error {
+	switch {
+	case f.value.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.Int:
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("not an integer: %q", raw)
+		}
+		f.value.SetInt(int64(i))
+	case f.value.Kind() == reflect.Float64:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("not a number: %q", raw)
+		}
+		f.value.SetFloat(v)
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("not a boolean: %q", raw)
+		}
+		f.value.SetBool(b)
+	case f.value.Kind() == reflect.String:
+		f.value.SetString(raw)
+	case f.value.Kind() == reflect.Slice:
+		var items []string
+		if raw != "" {
+			items = strings.Split(raw, ",")
+		}
+		f.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+	return nil
+}
+
+// settingFields returns fields of the `s` in the order of declaration.
+func settingFields(s *Settings) []settingField {
+	v := reflect.ValueOf(s).Elem()
+	fields := make([]settingField, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if name := field.Tag.Get("config"); name != "" {
+			fields = append(fields, settingField{name: name, field: field, value: v.Field(i)})
+		}
+	}
+	return fields
+}
+
+// names returns names of all settings.
+func names() []string {
+	fields := settingFields(&Settings{})
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	return names
+}
+
+func flagName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// flagValue records the value of the flag, so it is applied over the other sources only if set.
+type flagValue struct {
+	value string
+	set   bool
+}
+
+func (f *flagValue) String() string {
+	return f.value
+}
+
+func (f *flagValue) Set(value string) error {
+	f.value, f.set = value, true
+	return nil
+}
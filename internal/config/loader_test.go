@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoader_Load(t *testing.T) {
+	const prefix = "CONFIG_TEST"
+
+	setup := func(t *testing.T, file string, env map[string]string, args ...string) (*Loader, func()) {
+		dir, err := ioutil.TempDir("", "config")
+		require.NoError(t, err)
+
+		path := filepath.Join(dir, "config.yaml")
+		require.NoError(t, ioutil.WriteFile(path, []byte(file), 0600))
+		env["CONFIG_FILE"] = path
+		for k, v := range env {
+			require.NoError(t, os.Setenv(prefix+"_"+k, v))
+		}
+
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		loader := NewLoader(prefix, flags)
+		require.NoError(t, flags.Parse(args))
+
+		return loader, func() {
+			for k := range env {
+				_ = os.Unsetenv(prefix + "_" + k)
+			}
+			_ = os.RemoveAll(dir)
+		}
+	}
+
+	t.Run("precedence", func(t *testing.T) {
+		loader, cleanup := setup(t, `
+http_port: 7000
+admin_http_port: 7001
+users_cache_ttl: 5m
+users_nickname_max_len: 20
+rate_limit_by: [ip, route]
+http_trusted_proxies: [10.0.0.0/8, 192.0.2.1]
+tracing_sample_ratio: 0.25
+`, map[string]string{"HTTP_PORT": "7002", "ADMIN_HTTP_PORT": "7003"}, "--admin-http-port=7004")
+		defer cleanup()
+
+		settings, err := loader.Load()
+		require.NoError(t, err)
+		require.Equal(t, 7002, settings.HTTPPort(), "env overrides file")
+		require.Equal(t, 7004, settings.AdminHTTPPort(), "flag overrides env")
+		require.Equal(t, 5*time.Minute, settings.UsersCacheTTL(), "file overrides default")
+		require.Equal(t, []string{"ip", "route"}, settings.RateLimitBy())
+		require.Equal(t, 10000, settings.UsersCacheSize(), "default")
+		require.Equal(t, 20, settings.Users().NicknameMaxLen)
+		require.Equal(t, 0.25, settings.TracingSampleRatio())
+		require.Equal(t, 50, settings.Users().FirstNameMaxLen, "default")
+		require.Len(t, settings.HTTPTrustedProxies(), 2)
+		require.Equal(t, "192.0.2.1/32", settings.HTTPTrustedProxies()[1].String(), "single address")
+	})
+
+	t.Run("secrets", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "secrets")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		pwdFile := filepath.Join(dir, "db-password")
+		require.NoError(t, ioutil.WriteFile(pwdFile, []byte("old\n"), 0600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "admin-token"), []byte("token"), 0600))
+
+		loader, cleanup := setup(t, `
+secrets_dir: `+dir+`
+admin_token: secret://admin-token
+`, map[string]string{"STORAGE_PWD_FILE": pwdFile})
+		defer cleanup()
+
+		settings, err := loader.Load()
+		require.NoError(t, err)
+		require.Equal(t, "token", settings.AdminToken().Get())
+
+		pwd := settings.StoragePwd()
+		require.Equal(t, "old", pwd.Get())
+		require.NoError(t, ioutil.WriteFile(pwdFile, []byte("new"), 0600))
+		changed, err := pwd.Refresh(context.Background())
+		require.NoError(t, err)
+		require.True(t, changed, "rotated secret is re-read")
+		require.Equal(t, "new", pwd.Get())
+	})
+
+	t.Run("all errors are reported", func(t *testing.T) {
+		loader, cleanup := setup(t, `
+unknown: 1
+users_cache_ttl: 5
+users_nickname_max_len: 31
+admin_token: secret://missing
+auth_api_keys: [service]
+tracing_sample_ratio: 2
+`, map[string]string{"HTTP_PORT": "http", "STORAGE_PWD": "pwd", "STORAGE_PWD_FILE": "/pwd"}, "--rate-limit-store=redis", "--log-level=verbose")
+		defer cleanup()
+
+		_, err := loader.Load()
+		require.Error(t, err)
+		require.IsType(t, Errors{}, err)
+		require.Len(t, err.(Errors), 10, err.Error())
+		require.Contains(t, err.Error(), "USERS_NICKNAME_MAX_LEN: must be from 1 to 30, got 31")
+		require.Contains(t, err.Error(), "TRACING_SAMPLE_RATIO: must be from 0 to 1, got 2")
+	})
+}
+
+func TestPrint(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader("CONFIG_TEST", flags)
+	require.NoError(t, flags.Parse([]string{"--admin-token=secret-token", "--http-port=7000"}))
+
+	settings, err := loader.Load()
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, Print(&out, settings))
+	require.Contains(t, out.String(), "http_port: 7000\n")
+	require.Contains(t, out.String(), "users_nickname_max_len: 30\n")
+	require.Contains(t, out.String(), "admin_token: <redacted>\n")
+	require.Contains(t, out.String(), "log_debug_secret: \"\"\n", "empty secret is not redacted")
+	require.NotContains(t, out.String(), "secret-token")
+}
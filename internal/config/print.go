@@ -0,0 +1,34 @@
+package config
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Redacted replaces values of the secret settings that are set.
+const Redacted = "<redacted>"
+
+// Print writes settings in the format of the config file, so the output could be used as one.
+// Values of the secret settings are replaced with Redacted.
+func Print(w io.Writer, s Settings) error {
+	fields := settingFields(&s)
+	out := make(yaml.MapSlice, 0, len(fields))
+	for _, f := range fields {
+		var value interface{}
+		switch {
+		case f.secret() && !f.value.IsZero():
+			value = Redacted
+		case f.value.Type() == reflect.TypeOf(time.Duration(0)):
+			value = f.value.Interface().(time.Duration).String()
+		default:
+			value = f.value.Interface()
+		}
+		out = append(out, yaml.MapItem{Key: strings.ToLower(f.name), Value: value})
+	}
+
+	return yaml.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	reloadsDesc = prometheus.NewDesc("config_reloads_total",
+		"Number of the settings reloads partitioned by result.", []string{"result"}, nil)
+	lastReloadDesc = prometheus.NewDesc("config_last_reload_success_timestamp_seconds",
+		"Time of the last successful reload of the settings.", nil, nil)
+)
+
+// NewReloader returns a reloader of the settings loaded with `loader`, the `initial` ones are used until the first reload.
+func NewReloader(loader *Loader, initial Settings) *Reloader {
+	r := &Reloader{loader: loader, now: time.Now}
+	r.current.Store(initial)
+	return r
+}
+
+// Reloader reloads settings at runtime. Only the settings marked with `reload` tag are applied,
+// changes of the rest are ignored till restart.
+type Reloader struct {
+	loader  *Loader
+	current atomic.Value
+	now     func() time.Time
+
+	// mu serializes reloads, so subscribers are notified in the order of reloads
+	mu          sync.Mutex
+	subscribers []func(old, new Settings)
+	successes   uint64
+	failures    uint64
+	lastSuccess time.Time
+}
+
+// Current returns the latest applied settings.
+func (r *Reloader) Current() Settings {
+	return r.current.Load().(Settings)
+}
+
+// Subscribe registers `fn` to be called with the previous and the new settings after each successful reload.
+// `fn` is called synchronously, so it must not block.
+func (r *Reloader) Subscribe(fn func(old, new Settings)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Reload loads and validates settings, then applies the reloadable ones at once and notifies subscribers.
+// Current settings are kept if any of them is invalid. It returns names of the changed settings that are ignored
+// as they can't be reloaded.
+func (r *Reloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ignored, err := r.reload()
+	if err != nil {
+		r.failures++
+		return nil, err
+	}
+
+	r.successes++
+	r.lastSuccess = r.now()
+	return ignored, nil
+}
+
+func (r *Reloader) reload() ([]string, error) {
+	loaded, err := r.loader.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	current := r.Current()
+	next := current
+
+	var ignored []string
+	loadedFields := settingFields(&loaded)
+	for i, f := range settingFields(&next) {
+		value := loadedFields[i].value
+		if reflect.DeepEqual(f.value.Interface(), value.Interface()) {
+			continue
+		}
+
+		if _, rotated := current.secretRefs[f.name]; rotated {
+			// referenced secrets are refreshed on their own
+			continue
+		}
+
+		if f.field.Tag.Get("reload") != "true" {
+			ignored = append(ignored, f.name)
+			continue
+		}
+		f.value.Set(value)
+	}
+
+	// reloaded settings must be consistent with the ones kept till restart
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	r.current.Store(next)
+	for _, fn := range r.subscribers {
+		fn(current, next)
+	}
+	return ignored, nil
+}
+
+// Describe sends descriptions of the reloads metrics.
+func (r *Reloader) Describe(ch chan<- *prometheus.Desc) {
+	ch <- reloadsDesc
+	ch <- lastReloadDesc
+}
+
+// Collect sends statistics of the reloads.
+func (r *Reloader) Collect(ch chan<- prometheus.Metric) {
+	r.mu.Lock()
+	successes, failures, lastSuccess := r.successes, r.failures, r.lastSuccess
+	r.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(reloadsDesc, prometheus.CounterValue, float64(successes), "success")
+	ch <- prometheus.MustNewConstMetric(reloadsDesc, prometheus.CounterValue, float64(failures), "failure")
+	if !lastSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(lastReloadDesc, prometheus.GaugeValue, float64(lastSuccess.Unix()))
+	}
+}
+
+// WatchFile calls `onChange` each time modification time or size of the file at `path` changes
+// until the context is cancelled. The file is checked with `interval`, it may be missing for a while,
+// e.g. when it is replaced.
+func WatchFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	stat := func() (time.Time, int64) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return info.ModTime(), info.Size()
+	}
+
+	modTime, size := stat()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			newModTime, newSize := stat()
+			if newSize < 0 || newModTime.Equal(modTime) && newSize == size {
+				continue
+			}
+			modTime, size = newModTime, newSize
+			onChange()
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	write := func(content string) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+	write("log_level: info\nhttp_port: 7000\n")
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader("CONFIG_TEST", flags)
+	require.NoError(t, flags.Parse([]string{"--config-file=" + path}))
+
+	initial, err := loader.Load()
+	require.NoError(t, err)
+
+	reloader := NewReloader(loader, initial)
+	var notified []string
+	reloader.Subscribe(func(old, new Settings) {
+		notified = append(notified, old.LogLevel()+"->"+new.LogLevel())
+	})
+
+	write("log_level: debug\nhttp_port: 7001\nauth_api_keys: [service:key]\n")
+	ignored, err := reloader.Reload()
+	require.NoError(t, err)
+	require.Equal(t, []string{"HTTP_PORT"}, ignored)
+	require.Equal(t, "debug", reloader.Current().LogLevel())
+	require.Equal(t, []string{"service:key"}, reloader.Current().AuthAPIKeys(), "api keys are replaced")
+	require.Equal(t, 7000, reloader.Current().HTTPPort(), "not reloadable setting is kept")
+	require.Equal(t, []string{"info->debug"}, notified)
+
+	write("log_level: verbose\n")
+	_, err = reloader.Reload()
+	require.Error(t, err)
+	require.Equal(t, "debug", reloader.Current().LogLevel(), "invalid settings are not applied")
+	require.Len(t, notified, 1)
+	require.Equal(t, uint64(1), reloader.successes)
+	require.Equal(t, uint64(1), reloader.failures)
+}
+
+func TestWatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("log_level: info\n"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	go WatchFile(ctx, path, time.Millisecond, func() { changed <- struct{}{} })
+
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-changed:
+		t.Fatal("unchanged file is reported")
+	default:
+	}
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("log_level: debug\n"), 0600))
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("change is not reported")
+	}
+}
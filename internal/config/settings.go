@@ -0,0 +1,502 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pavelmemory/faceit-users/internal/ratelimit"
+	"github.com/pavelmemory/faceit-users/internal/secret"
+	"github.com/pavelmemory/faceit-users/internal/tlsconfig"
+	"github.com/pavelmemory/faceit-users/internal/user"
+)
+
+// Settings of the service. Each setting is identified by the name from the `config` tag of the field,
+// see Loader for how the name is mapped to the sources of the settings. Values of the settings
+// marked with `secret` tag are never printed. Settings marked with `reload` tag could be changed without restart.
+type Settings struct {
+	// secretRefs are references the secret settings were resolved from, they are used to re-read rotated secrets
+	secretRefs     map[string]string
+	secretProvider secret.Provider
+
+	EnvHTTPListenPort int    `config:"HTTP_PORT" default:"8080"`
+	EnvAdminHTTPHost  string `config:"ADMIN_HTTP_HOST" default:"localhost"`
+	EnvAdminHTTPPort  int    `config:"ADMIN_HTTP_PORT" default:"8081"`
+	EnvDumpDir        string `config:"DUMP_DIR" default:"/tmp"`
+	EnvLogLevel       string `config:"LOG_LEVEL" default:"info" reload:"true"`
+	EnvStorageAddr    string `config:"STORAGE_ADDR" default:"0.0.0.0:5432"`
+	EnvStoragePwd     string `config:"STORAGE_PWD" secret:"true"`
+
+	EnvHTTPReadHeaderTimeout time.Duration `config:"HTTP_READ_HEADER_TIMEOUT" default:"10s"`
+	EnvHTTPIdleTimeout       time.Duration `config:"HTTP_IDLE_TIMEOUT" default:"2m"`
+	EnvShutdownGracePeriod   time.Duration `config:"SHUTDOWN_GRACE_PERIOD" default:"1m"`
+	EnvShutdownDelay         time.Duration `config:"SHUTDOWN_DELAY" default:"5s"`
+
+	EnvHTTPTLSCertFile     string   `config:"HTTP_TLS_CERT_FILE"`
+	EnvHTTPTLSKeyFile      string   `config:"HTTP_TLS_KEY_FILE"`
+	EnvHTTPTLSClientCAFile string   `config:"HTTP_TLS_CLIENT_CA_FILE"`
+	EnvHTTPTLSClientAuth   string   `config:"HTTP_TLS_CLIENT_AUTH" default:"optional"`
+	EnvHTTPTLSMinVersion   string   `config:"HTTP_TLS_MIN_VERSION" default:"1.2"`
+	EnvHTTPTLSCipherSuites []string `config:"HTTP_TLS_CIPHER_SUITES"`
+
+	EnvHTTPTrustedProxies []string `config:"HTTP_TRUSTED_PROXIES"`
+
+	EnvAccessLogOutput               string `config:"ACCESS_LOG_OUTPUT" default:"stdout"`
+	EnvAccessLogFormat               string `config:"ACCESS_LOG_FORMAT" default:"json"`
+	EnvAccessLogSuccessSamplePercent int    `config:"ACCESS_LOG_SUCCESS_SAMPLE_PERCENT" default:"100"`
+
+	EnvStorageMaxOpenConns    int           `config:"STORAGE_MAX_OPEN_CONNS" default:"16"`
+	EnvStorageMaxIdleConns    int           `config:"STORAGE_MAX_IDLE_CONNS" default:"4"`
+	EnvStorageConnMaxLifetime time.Duration `config:"STORAGE_CONN_MAX_LIFETIME" default:"30s"`
+
+	EnvSecretsDir     string        `config:"SECRETS_DIR" default:"/run/secrets"`
+	EnvSecretsRefresh time.Duration `config:"SECRETS_REFRESH" default:"1m"`
+
+	EnvConfigWatchInterval time.Duration `config:"CONFIG_WATCH_INTERVAL" default:"10s"`
+
+	EnvEventsReplaySize  int           `config:"EVENTS_REPLAY_SIZE" default:"1024"`
+	EnvEventsClientQueue int           `config:"EVENTS_CLIENT_QUEUE" default:"64"`
+	EnvEventsHeartbeat   time.Duration `config:"EVENTS_HEARTBEAT" default:"15s"`
+
+	EnvIdempotencyTTL   time.Duration `config:"IDEMPOTENCY_TTL" default:"24h"`
+	EnvIdempotencyLease time.Duration `config:"IDEMPOTENCY_LEASE" default:"1m"`
+
+	EnvUsersCacheSize int           `config:"USERS_CACHE_SIZE" default:"10000"`
+	EnvUsersCacheTTL  time.Duration `config:"USERS_CACHE_TTL" default:"1m"`
+
+	EnvUsersFirstNameMaxLen int `config:"USERS_FIRST_NAME_MAX_LEN" default:"50"`
+	EnvUsersLastNameMaxLen  int `config:"USERS_LAST_NAME_MAX_LEN" default:"50"`
+	EnvUsersNicknameMaxLen  int `config:"USERS_NICKNAME_MAX_LEN" default:"30"`
+	EnvUsersPasswordMaxLen  int `config:"USERS_PASSWORD_MAX_LEN" default:"20"`
+	EnvUsersCountryMaxLen   int `config:"USERS_COUNTRY_MAX_LEN" default:"2"`
+	EnvUsersIDMaxLen        int `config:"USERS_ID_MAX_LEN" default:"36"`
+
+	EnvHTTPCacheControlUsers string `config:"HTTP_CACHE_CONTROL_USERS" default:"private, no-cache"`
+
+	EnvBatchMaxOperations int `config:"BATCH_MAX_OPERATIONS" default:"1000"`
+
+	EnvFeatureFlagsFile          string `config:"FEATURE_FLAGS_FILE"`
+	EnvFeatureFlagsCountryHeader string `config:"FEATURE_FLAGS_COUNTRY_HEADER" default:"X-Country-Code"`
+
+	EnvHealthCacheTTL time.Duration `config:"HEALTH_CACHE_TTL" default:"5s"`
+
+	EnvTracingExporter     string  `config:"TRACING_EXPORTER" default:"none"`
+	EnvTracingOTLPEndpoint string  `config:"TRACING_OTLP_ENDPOINT" default:"http://localhost:4318/v1/traces"`
+	EnvTracingServiceName  string  `config:"TRACING_SERVICE_NAME" default:"faceit-users"`
+	EnvTracingSampleRatio  float64 `config:"TRACING_SAMPLE_RATIO" default:"1"`
+
+	EnvAuthJWTSecret   string        `config:"AUTH_JWT_SECRET" secret:"true"`
+	EnvAuthJWKSURL     string        `config:"AUTH_JWKS_URL"`
+	EnvAuthJWKSFile    string        `config:"AUTH_JWKS_FILE"`
+	EnvAuthJWKSRefresh time.Duration `config:"AUTH_JWKS_REFRESH" default:"1h"`
+	EnvAuthJWKSTimeout time.Duration `config:"AUTH_JWKS_TIMEOUT" default:"5s"`
+	EnvAuthJWTIssuer   string        `config:"AUTH_JWT_ISSUER"`
+	EnvAuthJWTAudience string        `config:"AUTH_JWT_AUDIENCE"`
+	EnvAuthAPIKeys     []string      `config:"AUTH_API_KEYS" secret:"true" reload:"true"`
+	EnvAuthPolicyFile  string        `config:"AUTH_POLICY_FILE"`
+
+	EnvSessionAccessTTL  time.Duration `config:"SESSION_ACCESS_TTL" default:"15m"`
+	EnvSessionRefreshTTL time.Duration `config:"SESSION_REFRESH_TTL" default:"720h"`
+
+	EnvRateLimitRequests int           `config:"RATE_LIMIT_REQUESTS" default:"0" reload:"true"`
+	EnvRateLimitPeriod   time.Duration `config:"RATE_LIMIT_PERIOD" default:"1m" reload:"true"`
+	EnvRateLimitBurst    int           `config:"RATE_LIMIT_BURST" default:"0" reload:"true"`
+	EnvRateLimitBy       []string      `config:"RATE_LIMIT_BY" default:"ip"`
+	EnvRateLimitStore    string        `config:"RATE_LIMIT_STORE" default:"memory"`
+
+	EnvLoginFailureThreshold int           `config:"LOGIN_FAILURE_THRESHOLD" default:"3"`
+	EnvLoginFailureDelay     time.Duration `config:"LOGIN_FAILURE_DELAY" default:"1s"`
+	EnvLoginFailureMaxDelay  time.Duration `config:"LOGIN_FAILURE_MAX_DELAY" default:"1m"`
+	EnvLoginLockoutFailures  int           `config:"LOGIN_LOCKOUT_FAILURES" default:"10"`
+	EnvLoginLockoutDuration  time.Duration `config:"LOGIN_LOCKOUT_DURATION" default:"15m"`
+
+	EnvAdminToken     string `config:"ADMIN_TOKEN" secret:"true"`
+	EnvLogDebugSecret string `config:"LOG_DEBUG_SECRET" secret:"true"`
+}
+
+// HTTPPort returns a port number to listening for incoming HTTP connections.
+func (es Settings) HTTPPort() int {
+	return es.EnvHTTPListenPort
+}
+
+// AdminHTTPHost returns a host name or an IP address to listen for incoming HTTP connections to the admin endpoints.
+func (es Settings) AdminHTTPHost() string {
+	return es.EnvAdminHTTPHost
+}
+
+// AdminHTTPPort returns a port number to listen for incoming HTTP connections to the admin endpoints.
+func (es Settings) AdminHTTPPort() int {
+	return es.EnvAdminHTTPPort
+}
+
+// DumpDir returns a directory goroutine and heap dumps are written into.
+func (es Settings) DumpDir() string {
+	return es.EnvDumpDir
+}
+
+// LogLevel returns a logging level.
+func (es Settings) LogLevel() string {
+	return es.EnvLogLevel
+}
+
+// StorageAddr returns address of the main persistence storage.
+func (es Settings) StorageAddr() string {
+	return es.EnvStorageAddr
+}
+
+// StoragePwd returns a password of the main persistence storage.
+// It is re-read on refresh if it was resolved from a file or a secret provider.
+func (es Settings) StoragePwd() *secret.Value {
+	return es.secret("STORAGE_PWD", es.EnvStoragePwd)
+}
+
+// SecretsDir returns a directory with the secrets referred as 'secret://<file name>' by the default provider.
+func (es Settings) SecretsDir() string {
+	return es.EnvSecretsDir
+}
+
+// SecretsRefresh returns an interval the rotated secrets are re-read with.
+func (es Settings) SecretsRefresh() time.Duration {
+	return es.EnvSecretsRefresh
+}
+
+// ConfigWatchInterval returns an interval the config file is checked for changes with, it is not watched if 0.
+func (es Settings) ConfigWatchInterval() time.Duration {
+	return es.EnvConfigWatchInterval
+}
+
+// RateLimit returns a limit of the requests made with the same key.
+func (es Settings) RateLimit() ratelimit.Limit {
+	return ratelimit.Limit{
+		Rate:  float64(es.EnvRateLimitRequests) / es.EnvRateLimitPeriod.Seconds(),
+		Burst: es.RateLimitBurst(),
+	}
+}
+
+// secret returns the `value` of the secret setting that is re-read from the reference it was resolved from.
+func (es Settings) secret(name, value string) *secret.Value {
+	ref, ok := es.secretRefs[name]
+	if !ok {
+		return secret.NewValue(value, nil)
+	}
+
+	provider := es.secretProvider
+	return secret.NewValue(value, func(ctx context.Context) (string, error) {
+		return secret.Resolve(ctx, ref, provider)
+	})
+}
+
+// HTTPReadHeaderTimeout returns a time allowed to read headers of the request.
+func (es Settings) HTTPReadHeaderTimeout() time.Duration {
+	return es.EnvHTTPReadHeaderTimeout
+}
+
+// HTTPIdleTimeout returns a time the keep-alive connection waits for the next request.
+func (es Settings) HTTPIdleTimeout() time.Duration {
+	return es.EnvHTTPIdleTimeout
+}
+
+// ShutdownGracePeriod returns a time the servers are given to finish in-flight requests before they are forced to stop.
+func (es Settings) ShutdownGracePeriod() time.Duration {
+	return es.EnvShutdownGracePeriod
+}
+
+// ShutdownDelay returns a time the service keeps serving requests after it reports it is not ready,
+// so the load balancer stops routing new requests to it before the servers stop.
+func (es Settings) ShutdownDelay() time.Duration {
+	return es.EnvShutdownDelay
+}
+
+// HTTPTLSFiles returns paths to the certificate and the key of the public server and to the bundle of
+// the client authorities. The server accepts only TLS connections if the certificate is set,
+// client certificates are verified if the authorities are set.
+func (es Settings) HTTPTLSFiles() tlsconfig.Files {
+	return tlsconfig.Files{
+		CertFile:     es.EnvHTTPTLSCertFile,
+		KeyFile:      es.EnvHTTPTLSKeyFile,
+		ClientCAFile: es.EnvHTTPTLSClientCAFile,
+	}
+}
+
+// HTTPTLSClientAuth returns a mode of the client certificates verification: 'optional' or 'require'.
+func (es Settings) HTTPTLSClientAuth() string {
+	return es.EnvHTTPTLSClientAuth
+}
+
+// HTTPTLSMinVersion returns the minimal TLS version accepted by the public server.
+func (es Settings) HTTPTLSMinVersion() uint16 {
+	version, _ := tlsconfig.ParseVersion(es.EnvHTTPTLSMinVersion)
+	return version
+}
+
+// HTTPTLSCipherSuites returns cipher suites of TLS 1.2 connections, Go defaults are used if it is empty.
+func (es Settings) HTTPTLSCipherSuites() []uint16 {
+	suites, _ := tlsconfig.ParseCipherSuites(es.EnvHTTPTLSCipherSuites)
+	return suites
+}
+
+// HTTPTrustedProxies returns networks of the proxies the client address is taken from 'X-Forwarded-For' header of.
+func (es Settings) HTTPTrustedProxies() []*net.IPNet {
+	proxies, _ := parseNetworks(es.EnvHTTPTrustedProxies)
+	return proxies
+}
+
+// AccessLogOutput returns where the access log is written to: 'stdout', 'stderr' or a path to the file.
+// The access log is disabled if it is 'none'.
+func (es Settings) AccessLogOutput() string {
+	return es.EnvAccessLogOutput
+}
+
+// AccessLogFormat returns a format of the access log: 'json', 'common' or 'combined'.
+func (es Settings) AccessLogFormat() string {
+	return es.EnvAccessLogFormat
+}
+
+// AccessLogSuccessSamplePercent returns a percent of the successful requests written into the access log.
+func (es Settings) AccessLogSuccessSamplePercent() int {
+	return es.EnvAccessLogSuccessSamplePercent
+}
+
+// StorageMaxOpenConns returns max number of connections to the main persistence storage.
+func (es Settings) StorageMaxOpenConns() int {
+	return es.EnvStorageMaxOpenConns
+}
+
+// StorageMaxIdleConns returns max number of idle connections kept open to the main persistence storage.
+func (es Settings) StorageMaxIdleConns() int {
+	return es.EnvStorageMaxIdleConns
+}
+
+// StorageConnMaxLifetime returns max time the connection to the main persistence storage is reused for.
+func (es Settings) StorageConnMaxLifetime() time.Duration {
+	return es.EnvStorageConnMaxLifetime
+}
+
+// EventsReplaySize returns a number of recent user events kept to resume interrupted streams.
+func (es Settings) EventsReplaySize() int {
+	return es.EnvEventsReplaySize
+}
+
+// EventsClientQueue returns a number of user events that could be queued for a single
+// stream subscriber before it is considered too slow and disconnected.
+func (es Settings) EventsClientQueue() int {
+	return es.EnvEventsClientQueue
+}
+
+// EventsHeartbeat returns an interval of heartbeats sent to the idle event streams.
+func (es Settings) EventsHeartbeat() time.Duration {
+	return es.EnvEventsHeartbeat
+}
+
+// IdempotencyTTL returns a duration the outcomes of requests made with idempotency keys are kept for.
+func (es Settings) IdempotencyTTL() time.Duration {
+	return es.EnvIdempotencyTTL
+}
+
+// IdempotencyLease returns a duration the idempotency key is reserved for while the request is in progress.
+func (es Settings) IdempotencyLease() time.Duration {
+	return es.EnvIdempotencyLease
+}
+
+// UsersCacheSize returns max number of users cached in memory, users are not cached if it is 0.
+func (es Settings) UsersCacheSize() int {
+	return es.EnvUsersCacheSize
+}
+
+// UsersCacheTTL returns a time the user stays in the cache for.
+func (es Settings) UsersCacheTTL() time.Duration {
+	return es.EnvUsersCacheTTL
+}
+
+// Users returns limits of the user properties.
+func (es Settings) Users() user.Settings {
+	return user.Settings{
+		FirstNameMaxLen: es.EnvUsersFirstNameMaxLen,
+		LastNameMaxLen:  es.EnvUsersLastNameMaxLen,
+		NicknameMaxLen:  es.EnvUsersNicknameMaxLen,
+		PasswordMaxLen:  es.EnvUsersPasswordMaxLen,
+		CountryMaxLen:   es.EnvUsersCountryMaxLen,
+		IDMaxLen:        es.EnvUsersIDMaxLen,
+	}
+}
+
+// HTTPCacheControlUsers returns 'Cache-Control' directives of the user resource responses.
+func (es Settings) HTTPCacheControlUsers() string {
+	return es.EnvHTTPCacheControlUsers
+}
+
+// BatchMaxOperations returns max number of operations allowed in a single batch request.
+func (es Settings) BatchMaxOperations() int {
+	return es.EnvBatchMaxOperations
+}
+
+// FeatureFlagsFile returns a path to the YAML file with definitions of the feature flags, no flags are defined if it is empty.
+// The file is re-read on each reload of the settings.
+func (es Settings) FeatureFlagsFile() string {
+	return es.EnvFeatureFlagsFile
+}
+
+// FeatureFlagsCountryHeader returns a name of the request header with the country code of the caller
+// the feature flags are evaluated for.
+func (es Settings) FeatureFlagsCountryHeader() string {
+	return es.EnvFeatureFlagsCountryHeader
+}
+
+// TracingExporter returns a name of the exporter of tracing spans: 'none', 'stdout' or 'otlp'.
+func (es Settings) TracingExporter() string {
+	return es.EnvTracingExporter
+}
+
+// TracingOTLPEndpoint returns URL of the OpenTelemetry collector accepting spans with OTLP/HTTP protocol.
+func (es Settings) TracingOTLPEndpoint() string {
+	return es.EnvTracingOTLPEndpoint
+}
+
+// TracingServiceName returns a name of the service reported with tracing spans.
+func (es Settings) TracingServiceName() string {
+	return es.EnvTracingServiceName
+}
+
+// TracingSampleRatio returns a fraction of the traces started by the service that are sampled,
+// the traces continued from other services are sampled according to the decision of the caller.
+func (es Settings) TracingSampleRatio() float64 {
+	return es.EnvTracingSampleRatio
+}
+
+// HealthCacheTTL returns a duration results of the health checks are reused for.
+func (es Settings) HealthCacheTTL() time.Duration {
+	return es.EnvHealthCacheTTL
+}
+
+// AdminToken returns a bearer token required by the administrative endpoints, they are disabled if it is empty.
+// It is re-read on refresh if it was resolved from a file or a secret provider.
+func (es Settings) AdminToken() *secret.Value {
+	return es.secret("ADMIN_TOKEN", es.EnvAdminToken)
+}
+
+// LogDebugSecret returns a key used to sign tokens that force debug logging for a single request.
+// It is re-read on refresh if it was resolved from a file or a secret provider.
+func (es Settings) LogDebugSecret() *secret.Value {
+	return es.secret("LOG_DEBUG_SECRET", es.EnvLogDebugSecret)
+}
+
+// AuthJWTSecret returns a shared secret to verify HS256 signatures of the tokens.
+// It is re-read on refresh if it was resolved from a file or a secret provider.
+func (es Settings) AuthJWTSecret() *secret.Value {
+	return es.secret("AUTH_JWT_SECRET", es.EnvAuthJWTSecret)
+}
+
+// AuthJWKSURL returns URL of the JSON Web Key Set document with keys to verify signatures of the tokens.
+func (es Settings) AuthJWKSURL() string {
+	return es.EnvAuthJWKSURL
+}
+
+// AuthJWKSFile returns a path to the JSON Web Key Set document with keys to verify signatures of the tokens.
+func (es Settings) AuthJWKSFile() string {
+	return es.EnvAuthJWKSFile
+}
+
+// AuthJWKSRefresh returns an interval the JSON Web Key Set is reloaded with.
+func (es Settings) AuthJWKSRefresh() time.Duration {
+	return es.EnvAuthJWKSRefresh
+}
+
+// AuthJWKSTimeout returns a timeout of the JSON Web Key Set retrieval by URL.
+func (es Settings) AuthJWKSTimeout() time.Duration {
+	return es.EnvAuthJWKSTimeout
+}
+
+// AuthJWTIssuer returns an issuer the tokens must be issued by, it is not verified if empty.
+func (es Settings) AuthJWTIssuer() string {
+	return es.EnvAuthJWTIssuer
+}
+
+// AuthJWTAudience returns an audience the tokens must be issued for, it is not verified if empty.
+func (es Settings) AuthJWTAudience() string {
+	return es.EnvAuthJWTAudience
+}
+
+// AuthAPIKeys returns definitions of the API keys in '<subject>:<key>' or '<subject>:sha256:<hash>' format.
+// The keys are replaced on reload.
+func (es Settings) AuthAPIKeys() []string {
+	return es.EnvAuthAPIKeys
+}
+
+// AuthPolicyFile returns a path to the YAML file with authorization rules, the default rules are used if it is empty.
+func (es Settings) AuthPolicyFile() string {
+	return es.EnvAuthPolicyFile
+}
+
+// SessionAccessTTL returns a lifetime of the access tokens issued on login.
+func (es Settings) SessionAccessTTL() time.Duration {
+	return es.EnvSessionAccessTTL
+}
+
+// SessionRefreshTTL returns a lifetime of the refresh tokens issued on login.
+func (es Settings) SessionRefreshTTL() time.Duration {
+	return es.EnvSessionRefreshTTL
+}
+
+// RateLimitRequests returns a number of requests allowed per RateLimitPeriod, requests are not limited if it is 0.
+func (es Settings) RateLimitRequests() int {
+	return es.EnvRateLimitRequests
+}
+
+// RateLimitPeriod returns a period the RateLimitRequests are allowed in.
+func (es Settings) RateLimitPeriod() time.Duration {
+	return es.EnvRateLimitPeriod
+}
+
+// RateLimitBurst returns a number of requests allowed at once, it equals to RateLimitRequests if it is 0.
+func (es Settings) RateLimitBurst() int {
+	if es.EnvRateLimitBurst == 0 {
+		return es.EnvRateLimitRequests
+	}
+	return es.EnvRateLimitBurst
+}
+
+// RateLimitBy returns parts of the key the requests are limited by: 'ip', 'principal' and/or 'route'.
+func (es Settings) RateLimitBy() []string {
+	return es.EnvRateLimitBy
+}
+
+// RateLimitStore returns a name of the store of the rate limits: 'memory' or 'postgres'.
+func (es Settings) RateLimitStore() string {
+	return es.EnvRateLimitStore
+}
+
+// LoginFailurePolicy returns a policy the failed login attempts are slowed down and locked out with.
+func (es Settings) LoginFailurePolicy() ratelimit.FailurePolicy {
+	return ratelimit.FailurePolicy{
+		Threshold:   es.EnvLoginFailureThreshold,
+		Delay:       es.EnvLoginFailureDelay,
+		MaxDelay:    es.EnvLoginFailureMaxDelay,
+		MaxFailures: es.EnvLoginLockoutFailures,
+		Lockout:     es.EnvLoginLockoutDuration,
+	}
+}
+
+// parseNetworks parses networks in CIDR notation, a single IP address is a network of itself.
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if ip := net.ParseIP(value); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither IP address nor CIDR", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
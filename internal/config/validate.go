@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/tlsconfig"
+)
+
+// Validate checks that settings are consistent and returns Errors with all violations found.
+func (es Settings) Validate() error {
+	var errs Errors
+	check := func(ok bool, name, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]interface{}{name}, args...)...))
+		}
+	}
+	oneOf := func(value, name string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		check(false, name, "%q is not one of %q", value, allowed)
+	}
+	port := func(value int, name string) {
+		check(value > 0 && value <= 65535, name, "%d is not a valid port", value)
+	}
+	positive := func(value time.Duration, name string) {
+		check(value > 0, name, "must be positive, got %s", value)
+	}
+	notNegative := func(value int, name string) {
+		check(value >= 0, name, "must not be negative, got %d", value)
+	}
+	between := func(value, min, max int, name string) {
+		check(value >= min && value <= max, name, "must be from %d to %d, got %d", min, max, value)
+	}
+
+	port(es.EnvHTTPListenPort, "HTTP_PORT")
+	port(es.EnvAdminHTTPPort, "ADMIN_HTTP_PORT")
+	check(es.EnvHTTPListenPort != es.EnvAdminHTTPPort, "ADMIN_HTTP_PORT", "must differ from HTTP_PORT")
+	if err := logging.ValidateLevel(es.EnvLogLevel); err != nil {
+		check(false, "LOG_LEVEL", "%v", err)
+	}
+
+	_, _, err := net.SplitHostPort(es.EnvStorageAddr)
+	check(err == nil, "STORAGE_ADDR", "%q is not a host:port pair", es.EnvStorageAddr)
+	check(es.EnvStorageMaxOpenConns > 0, "STORAGE_MAX_OPEN_CONNS", "must be positive, got %d", es.EnvStorageMaxOpenConns)
+	notNegative(es.EnvStorageMaxIdleConns, "STORAGE_MAX_IDLE_CONNS")
+	check(es.EnvStorageMaxIdleConns <= es.EnvStorageMaxOpenConns, "STORAGE_MAX_IDLE_CONNS", "must not exceed STORAGE_MAX_OPEN_CONNS")
+	positive(es.EnvStorageConnMaxLifetime, "STORAGE_CONN_MAX_LIFETIME")
+
+	positive(es.EnvSecretsRefresh, "SECRETS_REFRESH")
+	check(es.EnvConfigWatchInterval >= 0, "CONFIG_WATCH_INTERVAL", "must not be negative, got %s", es.EnvConfigWatchInterval)
+
+	positive(es.EnvHTTPReadHeaderTimeout, "HTTP_READ_HEADER_TIMEOUT")
+	positive(es.EnvHTTPIdleTimeout, "HTTP_IDLE_TIMEOUT")
+	positive(es.EnvShutdownGracePeriod, "SHUTDOWN_GRACE_PERIOD")
+	check(es.EnvShutdownDelay >= 0, "SHUTDOWN_DELAY", "must not be negative, got %s", es.EnvShutdownDelay)
+
+	check((es.EnvHTTPTLSCertFile == "") == (es.EnvHTTPTLSKeyFile == ""), "HTTP_TLS_KEY_FILE", "must be set together with HTTP_TLS_CERT_FILE")
+	check(es.EnvHTTPTLSClientCAFile == "" || es.EnvHTTPTLSCertFile != "", "HTTP_TLS_CLIENT_CA_FILE", "requires HTTP_TLS_CERT_FILE")
+	oneOf(es.EnvHTTPTLSClientAuth, "HTTP_TLS_CLIENT_AUTH", tlsconfig.ClientAuthOptional, tlsconfig.ClientAuthRequire)
+	if _, err := tlsconfig.ParseVersion(es.EnvHTTPTLSMinVersion); err != nil {
+		check(false, "HTTP_TLS_MIN_VERSION", "%v", err)
+	}
+	if _, err := tlsconfig.ParseCipherSuites(es.EnvHTTPTLSCipherSuites); err != nil {
+		check(false, "HTTP_TLS_CIPHER_SUITES", "%v", err)
+	}
+	if _, err := parseNetworks(es.EnvHTTPTrustedProxies); err != nil {
+		check(false, "HTTP_TRUSTED_PROXIES", "%v", err)
+	}
+
+	check(es.EnvAccessLogOutput != "", "ACCESS_LOG_OUTPUT", "must not be empty, 'none' disables the access log")
+	oneOf(es.EnvAccessLogFormat, "ACCESS_LOG_FORMAT", "json", "common", "combined")
+	check(es.EnvAccessLogSuccessSamplePercent >= 0 && es.EnvAccessLogSuccessSamplePercent <= 100,
+		"ACCESS_LOG_SUCCESS_SAMPLE_PERCENT", "must be from 0 to 100, got %d", es.EnvAccessLogSuccessSamplePercent)
+
+	check(es.EnvEventsReplaySize > 0, "EVENTS_REPLAY_SIZE", "must be positive, got %d", es.EnvEventsReplaySize)
+	check(es.EnvEventsClientQueue > 0, "EVENTS_CLIENT_QUEUE", "must be positive, got %d", es.EnvEventsClientQueue)
+	positive(es.EnvEventsHeartbeat, "EVENTS_HEARTBEAT")
+	positive(es.EnvIdempotencyTTL, "IDEMPOTENCY_TTL")
+	positive(es.EnvIdempotencyLease, "IDEMPOTENCY_LEASE")
+	check(es.EnvIdempotencyLease <= es.EnvIdempotencyTTL, "IDEMPOTENCY_LEASE", "must not exceed IDEMPOTENCY_TTL")
+	notNegative(es.EnvUsersCacheSize, "USERS_CACHE_SIZE")
+	positive(es.EnvUsersCacheTTL, "USERS_CACHE_TTL")
+	// the properties must fit the columns of the users table, the password is stored hashed
+	between(es.EnvUsersFirstNameMaxLen, 1, 50, "USERS_FIRST_NAME_MAX_LEN")
+	between(es.EnvUsersLastNameMaxLen, 1, 50, "USERS_LAST_NAME_MAX_LEN")
+	between(es.EnvUsersNicknameMaxLen, 1, 30, "USERS_NICKNAME_MAX_LEN")
+	between(es.EnvUsersPasswordMaxLen, 1, 128, "USERS_PASSWORD_MAX_LEN")
+	between(es.EnvUsersCountryMaxLen, 1, 2, "USERS_COUNTRY_MAX_LEN")
+	// identifiers are UUIDs, shorter limit would reject all of them
+	between(es.EnvUsersIDMaxLen, 36, 255, "USERS_ID_MAX_LEN")
+	check(es.EnvBatchMaxOperations > 0, "BATCH_MAX_OPERATIONS", "must be positive, got %d", es.EnvBatchMaxOperations)
+	positive(es.EnvHealthCacheTTL, "HEALTH_CACHE_TTL")
+
+	oneOf(es.EnvTracingExporter, "TRACING_EXPORTER", "", "none", "stdout", "otlp")
+	check(es.EnvTracingSampleRatio >= 0 && es.EnvTracingSampleRatio <= 1, "TRACING_SAMPLE_RATIO", "must be from 0 to 1, got %v", es.EnvTracingSampleRatio)
+
+	positive(es.EnvAuthJWKSRefresh, "AUTH_JWKS_REFRESH")
+	positive(es.EnvAuthJWKSTimeout, "AUTH_JWKS_TIMEOUT")
+	positive(es.EnvSessionAccessTTL, "SESSION_ACCESS_TTL")
+	check(es.EnvSessionRefreshTTL > es.EnvSessionAccessTTL, "SESSION_REFRESH_TTL", "must be longer than SESSION_ACCESS_TTL")
+	for _, definition := range es.EnvAuthAPIKeys {
+		if _, err := auth.ParseAPIKey(definition); err != nil {
+			check(false, "AUTH_API_KEYS", "%v", err)
+		}
+	}
+
+	notNegative(es.EnvRateLimitRequests, "RATE_LIMIT_REQUESTS")
+	positive(es.EnvRateLimitPeriod, "RATE_LIMIT_PERIOD")
+	notNegative(es.EnvRateLimitBurst, "RATE_LIMIT_BURST")
+	for _, by := range es.EnvRateLimitBy {
+		oneOf(by, "RATE_LIMIT_BY", "ip", "principal", "route")
+	}
+	oneOf(es.EnvRateLimitStore, "RATE_LIMIT_STORE", "memory", "postgres")
+
+	notNegative(es.EnvLoginFailureThreshold, "LOGIN_FAILURE_THRESHOLD")
+	positive(es.EnvLoginFailureDelay, "LOGIN_FAILURE_DELAY")
+	check(es.EnvLoginFailureMaxDelay >= es.EnvLoginFailureDelay, "LOGIN_FAILURE_MAX_DELAY", "must not be shorter than LOGIN_FAILURE_DELAY")
+	check(es.EnvLoginLockoutFailures > es.EnvLoginFailureThreshold, "LOGIN_LOCKOUT_FAILURES", "must exceed LOGIN_FAILURE_THRESHOLD")
+	positive(es.EnvLoginLockoutDuration, "LOGIN_LOCKOUT_DURATION")
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
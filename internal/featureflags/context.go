@@ -0,0 +1,42 @@
+package featureflags
+
+import (
+	"context"
+	"time"
+)
+
+type ctxKey struct{}
+
+// evaluator evaluates flags for the target of the request with the state captured at the start of the request,
+// so the values of the flags don't change while the request is processed.
+type evaluator struct {
+	state  *state
+	target Target
+	now    time.Time
+}
+
+// ToContext injects the flags evaluated for the `target` into the context.
+func ToContext(ctx context.Context, flags *Flags, target Target) context.Context {
+	return context.WithValue(ctx, ctxKey{}, evaluator{state: flags.load(), target: target, now: flags.now()})
+}
+
+// Evaluate returns the value of the flag for the target of the context.
+// The reason is ReasonUnknown if there are no flags in the context.
+func Evaluate(ctx context.Context, name string) Evaluation {
+	e, ok := ctx.Value(ctxKey{}).(evaluator)
+	if !ok {
+		return Evaluation{Flag: name, Reason: ReasonUnknown, Rule: -1}
+	}
+	return e.state.evaluate(name, e.target, e.now)
+}
+
+// Enabled reports if the boolean flag is on for the target of the context.
+// Unknown flags are always off.
+func Enabled(ctx context.Context, name string) bool {
+	return Evaluate(ctx, name).Value == On
+}
+
+// Variant returns a variant of the flag for the target of the context, it is empty for unknown flags.
+func Variant(ctx context.Context, name string) string {
+	return Evaluate(ctx, name).Value
+}
@@ -0,0 +1,355 @@
+package featureflags
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/pavelmemory/faceit-users/internal"
+)
+
+// Kinds of the flags.
+const (
+	// KindBoolean is a flag evaluated to 'true' or 'false'.
+	KindBoolean = "boolean"
+	// KindVariant is a flag evaluated to one of the variants it defines.
+	KindVariant = "variant"
+)
+
+// Values of the boolean flags.
+const (
+	On  = "true"
+	Off = "false"
+)
+
+// Reasons of the evaluation results.
+const (
+	ReasonDefault  = "default"
+	ReasonRule     = "rule"
+	ReasonOverride = "override"
+	// ReasonUnknown means the flag is not defined or there are no flags in the context.
+	ReasonUnknown = "unknown"
+)
+
+// Flag is a definition of the feature flag. The first rule that matches the target sets the value of the flag,
+// it is the default value if none of them matches.
+type Flag struct {
+	// Name identifies the flag, e.g. 'users.strict_validation'.
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Variants the flag could be evaluated to, the flag is boolean if it is empty.
+	Variants []string `yaml:"variants"`
+	// Default is a value of the flag if no rule matches, it is 'false' for boolean flags and the first variant for the rest if empty.
+	Default string `yaml:"default"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+// Kind returns KindBoolean or KindVariant.
+func (f Flag) Kind() string {
+	if len(f.Variants) == 0 {
+		return KindBoolean
+	}
+	return KindVariant
+}
+
+// values returns all values the flag could be evaluated to.
+func (f Flag) values() []string {
+	if f.Kind() == KindBoolean {
+		return []string{Off, On}
+	}
+	return f.Variants
+}
+
+func (f Flag) valid(value string) bool {
+	for _, v := range f.values() {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule sets the value of the flag for the targets that meet all conditions of the rule.
+// The rule without conditions matches any target.
+type Rule struct {
+	// Users are identifiers of the users the rule matches.
+	Users []string `yaml:"users"`
+	// Countries are ISO 3166-1 alpha-2 codes of the countries the rule matches, e.g. 'DE'.
+	Countries []string `yaml:"countries"`
+	// Percentage of the users the rule matches, from 0 to 100. Each user falls into the same bucket
+	// on each evaluation of the flag, so increasing the percentage rolls out the flag to more users gradually.
+	// Anonymous targets never match the rule with percentage.
+	Percentage *float64 `yaml:"percentage"`
+	Value      string   `yaml:"value"`
+}
+
+// Target is a subject the flags are evaluated for.
+type Target struct {
+	UserID string
+	// Country is an ISO 3166-1 alpha-2 code of the country, e.g. 'DE'.
+	Country string
+}
+
+// Evaluation is a value of the flag evaluated for the target.
+type Evaluation struct {
+	Flag   string
+	Value  string
+	Reason string
+	// Rule is an index of the rule the value is set by, it is -1 if the reason is not ReasonRule.
+	Rule int
+}
+
+// Override replaces values of the flag for all targets.
+type Override struct {
+	Flag  string
+	Value string
+	// ExpiresAt is a moment the override is reverted at, zero value means it never expires.
+	ExpiresAt time.Time
+}
+
+// Load returns definitions of the flags from the YAML file.
+func Load(path string) ([]Flag, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read feature flags: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse returns validated definitions of the flags in YAML format.
+func Parse(data []byte) ([]Flag, error) {
+	var file struct {
+		Flags []Flag `yaml:"flags"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("parse feature flags: %w", err)
+	}
+
+	if err := validate(file.Flags); err != nil {
+		return nil, err
+	}
+	return file.Flags, nil
+}
+
+func validate(flags []Flag) error {
+	names := map[string]bool{}
+	for i, flag := range flags {
+		switch {
+		case flag.Name == "":
+			return fmt.Errorf("flag #%d: no name", i)
+		case names[flag.Name]:
+			return fmt.Errorf("flag %q: duplicate name", flag.Name)
+		case flag.Default != "" && !flag.valid(flag.Default):
+			return fmt.Errorf("flag %q: default %q is not one of %v", flag.Name, flag.Default, flag.values())
+		}
+		names[flag.Name] = true
+
+		for j, rule := range flag.Rules {
+			switch {
+			case !flag.valid(rule.Value):
+				return fmt.Errorf("flag %q: rule #%d: value %q is not one of %v", flag.Name, j, rule.Value, flag.values())
+			case rule.Percentage != nil && (*rule.Percentage < 0 || *rule.Percentage > 100):
+				return fmt.Errorf("flag %q: rule #%d: percentage must be from 0 to 100, got %v", flag.Name, j, *rule.Percentage)
+			}
+		}
+	}
+	return nil
+}
+
+// New returns feature flags with the definitions.
+func New(flags []Flag) (*Flags, error) {
+	f := &Flags{now: time.Now}
+	if err := f.Replace(flags); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Flags evaluates feature flags. Definitions of the flags could be replaced and their values overridden at runtime.
+type Flags struct {
+	now func() time.Time
+	// state is replaced on each change, so the evaluations made with the same state are consistent
+	state atomic.Value
+
+	// mu serializes changes of the state
+	mu sync.Mutex
+}
+
+// state is an immutable set of the flags definitions and overrides.
+type state struct {
+	flags     map[string]Flag
+	overrides map[string]Override
+}
+
+func (f *Flags) load() *state {
+	s, _ := f.state.Load().(*state)
+	if s == nil {
+		return &state{}
+	}
+	return s
+}
+
+// Replace replaces definitions of the flags. Overrides of the removed flags and variants are removed as well.
+func (f *Flags) Replace(flags []Flag) error {
+	if err := validate(flags); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	next := &state{flags: make(map[string]Flag, len(flags)), overrides: map[string]Override{}}
+	for _, flag := range flags {
+		next.flags[flag.Name] = flag
+	}
+	for name, override := range f.load().overrides {
+		if flag, ok := next.flags[name]; ok && flag.valid(override.Value) {
+			next.overrides[name] = override
+		}
+	}
+
+	f.state.Store(next)
+	return nil
+}
+
+// SetOverride sets the `value` of the flag for all targets. The override is reverted after `ttl` if it is positive.
+func (f *Flags) SetOverride(name, value string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current := f.load()
+	flag, ok := current.flags[name]
+	if !ok {
+		return fmt.Errorf("flag %q: %w", name, internal.ErrNotFound)
+	}
+	if !flag.valid(value) {
+		return fmt.Errorf("flag %q: value %q is not one of %v: %w", name, value, flag.values(), internal.ErrBadInput)
+	}
+
+	override := Override{Flag: name, Value: value}
+	if ttl > 0 {
+		override.ExpiresAt = f.now().Add(ttl)
+	}
+
+	next := current.withOverrides()
+	next.overrides[name] = override
+	f.state.Store(next)
+	return nil
+}
+
+// RemoveOverride reverts the value of the flag to the evaluated one.
+func (f *Flags) RemoveOverride(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	next := f.load().withOverrides()
+	delete(next.overrides, name)
+	f.state.Store(next)
+}
+
+// withOverrides returns a copy of the state with the overrides that could be changed.
+func (s *state) withOverrides() *state {
+	next := &state{flags: s.flags, overrides: make(map[string]Override, len(s.overrides)+1)}
+	for name, override := range s.overrides {
+		next.overrides[name] = override
+	}
+	return next
+}
+
+// Definitions returns definitions of all flags sorted by name.
+func (f *Flags) Definitions() []Flag {
+	current := f.load()
+	flags := make([]Flag, 0, len(current.flags))
+	for _, flag := range current.flags {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// Overrides returns active overrides of the flags.
+func (f *Flags) Overrides() []Override {
+	now := f.now()
+	var overrides []Override
+	for _, override := range f.load().overrides {
+		if !override.expired(now) {
+			overrides = append(overrides, override)
+		}
+	}
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].Flag < overrides[j].Flag })
+	return overrides
+}
+
+// Evaluate returns the value of the flag for the target.
+func (f *Flags) Evaluate(name string, target Target) Evaluation {
+	return f.load().evaluate(name, target, f.now())
+}
+
+func (o Override) expired(now time.Time) bool {
+	return !o.ExpiresAt.IsZero() && !now.Before(o.ExpiresAt)
+}
+
+func (s *state) evaluate(name string, target Target, now time.Time) Evaluation {
+	flag, ok := s.flags[name]
+	if !ok {
+		return Evaluation{Flag: name, Reason: ReasonUnknown, Rule: -1}
+	}
+
+	if override, ok := s.overrides[name]; ok && !override.expired(now) {
+		return Evaluation{Flag: name, Value: override.Value, Reason: ReasonOverride, Rule: -1}
+	}
+
+	for i, rule := range flag.Rules {
+		if rule.matches(name, target) {
+			return Evaluation{Flag: name, Value: rule.Value, Reason: ReasonRule, Rule: i}
+		}
+	}
+
+	value := flag.Default
+	if value == "" {
+		value = flag.values()[0]
+	}
+	return Evaluation{Flag: name, Value: value, Reason: ReasonDefault, Rule: -1}
+}
+
+func (r Rule) matches(flag string, target Target) bool {
+	if len(r.Users) > 0 && !contains(r.Users, target.UserID, false) {
+		return false
+	}
+	if len(r.Countries) > 0 && !contains(r.Countries, target.Country, true) {
+		return false
+	}
+	if r.Percentage != nil && (target.UserID == "" || bucket(flag, target.UserID) >= *r.Percentage*100) {
+		return false
+	}
+	return true
+}
+
+// bucket returns a stable number from 0 to 9999 for the user. The name of the flag is mixed in,
+// so different flags are rolled out to different users.
+func bucket(flag, userID string) float64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(flag))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(userID))
+	return float64(h.Sum32() % 10000)
+}
+
+func contains(values []string, value string, ignoreCase bool) bool {
+	if value == "" {
+		return false
+	}
+	for _, v := range values {
+		if v == value || ignoreCase && strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package featureflags
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal"
+)
+
+const testFlags = `
+flags:
+  - name: strict
+    description: stricter validation
+    rules:
+      - users: ["1"]
+        value: true
+      - countries: [de]
+        percentage: 50
+        value: true
+  - name: layout
+    variants: [classic, compact, wide]
+    default: classic
+    rules:
+      - percentage: 25
+        value: compact
+`
+
+func TestParse(t *testing.T) {
+	flags, err := Parse([]byte(testFlags))
+	require.NoError(t, err)
+	require.Len(t, flags, 2)
+	require.Equal(t, KindBoolean, flags[0].Kind())
+	require.Equal(t, On, flags[0].Rules[0].Value, "boolean is accepted as a value")
+	require.Equal(t, KindVariant, flags[1].Kind())
+
+	for name, data := range map[string]string{
+		"unknown key":         "flags: [{name: a, enabled: true}]",
+		"no name":             "flags: [{default: 'true'}]",
+		"duplicate":           "flags: [{name: a}, {name: a}]",
+		"unknown default":     "flags: [{name: a, variants: [x, y], default: z}]",
+		"unknown rule value":  "flags: [{name: a, rules: [{value: 'yes'}]}]",
+		"negative percentage": "flags: [{name: a, rules: [{percentage: -1, value: 'true'}]}]",
+		"percentage over 100": "flags: [{name: a, rules: [{percentage: 101, value: 'true'}]}]",
+	} {
+		_, err := Parse([]byte(data))
+		require.Error(t, err, name)
+	}
+}
+
+func TestFlags_Evaluate(t *testing.T) {
+	definitions, err := Parse([]byte(testFlags))
+	require.NoError(t, err)
+	flags, err := New(definitions)
+	require.NoError(t, err)
+
+	require.Equal(t, Evaluation{Flag: "strict", Value: On, Reason: ReasonRule, Rule: 0}, flags.Evaluate("strict", Target{UserID: "1"}))
+	require.Equal(t, Evaluation{Flag: "strict", Value: Off, Reason: ReasonDefault, Rule: -1}, flags.Evaluate("strict", Target{UserID: "2"}))
+	require.Equal(t, Evaluation{Flag: "strict", Value: Off, Reason: ReasonDefault, Rule: -1}, flags.Evaluate("strict", Target{Country: "DE"}),
+		"anonymous target doesn't match percentage")
+	require.Equal(t, Evaluation{Flag: "unknown", Reason: ReasonUnknown, Rule: -1}, flags.Evaluate("unknown", Target{UserID: "1"}))
+
+	t.Run("percentage", func(t *testing.T) {
+		const users = 10000
+		compact := 0
+		for i := 0; i < users; i++ {
+			target := Target{UserID: strconv.Itoa(i)}
+			evaluation := flags.Evaluate("layout", target)
+			require.Equal(t, evaluation, flags.Evaluate("layout", target), "evaluation is stable")
+			if evaluation.Value == "compact" {
+				compact++
+			}
+		}
+		require.InDelta(t, users/4, compact, users/50)
+
+		enabled := 0
+		for i := 0; i < users; i++ {
+			if flags.Evaluate("strict", Target{UserID: strconv.Itoa(i + 2), Country: "DE"}).Value == On {
+				enabled++
+			}
+			require.Equal(t, Off, flags.Evaluate("strict", Target{UserID: strconv.Itoa(i + 2), Country: "FR"}).Value)
+		}
+		require.InDelta(t, users/2, enabled, users/50)
+	})
+
+	t.Run("override", func(t *testing.T) {
+		now := time.Now()
+		flags.now = func() time.Time { return now }
+
+		require.True(t, errors.Is(flags.SetOverride("unknown", On, 0), internal.ErrNotFound))
+		require.True(t, errors.Is(flags.SetOverride("layout", "narrow", 0), internal.ErrBadInput))
+
+		require.NoError(t, flags.SetOverride("strict", Off, time.Minute))
+		require.NoError(t, flags.SetOverride("layout", "wide", 0))
+		require.Equal(t, Evaluation{Flag: "strict", Value: Off, Reason: ReasonOverride, Rule: -1}, flags.Evaluate("strict", Target{UserID: "1"}))
+		require.Len(t, flags.Overrides(), 2)
+
+		now = now.Add(time.Minute)
+		require.Equal(t, On, flags.Evaluate("strict", Target{UserID: "1"}).Value, "override expired")
+		require.Equal(t, []Override{{Flag: "layout", Value: "wide"}}, flags.Overrides())
+
+		// overrides of the variants that are not defined anymore are removed
+		require.NoError(t, flags.Replace([]Flag{{Name: "layout", Variants: []string{"classic", "compact"}}}))
+		require.Empty(t, flags.Overrides())
+		require.Equal(t, "classic", flags.Evaluate("layout", Target{}).Value, "the first variant is the default one")
+
+		flags.RemoveOverride("layout")
+		require.Equal(t, "classic", flags.Evaluate("layout", Target{}).Value)
+	})
+}
+
+func TestContext(t *testing.T) {
+	flags, err := New([]Flag{{Name: "strict", Default: On}, {Name: "layout", Variants: []string{"classic", "compact"}, Default: "compact"}})
+	require.NoError(t, err)
+
+	ctx := ToContext(context.Background(), flags, Target{UserID: "1"})
+	require.NoError(t, flags.SetOverride("strict", Off, 0))
+	require.True(t, Enabled(ctx, "strict"), "flags don't change while the request is processed")
+	require.Equal(t, "compact", Variant(ctx, "layout"))
+	require.False(t, Enabled(ToContext(context.Background(), flags, Target{}), "strict"))
+
+	require.False(t, Enabled(context.Background(), "strict"), "no flags in the context")
+	require.Equal(t, "", Variant(ctx, "unknown"))
+}
@@ -0,0 +1,204 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// State is a phase of the service lifecycle.
+type State int32
+
+const (
+	// StateStarting is a state of the service that is not yet initialized.
+	StateStarting State = iota
+	// StateReady is a state of the service ready to handle requests.
+	StateReady
+	// StateDraining is a state of the service that is stopping and finishes handling of in-flight requests.
+	StateDraining
+)
+
+func (s State) String() string {
+	switch s {
+	case StateReady:
+		return "ready"
+	case StateDraining:
+		return "draining"
+	default:
+		return "starting"
+	}
+}
+
+// Status is an outcome of the check.
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Check is a verification of the dependency or an internal state of the component.
+type Check struct {
+	// Name is a unique name of the check, e.g. 'postgres.ping'.
+	Name string
+	// Timeout limits duration of the single check.
+	Timeout time.Duration
+	// Critical checks affect readiness of the service, the rest are only reported.
+	Critical bool
+	// Func returns an error if the check fails.
+	Func func(ctx context.Context) error
+}
+
+// Result is an outcome of the check.
+type Result struct {
+	Name      string
+	Critical  bool
+	Status    Status
+	Err       error
+	Latency   time.Duration
+	CheckedAt time.Time
+}
+
+// Report is an outcome of all registered checks.
+type Report struct {
+	// Status is up only if the service is ready and all critical checks are up.
+	Status  Status
+	State   State
+	Results []Result
+}
+
+// ErrNotReady is returned by the readiness check when the service is starting or draining.
+var ErrNotReady = errors.New("not ready")
+
+// NewRegistry returns an empty registry in the StateStarting state.
+// Results of the checks are cached for `ttl` to avoid overloading of the dependencies
+// by the frequent probes.
+func NewRegistry(ttl time.Duration) *Registry {
+	return &Registry{ttl: ttl}
+}
+
+// Registry keeps checks registered by the components and the state of the service.
+type Registry struct {
+	ttl   time.Duration
+	state int32
+
+	mtx    sync.Mutex
+	checks []*cachedCheck
+}
+
+type cachedCheck struct {
+	Check
+
+	mtx    sync.Mutex
+	result Result
+}
+
+// Register adds checks to the registry.
+func (r *Registry) Register(checks ...Check) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, check := range checks {
+		r.checks = append(r.checks, &cachedCheck{Check: check})
+	}
+}
+
+// SetState changes the state of the service.
+func (r *Registry) SetState(state State) {
+	atomic.StoreInt32(&r.state, int32(state))
+}
+
+// State returns current state of the service.
+func (r *Registry) State() State {
+	return State(atomic.LoadInt32(&r.state))
+}
+
+// Report runs all checks concurrently and returns their results ordered by name.
+// Results that are not older than the cache TTL are reused.
+func (r *Registry) Report(ctx context.Context) Report {
+	r.mtx.Lock()
+	checks := append([]*cachedCheck(nil), r.checks...)
+	r.mtx.Unlock()
+
+	report := Report{State: r.State(), Status: StatusUp, Results: make([]Result, len(checks))}
+
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+	for i, check := range checks {
+		go func(i int, check *cachedCheck) {
+			defer wg.Done()
+			report.Results[i] = check.run(ctx, r.ttl)
+		}(i, check)
+	}
+	wg.Wait()
+
+	sort.Slice(report.Results, func(i, j int) bool {
+		return report.Results[i].Name < report.Results[j].Name
+	})
+
+	if report.State != StateReady {
+		report.Status = StatusDown
+	}
+	for _, result := range report.Results {
+		if result.Critical && result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+// Ready returns an error if the service is not ready or any of the critical checks is down.
+func (r *Registry) Ready(ctx context.Context) error {
+	if r.State() != StateReady {
+		return ErrNotReady
+	}
+
+	for _, result := range r.Report(ctx).Results {
+		if result.Critical && result.Status != StatusUp {
+			return result.Err
+		}
+	}
+	return nil
+}
+
+// run returns a cached result if it is fresh, otherwise it executes the check.
+// Concurrent callers wait for the single execution of the check.
+func (cc *cachedCheck) run(ctx context.Context, ttl time.Duration) Result {
+	cc.mtx.Lock()
+	defer cc.mtx.Unlock()
+
+	if !cc.result.CheckedAt.IsZero() && time.Since(cc.result.CheckedAt) < ttl {
+		return cc.result
+	}
+
+	checkCtx := ctx
+	if cc.Timeout > 0 {
+		var cancel context.CancelFunc
+		checkCtx, cancel = context.WithTimeout(ctx, cc.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := cc.Func(checkCtx)
+	result := Result{
+		Name:      cc.Name,
+		Critical:  cc.Critical,
+		Status:    StatusUp,
+		Err:       err,
+		Latency:   time.Since(start),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusDown
+	}
+
+	if ctx.Err() == nil {
+		// the result is not cached if the caller gave up on the check, so the next caller runs it again
+		cc.result = result
+	}
+	return result
+}
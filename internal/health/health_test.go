@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Report(t *testing.T) {
+	var calls int64
+	errDown := errors.New("down")
+
+	registry := NewRegistry(time.Minute)
+	registry.Register(
+		Check{Name: "b.critical", Critical: true, Func: func(context.Context) error {
+			atomic.AddInt64(&calls, 1)
+			return nil
+		}},
+		Check{Name: "a.optional", Func: func(context.Context) error {
+			return errDown
+		}},
+		Check{Name: "c.slow", Timeout: time.Millisecond, Func: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	)
+
+	report := registry.Report(context.Background())
+	require.Equal(t, StatusDown, report.Status, "service is starting")
+	require.Equal(t, StateStarting, report.State)
+	require.Len(t, report.Results, 3)
+	require.Equal(t, "a.optional", report.Results[0].Name)
+	require.Equal(t, StatusDown, report.Results[0].Status)
+	require.Equal(t, errDown, report.Results[0].Err)
+	require.Equal(t, "b.critical", report.Results[1].Name)
+	require.Equal(t, StatusUp, report.Results[1].Status)
+	require.Equal(t, StatusDown, report.Results[2].Status)
+	require.True(t, errors.Is(report.Results[2].Err, context.DeadlineExceeded))
+
+	require.Equal(t, ErrNotReady, registry.Ready(context.Background()))
+
+	registry.SetState(StateReady)
+	report = registry.Report(context.Background())
+	require.Equal(t, StatusUp, report.Status, "failed checks are not critical")
+	require.NoError(t, registry.Ready(context.Background()))
+	require.EqualValues(t, 1, atomic.LoadInt64(&calls), "results are cached")
+
+	registry.SetState(StateDraining)
+	require.Equal(t, ErrNotReady, registry.Ready(context.Background()))
+}
+
+func TestRegistry_Ready(t *testing.T) {
+	errDown := errors.New("down")
+	registry := NewRegistry(0)
+	registry.SetState(StateReady)
+
+	var failing int32
+	registry.Register(Check{Name: "critical", Critical: true, Func: func(context.Context) error {
+		if atomic.LoadInt32(&failing) == 1 {
+			return errDown
+		}
+		return nil
+	}})
+
+	require.NoError(t, registry.Ready(context.Background()))
+
+	atomic.StoreInt32(&failing, 1)
+	require.Equal(t, errDown, registry.Ready(context.Background()), "results are not cached with zero TTL")
+}
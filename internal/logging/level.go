@@ -0,0 +1,188 @@
+package logging
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+// ErrUnknownLevel is returned for the names of the levels that are not supported.
+var ErrUnknownLevel = errors.New("unknown logging level")
+
+// ValidateLevel returns an error if the level is not supported.
+func ValidateLevel(level string) error {
+	switch level {
+	case "debug", "info", "warn", "error":
+		return nil
+	default:
+		return fmt.Errorf("%q: %w", level, ErrUnknownLevel)
+	}
+}
+
+// LevelSetter changes the minimum severity of the logs at runtime.
+type LevelSetter interface {
+	// Level returns current logging level.
+	Level() string
+	// SetLevel changes logging level.
+	SetLevel(level string) error
+}
+
+// NewLevelController returns a controller of the `global` logging level.
+// `debugSecret` is a key used to sign tokens that force debug level for a single request,
+// such tokens are not accepted while it is empty. The tokens signed with the previous value of the rotated
+// secret are not accepted anymore.
+func NewLevelController(global LevelSetter, debugSecret *secret.Value) *LevelController {
+	return &LevelController{
+		global:      global,
+		debugSecret: debugSecret,
+		overrides:   map[string]Override{},
+		now:         time.Now,
+	}
+}
+
+// LevelController controls logging levels at runtime: the global one and overrides for particular routes.
+type LevelController struct {
+	global      LevelSetter
+	debugSecret *secret.Value
+	now         func() time.Time
+
+	mtx       sync.Mutex
+	overrides map[string]Override
+}
+
+// Override is a logging level used for requests of the route instead of the global one.
+type Override struct {
+	Route string
+	Level string
+	// ExpiresAt is a moment the override is reverted at, zero value means it never expires.
+	ExpiresAt time.Time
+}
+
+// Level returns the global logging level.
+func (lc *LevelController) Level() string {
+	return lc.global.Level()
+}
+
+// SetLevel changes the global logging level.
+func (lc *LevelController) SetLevel(level string) error {
+	if err := ValidateLevel(level); err != nil {
+		return err
+	}
+	return lc.global.SetLevel(level)
+}
+
+// SetOverride sets logging level for requests of the `route` (a route pattern, e.g. '/users/{id}').
+// The override is reverted after `ttl` if it is positive.
+func (lc *LevelController) SetOverride(route, level string, ttl time.Duration) error {
+	if err := ValidateLevel(level); err != nil {
+		return err
+	}
+
+	override := Override{Route: route, Level: level}
+	if ttl > 0 {
+		override.ExpiresAt = lc.now().Add(ttl)
+	}
+
+	lc.mtx.Lock()
+	defer lc.mtx.Unlock()
+
+	lc.overrides[route] = override
+	return nil
+}
+
+// RemoveOverride reverts logging level of the `route` to the global one.
+func (lc *LevelController) RemoveOverride(route string) {
+	lc.mtx.Lock()
+	defer lc.mtx.Unlock()
+
+	delete(lc.overrides, route)
+}
+
+// Override returns logging level of the `route` if it is overridden.
+func (lc *LevelController) Override(route string) (string, bool) {
+	lc.mtx.Lock()
+	defer lc.mtx.Unlock()
+
+	override, ok := lc.overrides[route]
+	if !ok {
+		return "", false
+	}
+
+	if lc.expired(override) {
+		delete(lc.overrides, route)
+		return "", false
+	}
+	return override.Level, true
+}
+
+// Overrides returns all active overrides ordered by route.
+func (lc *LevelController) Overrides() []Override {
+	lc.mtx.Lock()
+	defer lc.mtx.Unlock()
+
+	overrides := make([]Override, 0, len(lc.overrides))
+	for route, override := range lc.overrides {
+		if lc.expired(override) {
+			delete(lc.overrides, route)
+			continue
+		}
+		overrides = append(overrides, override)
+	}
+
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].Route < overrides[j].Route
+	})
+	return overrides
+}
+
+func (lc *LevelController) expired(override Override) bool {
+	return !override.ExpiresAt.IsZero() && !lc.now().Before(override.ExpiresAt)
+}
+
+// DebugToken returns a token that forces debug level for requests it is sent with until it expires.
+// The token has a form of '<expiration unix timestamp>.<hex encoded HMAC-SHA256 signature of the timestamp>'.
+func (lc *LevelController) DebugToken(ttl time.Duration) (string, error) {
+	key := lc.debugSecret.Get()
+	if key == "" {
+		return "", errors.New("debug tokens are disabled")
+	}
+
+	expiresAt := strconv.FormatInt(lc.now().Add(ttl).Unix(), 10)
+	return expiresAt + "." + sign(key, expiresAt), nil
+}
+
+// VerifyDebugToken reports if the token was issued by DebugToken and is not yet expired.
+func (lc *LevelController) VerifyDebugToken(token string) bool {
+	key := lc.debugSecret.Get()
+	if key == "" || token == "" {
+		return false
+	}
+
+	dot := strings.IndexByte(token, '.')
+	if dot < 0 {
+		return false
+	}
+
+	expiresAt, signature := token[:dot], token[dot+1:]
+	if !hmac.Equal([]byte(signature), []byte(sign(key, expiresAt))) {
+		return false
+	}
+
+	unix, err := strconv.ParseInt(expiresAt, 10, 64)
+	return err == nil && lc.now().Before(time.Unix(unix, 0))
+}
+
+func sign(key, value string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+type stubLevel struct {
+	level string
+}
+
+func (sl *stubLevel) Level() string {
+	return sl.level
+}
+
+func (sl *stubLevel) SetLevel(level string) error {
+	sl.level = level
+	return nil
+}
+
+func TestLevelController_SetLevel(t *testing.T) {
+	global := &stubLevel{level: "info"}
+	lc := NewLevelController(global, secret.NewValue("", nil))
+
+	require.NoError(t, lc.SetLevel("debug"))
+	require.Equal(t, "debug", lc.Level())
+
+	err := lc.SetLevel("verbose")
+	require.True(t, errors.Is(err, ErrUnknownLevel))
+	require.Equal(t, "debug", global.level)
+}
+
+func TestLevelController_Override(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	lc := NewLevelController(&stubLevel{level: "info"}, secret.NewValue("", nil))
+	lc.now = func() time.Time { return now }
+
+	require.NoError(t, lc.SetOverride("/users/{id}", "debug", time.Minute))
+	require.NoError(t, lc.SetOverride("/users", "error", 0))
+	require.True(t, errors.Is(lc.SetOverride("/events", "trace", 0), ErrUnknownLevel))
+
+	level, ok := lc.Override("/users/{id}")
+	require.True(t, ok)
+	require.Equal(t, "debug", level)
+	require.Equal(t, []Override{
+		{Route: "/users", Level: "error"},
+		{Route: "/users/{id}", Level: "debug", ExpiresAt: now.Add(time.Minute)},
+	}, lc.Overrides())
+
+	now = now.Add(time.Minute)
+	_, ok = lc.Override("/users/{id}")
+	require.False(t, ok, "override expired")
+	require.Equal(t, []Override{{Route: "/users", Level: "error"}}, lc.Overrides())
+
+	lc.RemoveOverride("/users")
+	require.Empty(t, lc.Overrides())
+}
+
+func TestLevelController_DebugToken(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	current := "secret"
+	debugSecret := secret.NewValue(current, func(context.Context) (string, error) { return current, nil })
+	lc := NewLevelController(&stubLevel{level: "info"}, debugSecret)
+	lc.now = func() time.Time { return now }
+
+	token, err := lc.DebugToken(time.Minute)
+	require.NoError(t, err)
+	require.True(t, lc.VerifyDebugToken(token))
+
+	other := NewLevelController(&stubLevel{level: "info"}, secret.NewValue("other", nil))
+	require.False(t, other.VerifyDebugToken(token), "signed with another secret")
+	require.False(t, lc.VerifyDebugToken("9999999999"+token[len("1577836860"):]), "expiration changed")
+	require.False(t, lc.VerifyDebugToken(""))
+
+	current = "rotated"
+	_, err = debugSecret.Refresh(context.Background())
+	require.NoError(t, err)
+	require.False(t, lc.VerifyDebugToken(token), "signed with the previous secret")
+	token, err = lc.DebugToken(time.Minute)
+	require.NoError(t, err)
+	require.True(t, lc.VerifyDebugToken(token), "signed with the rotated secret")
+
+	now = now.Add(time.Minute)
+	require.False(t, lc.VerifyDebugToken(token), "token expired")
+
+	_, err = NewLevelController(&stubLevel{}, secret.NewValue("", nil)).DebugToken(time.Minute)
+	require.Error(t, err, "debug tokens are disabled")
+}
@@ -15,6 +15,8 @@ type Logger interface {
 	WithInt(key string, val int) Logger
 	// WithError adds `err` to the logging context and returns it to the caller.
 	WithError(err error) Logger
+	// WithLevel returns a logger with the provided logging level instead of the global one.
+	WithLevel(level string) Logger
 	// Debug flushes logging context with "debug" severity level.
 	Debug(msg string)
 	// Info flushes logging context with "info" severity level.
@@ -77,6 +79,11 @@ func (tl *TestLogger) WithError(err error) Logger {
 	return tl
 }
 
+func (tl *TestLogger) WithLevel(lvl string) Logger {
+	tl.with("logger_level", lvl)
+	return tl
+}
+
 func (tl *TestLogger) level(lvl, msg string) {
 	tl.with("level", lvl)
 	tl.with("msg", msg)
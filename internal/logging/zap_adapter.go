@@ -8,43 +8,82 @@ import (
 )
 
 // NewZapLogger returns a zap logger adapter to be used with `Logging` interface.
+// The level could be changed at runtime with SetLevel.
 func NewZapLogger(lvl string) ZapWrapper {
 	var logLevel zapcore.Level
 	if err := logLevel.UnmarshalText([]byte(lvl)); err != nil {
 		logLevel = zapcore.InfoLevel
 	}
+	level := zap.NewAtomicLevelAt(logLevel)
 
 	config := zap.NewProductionEncoderConfig()
 	config.EncodeTime = zapcore.RFC3339NanoTimeEncoder
 
-	core := zapcore.NewCore(zapcore.NewJSONEncoder(config), zapcore.Lock(os.Stderr), logLevel)
-	logger := zap.New(core, zap.WithCaller(false))
-	return ZapWrapper{Logger: logger}
+	// the core itself accepts all levels, so the filtering level could be lowered for a particular logger
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(config), zapcore.Lock(os.Stderr), zapcore.DebugLevel)
+	logger := zap.New(levelCore{Core: core, enabler: level}, zap.WithCaller(false))
+	return ZapWrapper{Logger: logger, level: level}
 }
 
 // ZapWrapper is an adapter between service defined logging interface and zap logging implementation.
 type ZapWrapper struct {
 	*zap.Logger
+	level zap.AtomicLevel
+}
+
+func (zw ZapWrapper) with(logger *zap.Logger) ZapWrapper {
+	return ZapWrapper{Logger: logger, level: zw.level}
 }
 
 func (zw ZapWrapper) WithString(key, val string) Logger {
-	return ZapWrapper{Logger: zw.Logger.With(zap.String(key, val))}
+	return zw.with(zw.Logger.With(zap.String(key, val)))
 }
 
 func (zw ZapWrapper) WithUint64(key string, val uint64) Logger {
-	return ZapWrapper{Logger: zw.Logger.With(zap.Uint64(key, val))}
+	return zw.with(zw.Logger.With(zap.Uint64(key, val)))
 }
 
 func (zw ZapWrapper) WithInt64(key string, val int64) Logger {
-	return ZapWrapper{Logger: zw.Logger.With(zap.Int64(key, val))}
+	return zw.with(zw.Logger.With(zap.Int64(key, val)))
 }
 
 func (zw ZapWrapper) WithInt(key string, val int) Logger {
-	return ZapWrapper{Logger: zw.Logger.With(zap.Int(key, val))}
+	return zw.with(zw.Logger.With(zap.Int(key, val)))
 }
 
 func (zw ZapWrapper) WithError(err error) Logger {
-	return ZapWrapper{Logger: zw.Logger.With(zap.Error(err))}
+	return zw.with(zw.Logger.With(zap.Error(err)))
+}
+
+// WithLevel returns a logger that ignores the global level and uses provided one.
+func (zw ZapWrapper) WithLevel(lvl string) Logger {
+	var logLevel zapcore.Level
+	if err := logLevel.UnmarshalText([]byte(lvl)); err != nil {
+		return zw
+	}
+
+	return zw.with(zw.Logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if lc, ok := core.(levelCore); ok {
+			return levelCore{Core: lc.Core, enabler: logLevel}
+		}
+		return core
+	})))
+}
+
+// Level returns the global logging level.
+func (zw ZapWrapper) Level() string {
+	return zw.level.Level().String()
+}
+
+// SetLevel changes the global logging level, it affects all loggers derived from the root one.
+func (zw ZapWrapper) SetLevel(lvl string) error {
+	var logLevel zapcore.Level
+	if err := logLevel.UnmarshalText([]byte(lvl)); err != nil {
+		return err
+	}
+
+	zw.level.SetLevel(logLevel)
+	return nil
 }
 
 func (zw ZapWrapper) Debug(msg string) {
@@ -62,3 +101,24 @@ func (zw ZapWrapper) Error(msg string) {
 func (zw ZapWrapper) IsDebug() bool {
 	return zw.Logger.Core().Enabled(zapcore.DebugLevel)
 }
+
+// levelCore filters entries with its own level enabler instead of the level of the wrapped core.
+type levelCore struct {
+	zapcore.Core
+	enabler zapcore.LevelEnabler
+}
+
+func (lc levelCore) Enabled(lvl zapcore.Level) bool {
+	return lc.enabler.Enabled(lvl)
+}
+
+func (lc levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return levelCore{Core: lc.Core.With(fields), enabler: lc.enabler}
+}
+
+func (lc levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if lc.Enabled(entry.Level) {
+		return checked.AddCore(entry, lc)
+	}
+	return checked
+}
@@ -0,0 +1,221 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+)
+
+// Actions on the user resources.
+const (
+	ActionUsersCreate = "users.create"
+	ActionUsersGet    = "users.get"
+	ActionUsersUpdate = "users.update"
+	ActionUsersDelete = "users.delete"
+	ActionUsersExport = "users.export"
+	ActionUsersImport = "users.import"
+	ActionUsersEvents = "users.events"
+
+	ActionUsersChangePassword = "users.change_password"
+)
+
+// anyAction matches all actions.
+const anyAction = "*"
+
+// Default is a policy used if no other policy is configured:
+// users may read, update and watch only themselves and change own password, support can read anyone and update some fields,
+// admins can do everything including watching the events of all users.
+const Default = `
+rules:
+  - name: self-read
+    actions: [users.get, users.events]
+    owner: true
+  - name: self-update
+    actions: [users.update]
+    owner: true
+    fields: [first_name, last_name, nickname, email, country]
+  - name: self-password
+    actions: [users.change_password]
+    owner: true
+  - name: support-read
+    actions: [users.get, users.export]
+    roles: [support]
+  - name: support-update
+    actions: [users.update]
+    roles: [support]
+    fields: [nickname, country]
+  - name: admin
+    actions: ["*"]
+    roles: [admin]
+`
+
+// Rule allows principals to perform actions. All conditions of the rule must be met.
+type Rule struct {
+	// Name identifies the rule in the decisions.
+	Name string `yaml:"name"`
+	// Actions allowed by the rule, '*' allows all actions.
+	Actions []string `yaml:"actions"`
+	// Roles the principal must have at least one of, any principal matches if it is empty.
+	Roles []string `yaml:"roles"`
+	// Owner requires the principal to be the owner of the resource.
+	Owner bool `yaml:"owner"`
+	// Fields that are allowed to be changed, all fields are allowed if it is empty.
+	Fields []string `yaml:"fields"`
+}
+
+// Request is an attempt of the principal to perform the action.
+type Request struct {
+	Principal auth.Principal
+	Action    string
+	// Owner is a subject that owns the resource, it is empty if the resource is not owned by anyone.
+	Owner string
+	// Fields that are going to be changed by the action.
+	Fields []string
+}
+
+// Decision is an outcome of the policy evaluation.
+type Decision struct {
+	Allowed bool
+	// Rules that allowed the action.
+	Rules []string
+	// Reasons of the denial.
+	Reasons []string
+}
+
+// Load returns an engine with the policy from the YAML file.
+func Load(path string) (*Engine, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse returns an engine with the policy defined in YAML format.
+func Parse(data []byte) (*Engine, error) {
+	var policy struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+	return NewEngine(policy.Rules)
+}
+
+// NewEngine returns an engine that evaluates requests against the rules.
+func NewEngine(rules []Rule) (*Engine, error) {
+	names := map[string]bool{}
+	for i, rule := range rules {
+		switch {
+		case rule.Name == "":
+			return nil, fmt.Errorf("rule #%d: no name", i)
+		case names[rule.Name]:
+			return nil, fmt.Errorf("rule %q: duplicate name", rule.Name)
+		case len(rule.Actions) == 0:
+			return nil, fmt.Errorf("rule %q: no actions", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	return &Engine{rules: rules}, nil
+}
+
+// Engine evaluates requests against the rules of the policy.
+// Everything that is not explicitly allowed by the rules is denied.
+type Engine struct {
+	rules []Rule
+}
+
+// ErrDenied is returned if the action is not allowed by the policy.
+var ErrDenied = errors.New("access denied")
+
+// Evaluate returns a decision on the request.
+// The action is allowed if at least one rule matches the request and each of the fields is allowed by
+// at least one of the matching rules.
+func (e *Engine) Evaluate(req Request) Decision {
+	var matched []Rule
+	for _, rule := range e.rules {
+		if rule.matches(req) {
+			matched = append(matched, rule)
+		}
+	}
+
+	if len(matched) == 0 {
+		reason := fmt.Sprintf("no rule allows %s", req.Action)
+		if req.Owner != "" && req.Owner != req.Principal.Subject {
+			reason += " on resources of other subjects"
+		}
+		if len(req.Principal.Roles) > 0 {
+			reason += " for roles " + strings.Join(req.Principal.Roles, ", ")
+		}
+		return Decision{Reasons: []string{reason}}
+	}
+
+	var decision Decision
+	for _, field := range req.Fields {
+		allowed := false
+		for _, rule := range matched {
+			if rule.allowsField(field) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("field %s is not allowed to be changed", field))
+		}
+	}
+	if len(decision.Reasons) > 0 {
+		return decision
+	}
+
+	decision.Allowed = true
+	for _, rule := range matched {
+		decision.Rules = append(decision.Rules, rule.Name)
+	}
+	sort.Strings(decision.Rules)
+	return decision
+}
+
+func (r Rule) matches(req Request) bool {
+	return r.allowsAction(req.Action) && r.grantedTo(req.Principal) &&
+		(!r.Owner || (req.Owner != "" && req.Owner == req.Principal.Subject))
+}
+
+func (r Rule) allowsAction(action string) bool {
+	for _, a := range r.Actions {
+		if a == anyAction || a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) grantedTo(principal auth.Principal) bool {
+	if len(r.Roles) == 0 {
+		return true
+	}
+	for _, role := range r.Roles {
+		if principal.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) allowsField(field string) bool {
+	if len(r.Fields) == 0 {
+		return true
+	}
+	for _, f := range r.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+)
+
+func TestDefault(t *testing.T) {
+	engine, err := Parse([]byte(Default))
+	require.NoError(t, err)
+
+	self := auth.Principal{Subject: "1"}
+	support := auth.Principal{Subject: "2", Roles: []string{"support"}}
+	admin := auth.Principal{Subject: "3", Roles: []string{"admin"}}
+
+	for name, tc := range map[string]struct {
+		req     Request
+		allowed bool
+	}{
+		"self read":               {req: Request{Principal: self, Action: ActionUsersGet, Owner: "1"}, allowed: true},
+		"self update":             {req: Request{Principal: self, Action: ActionUsersUpdate, Owner: "1", Fields: []string{"email"}}, allowed: true},
+		"read of other":           {req: Request{Principal: self, Action: ActionUsersGet, Owner: "9"}},
+		"self delete":             {req: Request{Principal: self, Action: ActionUsersDelete, Owner: "1"}},
+		"support read":            {req: Request{Principal: support, Action: ActionUsersGet, Owner: "1"}, allowed: true},
+		"support update nickname": {req: Request{Principal: support, Action: ActionUsersUpdate, Owner: "1", Fields: []string{"nickname"}}, allowed: true},
+		"support update email":    {req: Request{Principal: support, Action: ActionUsersUpdate, Owner: "1", Fields: []string{"nickname", "email"}}},
+		"support delete":          {req: Request{Principal: support, Action: ActionUsersDelete, Owner: "1"}},
+		"admin delete":            {req: Request{Principal: admin, Action: ActionUsersDelete, Owner: "1"}, allowed: true},
+		"admin import":            {req: Request{Principal: admin, Action: ActionUsersImport}, allowed: true},
+		"anonymous create":        {req: Request{Action: ActionUsersCreate}},
+		"self change password":    {req: Request{Principal: self, Action: ActionUsersChangePassword, Owner: "1"}, allowed: true},
+		"self events":             {req: Request{Principal: self, Action: ActionUsersEvents, Owner: "1"}, allowed: true},
+		"events of other":         {req: Request{Principal: self, Action: ActionUsersEvents, Owner: "9"}},
+		"support all events":      {req: Request{Principal: support, Action: ActionUsersEvents}},
+		"admin all events":        {req: Request{Principal: admin, Action: ActionUsersEvents}, allowed: true},
+		"support change password": {req: Request{Principal: support, Action: ActionUsersChangePassword, Owner: "1"}},
+	} {
+		decision := engine.Evaluate(tc.req)
+		require.Equal(t, tc.allowed, decision.Allowed, name)
+		if tc.allowed {
+			require.NotEmpty(t, decision.Rules, name)
+		} else {
+			require.NotEmpty(t, decision.Reasons, name)
+		}
+	}
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	engine, err := NewEngine([]Rule{
+		{Name: "owner", Actions: []string{ActionUsersUpdate}, Owner: true, Fields: []string{"first_name"}},
+		{Name: "editor", Actions: []string{ActionUsersUpdate}, Roles: []string{"editor"}, Fields: []string{"last_name"}},
+	})
+	require.NoError(t, err)
+
+	principal := auth.Principal{Subject: "1", Roles: []string{"editor"}}
+	decision := engine.Evaluate(Request{Principal: principal, Action: ActionUsersUpdate, Owner: "1", Fields: []string{"first_name", "last_name"}})
+	require.Equal(t, Decision{Allowed: true, Rules: []string{"editor", "owner"}}, decision, "fields are allowed by different rules")
+
+	decision = engine.Evaluate(Request{Principal: principal, Action: ActionUsersUpdate, Owner: "2", Fields: []string{"first_name", "last_name"}})
+	require.Equal(t, Decision{Reasons: []string{"field first_name is not allowed to be changed"}}, decision)
+
+	decision = engine.Evaluate(Request{Principal: auth.Principal{Subject: "1"}, Action: ActionUsersDelete, Owner: "2"})
+	require.Equal(t, Decision{Reasons: []string{"no rule allows users.delete on resources of other subjects"}}, decision)
+}
+
+func TestParse(t *testing.T) {
+	for name, policy := range map[string]string{
+		"no name":        "rules: [{actions: [users.get]}]",
+		"no actions":     "rules: [{name: read}]",
+		"duplicate name": "rules: [{name: read, actions: [users.get]}, {name: read, actions: [users.update]}]",
+		"unknown field":  "rules: [{name: read, actions: [users.get], role: [admin]}]",
+		"malformed":      "rules: {",
+	} {
+		_, err := Parse([]byte(policy))
+		require.Error(t, err, name)
+	}
+}
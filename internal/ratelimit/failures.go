@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// FailurePolicy defines how the attempts are slowed down after failures.
+type FailurePolicy struct {
+	// Threshold is a number of failures allowed without any delay.
+	Threshold int
+	// Delay is required before the next attempt after the first failure above the threshold,
+	// it is doubled with each consecutive failure up to MaxDelay.
+	Delay    time.Duration
+	MaxDelay time.Duration
+	// MaxFailures is a number of failures that locks the key for Lockout.
+	// Failures are forgotten after Lockout since the last one.
+	MaxFailures int
+	Lockout     time.Duration
+}
+
+// NewFailureLimiter returns a limiter of the failed attempts with the state kept in `store`.
+func NewFailureLimiter(store Store, policy FailurePolicy) *FailureLimiter {
+	return &FailureLimiter{store: store, policy: policy, now: time.Now}
+}
+
+// FailureLimiter protects from brute-force attacks by progressive delays between the failed attempts
+// made with the same key and a temporary lockout of the key.
+type FailureLimiter struct {
+	store  Store
+	policy FailurePolicy
+	now    func() time.Time
+}
+
+// Attempt decides if the attempt is allowed and registers the allowed one as failed in the same update of the store,
+// so the concurrent attempts can't all pass before any of them fails. It returns a time until the next attempt
+// is allowed, it is zero if the attempt is allowed now. The second result is true if the key is locked.
+// The attempt is expected to be forgotten with Reset if it succeeds or with Release if it ends for another reason.
+func (fl *FailureLimiter) Attempt(ctx context.Context, key string) (time.Duration, bool, error) {
+	var wait time.Duration
+	var locked bool
+	now := fl.now()
+	err := fl.store.Update(ctx, "failures:"+key, now, fl.policy.Lockout, func(failures float64, at time.Time) (float64, time.Time) {
+		failures, at = fl.forget(failures, at, now)
+		if wait, locked = fl.wait(int(failures), at, now); wait > 0 {
+			return failures, at
+		}
+		return failures + 1, now
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("register attempt: %w", err)
+	}
+	return wait, locked, nil
+}
+
+// Release forgets the attempt registered by Attempt that neither succeeded nor failed,
+// e.g. when the credentials couldn't be verified. The delay is still counted from the time of the attempt.
+func (fl *FailureLimiter) Release(ctx context.Context, key string) error {
+	now := fl.now()
+	err := fl.store.Update(ctx, "failures:"+key, now, fl.policy.Lockout, func(failures float64, at time.Time) (float64, time.Time) {
+		failures, at = fl.forget(failures, at, now)
+		if failures <= 1 {
+			return 0, time.Time{}
+		}
+		return failures - 1, at
+	})
+	if err != nil {
+		return fmt.Errorf("release attempt: %w", err)
+	}
+	return nil
+}
+
+// Reset forgets the failures of the key, including the attempt in progress,
+// it is expected to be called after the successful attempt.
+func (fl *FailureLimiter) Reset(ctx context.Context, key string) error {
+	err := fl.store.Update(ctx, "failures:"+key, fl.now(), 0, func(float64, time.Time) (float64, time.Time) {
+		return 0, time.Time{}
+	})
+	if err != nil {
+		return fmt.Errorf("reset failures: %w", err)
+	}
+	return nil
+}
+
+// forget returns zero state if the last failure happened more than Lockout ago.
+func (fl *FailureLimiter) forget(failures float64, at, now time.Time) (float64, time.Time) {
+	if at.IsZero() || now.Sub(at) >= fl.policy.Lockout {
+		return 0, time.Time{}
+	}
+	return failures, at
+}
+
+// wait returns a time until the next attempt is allowed after `failures` with the last one made `at`.
+func (fl *FailureLimiter) wait(failures int, at, now time.Time) (time.Duration, bool) {
+	if failures >= fl.policy.MaxFailures {
+		return at.Add(fl.policy.Lockout).Sub(now), true
+	}
+	if failures <= fl.policy.Threshold {
+		return 0, false
+	}
+
+	delay := fl.policy.Delay
+	for i := fl.policy.Threshold + 1; i < failures && delay < fl.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > fl.policy.MaxDelay {
+		delay = fl.policy.MaxDelay
+	}
+
+	if wait := at.Add(delay).Sub(now); wait > 0 {
+		return wait, false
+	}
+	return 0, false
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired states are removed from the memory.
+const sweepInterval = time.Minute
+
+// NewMemoryStore returns a store that keeps the state in the memory of the process,
+// so each instance of the service limits the requests on its own.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: map[string]memoryState{}}
+}
+
+// MemoryStore keeps the state of the limiters in the memory.
+type MemoryStore struct {
+	mu      sync.Mutex
+	states  map[string]memoryState
+	sweptAt time.Time
+}
+
+type memoryState struct {
+	value     float64
+	at        time.Time
+	expiresAt time.Time
+}
+
+// Update atomically replaces the state of the key with the one returned by `fn`.
+func (ms *MemoryStore) Update(_ context.Context, key string, now time.Time, ttl time.Duration, fn func(value float64, at time.Time) (float64, time.Time)) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if now.Sub(ms.sweptAt) >= sweepInterval {
+		ms.sweep(now)
+	}
+
+	state, ok := ms.states[key]
+	if !ok || !now.Before(state.expiresAt) {
+		state = memoryState{}
+	}
+
+	value, at := fn(state.value, state.at)
+	ms.states[key] = memoryState{value: value, at: at, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// sweep removes expired states, so the memory is not exhausted by the keys that are not used anymore.
+func (ms *MemoryStore) sweep(now time.Time) {
+	for key, state := range ms.states {
+		if !now.Before(state.expiresAt) {
+			delete(ms.states, key)
+		}
+	}
+	ms.sweptAt = now
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store keeps state of the limiters. The state of each key is a value and a time it was updated at.
+type Store interface {
+	// Update atomically replaces the state of the key with the one returned by `fn`.
+	// Zero state is passed to `fn` if the key doesn't exist or its state expired.
+	// The new state expires in `ttl` from `now`.
+	Update(ctx context.Context, key string, now time.Time, ttl time.Duration, fn func(value float64, at time.Time) (float64, time.Time)) error
+}
+
+// TokenStore is a Store that takes the tokens from the buckets itself, so the bucket is updated in a single
+// round trip to the remote store. Limiter uses it instead of Store.Update if the store implements it.
+type TokenStore interface {
+	// Take refills the bucket of the key with `limit` for the time passed since its last update and takes a token
+	// from it if there is one. It returns the tokens left in the bucket and if the token was taken.
+	// The bucket expires in `ttl` from `now`, it is full after that.
+	Take(ctx context.Context, key string, now time.Time, ttl time.Duration, limit Limit) (tokens float64, taken bool, err error)
+}
+
+// Refill returns the tokens in the bucket that had `tokens` at `at` and is refilled with `limit` till `now`.
+// The bucket is full if `at` is zero.
+func Refill(tokens float64, at, now time.Time, limit Limit) float64 {
+	if at.IsZero() {
+		return float64(limit.Burst)
+	}
+	return math.Min(float64(limit.Burst), tokens+now.Sub(at).Seconds()*limit.Rate)
+}
+
+// Limit is a rate of the requests: `Burst` requests are allowed at once,
+// after that the requests are allowed with `Rate` per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Every returns a limit that allows `n` requests per `period`, all of them could be made at once.
+func Every(period time.Duration, n int) Limit {
+	return Limit{Rate: float64(n) / period.Seconds(), Burst: n}
+}
+
+// Result is a decision on the request.
+type Result struct {
+	Allowed bool
+	// Limit is a max number of requests allowed at once.
+	Limit int
+	// Remaining is a number of requests allowed at the moment.
+	Remaining int
+	// RetryAfter is a time until the next request is allowed, it is zero if the request is allowed.
+	RetryAfter time.Duration
+	// Reset is a time until the limit is fully restored.
+	Reset time.Duration
+}
+
+// NewLimiter returns a token bucket limiter with the state kept in `store`.
+func NewLimiter(store Store, limit Limit) *Limiter {
+	return &Limiter{store: store, limit: limit, now: time.Now}
+}
+
+// Limiter limits rate of the requests made with the same key.
+// Each key has a bucket of `Burst` tokens refilled with `Rate` tokens per second, each request takes a token.
+type Limiter struct {
+	store Store
+	now   func() time.Time
+
+	mu    sync.RWMutex
+	limit Limit
+}
+
+// SetLimit replaces the limit, the state of the buckets is kept.
+func (l *Limiter) SetLimit(limit Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = limit
+}
+
+// Allow takes a token from the bucket of the key and returns the decision on the request.
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	l.mu.RLock()
+	limit := l.limit
+	l.mu.RUnlock()
+
+	burst := float64(limit.Burst)
+	// the bucket is full after this period, it is the same as a new one
+	ttl := time.Duration(burst / limit.Rate * float64(time.Second))
+
+	var tokens float64
+	var allowed bool
+	var err error
+	now := l.now()
+	if ts, ok := l.store.(TokenStore); ok {
+		tokens, allowed, err = ts.Take(ctx, key, now, ttl, limit)
+	} else {
+		err = l.store.Update(ctx, key, now, ttl, func(value float64, at time.Time) (float64, time.Time) {
+			tokens = Refill(value, at, now, limit)
+			if allowed = tokens >= 1; allowed {
+				tokens--
+			}
+			return tokens, now
+		})
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("take token: %w", err)
+	}
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(tokens),
+		Reset:     limit.duration(burst - tokens),
+	}
+	if !allowed {
+		res.RetryAfter = limit.duration(1 - tokens)
+	}
+	return res, nil
+}
+
+// duration returns a time required to refill `tokens`.
+func (l Limit) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
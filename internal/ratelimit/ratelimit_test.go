@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(NewMemoryStore(), Every(time.Minute, 2))
+	limiter.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	res, err := limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 30 * time.Second}, res)
+
+	res, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: time.Minute}, res)
+
+	res, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, Result{Allowed: false, Limit: 2, Remaining: 0, RetryAfter: 30 * time.Second, Reset: time.Minute}, res)
+
+	res, err = limiter.Allow(ctx, "b")
+	require.NoError(t, err)
+	require.True(t, res.Allowed, "keys are limited independently")
+
+	now = now.Add(15 * time.Second)
+	res, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, res.Allowed, "denied requests don't take tokens")
+	require.Equal(t, 15*time.Second, res.RetryAfter)
+
+	now = now.Add(15 * time.Second)
+	res, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, res.Allowed, "token is refilled")
+
+	now = now.Add(time.Hour)
+	res, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Remaining, "refill doesn't exceed the burst")
+
+	limiter.SetLimit(Every(time.Minute, 4))
+	res, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, Result{Allowed: true, Limit: 4, Remaining: 0, Reset: time.Minute}, res, "bucket is kept with the new limit")
+}
+
+type tokenStore struct {
+	*MemoryStore
+	limits []Limit
+}
+
+func (ts *tokenStore) Take(_ context.Context, _ string, _ time.Time, _ time.Duration, limit Limit) (float64, bool, error) {
+	ts.limits = append(ts.limits, limit)
+	return 0.5, false, nil
+}
+
+func TestLimiter_Allow_TokenStore(t *testing.T) {
+	store := &tokenStore{MemoryStore: NewMemoryStore()}
+	limiter := NewLimiter(store, Every(time.Minute, 2))
+
+	res, err := limiter.Allow(context.Background(), "a")
+	require.NoError(t, err)
+	require.Equal(t, Result{Allowed: false, Limit: 2, Remaining: 0, RetryAfter: 15 * time.Second, Reset: 45 * time.Second}, res)
+	require.Equal(t, []Limit{Every(time.Minute, 2)}, store.limits, "tokens are taken by the store")
+}
+
+func TestFailureLimiter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewFailureLimiter(NewMemoryStore(), FailurePolicy{
+		Threshold:   1,
+		Delay:       time.Second,
+		MaxDelay:    3 * time.Second,
+		MaxFailures: 5,
+		Lockout:     time.Minute,
+	})
+	limiter.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	attempt := func() (time.Duration, bool) {
+		wait, locked, err := limiter.Attempt(ctx, "john")
+		require.NoError(t, err)
+		return wait, locked
+	}
+
+	wait, locked := attempt()
+	require.Zero(t, wait, "failures under the threshold are not delayed")
+	require.False(t, locked)
+
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		wait, _ = attempt()
+		require.Zero(t, wait)
+		wait, _ = attempt()
+		require.Equal(t, expected, wait, "delay is doubled up to the max")
+		wait, _ = attempt()
+		require.Equal(t, expected, wait, "rejected attempts are not counted")
+		now = now.Add(wait)
+	}
+
+	wait, locked = attempt()
+	require.Zero(t, wait)
+	require.False(t, locked)
+
+	wait, locked = attempt()
+	require.Equal(t, time.Minute, wait)
+	require.True(t, locked)
+
+	now = now.Add(30 * time.Second)
+	wait, locked = attempt()
+	require.Equal(t, 30*time.Second, wait)
+	require.True(t, locked)
+
+	now = now.Add(30 * time.Second)
+	wait, locked = attempt()
+	require.Zero(t, wait, "failures are forgotten after the lockout")
+	require.False(t, locked)
+
+	attempt()
+	require.NoError(t, limiter.Release(ctx, "john"))
+	wait, _ = attempt()
+	require.Zero(t, wait, "released attempts are not counted")
+	wait, _ = attempt()
+	require.Equal(t, time.Second, wait)
+
+	require.NoError(t, limiter.Reset(ctx, "john"))
+	wait, _ = attempt()
+	require.Zero(t, wait, "failures are forgotten after reset")
+}
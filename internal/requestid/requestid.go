@@ -0,0 +1,58 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// Header is a name of the HTTP header the request ID is passed with.
+const Header = "X-Request-ID"
+
+// maxLen limits length of the request ID received from the client.
+const maxLen = 128
+
+type ctxKey struct{}
+
+// FromContext extracts request ID from the context, it returns an empty string if there is no ID.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// ToContext injects request ID into the context.
+func ToContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// New returns a new random (version 4) UUID.
+func New() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// the system source of randomness is broken, nothing could be done about it
+		panic(err)
+	}
+
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Valid reports if the request ID received from the client could be used as is.
+// Only letters, digits and '-', '_', '.', ':' are allowed, so the ID is safe to be placed
+// into logs, headers and comments of SQL statements.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,33 @@
+package requestid
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	id := New()
+	require.Regexp(t, uuid, id)
+	require.True(t, Valid(id))
+	require.NotEqual(t, id, New())
+}
+
+func TestValid(t *testing.T) {
+	require.True(t, Valid("req:1_2.3-4"))
+	require.True(t, Valid(strings.Repeat("a", 128)))
+
+	require.False(t, Valid(""))
+	require.False(t, Valid(strings.Repeat("a", 129)))
+	require.False(t, Valid("id */ DROP TABLE users; /*"))
+	require.False(t, Valid("id\nnext"))
+}
+
+func TestContext(t *testing.T) {
+	require.Empty(t, FromContext(context.Background()))
+	require.Equal(t, "id", FromContext(ToContext(context.Background(), "id")))
+}
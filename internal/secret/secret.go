@@ -0,0 +1,118 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// FileScheme prefixes a reference to the file with the secret, e.g. 'file:///run/secrets/db-password'.
+	FileScheme = "file://"
+	// ProviderScheme prefixes a reference to the secret resolved by the Provider, e.g. 'secret://db-password'.
+	ProviderScheme = "secret://"
+)
+
+// ErrNoProvider is returned for references to the provider's secrets if there is no provider.
+var ErrNoProvider = errors.New("no secret provider")
+
+// Provider resolves secrets by their names, e.g. reads them from the secret manager.
+type Provider interface {
+	// Secret returns a current value of the secret.
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// NewDirProvider returns a provider of the secrets mounted as files into the `dir`,
+// the name of the secret is the name of the file.
+func NewDirProvider(dir string) Provider {
+	return dirProvider(dir)
+}
+
+type dirProvider string
+
+func (dp dirProvider) Secret(_ context.Context, name string) (string, error) {
+	if name == "" || name != filepath.Base(name) {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+	return readFile(filepath.Join(string(dp), name))
+}
+
+// IsReference returns true if the `value` is a reference to the secret rather than the secret itself.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, FileScheme) || strings.HasPrefix(value, ProviderScheme)
+}
+
+// Resolve returns the secret the `ref` refers to. The `ref` is returned as is if it is not a reference.
+func Resolve(ctx context.Context, ref string, provider Provider) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, FileScheme):
+		return readFile(strings.TrimPrefix(ref, FileScheme))
+	case strings.HasPrefix(ref, ProviderScheme):
+		if provider == nil {
+			return "", ErrNoProvider
+		}
+		name := strings.TrimPrefix(ref, ProviderScheme)
+		value, err := provider.Secret(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("secret %q: %w", name, err)
+		}
+		return value, nil
+	default:
+		return ref, nil
+	}
+}
+
+// readFile returns content of the file without trailing line breaks added by editors and `echo`.
+func readFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// NewValue returns a secret with the `initial` value that is re-read with `load` on refresh.
+// The value never changes if `load` is nil.
+func NewValue(initial string, load func(ctx context.Context) (string, error)) *Value {
+	return &Value{value: initial, load: load}
+}
+
+// Value is a secret that could be rotated. It is safe for concurrent use.
+type Value struct {
+	load func(ctx context.Context) (string, error)
+
+	mu    sync.RWMutex
+	value string
+}
+
+// Get returns the current value of the secret.
+func (v *Value) Get() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.value
+}
+
+// Refresh re-reads the secret and returns true if its value has changed.
+// The current value is kept if the secret can't be read.
+func (v *Value) Refresh(ctx context.Context) (bool, error) {
+	if v.load == nil {
+		return false, nil
+	}
+
+	value, err := v.load(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	changed := v.value != value
+	v.value = value
+	return changed, nil
+}
@@ -0,0 +1,59 @@
+package secret
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "db-password"), []byte("s3cr3t\n"), 0600))
+	provider := NewDirProvider(dir)
+
+	for ref, exp := range map[string]string{
+		"plain":                "plain",
+		"secret://db-password": "s3cr3t",
+		FileScheme + filepath.Join(dir, "db-password"): "s3cr3t",
+	} {
+		value, err := Resolve(context.Background(), ref, provider)
+		require.NoError(t, err, ref)
+		require.Equal(t, exp, value, ref)
+	}
+
+	for _, ref := range []string{"secret://missing", "secret://../db-password", "secret://", FileScheme + filepath.Join(dir, "missing")} {
+		_, err := Resolve(context.Background(), ref, provider)
+		require.Error(t, err, ref)
+	}
+
+	_, err = Resolve(context.Background(), "secret://db-password", nil)
+	require.Equal(t, ErrNoProvider, err)
+}
+
+func TestValue_Refresh(t *testing.T) {
+	current := "old"
+	v := NewValue("old", func(context.Context) (string, error) { return current, nil })
+
+	changed, err := v.Refresh(context.Background())
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	current = "new"
+	changed, err = v.Refresh(context.Background())
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "new", v.Get())
+
+	static := NewValue("static", nil)
+	changed, err = static.Refresh(context.Background())
+	require.NoError(t, err)
+	require.False(t, changed)
+	require.Equal(t, "static", static.Get())
+}
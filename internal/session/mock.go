@@ -0,0 +1,191 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: service.go
+
+// Package session is a generated GoMock package.
+package session
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+
+	storage "github.com/pavelmemory/faceit-users/internal/storage"
+)
+
+// MockStorage is a mock of Storage interface
+type MockStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStorageMockRecorder
+}
+
+// MockStorageMockRecorder is the mock recorder for MockStorage
+type MockStorageMockRecorder struct {
+	mock *MockStorage
+}
+
+// NewMockStorage creates a new mock instance
+func NewMockStorage(ctrl *gomock.Controller) *MockStorage {
+	mock := &MockStorage{ctrl: ctrl}
+	mock.recorder = &MockStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
+	return m.recorder
+}
+
+// WithTx mocks base method
+func (m *MockStorage) WithTx(arg0 context.Context, arg1 func(storage.Runner) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx
+func (mr *MockStorageMockRecorder) WithTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockStorage)(nil).WithTx), arg0, arg1)
+}
+
+// WithoutTx mocks base method
+func (m *MockStorage) WithoutTx(arg0 context.Context, arg1 func(storage.Runner) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithoutTx", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithoutTx indicates an expected call of WithoutTx
+func (mr *MockStorageMockRecorder) WithoutTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithoutTx", reflect.TypeOf((*MockStorage)(nil).WithoutTx), arg0, arg1)
+}
+
+// VerifyCredentials mocks base method
+func (m *MockStorage) VerifyCredentials(ctx context.Context, run storage.Runner, email, password string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyCredentials", ctx, run, email, password)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyCredentials indicates an expected call of VerifyCredentials
+func (mr *MockStorageMockRecorder) VerifyCredentials(ctx, run, email, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyCredentials", reflect.TypeOf((*MockStorage)(nil).VerifyCredentials), ctx, run, email, password)
+}
+
+// PersistRefreshToken mocks base method
+func (m *MockStorage) PersistRefreshToken(ctx context.Context, run storage.Runner, token storage.RefreshToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PersistRefreshToken", ctx, run, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PersistRefreshToken indicates an expected call of PersistRefreshToken
+func (mr *MockStorageMockRecorder) PersistRefreshToken(ctx, run, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PersistRefreshToken", reflect.TypeOf((*MockStorage)(nil).PersistRefreshToken), ctx, run, token)
+}
+
+// RetrieveRefreshToken mocks base method
+func (m *MockStorage) RetrieveRefreshToken(ctx context.Context, run storage.Runner, hash string, forUpdate bool) (storage.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetrieveRefreshToken", ctx, run, hash, forUpdate)
+	ret0, _ := ret[0].(storage.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RetrieveRefreshToken indicates an expected call of RetrieveRefreshToken
+func (mr *MockStorageMockRecorder) RetrieveRefreshToken(ctx, run, hash, forUpdate interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetrieveRefreshToken", reflect.TypeOf((*MockStorage)(nil).RetrieveRefreshToken), ctx, run, hash, forUpdate)
+}
+
+// UseRefreshToken mocks base method
+func (m *MockStorage) UseRefreshToken(ctx context.Context, run storage.Runner, hash string, usedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRefreshToken", ctx, run, hash, usedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRefreshToken indicates an expected call of UseRefreshToken
+func (mr *MockStorageMockRecorder) UseRefreshToken(ctx, run, hash, usedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRefreshToken", reflect.TypeOf((*MockStorage)(nil).UseRefreshToken), ctx, run, hash, usedAt)
+}
+
+// RevokeRefreshTokens mocks base method
+func (m *MockStorage) RevokeRefreshTokens(ctx context.Context, run storage.Runner, familyID string, revokedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshTokens", ctx, run, familyID, revokedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRefreshTokens indicates an expected call of RevokeRefreshTokens
+func (mr *MockStorageMockRecorder) RevokeRefreshTokens(ctx, run, familyID, revokedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshTokens", reflect.TypeOf((*MockStorage)(nil).RevokeRefreshTokens), ctx, run, familyID, revokedAt)
+}
+
+// PurgeRefreshTokens mocks base method
+func (m *MockStorage) PurgeRefreshTokens(ctx context.Context, run storage.Runner, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeRefreshTokens", ctx, run, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeRefreshTokens indicates an expected call of PurgeRefreshTokens
+func (mr *MockStorageMockRecorder) PurgeRefreshTokens(ctx, run, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeRefreshTokens", reflect.TypeOf((*MockStorage)(nil).PurgeRefreshTokens), ctx, run, before)
+}
+
+// MockSigner is a mock of Signer interface
+type MockSigner struct {
+	ctrl     *gomock.Controller
+	recorder *MockSignerMockRecorder
+}
+
+// MockSignerMockRecorder is the mock recorder for MockSigner
+type MockSignerMockRecorder struct {
+	mock *MockSigner
+}
+
+// NewMockSigner creates a new mock instance
+func NewMockSigner(ctrl *gomock.Controller) *MockSigner {
+	mock := &MockSigner{ctrl: ctrl}
+	mock.recorder = &MockSignerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSigner) EXPECT() *MockSignerMockRecorder {
+	return m.recorder
+}
+
+// Sign mocks base method
+func (m *MockSigner) Sign(claims map[string]interface{}) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sign", claims)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Sign indicates an expected call of Sign
+func (mr *MockSignerMockRecorder) Sign(claims interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sign", reflect.TypeOf((*MockSigner)(nil).Sign), claims)
+}
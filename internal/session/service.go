@@ -0,0 +1,272 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/storage"
+)
+
+//go:generate mockgen -source=service.go -destination mock.go -package session Storage,Signer
+
+var (
+	// ErrInvalidCredentials is returned if there is no user with the email and the password.
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidToken is returned if the refresh token is unknown, expired or revoked.
+	ErrInvalidToken = errors.New("invalid refresh token")
+	// ErrTokenReused is returned if the refresh token was already exchanged for a new one.
+	// It means the token could be stolen, so the whole session is revoked.
+	ErrTokenReused = errors.New("refresh token reused, session revoked")
+)
+
+// Storage is a persistence storage for the sessions.
+type Storage interface {
+	// WithTx executes provided callback inside of the transaction.
+	WithTx(context.Context, func(runner storage.Runner) error) error
+	// WithoutTx executes provided callback without explicitly open transaction.
+	WithoutTx(context.Context, func(runner storage.Runner) error) error
+	// VerifyCredentials returns ID of the user with the email and the password.
+	VerifyCredentials(ctx context.Context, run storage.Runner, email, password string) (string, error)
+	// PersistRefreshToken saves the token.
+	PersistRefreshToken(ctx context.Context, run storage.Runner, token storage.RefreshToken) error
+	// RetrieveRefreshToken returns the token by its hash.
+	RetrieveRefreshToken(ctx context.Context, run storage.Runner, hash string, forUpdate bool) (storage.RefreshToken, error)
+	// UseRefreshToken marks the token as exchanged for a new one.
+	UseRefreshToken(ctx context.Context, run storage.Runner, hash string, usedAt time.Time) error
+	// RevokeRefreshTokens revokes all tokens of the family.
+	RevokeRefreshTokens(ctx context.Context, run storage.Runner, familyID string, revokedAt time.Time) error
+	// PurgeRefreshTokens removes the tokens expired before `before`.
+	PurgeRefreshTokens(ctx context.Context, run storage.Runner, before time.Time) (int64, error)
+}
+
+// Signer issues signed access tokens.
+type Signer interface {
+	// Sign returns a token with the claims.
+	Sign(claims map[string]interface{}) (string, error)
+}
+
+// Tokens are issued for the session.
+type Tokens struct {
+	// AccessToken is a short-lived JSON Web Token to authenticate requests.
+	AccessToken     string
+	AccessExpiresAt time.Time
+	// RefreshToken is a long-lived opaque token to get new tokens, it could be used only once.
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// Option allows to customize issued tokens.
+type Option func(s *Service)
+
+// WithTTL sets lifetime of the access and refresh tokens.
+func WithTTL(access, refresh time.Duration) Option {
+	return func(s *Service) {
+		s.accessTTL, s.refreshTTL = access, refresh
+	}
+}
+
+// WithIssuer sets 'iss' and 'aud' claims of the access tokens if they are not empty.
+func WithIssuer(issuer, audience string) Option {
+	return func(s *Service) {
+		s.issuer, s.audience = issuer, audience
+	}
+}
+
+// NewService returns a service of the user sessions.
+func NewService(storage Storage, signer Signer, options ...Option) *Service {
+	s := &Service{
+		storage:    storage,
+		signer:     signer,
+		accessTTL:  15 * time.Minute,
+		refreshTTL: 30 * 24 * time.Hour,
+		now:        time.Now,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Service starts, prolongs and ends sessions of the users.
+// Each session is a family of refresh tokens: a token is replaced with a new one on each refresh.
+type Service struct {
+	storage    Storage
+	signer     Signer
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	issuer     string
+	audience   string
+	now        func() time.Time
+}
+
+// Login verifies credentials of the user and starts a new session.
+func (s *Service) Login(ctx context.Context, email, password string) (Tokens, error) {
+	var tokens Tokens
+	err := s.storage.WithTx(ctx, func(run storage.Runner) error {
+		userID, err := s.storage.VerifyCredentials(ctx, run, email, password)
+		if err != nil {
+			if errors.Is(err, internal.ErrNotFound) {
+				return ErrInvalidCredentials
+			}
+			return err
+		}
+
+		familyID, err := randomHex(16)
+		if err != nil {
+			return err
+		}
+
+		tokens, err = s.issue(ctx, run, userID, familyID)
+		return err
+	})
+	if err != nil {
+		return Tokens{}, fmt.Errorf("login: %w", err)
+	}
+	return tokens, nil
+}
+
+// Refresh exchanges the refresh token for new tokens of the same session.
+// Reuse of the already exchanged token revokes the session.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (Tokens, error) {
+	hash := hashToken(refreshToken)
+
+	var tokens Tokens
+	// the outcome is returned after the transaction is committed, so the revocation of the session is not rolled back
+	var outcome error
+	err := s.storage.WithTx(ctx, func(run storage.Runner) error {
+		token, err := s.storage.RetrieveRefreshToken(ctx, run, hash, true)
+		if err != nil {
+			if errors.Is(err, internal.ErrNotFound) {
+				outcome = ErrInvalidToken
+				return nil
+			}
+			return err
+		}
+
+		now := s.now().UTC()
+		switch {
+		case !token.RevokedAt.IsZero(), !now.Before(token.ExpiresAt):
+			outcome = ErrInvalidToken
+			return nil
+		case !token.UsedAt.IsZero():
+			outcome = ErrTokenReused
+			return s.storage.RevokeRefreshTokens(ctx, run, token.FamilyID, now)
+		}
+
+		if err := s.storage.UseRefreshToken(ctx, run, hash, now); err != nil {
+			return err
+		}
+
+		tokens, err = s.issue(ctx, run, token.UserID, token.FamilyID)
+		return err
+	})
+	if err == nil {
+		err = outcome
+	}
+	if err != nil {
+		return Tokens{}, fmt.Errorf("refresh: %w", err)
+	}
+	return tokens, nil
+}
+
+// Logout revokes the session the refresh token belongs to.
+// Already issued access tokens stay valid until they expire.
+func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+	err := s.storage.WithTx(ctx, func(run storage.Runner) error {
+		token, err := s.storage.RetrieveRefreshToken(ctx, run, hashToken(refreshToken), false)
+		if err != nil {
+			if errors.Is(err, internal.ErrNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+
+		return s.storage.RevokeRefreshTokens(ctx, run, token.FamilyID, s.now().UTC())
+	})
+	if err != nil {
+		return fmt.Errorf("logout: %w", err)
+	}
+	return nil
+}
+
+// Purge removes expired refresh tokens and returns their number.
+func (s *Service) Purge(ctx context.Context) (int64, error) {
+	var purged int64
+	err := s.storage.WithoutTx(ctx, func(run storage.Runner) (err error) {
+		purged, err = s.storage.PurgeRefreshTokens(ctx, run, s.now().UTC())
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("purge refresh tokens: %w", err)
+	}
+	return purged, nil
+}
+
+// issue returns new access and refresh tokens of the session.
+func (s *Service) issue(ctx context.Context, run storage.Runner, userID, familyID string) (Tokens, error) {
+	now := s.now().UTC()
+	tokens := Tokens{AccessExpiresAt: now.Add(s.accessTTL), RefreshExpiresAt: now.Add(s.refreshTTL)}
+
+	claims := map[string]interface{}{
+		"sub": userID,
+		"sid": familyID,
+		"iat": now.Unix(),
+		"exp": tokens.AccessExpiresAt.Unix(),
+	}
+	if s.issuer != "" {
+		claims["iss"] = s.issuer
+	}
+	if s.audience != "" {
+		claims["aud"] = s.audience
+	}
+
+	var err error
+	if tokens.AccessToken, err = s.signer.Sign(claims); err != nil {
+		return Tokens{}, fmt.Errorf("sign access token: %w", err)
+	}
+
+	if tokens.RefreshToken, err = randomToken(32); err != nil {
+		return Tokens{}, err
+	}
+
+	err = s.storage.PersistRefreshToken(ctx, run, storage.RefreshToken{
+		Hash:      hashToken(tokens.RefreshToken),
+		FamilyID:  familyID,
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: tokens.RefreshExpiresAt,
+	})
+	if err != nil {
+		return Tokens{}, fmt.Errorf("persist refresh token: %w", err)
+	}
+
+	return tokens, nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomHex(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
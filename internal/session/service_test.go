@@ -0,0 +1,176 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/storage"
+)
+
+func TestService_Login(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("ok", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := NewMockStorage(ctrl)
+		mockStorage.EXPECT().WithTx(gomock.Any(), gomock.Any()).DoAndReturn(inTx)
+		mockStorage.EXPECT().VerifyCredentials(gomock.Any(), gomock.Any(), "john@mail.com", "secret").Return("1", nil)
+
+		var persisted storage.RefreshToken
+		mockStorage.EXPECT().PersistRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ storage.Runner, token storage.RefreshToken) error {
+				persisted = token
+				return nil
+			})
+
+		mockSigner := NewMockSigner(ctrl)
+		mockSigner.EXPECT().Sign(gomock.Any()).DoAndReturn(func(claims map[string]interface{}) (string, error) {
+			require.Equal(t, "1", claims["sub"])
+			require.Equal(t, now.Unix(), claims["iat"])
+			require.Equal(t, now.Add(time.Minute).Unix(), claims["exp"])
+			require.Equal(t, "faceit-users", claims["iss"])
+			require.NotContains(t, claims, "aud")
+			require.Len(t, claims["sid"], 32)
+			return "access", nil
+		})
+
+		srv := NewService(mockStorage, mockSigner, WithTTL(time.Minute, time.Hour), WithIssuer("faceit-users", ""))
+		srv.now = func() time.Time { return now }
+
+		tokens, err := srv.Login(context.Background(), "john@mail.com", "secret")
+		require.NoError(t, err)
+		require.Equal(t, "access", tokens.AccessToken)
+		require.Equal(t, now.Add(time.Minute), tokens.AccessExpiresAt)
+		require.NotEmpty(t, tokens.RefreshToken)
+		require.Equal(t, now.Add(time.Hour), tokens.RefreshExpiresAt)
+
+		require.Equal(t, hashToken(tokens.RefreshToken), persisted.Hash, "only hash of the token is persisted")
+		require.Equal(t, "1", persisted.UserID)
+		require.Len(t, persisted.FamilyID, 32)
+		require.Equal(t, now.Add(time.Hour), persisted.ExpiresAt)
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := NewMockStorage(ctrl)
+		mockStorage.EXPECT().WithTx(gomock.Any(), gomock.Any()).DoAndReturn(inTx)
+		mockStorage.EXPECT().VerifyCredentials(gomock.Any(), gomock.Any(), "john@mail.com", "wrong").Return("", internal.ErrNotFound)
+
+		srv := NewService(mockStorage, NewMockSigner(ctrl))
+		_, err := srv.Login(context.Background(), "john@mail.com", "wrong")
+		require.True(t, errors.Is(err, ErrInvalidCredentials))
+	})
+}
+
+func TestService_Refresh(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := storage.RefreshToken{
+		Hash:      hashToken("refresh"),
+		FamilyID:  "family",
+		UserID:    "1",
+		CreatedAt: now.Add(-time.Hour),
+		ExpiresAt: now.Add(time.Hour),
+	}
+
+	setup := func(t *testing.T, stored storage.RefreshToken, err error) (*Service, *MockStorage, *MockSigner, func()) {
+		ctrl := gomock.NewController(t)
+		mockStorage := NewMockStorage(ctrl)
+		mockStorage.EXPECT().WithTx(gomock.Any(), gomock.Any()).DoAndReturn(inTx)
+		mockStorage.EXPECT().RetrieveRefreshToken(gomock.Any(), gomock.Any(), hashToken("refresh"), true).Return(stored, err)
+
+		mockSigner := NewMockSigner(ctrl)
+		srv := NewService(mockStorage, mockSigner)
+		srv.now = func() time.Time { return now }
+		return srv, mockStorage, mockSigner, ctrl.Finish
+	}
+
+	t.Run("rotate", func(t *testing.T) {
+		srv, mockStorage, mockSigner, finish := setup(t, token, nil)
+		defer finish()
+
+		mockStorage.EXPECT().UseRefreshToken(gomock.Any(), gomock.Any(), token.Hash, now).Return(nil)
+		mockStorage.EXPECT().PersistRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ storage.Runner, rotated storage.RefreshToken) error {
+				require.Equal(t, "family", rotated.FamilyID, "new token belongs to the same session")
+				require.Equal(t, "1", rotated.UserID)
+				require.NotEqual(t, token.Hash, rotated.Hash)
+				return nil
+			})
+		mockSigner.EXPECT().Sign(gomock.Any()).Return("access", nil)
+
+		tokens, err := srv.Refresh(context.Background(), "refresh")
+		require.NoError(t, err)
+		require.Equal(t, "access", tokens.AccessToken)
+		require.NotEqual(t, "refresh", tokens.RefreshToken)
+	})
+
+	t.Run("reuse revokes session", func(t *testing.T) {
+		used := token
+		used.UsedAt = now.Add(-time.Minute)
+		srv, mockStorage, _, finish := setup(t, used, nil)
+		defer finish()
+
+		mockStorage.EXPECT().RevokeRefreshTokens(gomock.Any(), gomock.Any(), "family", now).Return(nil)
+
+		_, err := srv.Refresh(context.Background(), "refresh")
+		require.True(t, errors.Is(err, ErrTokenReused))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		expired := token
+		expired.ExpiresAt = now
+		revoked := token
+		revoked.RevokedAt = now.Add(-time.Minute)
+
+		for name, tc := range map[string]struct {
+			token storage.RefreshToken
+			err   error
+		}{
+			"unknown": {err: internal.ErrNotFound},
+			"expired": {token: expired},
+			"revoked": {token: revoked},
+		} {
+			t.Run(name, func(t *testing.T) {
+				srv, _, _, finish := setup(t, tc.token, tc.err)
+				defer finish()
+
+				_, err := srv.Refresh(context.Background(), "refresh")
+				require.True(t, errors.Is(err, ErrInvalidToken))
+			})
+		}
+	})
+}
+
+func TestService_Logout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	mockStorage.EXPECT().WithTx(gomock.Any(), gomock.Any()).DoAndReturn(inTx).Times(2)
+	gomock.InOrder(
+		mockStorage.EXPECT().RetrieveRefreshToken(gomock.Any(), gomock.Any(), hashToken("refresh"), false).
+			Return(storage.RefreshToken{FamilyID: "family"}, nil),
+		mockStorage.EXPECT().RetrieveRefreshToken(gomock.Any(), gomock.Any(), hashToken("unknown"), false).
+			Return(storage.RefreshToken{}, internal.ErrNotFound),
+	)
+	mockStorage.EXPECT().RevokeRefreshTokens(gomock.Any(), gomock.Any(), "family", gomock.Any()).Return(nil)
+
+	srv := NewService(mockStorage, NewMockSigner(ctrl))
+	require.NoError(t, srv.Logout(context.Background(), "refresh"))
+	require.True(t, errors.Is(srv.Logout(context.Background(), "unknown"), ErrInvalidToken))
+}
+
+// inTx stands in for a transaction of the storage.
+func inTx(_ context.Context, call func(runner storage.Runner) error) error {
+	return call(nil)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+// connector opens connections to PostgreSQL with the current password, so the pool picks up rotated password
+// with the new connections while the established ones keep working.
+type connector struct {
+	host, port string
+	password   *secret.Value
+}
+
+// applicationName is reported to the database as a name of the client application once per connection.
+const applicationName = "faceit-users"
+
+// dsn returns a connection string with the current password.
+func (c connector) dsn() string {
+	// TODO: make more dynamic configuration of the database connection
+	return "host=" + c.host + " port=" + c.port + " user=postgres password=" + quoteDSN(c.password.Get()) +
+		" dbname=postgres sslmode=disable binary_parameters=yes application_name=" + applicationName
+}
+
+// Connect opens a new connection. If the password is rejected it is re-read and the connection is retried once,
+// as the password could be rotated in the database before the periodic refresh.
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connect(ctx)
+	if err == nil || !isInvalidPassword(err) {
+		return conn, err
+	}
+
+	if changed, refreshErr := c.password.Refresh(ctx); refreshErr != nil || !changed {
+		return nil, err
+	}
+	return c.connect(ctx)
+}
+
+func (c connector) connect(ctx context.Context) (driver.Conn, error) {
+	pqConnector, err := pq.NewConnector(c.dsn())
+	if err != nil {
+		return nil, err
+	}
+	return pqConnector.Connect(ctx)
+}
+
+func (c connector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// isInvalidPassword returns true if the error is caused by the password rejected by the database.
+func isInvalidPassword(err error) bool {
+	var pqErr *pq.Error
+	// https://www.postgresql.org/docs/11/errcodes-appendix.html
+	return errors.As(err, &pqErr) && (pqErr.Code == "28P01" || pqErr.Code == "28000")
+}
+
+// quoteDSN quotes the value of the connection string parameter, so it could contain spaces and quotes.
+func quoteDSN(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
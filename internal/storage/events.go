@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// UserEventsChannel is a channel the events about the users are published to, so they reach all instances of the service.
+const UserEventsChannel = "user_events"
+
+// UserEventsHandler reacts on the events published by any of the instances.
+type UserEventsHandler interface {
+	// EventPublished is called with the payload of each published event.
+	EventPublished(payload []byte)
+	// EventsMissed is called when events could be lost because the listener was not connected.
+	EventsMissed()
+}
+
+// PublishUserEvent sends the event to the listeners of all instances, including the current one.
+// The payload must be shorter than 8000 bytes.
+func (p *Postgres) PublishUserEvent(ctx context.Context, payload []byte) error {
+	return p.WithoutTx(ctx, func(run Runner) error {
+		if err := convertError(run.Exec(ctx, `SELECT pg_notify($1, $2)`, UserEventsChannel, string(payload)).Err()); err != nil {
+			return fmt.Errorf("publish user event: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListenUserEvents passes the events published by all instances to the `handler` until the `ctx` is cancelled.
+// The dedicated connection is re-established the same way as by ListenUserChanges.
+func (p *Postgres) ListenUserEvents(ctx context.Context, minReconnect, maxReconnect time.Duration, handler UserEventsHandler, onError func(err error)) {
+	retry(ctx, minReconnect, maxReconnect, func() error {
+		return p.listen(ctx, UserEventsChannel, minReconnect, maxReconnect, handler.EventsMissed, func(n *pq.Notification) {
+			dispatchEvent(n, handler)
+		}, onError)
+	}, onError)
+}
+
+// dispatchEvent passes the published event to the handler.
+func dispatchEvent(n *pq.Notification, handler UserEventsHandler) {
+	if n == nil {
+		// nil notification is sent when the connection is re-established
+		handler.EventsMissed()
+		return
+	}
+	handler.EventPublished([]byte(n.Extra))
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pavelmemory/faceit-users/internal/health"
+)
+
+// SchemaVersion is a version of the latest migration the service depends on.
+// It needs to be increased with each new migration in 'migrations/postgres' directory.
+const SchemaVersion = 7
+
+// poolSaturation is a share of the connections in use the pool is considered saturated after.
+const poolSaturation = 0.9
+
+// HealthChecks returns checks of the database availability, connection pool saturation and schema version.
+func (p *Postgres) HealthChecks() []health.Check {
+	return []health.Check{
+		{
+			Name:     "postgres.ping",
+			Timeout:  time.Second,
+			Critical: true,
+			Func:     p.db.PingContext,
+		},
+		{
+			// saturated pool slows down requests, but doesn't prevent them from being handled
+			Name: "postgres.pool",
+			Func: func(context.Context) error {
+				stats := p.db.Stats()
+				if stats.MaxOpenConnections > 0 && float64(stats.InUse) >= poolSaturation*float64(stats.MaxOpenConnections) {
+					return fmt.Errorf("pool is saturated: %d of %d connections are in use", stats.InUse, stats.MaxOpenConnections)
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "postgres.migrations",
+			Timeout:  time.Second,
+			Critical: true,
+			Func: func(ctx context.Context) error {
+				var version int
+				if err := p.WithoutTx(ctx, func(runner Runner) error {
+					return runner.QuerySingle(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+				}); err != nil {
+					return fmt.Errorf("get schema version: %w", err)
+				}
+
+				if version < SchemaVersion {
+					return fmt.Errorf("schema version %d is older than required %d", version, SchemaVersion)
+				}
+				return nil
+			},
+		},
+	}
+}
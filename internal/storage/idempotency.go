@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IdempotencyRecord is an outcome of the request made with an idempotency key.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	// Status is a response status code, it equals to 0 while the request is in progress.
+	Status int
+	// Location is a value of the 'location' response header.
+	Location string
+	// Token identifies the reservation, it is set only for the record reserved by the call.
+	Token     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// NewIdempotencyKeys returns storage of the idempotency keys backed by PostgreSQL.
+func NewIdempotencyKeys(pg *Postgres) *IdempotencyKeys {
+	return &IdempotencyKeys{pg: pg}
+}
+
+// IdempotencyKeys stores outcomes of the requests made with idempotency keys.
+type IdempotencyKeys struct {
+	pg *Postgres
+}
+
+// reserveAttempts limits the number of times the reservation is retried when the conflicting record is modified concurrently.
+const reserveAttempts = 3
+
+// Reserve creates a record for the key if it doesn't exist yet or already expired.
+// The reservation expires after `lease` unless it is completed, so the key of the request that never completes
+// e.g. because of the crash, could be reserved again.
+// It returns `true` if the key was reserved by this call, then the record holds a token required to complete
+// or release the reservation. Otherwise an existing record is returned without its token.
+func (ik *IdempotencyKeys) Reserve(ctx context.Context, key, fingerprint string, lease time.Duration) (IdempotencyRecord, bool, error) {
+	// the existing record is selected by the same statement, so it can't be released in between,
+	// but the record inserted by the concurrent transaction is not visible to it and the statement is retried
+	const query = `
+		WITH reserved AS (
+			INSERT INTO idempotency_keys(key, fingerprint, token, created_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (key) DO UPDATE
+			SET
+				fingerprint = EXCLUDED.fingerprint,
+				token = EXCLUDED.token,
+				status = NULL,
+				location = NULL,
+				created_at = EXCLUDED.created_at,
+				expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at < EXCLUDED.created_at
+			RETURNING fingerprint, status, location, created_at, expires_at
+		)
+		SELECT true, fingerprint, status, location, created_at, expires_at FROM reserved
+		UNION ALL
+		SELECT false, fingerprint, status, location, created_at, expires_at FROM idempotency_keys
+		WHERE key = $1 AND NOT EXISTS (SELECT 1 FROM reserved)`
+
+	token, err := reservationToken()
+	if err != nil {
+		return IdempotencyRecord{}, false, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+
+	now := time.Now().UTC()
+	rec := IdempotencyRecord{Key: key}
+	var reserved bool
+
+	err = ik.pg.WithoutTx(ctx, func(run Runner) error {
+		for attempt := 1; ; attempt++ {
+			var status sql.NullInt64
+			var location sql.NullString
+			err := run.QuerySingle(ctx, query, key, fingerprint, token, now, now.Add(lease)).
+				Scan(&reserved, &rec.Fingerprint, &status, &location, &rec.CreatedAt, &rec.ExpiresAt)
+			switch {
+			case err == nil:
+				rec.Status, rec.Location = int(status.Int64), location.String
+				if reserved {
+					rec.Token = token
+				}
+				return nil
+			case !errors.Is(err, sql.ErrNoRows) || attempt == reserveAttempts:
+				return convertError(err)
+			}
+		}
+	})
+	if err != nil {
+		return IdempotencyRecord{}, false, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+
+	return rec, reserved, nil
+}
+
+// Complete saves the outcome of the request made with the key reserved with the `token`, it is kept for `ttl`.
+// It has no effect if the reservation has expired and the key was reserved again.
+func (ik *IdempotencyKeys) Complete(ctx context.Context, key, token string, status int, location string, ttl time.Duration) error {
+	const query = `
+		UPDATE idempotency_keys SET status = $3, location = $4, expires_at = $5
+		WHERE key = $1 AND token = $2 AND status IS NULL`
+
+	return ik.pg.WithoutTx(ctx, func(run Runner) error {
+		if err := convertError(run.Exec(ctx, query, key, token, status, location, time.Now().UTC().Add(ttl)).Err()); err != nil {
+			return fmt.Errorf("complete idempotency key: %w", err)
+		}
+		return nil
+	})
+}
+
+// Release removes reservation of the key made with the `token`, so the request could be retried.
+// It has no effect if the reservation has expired and the key was reserved again.
+func (ik *IdempotencyKeys) Release(ctx context.Context, key, token string) error {
+	const query = `DELETE FROM idempotency_keys WHERE key = $1 AND token = $2 AND status IS NULL`
+
+	return ik.pg.WithoutTx(ctx, func(run Runner) error {
+		if err := convertError(run.Exec(ctx, query, key, token).Err()); err != nil {
+			return fmt.Errorf("release idempotency key: %w", err)
+		}
+		return nil
+	})
+}
+
+// Purge removes expired records and returns their number.
+func (ik *IdempotencyKeys) Purge(ctx context.Context) (int64, error) {
+	const query = `DELETE FROM idempotency_keys WHERE expires_at < $1`
+
+	var purged int64
+	err := ik.pg.WithoutTx(ctx, func(run Runner) error {
+		res := run.Exec(ctx, query, time.Now().UTC())
+		if err := convertError(res.Err()); err != nil {
+			return fmt.Errorf("purge idempotency keys: %w", err)
+		}
+		purged = res.Affected()
+		return nil
+	})
+	return purged, err
+}
+
+// reservationToken returns a random value that identifies the reservation of the key.
+func reservationToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate reservation token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
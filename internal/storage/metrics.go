@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/pavelmemory/faceit-users/internal/metrics"
+)
+
+var (
+	txDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_transaction_duration_seconds",
+		Help:    "Duration of database transactions partitioned by outcome.",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"outcome"})
+	txRollbacks = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "db_transaction_rollbacks_total",
+		Help: "Number of rolled back database transactions.",
+	})
+)
+
+func init() {
+	metrics.Default.MustRegister(txDuration, txRollbacks)
+}
+
+// dbName is a value of the 'db_name' label of the connection pool metrics.
+const dbName = "postgres"
+
+// Describe sends descriptions of the connection pool metrics.
+func (p *Postgres) Describe(ch chan<- *prometheus.Desc) {
+	p.stats.Describe(ch)
+}
+
+// Collect sends statistics of the connection pool, see collectors.NewDBStatsCollector.
+func (p *Postgres) Collect(ch chan<- prometheus.Metric) {
+	p.stats.Collect(ch)
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// UsersChangedChannel is a channel the database notifies about committed modifications and deletions of the users.
+const UsersChangedChannel = "users_changed"
+
+// UserChange is a notification about modified or deleted user.
+type UserChange struct {
+	ID      string `json:"id"`
+	Version int64  `json:"version"`
+}
+
+// UserChangesHandler reacts on the notifications about the users.
+type UserChangesHandler interface {
+	// UserChanged is called for each modified or deleted user.
+	UserChanged(change UserChange)
+	// ChangesMissed is called when notifications could be lost because the listener was not connected.
+	ChangesMissed()
+}
+
+// ListenUserChanges passes notifications about the users to the `handler` until the `ctx` is cancelled.
+// It uses a dedicated connection that is re-established with backoff from `minReconnect` up to `maxReconnect`,
+// the `handler` is told about missed changes once the listener is connected and after each reconnection.
+// Lost connections, failed reconnection attempts and failures of the listener are reported to the `onError`,
+// the failed listener is re-created with the same backoff, so it never gives up.
+func (p *Postgres) ListenUserChanges(ctx context.Context, minReconnect, maxReconnect time.Duration, handler UserChangesHandler, onError func(err error)) {
+	retry(ctx, minReconnect, maxReconnect, func() error {
+		return p.listen(ctx, UsersChangedChannel, minReconnect, maxReconnect, handler.ChangesMissed, func(n *pq.Notification) {
+			dispatch(n, handler)
+		}, onError)
+	}, onError)
+}
+
+// retry calls `attempt` until the `ctx` is cancelled. Each failure is reported to the `onError` and
+// the next attempt is delayed twice as long as the previous one, starting from `min` up to `max`.
+// The delay is reset once the attempt succeeds or lasts longer than `max`.
+func retry(ctx context.Context, min, max time.Duration, attempt func() error, onError func(err error)) {
+	delay := min
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := attempt()
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case err == nil || time.Since(started) > max:
+			delay = min
+		case delay < max:
+			delay *= 2
+			if delay > max {
+				delay = max
+			}
+		}
+		if err != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+	}
+}
+
+// listen passes notifications of the `channel` to the `notify` until the listener is closed, `missed` is called
+// once the listener is connected. The nil notification is passed after each reconnection.
+// It returns nil if the listener needs to be re-created, as it reconnects with the same password and
+// never succeeds once the password is rotated.
+func (p *Postgres) listen(ctx context.Context, channel string, minReconnect, maxReconnect time.Duration, missed func(), notify func(n *pq.Notification), onError func(err error)) error {
+	rejected := make(chan struct{}, 1)
+	listener := pq.NewListener(p.connector.dsn(), minReconnect, maxReconnect, func(_ pq.ListenerEventType, err error) {
+		if err == nil {
+			return
+		}
+		onError(err)
+		if isInvalidPassword(err) {
+			select {
+			case rejected <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	// closing of the listener interrupts waiting for the connection and the notifications
+	stop := make(chan struct{})
+	defer close(stop)
+	restarting := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		case <-rejected:
+			_, _ = p.connector.password.Refresh(ctx)
+			close(restarting)
+		}
+		_ = listener.Close()
+	}()
+
+	// closed returns an error if the listener is closed not to be re-created
+	closed := func() error {
+		select {
+		case <-restarting:
+			return nil
+		default:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.New("listener is closed")
+		}
+	}
+
+	if err := listener.Listen(channel); err != nil {
+		select {
+		case <-restarting:
+			return nil
+		default:
+			return fmt.Errorf("listen %s: %w", channel, err)
+		}
+	}
+
+	// notifications sent before the listener was connected are unknown
+	missed()
+
+	// the connection is checked periodically as a broken one doesn't produce any notification
+	const pingInterval = 30 * time.Second
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ping.C:
+			go listener.Ping()
+		case n, ok := <-listener.Notify:
+			if !ok {
+				return closed()
+			}
+			notify(n)
+		}
+	}
+}
+
+// dispatch passes the notification to the handler.
+func dispatch(n *pq.Notification, handler UserChangesHandler) {
+	if n == nil {
+		// nil notification is sent when the connection is re-established
+		handler.ChangesMissed()
+		return
+	}
+
+	var change UserChange
+	if err := json.Unmarshal([]byte(n.Extra), &change); err != nil || change.ID == "" {
+		// the user is unknown, so everything is considered changed
+		handler.ChangesMissed()
+		return
+	}
+	handler.UserChanged(change)
+}
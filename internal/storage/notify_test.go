@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	t.Run("failures are retried with backoff", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var attempts []time.Time
+		var reported []error
+		failure := errors.New("listen")
+		retry(ctx, 10*time.Millisecond, 40*time.Millisecond, func() error {
+			attempts = append(attempts, time.Now())
+			if len(attempts) == 5 {
+				cancel()
+			}
+			return failure
+		}, func(err error) {
+			reported = append(reported, err)
+		})
+
+		require.Len(t, attempts, 5, "stopped once the context is cancelled")
+		require.Equal(t, []error{failure, failure, failure, failure}, reported, "the failure after cancellation is not reported")
+		for i, min := range []time.Duration{20, 40, 40} {
+			require.GreaterOrEqual(t, int64(attempts[i+1].Sub(attempts[i])), int64(min*time.Millisecond), "delay before attempt %d", i+2)
+		}
+	})
+
+	t.Run("delay is reset after success", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var attempts []time.Time
+		retry(ctx, time.Millisecond, time.Second, func() error {
+			attempts = append(attempts, time.Now())
+			switch len(attempts) {
+			case 1, 2, 3, 4, 5:
+				return errors.New("listen")
+			case 7:
+				cancel()
+			}
+			return nil
+		}, func(error) {})
+
+		require.Len(t, attempts, 7)
+		require.Less(t, int64(attempts[6].Sub(attempts[5])), int64(attempts[5].Sub(attempts[4])))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		retry(ctx, time.Millisecond, time.Second, func() error {
+			t.Fatal("no attempt is made")
+			return nil
+		}, func(error) {})
+	})
+}
+
+func TestDispatch(t *testing.T) {
+	for name, tc := range map[string]struct {
+		notification *pq.Notification
+		changed      []UserChange
+		missed       int
+	}{
+		"reconnected":   {notification: nil, missed: 1},
+		"malformed":     {notification: &pq.Notification{Extra: "{"}, missed: 1},
+		"no identifier": {notification: &pq.Notification{Extra: `{"version":2}`}, missed: 1},
+		"changed":       {notification: &pq.Notification{Extra: `{"id":"1","version":2}`}, changed: []UserChange{{ID: "1", Version: 2}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := &testChangesHandler{}
+			dispatch(tc.notification, handler)
+			require.Equal(t, tc.changed, handler.changed)
+			require.Equal(t, tc.missed, handler.missed)
+		})
+	}
+}
+
+type testChangesHandler struct {
+	changed []UserChange
+	missed  int
+}
+
+func (h *testChangesHandler) UserChanged(change UserChange) {
+	h.changed = append(h.changed, change)
+}
+
+func (h *testChangesHandler) ChangesMissed() {
+	h.missed++
+}
+
+func TestDispatchEvent(t *testing.T) {
+	handler := &testEventsHandler{}
+	dispatchEvent(nil, handler)
+	dispatchEvent(&pq.Notification{Extra: `{"Type":"created"}`}, handler)
+
+	require.Equal(t, 1, handler.missed, "reconnected")
+	require.Equal(t, []string{`{"Type":"created"}`}, handler.published)
+}
+
+type testEventsHandler struct {
+	published []string
+	missed    int
+}
+
+func (h *testEventsHandler) EventPublished(payload []byte) {
+	h.published = append(h.published, string(payload))
+}
+
+func (h *testEventsHandler) EventsMissed() {
+	h.missed++
+}
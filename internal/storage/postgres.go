@@ -9,8 +9,12 @@ import (
 	"time"
 
 	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 
 	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/secret"
+	"github.com/pavelmemory/faceit-users/internal/tracing"
 )
 
 type ExecResult interface {
@@ -32,54 +36,100 @@ type Runner interface {
 	Exec(ctx context.Context, query string, params ...interface{}) ExecResult
 	Query(ctx context.Context, query string, params ...interface{}) (MultiResult, error)
 	QuerySingle(ctx context.Context, query string, params ...interface{}) SingleResult
+	// CopyIn bulk loads rows produced by `rows` into the `table` using COPY protocol.
+	// It can be used only inside of the transaction.
+	CopyIn(ctx context.Context, table string, columns []string, rows func(yield func(values ...interface{}) error) error) (int64, error)
 }
 
+// ErrNoTx is returned for operations that require to be executed inside of the transaction.
+var ErrNoTx = errors.New("transaction required")
+
 // NewPostgres returns a connection pool ready to execute statements on PostgreSQL database.
+// New connections are opened with the current value of the `password`, so it could be rotated without restart.
 // TODO: there should be a PgBouncer instance between clients and PostgreSQL dabatase.
-func NewPostgres(addr string, pwd string) (*Postgres, error) {
+func NewPostgres(addr string, password *secret.Value, options ...PostgresOption) (*Postgres, error) {
+	opts := postgresOptions{maxOpenConns: 16, maxIdleConns: 4, connMaxLifetime: 30 * time.Second}
+	for _, option := range options {
+		option(&opts)
+	}
+
 	host, port, err := net.SplitHostPort(addr)
 	if err != nil {
 		return nil, fmt.Errorf("split host and port: %w", err)
 	}
 
-	// TODO: make more dynamic configuration of the database connection
-	db, err := sql.Open("postgres", "host="+host+" port="+port+" user=postgres password="+pwd+" dbname=postgres sslmode=disable binary_parameters=yes")
-	if err != nil {
-		return nil, fmt.Errorf("open connection: %w", err)
-	}
+	conn := connector{host: host, port: port, password: password}
+	db := sql.OpenDB(conn)
 
-	// TODO: should be weighted if those are meaningful
-	db.SetMaxOpenConns(16)
-	db.SetMaxIdleConns(4)
-	db.SetConnMaxLifetime(30 * time.Second)
+	db.SetMaxOpenConns(opts.maxOpenConns)
+	db.SetMaxIdleConns(opts.maxIdleConns)
+	db.SetConnMaxLifetime(opts.connMaxLifetime)
 
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("ping databse: %w", err)
 	}
 
-	return &Postgres{db: db}, nil
+	return &Postgres{db: db, connector: conn, stats: collectors.NewDBStatsCollector(db, dbName)}, nil
+}
+
+// PostgresOption allows to customize behaviour of the connection pool.
+type PostgresOption func(opts *postgresOptions)
+
+type postgresOptions struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+}
+
+// WithPool sets max number of open and idle connections of the pool and max time a connection is reused for.
+func WithPool(maxOpen, maxIdle int, maxLifetime time.Duration) PostgresOption {
+	return func(opts *postgresOptions) {
+		opts.maxOpenConns = maxOpen
+		opts.maxIdleConns = maxIdle
+		opts.connMaxLifetime = maxLifetime
+	}
 }
 
 type Postgres struct {
 	db *sql.DB
+	// connector is used to open dedicated connections outside of the pool as well
+	connector connector
+	stats     prometheus.Collector
 }
 
 func (p *Postgres) WithTx(ctx context.Context, action func(runner Runner) error) error {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "db transaction", tracing.KindInternal)
+	defer span.End()
+
 	tx, err := p.db.BeginTx(ctx, nil)
 	if err != nil {
+		span.SetError(err)
 		return err
 	}
 
-	if err := action(txRunner{tx: tx}); err != nil {
+	runner := tracedRunner{runner: annotatedRunner{Runner: txRunner{tx: tx}}, parent: span}
+
+	if err := action(runner); err != nil {
 		_ = tx.Rollback()
+		txRollbacks.Inc()
+		txDuration.WithLabelValues("rollback").Observe(time.Since(start).Seconds())
+		span.SetError(err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		txDuration.WithLabelValues("commit_failed").Observe(time.Since(start).Seconds())
+		span.SetError(err)
 		return err
 	}
 
-	return tx.Commit()
+	txDuration.WithLabelValues("commit").Observe(time.Since(start).Seconds())
+	return nil
 }
 
 func (p *Postgres) WithoutTx(_ context.Context, action func(runner Runner) error) error {
-	return action(qRunner{db: p.db})
+	return action(tracedRunner{runner: annotatedRunner{Runner: qRunner{db: p.db}}})
 }
 
 func (p *Postgres) Close() {
@@ -108,6 +158,10 @@ func (q qRunner) QuerySingle(ctx context.Context, query string, params ...interf
 	return q.db.QueryRowContext(ctx, query, params...)
 }
 
+func (q qRunner) CopyIn(context.Context, string, []string, func(func(...interface{}) error) error) (int64, error) {
+	return 0, ErrNoTx
+}
+
 type txRunner struct {
 	tx *sql.Tx
 }
@@ -130,6 +184,32 @@ func (r txRunner) QuerySingle(ctx context.Context, query string, params ...inter
 	return r.tx.QueryRowContext(ctx, query, params...)
 }
 
+func (r txRunner) CopyIn(ctx context.Context, table string, columns []string, rows func(yield func(values ...interface{}) error) error) (int64, error) {
+	stmt, err := r.tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return 0, fmt.Errorf("prepare copy: %w", err)
+	}
+	defer stmt.Close()
+
+	var copied int64
+	if err := rows(func(values ...interface{}) error {
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return fmt.Errorf("copy row: %w", err)
+		}
+		copied++
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	// execution without arguments flushes all buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, fmt.Errorf("flush copy: %w", convertError(err))
+	}
+
+	return copied, nil
+}
+
 type execResult struct {
 	result int64
 	err    error
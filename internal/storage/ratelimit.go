@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/ratelimit"
+)
+
+// NewRateLimits returns storage of the rate limiters state backed by PostgreSQL.
+func NewRateLimits(pg *Postgres) *RateLimits {
+	return &RateLimits{pg: pg}
+}
+
+// RateLimits stores state of the rate limiters, so the limits are shared by all instances of the service.
+type RateLimits struct {
+	pg *Postgres
+}
+
+// Update atomically replaces the state of the key with the one returned by `fn`.
+// Zero state is passed to `fn` if the key doesn't exist or its state expired.
+func (rl *RateLimits) Update(ctx context.Context, key string, now time.Time, ttl time.Duration, fn func(value float64, at time.Time) (float64, time.Time)) error {
+	// the expired placeholder makes sure there is a row to lock, so concurrent updates of a new key are serialized
+	const reserve = `
+		INSERT INTO rate_limits(key, value, updated_at, expires_at)
+		VALUES ($1, 0, $2, $2)
+		ON CONFLICT (key) DO NOTHING`
+
+	const lock = `SELECT value, updated_at, expires_at FROM rate_limits WHERE key = $1 FOR UPDATE`
+
+	const update = `UPDATE rate_limits SET value = $2, updated_at = $3, expires_at = $4 WHERE key = $1`
+
+	now = now.UTC()
+	err := rl.pg.WithTx(ctx, func(run Runner) error {
+		if err := convertError(run.Exec(ctx, reserve, key, now).Err()); err != nil {
+			return err
+		}
+
+		var value float64
+		var at, expiresAt time.Time
+		if err := convertError(run.QuerySingle(ctx, lock, key).Scan(&value, &at, &expiresAt)); err != nil {
+			return err
+		}
+		if !now.Before(expiresAt) {
+			value, at = 0, time.Time{}
+		}
+
+		value, at = fn(value, at)
+		return convertError(run.Exec(ctx, update, key, value, at.UTC(), now.Add(ttl)).Err())
+	})
+	if err != nil {
+		return fmt.Errorf("update rate limit: %w", err)
+	}
+	return nil
+}
+
+// Take takes a token from the bucket of the key with a single statement, see ratelimit.TokenStore.
+// The row is updated only if the token is taken, otherwise the state is read to report the tokens left.
+func (rl *RateLimits) Take(ctx context.Context, key string, now time.Time, ttl time.Duration, limit ratelimit.Limit) (float64, bool, error) {
+	// refilled is the same as ratelimit.Refill: the expired bucket is full
+	const refilled = `CASE WHEN rl.expires_at <= $2::TIMESTAMP THEN $3::FLOAT8
+		ELSE LEAST($3::FLOAT8, rl.value + EXTRACT(EPOCH FROM $2::TIMESTAMP - rl.updated_at)::FLOAT8 * $4::FLOAT8) END`
+
+	const take = `
+		INSERT INTO rate_limits AS rl (key, value, updated_at, expires_at)
+		SELECT $1, $3::FLOAT8 - 1, $2::TIMESTAMP, $5::TIMESTAMP WHERE $3::FLOAT8 >= 1
+		ON CONFLICT (key) DO UPDATE SET value = ` + refilled + ` - 1, updated_at = $2::TIMESTAMP, expires_at = $5::TIMESTAMP
+		WHERE ` + refilled + ` >= 1
+		RETURNING value`
+
+	const state = `SELECT value, updated_at, expires_at FROM rate_limits WHERE key = $1`
+
+	now = now.UTC()
+	var tokens float64
+	var taken bool
+	err := rl.pg.WithoutTx(ctx, func(run Runner) error {
+		err := convertError(run.QuerySingle(ctx, take, key, now, float64(limit.Burst), limit.Rate, now.Add(ttl)).Scan(&tokens))
+		if !errors.Is(err, internal.ErrNotFound) {
+			taken = err == nil
+			return err
+		}
+
+		var value float64
+		var at, expiresAt time.Time
+		err = convertError(run.QuerySingle(ctx, state, key).Scan(&value, &at, &expiresAt))
+		switch {
+		case errors.Is(err, internal.ErrNotFound) || err == nil && !now.Before(expiresAt):
+			// the bucket is full, but it has no tokens at all
+			tokens = float64(limit.Burst)
+			return nil
+		case err != nil:
+			return err
+		}
+		tokens = ratelimit.Refill(value, at, now, limit)
+		return nil
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("take rate limit token: %w", err)
+	}
+	return tokens, taken, nil
+}
+
+// Purge removes expired states and returns their number.
+func (rl *RateLimits) Purge(ctx context.Context) (int64, error) {
+	const query = `DELETE FROM rate_limits WHERE expires_at < $1`
+
+	var purged int64
+	err := rl.pg.WithoutTx(ctx, func(run Runner) error {
+		res := run.Exec(ctx, query, time.Now().UTC())
+		if err := convertError(res.Err()); err != nil {
+			return fmt.Errorf("purge rate limits: %w", err)
+		}
+		purged = res.Affected()
+		return nil
+	})
+	return purged, err
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/pavelmemory/faceit-users/internal/requestid"
+)
+
+// annotatedRunner prepends each statement with a comment that contains the request ID,
+// so the statement could be correlated with the HTTP request in slow-query logs and in pg_stat_activity.
+// The comment is sent with the statement itself, so it costs no extra round trips to the database.
+type annotatedRunner struct {
+	Runner
+}
+
+func (ar annotatedRunner) annotate(ctx context.Context, query string) string {
+	id := requestid.FromContext(ctx)
+	if id == "" {
+		return query
+	}
+
+	// the ID is validated to consist only of safe symbols, so it can't terminate the comment
+	return "/* request_id=" + id + " */ " + query
+}
+
+func (ar annotatedRunner) Exec(ctx context.Context, query string, params ...interface{}) ExecResult {
+	return ar.Runner.Exec(ctx, ar.annotate(ctx, query), params...)
+}
+
+func (ar annotatedRunner) Query(ctx context.Context, query string, params ...interface{}) (MultiResult, error) {
+	return ar.Runner.Query(ctx, ar.annotate(ctx, query), params...)
+}
+
+func (ar annotatedRunner) QuerySingle(ctx context.Context, query string, params ...interface{}) SingleResult {
+	return ar.Runner.QuerySingle(ctx, ar.annotate(ctx, query), params...)
+}
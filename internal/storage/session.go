@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RefreshToken is a token that allows to prolong the session of the user.
+type RefreshToken struct {
+	// Hash is a hex encoded SHA-256 hash of the token, the token itself is never stored.
+	Hash string
+	// FamilyID identifies the session all rotated tokens belong to.
+	FamilyID  string
+	UserID    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// UsedAt is a moment the token was exchanged for a new one, zero value means it was not used yet.
+	UsedAt time.Time
+	// RevokedAt is a moment the token was revoked, zero value means it is not revoked.
+	RevokedAt time.Time
+}
+
+// VerifyCredentials returns ID of the user with the email and the password.
+// It returns internal.ErrNotFound if there is no such user or the password doesn't match.
+func (p *Postgres) VerifyCredentials(ctx context.Context, run Runner, email, password string) (string, error) {
+	const query = `SELECT id FROM users WHERE email = $1 AND password = CRYPT($2, password)`
+
+	var id string
+	if err := convertError(run.QuerySingle(ctx, query, email, password).Scan(&id)); err != nil {
+		return "", fmt.Errorf("query single: %w", err)
+	}
+	return id, nil
+}
+
+// ChangePassword replaces the password of the user if the `current` one matches.
+// It returns internal.ErrNotFound if there is no such user or the `current` password doesn't match.
+func (p *Postgres) ChangePassword(ctx context.Context, run Runner, id, current, password string, updatedAt time.Time) error {
+	const query = `
+		UPDATE users
+		SET password = CRYPT($3, GEN_SALT('md5')), updated_at = $4
+		WHERE id = $1 AND password = CRYPT($2, password)
+		RETURNING true`
+
+	var confirmation sql.NullBool
+	if err := convertError(run.QuerySingle(ctx, query, id, current, password, updatedAt).Scan(&confirmation)); err != nil {
+		return fmt.Errorf("query single: %w", err)
+	}
+	return nil
+}
+
+// PersistRefreshToken saves the token.
+func (p *Postgres) PersistRefreshToken(ctx context.Context, run Runner, token RefreshToken) error {
+	const query = `
+		INSERT INTO refresh_tokens(token_hash, family_id, user_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	if err := convertError(run.Exec(ctx, query, token.Hash, token.FamilyID, token.UserID, token.CreatedAt, token.ExpiresAt).Err()); err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+// RetrieveRefreshToken returns the token by its hash.
+// If the token doesn't exist it returns internal.ErrNotFound.
+func (p *Postgres) RetrieveRefreshToken(ctx context.Context, run Runner, hash string, forUpdate bool) (RefreshToken, error) {
+	var query = []string{`
+		SELECT family_id, user_id, created_at, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1`,
+	}
+
+	if forUpdate {
+		query = append(query, `FOR UPDATE`)
+	}
+
+	token := RefreshToken{Hash: hash}
+	var usedAt, revokedAt sql.NullTime
+	res := run.QuerySingle(ctx, strings.Join(query, " "), hash)
+	if err := convertError(res.Scan(&token.FamilyID, &token.UserID, &token.CreatedAt, &token.ExpiresAt, &usedAt, &revokedAt)); err != nil {
+		return RefreshToken{}, fmt.Errorf("query single: %w", err)
+	}
+
+	token.UsedAt, token.RevokedAt = usedAt.Time, revokedAt.Time
+	return token, nil
+}
+
+// UseRefreshToken marks the token as exchanged for a new one.
+func (p *Postgres) UseRefreshToken(ctx context.Context, run Runner, hash string, usedAt time.Time) error {
+	const query = `UPDATE refresh_tokens SET used_at = $2 WHERE token_hash = $1`
+
+	if err := convertError(run.Exec(ctx, query, hash, usedAt).Err()); err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+// RevokeRefreshTokens revokes all not yet revoked tokens of the family.
+func (p *Postgres) RevokeRefreshTokens(ctx context.Context, run Runner, familyID string, revokedAt time.Time) error {
+	const query = `UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL`
+
+	if err := convertError(run.Exec(ctx, query, familyID, revokedAt).Err()); err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+// RevokeSessions revokes all not yet revoked tokens of all sessions of the user.
+func (p *Postgres) RevokeSessions(ctx context.Context, run Runner, userID string, revokedAt time.Time) error {
+	const query = `UPDATE refresh_tokens SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL`
+
+	if err := convertError(run.Exec(ctx, query, userID, revokedAt).Err()); err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+// PurgeRefreshTokens removes the tokens expired before `before` and returns their number.
+func (p *Postgres) PurgeRefreshTokens(ctx context.Context, run Runner, before time.Time) (int64, error) {
+	const query = `DELETE FROM refresh_tokens WHERE expires_at < $1`
+
+	res := run.Exec(ctx, query, before)
+	if err := convertError(res.Err()); err != nil {
+		return 0, fmt.Errorf("exec: %w", err)
+	}
+	return res.Affected(), nil
+}
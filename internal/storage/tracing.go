@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/pavelmemory/faceit-users/internal/tracing"
+)
+
+// tracedRunner starts a span for each statement executed by the runner.
+// Spans are children of the `parent` span if it is set, so statements are grouped by the transaction.
+type tracedRunner struct {
+	runner Runner
+	parent tracing.Span
+}
+
+func (tr tracedRunner) start(ctx context.Context, query string) (context.Context, tracing.Span) {
+	if tr.parent.Span != nil {
+		ctx = tracing.ContextWithSpan(ctx, tr.parent)
+	}
+
+	statement := sanitizeSQL(query)
+	return tracing.Start(ctx, "db "+sqlOperation(statement), tracing.KindClient,
+		tracing.String("db.system", "postgresql"),
+		tracing.String("db.statement", statement),
+	)
+}
+
+func (tr tracedRunner) Exec(ctx context.Context, query string, params ...interface{}) ExecResult {
+	ctx, span := tr.start(ctx, query)
+	defer span.End()
+
+	res := tr.runner.Exec(ctx, query, params...)
+	span.SetError(res.Err())
+	return res
+}
+
+func (tr tracedRunner) Query(ctx context.Context, query string, params ...interface{}) (MultiResult, error) {
+	ctx, span := tr.start(ctx, query)
+	res, err := tr.runner.Query(ctx, query, params...)
+	if err != nil {
+		span.SetError(err)
+		span.End()
+		return nil, err
+	}
+
+	return tracedMultiResult{MultiResult: res, span: span}, nil
+}
+
+func (tr tracedRunner) QuerySingle(ctx context.Context, query string, params ...interface{}) SingleResult {
+	ctx, span := tr.start(ctx, query)
+	return tracedSingleResult{SingleResult: tr.runner.QuerySingle(ctx, query, params...), span: span}
+}
+
+func (tr tracedRunner) CopyIn(ctx context.Context, table string, columns []string, rows func(yield func(values ...interface{}) error) error) (int64, error) {
+	ctx, span := tr.start(ctx, "COPY "+table+" ("+strings.Join(columns, ", ")+") FROM STDIN")
+	defer span.End()
+
+	copied, err := tr.runner.CopyIn(ctx, table, columns, rows)
+	span.SetError(err)
+	span.SetAttributes(tracing.Int("db.rows", int(copied)))
+	return copied, err
+}
+
+// tracedMultiResult ends the span when the result is closed, so the span covers fetching of the rows.
+type tracedMultiResult struct {
+	MultiResult
+	span tracing.Span
+}
+
+func (r tracedMultiResult) Close() error {
+	err := r.MultiResult.Close()
+	r.span.SetError(err)
+	r.span.End()
+	return err
+}
+
+// tracedSingleResult ends the span when the result is scanned.
+type tracedSingleResult struct {
+	SingleResult
+	span tracing.Span
+}
+
+func (r tracedSingleResult) Scan(dst ...interface{}) error {
+	err := r.SingleResult.Scan(dst...)
+	if !errors.Is(err, sql.ErrNoRows) {
+		// absence of the row is an expected outcome of the statement
+		r.span.SetError(err)
+	}
+	r.span.End()
+	return err
+}
+
+// sqlOperation returns the first keyword of the statement.
+func sqlOperation(statement string) string {
+	if i := strings.IndexAny(statement, " ("); i > 0 {
+		statement = statement[:i]
+	}
+	return strings.ToUpper(statement)
+}
+
+// sanitizeSQL replaces literals of the query with '?' and collapses whitespaces,
+// so the statement could be recorded without leaking any sensitive data.
+// Placeholders of the parameters are kept as is.
+func sanitizeSQL(query string) string {
+	isIdent := func(c byte) bool {
+		return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+	isDigit := func(c byte) bool {
+		return c >= '0' && c <= '9'
+	}
+
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			space = true
+			i++
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+
+		switch {
+		case c == '\'':
+			// quotes inside of the literal are escaped by doubling them
+			i++
+			for i < len(query) {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+			b.WriteByte('?')
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			j := i + 1
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			b.WriteString(query[i:j])
+			i = j
+		case isDigit(c):
+			for i < len(query) && (isDigit(query[i]) || query[i] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		case isIdent(c):
+			j := i
+			for j < len(query) && isIdent(query[j]) {
+				j++
+			}
+			b.WriteString(query[i:j])
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeSQL(t *testing.T) {
+	for query, exp := range map[string]string{
+		"SELECT id\n\tFROM users WHERE id = $1 LIMIT 10": "SELECT id FROM users WHERE id = $1 LIMIT ?",
+		"UPDATE users SET email = 'o''neil@mail.com'":    "UPDATE users SET email = ?",
+		"INSERT INTO t1 (a) VALUES (1.5, 'x'), (-2, '')": "INSERT INTO t1 (a) VALUES (?, ?), (-?, ?)",
+		"   ":                              "",
+		"SELECT 'unterminated":             "SELECT ?",
+		"DECLARE users_cursor CURSOR FOR ": "DECLARE users_cursor CURSOR FOR",
+	} {
+		require.Equal(t, exp, sanitizeSQL(query), query)
+	}
+
+	require.Equal(t, "INSERT", sqlOperation("insert INTO users"))
+	require.Equal(t, "WITH", sqlOperation("WITH(x) AS"))
+}
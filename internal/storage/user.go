@@ -4,13 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type User struct {
 	ID        string
-	Version   int64 // TODO: optimistic locking to prevent unexpected concurrent modification by other instances
+	Version   int64 // increased on each modification, TODO: optimistic locking to prevent unexpected concurrent modification by other instances
 	FirstName string
 	LastName  string
 	Nickname  string
@@ -39,9 +41,60 @@ func (p *Postgres) Persist(ctx context.Context, run Runner, user User) (string,
 	return id, nil
 }
 
+func (p *Postgres) PersistMany(ctx context.Context, run Runner, users []User) ([]string, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	const columns = 8
+	values := make([]string, len(users))
+	params := make([]interface{}, 0, len(users)*columns)
+	for i, user := range users {
+		n := i * columns
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, CRYPT($%d, GEN_SALT('md5')), $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8)
+		params = append(params, user.FirstName, user.LastName, user.Nickname, user.Email, user.Country, user.Password, user.CreatedAt, user.UpdatedAt)
+	}
+
+	// rows that violate uniqueness constraints are skipped,
+	// the rest are matched back by nickname as the order of returned rows is not guaranteed
+	query := `
+		INSERT INTO users(first_name, last_name, nickname, email, country, password, created_at, updated_at)
+		VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT DO NOTHING
+		RETURNING id, nickname`
+
+	rows, err := run.Query(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", convertError(err))
+	}
+	defer rows.Close()
+
+	inserted := make(map[string]string, len(users))
+	for rows.Next() {
+		var id, nickname string
+		if err := rows.Scan(&id, &nickname); err != nil {
+			return nil, fmt.Errorf("scan: %w", convertError(err))
+		}
+		inserted[nickname] = id
+	}
+
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("close rows: %w", convertError(err))
+	}
+
+	ids := make([]string, len(users))
+	for i, user := range users {
+		// the same nickname could be used only once
+		ids[i] = inserted[user.Nickname]
+		delete(inserted, user.Nickname)
+	}
+
+	return ids, nil
+}
+
 func (p *Postgres) Retrieve(ctx context.Context, run Runner, id string, forUpdate bool) (User, error) {
 	var query = []string{`
-		SELECT first_name, last_name, nickname, email, country, created_at, updated_at
+		SELECT version, first_name, last_name, nickname, email, country, created_at, updated_at
 		FROM users
 		WHERE id = $1`,
 	}
@@ -53,7 +106,7 @@ func (p *Postgres) Retrieve(ctx context.Context, run Runner, id string, forUpdat
 	u := User{ID: id}
 
 	res := run.QuerySingle(ctx, strings.Join(query, " "), id)
-	if err := convertError(res.Scan(&u.FirstName, &u.LastName, &u.Nickname, &u.Email, &u.Country, &u.CreatedAt, &u.UpdatedAt)); err != nil {
+	if err := convertError(res.Scan(&u.Version, &u.FirstName, &u.LastName, &u.Nickname, &u.Email, &u.Country, &u.CreatedAt, &u.UpdatedAt)); err != nil {
 		return User{}, fmt.Errorf("query single: %w", err)
 	}
 
@@ -98,3 +151,167 @@ func (p *Postgres) Delete(ctx context.Context, run Runner, id string) error {
 	}
 	return nil
 }
+
+// Scan calls `fn` for each user ordered by creation time.
+// Users are fetched with a server-side cursor by `batchSize` rows at a time,
+// so the whole table is never loaded into memory. It must be used inside of the transaction.
+func (p *Postgres) Scan(ctx context.Context, run Runner, batchSize int, fn func(User) error) error {
+	const declare = `
+		DECLARE users_scan NO SCROLL CURSOR FOR
+		SELECT id, first_name, last_name, nickname, email, country, created_at, updated_at
+		FROM users
+		ORDER BY created_at, id`
+
+	if err := convertError(run.Exec(ctx, declare).Err()); err != nil {
+		return fmt.Errorf("declare cursor: %w", err)
+	}
+
+	fetch := `FETCH FORWARD ` + strconv.Itoa(batchSize) + ` FROM users_scan`
+	for {
+		rows, err := run.Query(ctx, fetch)
+		if err != nil {
+			return fmt.Errorf("fetch: %w", convertError(err))
+		}
+
+		fetched, err := scanUsers(rows, fn)
+		if err != nil {
+			return err
+		}
+
+		if fetched < batchSize {
+			break
+		}
+	}
+
+	if err := convertError(run.Exec(ctx, `CLOSE users_scan`).Err()); err != nil {
+		return fmt.Errorf("close cursor: %w", err)
+	}
+
+	return nil
+}
+
+func scanUsers(rows MultiResult, fn func(User) error) (int, error) {
+	defer rows.Close()
+
+	var scanned int
+	for rows.Next() {
+		var u User
+		var country sql.NullString
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Nickname, &u.Email, &country, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return scanned, fmt.Errorf("scan: %w", convertError(err))
+		}
+		u.Country = country.String
+		scanned++
+
+		if err := fn(u); err != nil {
+			return scanned, err
+		}
+	}
+
+	if err := rows.Close(); err != nil {
+		return scanned, fmt.Errorf("close rows: %w", convertError(err))
+	}
+
+	return scanned, nil
+}
+
+// Import saves users produced by `users` using COPY protocol. Each user is identified by the `line` number.
+// It returns identifiers of the imported users in order of their lines.
+// Users that are not unique (among existing users or each other) are skipped, their line numbers are returned.
+// It must be used inside of the transaction.
+func (p *Postgres) Import(ctx context.Context, run Runner, users func(yield func(line int, user User) error) error) ([]string, []int, error) {
+	const staging = `
+		CREATE TEMPORARY TABLE users_import (
+			line       INTEGER NOT NULL,
+			first_name TEXT NOT NULL,
+			last_name  TEXT NOT NULL,
+			nickname   TEXT NOT NULL,
+			email      TEXT NOT NULL,
+			country    TEXT,
+			password   TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		) ON COMMIT DROP`
+
+	// only the first occurrence of the nickname or email is imported
+	const dedup = `
+		DELETE FROM users_import dup
+		USING users_import orig
+		WHERE dup.line > orig.line AND (dup.nickname = orig.nickname OR dup.email = orig.email)
+		RETURNING dup.line`
+
+	const insert = `
+		WITH inserted AS (
+			INSERT INTO users(first_name, last_name, nickname, email, country, password, created_at, updated_at)
+			SELECT first_name, last_name, nickname, email, country, CRYPT(password, GEN_SALT('md5')), created_at, updated_at
+			FROM users_import
+			ORDER BY line
+			ON CONFLICT DO NOTHING
+			RETURNING id, nickname
+		)
+		SELECT imp.line, ins.id
+		FROM users_import imp
+		LEFT JOIN inserted ins ON ins.nickname = imp.nickname
+		ORDER BY imp.line`
+
+	if err := convertError(run.Exec(ctx, staging).Err()); err != nil {
+		return nil, nil, fmt.Errorf("create staging table: %w", err)
+	}
+
+	columns := []string{"line", "first_name", "last_name", "nickname", "email", "country", "password", "created_at", "updated_at"}
+	_, err := run.CopyIn(ctx, "users_import", columns, func(yield func(values ...interface{}) error) error {
+		return users(func(line int, u User) error {
+			return yield(line, u.FirstName, u.LastName, u.Nickname, u.Email, u.Country, u.Password, u.CreatedAt, u.UpdatedAt)
+		})
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("copy: %w", err)
+	}
+
+	each := func(query string, scan func(rows MultiResult) error) error {
+		rows, err := run.Query(ctx, query)
+		if err != nil {
+			return convertError(err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				return convertError(err)
+			}
+		}
+		return convertError(rows.Close())
+	}
+
+	var skipped []int
+	if err := each(dedup, func(rows MultiResult) error {
+		var line int
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		skipped = append(skipped, line)
+		return nil
+	}); err != nil {
+		return nil, nil, fmt.Errorf("deduplicate: %w", err)
+	}
+
+	var imported []string
+	if err := each(insert, func(rows MultiResult) error {
+		var line int
+		var id sql.NullString
+		if err := rows.Scan(&line, &id); err != nil {
+			return err
+		}
+		if !id.Valid {
+			skipped = append(skipped, line)
+			return nil
+		}
+		imported = append(imported, id.String)
+		return nil
+	}); err != nil {
+		return nil, nil, fmt.Errorf("insert: %w", err)
+	}
+
+	sort.Ints(skipped)
+	return imported, skipped, nil
+}
@@ -0,0 +1,196 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var certificateExpiryDesc = prometheus.NewDesc("tls_certificate_expiry_timestamp_seconds",
+	"Time the server certificate expires at.", nil, nil)
+
+// Client authentication modes.
+const (
+	// ClientAuthOptional verifies the client certificate only if it is sent.
+	ClientAuthOptional = "optional"
+	// ClientAuthRequire rejects connections without a valid client certificate.
+	ClientAuthRequire = "require"
+)
+
+// ParseVersion returns the TLS version by its name: '1.2' or '1.3'. Older versions are not supported.
+func ParseVersion(name string) (uint16, error) {
+	switch name {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q, must be 1.2 or 1.3", name)
+	}
+}
+
+// ParseCipherSuites returns identifiers of the cipher suites by their names, e.g. 'TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256'.
+// Only the suites without known security issues are accepted. HTTP/2 requires one of the AES-128-GCM suites
+// to be in the list. Cipher suites of TLS 1.3 are not configurable.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	supported := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, len(names))
+	http2 := false
+	for i, name := range names {
+		id, ok := supported[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported cipher suite %q", name)
+		}
+		ids[i] = id
+		http2 = http2 || id == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || id == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+	}
+
+	if !http2 {
+		return nil, errors.New("cipher suites must include TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 required by HTTP/2")
+	}
+	return ids, nil
+}
+
+// Files are paths to the PEM encoded files of the server.
+type Files struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a bundle of the certificates of the authorities client certificates are verified with,
+	// client certificates are not requested if it is empty.
+	ClientCAFile string
+}
+
+// NewServer returns a TLS configuration of the server with the certificate and client authorities loaded from `files`.
+func NewServer(files Files, options ...ServerOption) (*Server, error) {
+	s := &Server{files: files, minVersion: tls.VersionTLS12, clientAuth: tls.VerifyClientCertIfGiven}
+	for _, option := range options {
+		option(s)
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ServerOption allows to customize TLS configuration of the server.
+type ServerOption func(s *Server)
+
+// WithMinVersion sets the minimal TLS version accepted by the server, see ParseVersion.
+func WithMinVersion(version uint16) ServerOption {
+	return func(s *Server) {
+		s.minVersion = version
+	}
+}
+
+// WithCipherSuites sets cipher suites of TLS 1.2 connections, see ParseCipherSuites. Go defaults are used if it is empty.
+func WithCipherSuites(suites []uint16) ServerOption {
+	return func(s *Server) {
+		s.cipherSuites = suites
+	}
+}
+
+// WithClientAuth sets a mode of the client certificates verification: ClientAuthOptional or ClientAuthRequire.
+// It has effect only if the client authorities are set.
+func WithClientAuth(mode string) ServerOption {
+	return func(s *Server) {
+		if mode == ClientAuthRequire {
+			s.clientAuth = tls.RequireAndVerifyClientCert
+		} else {
+			s.clientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+}
+
+// Server is a TLS configuration of the server that could be reloaded, so the certificates are rotated without restart.
+// HTTP/2 is negotiated with the clients that support it.
+type Server struct {
+	files        Files
+	minVersion   uint16
+	cipherSuites []uint16
+	clientAuth   tls.ClientAuthType
+
+	// current is the *tls.Config with the last loaded certificates used for new connections
+	current atomic.Value
+}
+
+// Reload re-reads the certificate, the key and the client authorities. The current ones are kept on failure.
+func (s *Server) Reload() error {
+	cert, err := tls.LoadX509KeyPair(s.files.CertFile, s.files.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("parse certificate: %w", err)
+		}
+	}
+
+	config := s.base()
+	config.Certificates = []tls.Certificate{cert}
+
+	if s.files.ClientCAFile != "" {
+		data, err := ioutil.ReadFile(s.files.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client authorities: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in %s", s.files.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = s.clientAuth
+	}
+
+	s.current.Store(config)
+	return nil
+}
+
+// TLSConfig returns a configuration to be set to the http.Server.
+// Each new connection uses the certificates loaded by the last successful reload.
+func (s *Server) TLSConfig() *tls.Config {
+	config := s.base()
+	config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return &s.config().Certificates[0], nil
+	}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return s.config(), nil
+	}
+	return config
+}
+
+func (s *Server) config() *tls.Config {
+	return s.current.Load().(*tls.Config)
+}
+
+func (s *Server) base() *tls.Config {
+	return &tls.Config{
+		MinVersion:   s.minVersion,
+		CipherSuites: s.cipherSuites,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+}
+
+// Describe sends description of the certificate expiration metric.
+func (s *Server) Describe(ch chan<- *prometheus.Desc) {
+	ch <- certificateExpiryDesc
+}
+
+// Collect sends expiration time of the server certificate.
+func (s *Server) Collect(ch chan<- prometheus.Metric) {
+	leaf := s.config().Certificates[0].Leaf
+	ch <- prometheus.MustNewConstMetric(certificateExpiryDesc, prometheus.GaugeValue, float64(leaf.NotAfter.Unix()))
+}
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// propagator passes the span context across process boundaries with W3C 'traceparent' and 'tracestate' headers.
+// https://www.w3.org/TR/trace-context/
+var propagator = propagation.TraceContext{}
+
+// Extract returns a copy of the context with the span context propagated with the headers of the incoming request.
+// It becomes a parent for spans started with the context if there is no local span in the context.
+// The context is returned as is if the headers are absent or malformed.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Inject sets 'traceparent' and 'tracestate' headers of the outgoing request from the span of the context,
+// so the trace could be continued by the called service.
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Transport returns a round tripper that starts a client span for each request sent with `base`
+// and propagates it to the called service with Inject. If `base` is nil http.DefaultTransport is used.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, span := Start(r.Context(), "HTTP "+r.Method, KindClient,
+		String("http.method", r.Method),
+		String("http.url", r.URL.Redacted()),
+	)
+	defer span.End()
+
+	// the request must not be modified by the round tripper
+	r = r.Clone(ctx)
+	Inject(ctx, r.Header)
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+
+	span.SetAttributes(Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, strconv.Itoa(resp.StatusCode))
+	}
+	return resp, nil
+}
@@ -0,0 +1,207 @@
+package tracing
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans started by the service.
+const instrumentationName = "github.com/pavelmemory/faceit-users"
+
+var (
+	spansEndedDesc = prometheus.NewDesc("tracing_spans_ended_total",
+		"Number of the sampled spans passed for export.", nil, nil)
+	spansExportedDesc = prometheus.NewDesc("tracing_spans_exported_total",
+		"Number of the spans sent by the exporter partitioned by result.", []string{"result"}, nil)
+)
+
+// kinds of the spans describe relationship of the span to its parent and children
+// https://opentelemetry.io/docs/specs/otel/trace/api/#spankind
+const (
+	KindInternal = trace.SpanKindInternal
+	KindServer   = trace.SpanKindServer
+	KindClient   = trace.SpanKindClient
+)
+
+func String(key, value string) attribute.KeyValue {
+	return attribute.String(key, value)
+}
+
+func Int(key string, value int) attribute.KeyValue {
+	return attribute.Int(key, value)
+}
+
+func Bool(key string, value bool) attribute.KeyValue {
+	return attribute.Bool(key, value)
+}
+
+// Span is a single operation of the trace.
+// Spans of the context without a span or of the unsampled trace record nothing.
+type Span struct {
+	trace.Span
+}
+
+// SetError marks the span as failed with the error, nil is ignored.
+func (s Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.RecordError(err)
+	s.SetStatus(codes.Error, err.Error())
+}
+
+// ContextWithSpan returns a copy of the context with the span, it becomes a parent for spans started with the context.
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return trace.ContextWithSpan(ctx, span.Span)
+}
+
+// SpanFromContext returns a span of the context, it records nothing if there is no span.
+func SpanFromContext(ctx context.Context) Span {
+	return Span{Span: trace.SpanFromContext(ctx)}
+}
+
+// ProviderOption changes default behaviour of the Provider.
+type ProviderOption func(*providerOptions)
+
+type providerOptions struct {
+	sampleRatio float64
+	attributes  []attribute.KeyValue
+}
+
+// WithSampleRatio samples the `ratio` (from 0 to 1) of the traces started by the provider, all of them are sampled by default.
+// The traces continued from the parent are sampled according to the decision of the parent.
+func WithSampleRatio(ratio float64) ProviderOption {
+	return func(o *providerOptions) {
+		o.sampleRatio = ratio
+	}
+}
+
+// WithServiceName sets 'service.name' resource attribute of the exported spans.
+func WithServiceName(name string) ProviderOption {
+	return func(o *providerOptions) {
+		o.attributes = append(o.attributes, attribute.String("service.name", name))
+	}
+}
+
+// Provider starts spans and passes the finished ones to the exporter in batches.
+// Finished spans wait for export in a queue of 2048 spans, the spans that don't fit are dropped.
+type Provider struct {
+	// counters are accessed atomically, so they are aligned by placing them first
+	ended          int64
+	exported       int64
+	exportFailures int64
+
+	*sdktrace.TracerProvider
+}
+
+// NewProvider returns a provider that exports finished spans with the `exporter`.
+// If `exporter` is nil spans are still created and propagated, but never exported.
+// Failures of the export are reported with otel.Handle.
+func NewProvider(exporter sdktrace.SpanExporter, options ...ProviderOption) *Provider {
+	opts := providerOptions{sampleRatio: 1}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	p := &Provider{}
+	tpOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.sampleRatio))),
+	}
+	if len(opts.attributes) > 0 {
+		// schemaless resource never conflicts with the default one
+		res, _ := resource.Merge(resource.Default(), resource.NewSchemaless(opts.attributes...))
+		tpOptions = append(tpOptions, sdktrace.WithResource(res))
+	}
+	if exporter != nil {
+		batcher := sdktrace.NewBatchSpanProcessor(countingExporter{SpanExporter: exporter, provider: p})
+		tpOptions = append(tpOptions, sdktrace.WithSpanProcessor(countingProcessor{SpanProcessor: batcher, provider: p}))
+	}
+
+	p.TracerProvider = sdktrace.NewTracerProvider(tpOptions...)
+	return p
+}
+
+// Start starts a new span that is a child of the span found in the context.
+// It returns a copy of the context with the new span in it.
+func (p *Provider) Start(ctx context.Context, name string, kind trace.SpanKind, attributes ...attribute.KeyValue) (context.Context, Span) {
+	ctx, span := p.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attributes...))
+	return ctx, Span{Span: span}
+}
+
+// Describe sends descriptions of the export metrics.
+func (p *Provider) Describe(ch chan<- *prometheus.Desc) {
+	ch <- spansEndedDesc
+	ch <- spansExportedDesc
+}
+
+// Collect sends statistics of the export.
+// The difference of the ended and exported spans is a number of the spans waiting for export or dropped.
+func (p *Provider) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(spansEndedDesc, prometheus.CounterValue, float64(atomic.LoadInt64(&p.ended)))
+	ch <- prometheus.MustNewConstMetric(spansExportedDesc, prometheus.CounterValue, float64(atomic.LoadInt64(&p.exported)), "success")
+	ch <- prometheus.MustNewConstMetric(spansExportedDesc, prometheus.CounterValue, float64(atomic.LoadInt64(&p.exportFailures)), "failure")
+}
+
+// countingProcessor counts the spans passed for export.
+type countingProcessor struct {
+	sdktrace.SpanProcessor
+	provider *Provider
+}
+
+func (cp countingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		atomic.AddInt64(&cp.provider.ended, 1)
+	}
+	cp.SpanProcessor.OnEnd(s)
+}
+
+// countingExporter counts the exported spans by result.
+type countingExporter struct {
+	sdktrace.SpanExporter
+	provider *Provider
+}
+
+func (ce countingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := ce.SpanExporter.ExportSpans(ctx, spans)
+	if err != nil {
+		atomic.AddInt64(&ce.provider.exportFailures, int64(len(spans)))
+	} else {
+		atomic.AddInt64(&ce.provider.exported, int64(len(spans)))
+	}
+	return err
+}
+
+var defaultProvider atomic.Value
+
+func init() {
+	defaultProvider.Store(NewProvider(nil))
+}
+
+// SetDefault replaces a provider used by the package level Start function.
+// It also becomes the global provider and W3C trace context the global propagator of the OpenTelemetry API,
+// so the libraries instrumented with it are traced the same way.
+func SetDefault(p *Provider) {
+	defaultProvider.Store(p)
+	otel.SetTracerProvider(p)
+	otel.SetTextMapPropagator(propagator)
+}
+
+// Default returns a provider used by the package level Start function.
+// It doesn't export spans unless it was replaced with SetDefault.
+func Default() *Provider {
+	return defaultProvider.Load().(*Provider)
+}
+
+// Start starts a new span with the default provider.
+func Start(ctx context.Context, name string, kind trace.SpanKind, attributes ...attribute.KeyValue) (context.Context, Span) {
+	return Default().Start(ctx, name, kind, attributes...)
+}
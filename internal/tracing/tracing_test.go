@@ -0,0 +1,257 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func remoteParent(flags string) context.Context {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-"+flags)
+	header.Set("tracestate", "vendor=value")
+	return Extract(context.Background(), header)
+}
+
+func shutdown(t *testing.T, p *Provider) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, p.Shutdown(ctx))
+}
+
+func TestExtract(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		sc := trace.SpanContextFromContext(remoteParent("01"))
+		require.True(t, sc.IsRemote())
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+		require.Equal(t, "00f067aa0ba902b7", sc.SpanID().String())
+		require.True(t, sc.IsSampled())
+		require.Equal(t, "vendor=value", sc.TraceState().String())
+
+		header := http.Header{}
+		Inject(trace.ContextWithSpanContext(context.Background(), sc), header)
+		require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", header.Get("traceparent"))
+		require.Equal(t, "vendor=value", header.Get("tracestate"))
+	})
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		header := http.Header{}
+		header.Set("traceparent", value)
+		require.False(t, trace.SpanContextFromContext(Extract(context.Background(), header)).IsValid(), value)
+	}
+
+	t.Run("nothing to inject", func(t *testing.T) {
+		header := http.Header{}
+		Inject(context.Background(), header)
+		require.Empty(t, header)
+	})
+}
+
+func TestProvider_Start(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewProvider(exporter, WithServiceName("users"))
+
+	ctx, server := provider.Start(remoteParent("01"), "server", KindServer)
+	_, internal := provider.Start(ctx, "internal", KindInternal, String("key", "value"))
+	internal.SetError(context.Canceled)
+	internal.End()
+	server.SetError(nil)
+	server.End()
+
+	remote := trace.SpanContextFromContext(remoteParent("01"))
+	require.Equal(t, remote.TraceID(), server.SpanContext().TraceID())
+	require.Equal(t, remote.TraceID(), internal.SpanContext().TraceID())
+	require.NotEqual(t, remote.SpanID(), server.SpanContext().SpanID())
+	require.Equal(t, server.SpanContext(), SpanFromContext(ctx).SpanContext())
+
+	require.NoError(t, provider.ForceFlush(context.Background()))
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	require.Equal(t, "internal", spans[0].Name)
+	require.Equal(t, trace.SpanKindInternal, spans[0].SpanKind)
+	require.Equal(t, server.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	require.Equal(t, codes.Error, spans[0].Status.Code)
+	require.Equal(t, "context canceled", spans[0].Status.Description)
+	require.Equal(t, []attribute.KeyValue{String("key", "value")}, spans[0].Attributes)
+
+	require.Equal(t, "server", spans[1].Name)
+	require.Equal(t, trace.SpanKindServer, spans[1].SpanKind)
+	require.Equal(t, remote.SpanID(), spans[1].Parent.SpanID())
+	require.Equal(t, codes.Unset, spans[1].Status.Code)
+	name, ok := spans[1].Resource.Set().Value("service.name")
+	require.True(t, ok)
+	require.Equal(t, "users", name.AsString())
+
+	// spans ended after shutdown are dropped
+	shutdown(t, provider)
+	_, late := provider.Start(context.Background(), "late", KindInternal)
+	late.End()
+	require.Empty(t, exporter.GetSpans())
+}
+
+func TestProvider_Sampling(t *testing.T) {
+	sampled := func(provider *Provider, n int) int {
+		var count int
+		for i := 0; i < n; i++ {
+			_, span := provider.Start(context.Background(), "root", KindServer)
+			if span.SpanContext().IsSampled() {
+				count++
+			}
+		}
+		return count
+	}
+
+	require.Equal(t, 100, sampled(NewProvider(nil), 100), "all traces are sampled by default")
+	require.Zero(t, sampled(NewProvider(nil, WithSampleRatio(0)), 100))
+	require.InDelta(t, 1000, sampled(NewProvider(nil, WithSampleRatio(0.25)), 4000), 200)
+
+	provider := NewProvider(nil, WithSampleRatio(0))
+	for flags, exp := range map[string]bool{"01": true, "00": false} {
+		ctx, server := provider.Start(remoteParent(flags), "server", KindServer)
+		_, internal := provider.Start(ctx, "internal", KindInternal)
+		require.Equal(t, exp, server.SpanContext().IsSampled(), "decision of the remote parent is kept")
+		require.Equal(t, exp, internal.SpanContext().IsSampled(), "decision of the parent is kept")
+	}
+}
+
+type failingExporter struct{}
+
+func (failingExporter) ExportSpans(context.Context, []sdktrace.ReadOnlySpan) error {
+	return errors.New("collector is unavailable")
+}
+
+func (failingExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func TestProvider_Collect(t *testing.T) {
+	for name, tc := range map[string]struct {
+		exporter sdktrace.SpanExporter
+		result   string
+	}{
+		"success": {exporter: tracetest.NewNoopExporter(), result: "success"},
+		"failure": {exporter: failingExporter{}, result: "failure"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			provider := NewProvider(tc.exporter)
+			for i := 0; i < 10; i++ {
+				_, span := provider.Start(remoteParent("01"), "span", KindInternal)
+				span.End()
+			}
+			// spans of the unsampled traces are not exported
+			_, span := provider.Start(remoteParent("00"), "span", KindInternal)
+			span.End()
+			_ = provider.ForceFlush(context.Background())
+
+			counts := map[string]int{"success": 0, "failure": 0}
+			counts[tc.result] = 10
+			expected := "# HELP tracing_spans_ended_total Number of the sampled spans passed for export.\n" +
+				"# TYPE tracing_spans_ended_total counter\n" +
+				"tracing_spans_ended_total 10\n" +
+				"# HELP tracing_spans_exported_total Number of the spans sent by the exporter partitioned by result.\n" +
+				"# TYPE tracing_spans_exported_total counter\n" +
+				"tracing_spans_exported_total{result=\"failure\"} " + strconv.Itoa(counts["failure"]) + "\n" +
+				"tracing_spans_exported_total{result=\"success\"} " + strconv.Itoa(counts["success"]) + "\n"
+			require.NoError(t, testutil.CollectAndCompare(provider, strings.NewReader(expected)))
+
+			shutdown(t, provider)
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer srv.Close()
+
+	ctx, parent := Start(context.Background(), "server", KindServer)
+	defer parent.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	require.Empty(t, req.Header, "request of the caller is not modified")
+	sc := trace.SpanContextFromContext(Extract(context.Background(), got))
+	require.Equal(t, parent.SpanContext().TraceID(), sc.TraceID())
+	require.NotEqual(t, parent.SpanContext().SpanID(), sc.SpanID(), "client span is propagated")
+}
+
+func TestNewStdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter, err := NewStdoutExporter(&buf)
+	require.NoError(t, err)
+
+	provider := NewProvider(exporter)
+	for _, name := range []string{"first", "second"} {
+		_, span := provider.Start(context.Background(), name, KindInternal)
+		span.End()
+	}
+	shutdown(t, provider)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	for i, name := range []string{"first", "second"} {
+		var span struct{ Name string }
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &span))
+		require.Equal(t, name, span.Name)
+	}
+}
+
+func TestNewOTLPExporter(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer srv.Close()
+
+	exporter, err := NewOTLPExporter(context.Background(), srv.URL+"/v1/traces")
+	require.NoError(t, err)
+
+	provider := NewProvider(exporter)
+	_, span := provider.Start(context.Background(), "query", KindClient, Int("rows", 3), Bool("cached", false))
+	span.End()
+	shutdown(t, provider)
+
+	select {
+	case r := <-requests:
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/v1/traces", r.URL.Path)
+		require.Equal(t, "application/x-protobuf", r.Header.Get("content-type"))
+	default:
+		require.Fail(t, "spans are not sent")
+	}
+
+	for _, endpoint := range []string{"localhost:4318", "/v1/traces", "grpc://localhost:4317", "http://%zz"} {
+		_, err := NewOTLPExporter(context.Background(), endpoint)
+		require.Error(t, err, endpoint)
+	}
+}
@@ -0,0 +1,262 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/storage"
+)
+
+// OperationType is a kind of modification made by the batch operation.
+type OperationType string
+
+const (
+	OperationCreate = OperationType("create")
+	OperationUpdate = OperationType("update")
+	OperationDelete = OperationType("delete")
+)
+
+// persistChunkSize is a max number of users saved with a single statement.
+// Each user takes 8 parameters and PostgreSQL allows up to 65535 parameters per statement.
+const persistChunkSize = 1000
+
+// ErrAborted shows that operation was not applied because another operation of the same batch failed.
+var ErrAborted = errors.New("aborted")
+
+// Operation is a single modification of the batch.
+type Operation struct {
+	Type OperationType
+	// ID identifies the user to update or delete.
+	ID string
+	// User holds properties of the user to create or update.
+	User Entity
+}
+
+// OperationResult is an outcome of the single operation of the batch.
+type OperationResult struct {
+	// ID is an identifier of the created, updated or deleted user.
+	ID string
+	// Err describes why operation was not applied.
+	Err error
+}
+
+// Batch applies operations in the provided order and returns an outcome for each of them.
+// In `atomic` mode all operations are applied in a single transaction: if any of them fails
+// none of them is applied and the rest are reported with ErrAborted.
+// Otherwise each operation is applied independently of others.
+func (s *Service) Batch(ctx context.Context, ops []Operation, atomic bool) (results []OperationResult) {
+	ctx, done := instrumentBatch(ctx, ops)
+	defer done(&results)
+
+	results = make([]OperationResult, len(ops))
+	invalid := false
+	for i, op := range ops {
+		results[i].ID = op.ID
+		if err := s.validateOperation(ctx, op); err != nil {
+			results[i].Err = err
+			invalid = true
+		}
+	}
+
+	if !atomic {
+		for _, event := range s.applyBatch(ctx, ops, results, s.storage.WithTx, false) {
+			s.notifier.Notify(ctx, event)
+		}
+		return results
+	}
+
+	if invalid {
+		return abortBatch(ops, results)
+	}
+
+	var events []Event
+	errFailed := errors.New("batch failed")
+	if err := s.storage.WithTx(ctx, func(runner storage.Runner) error {
+		inTx := func(_ context.Context, action func(storage.Runner) error) error {
+			return action(runner)
+		}
+
+		events = s.applyBatch(ctx, ops, results, inTx, true)
+		for _, result := range results {
+			if result.Err != nil {
+				return errFailed
+			}
+		}
+		return nil
+	}); err != nil {
+		if !errors.Is(err, errFailed) {
+			// transaction itself failed, so the outcome of each operation is the same
+			for i := range results {
+				results[i].Err = fmt.Errorf("apply batch: %w", err)
+			}
+			return results
+		}
+		return abortBatch(ops, results)
+	}
+
+	for _, event := range events {
+		s.notifier.Notify(ctx, event)
+	}
+	return results
+}
+
+func (s *Service) validateOperation(ctx context.Context, op Operation) error {
+	switch op.Type {
+	case OperationCreate:
+		return s.validate(ctx, op.User, propertyFirstName, propertyLastName, propertyNickname, propertyEmail, propertyCountry, propertyPassword)
+	case OperationUpdate:
+		if err := validateBlankOrEmptyWithMaxLen(op.ID, "ID", s.settings.IDMaxLen)(); err != nil {
+			return err
+		}
+		return s.validate(ctx, op.User, propertyFirstName, propertyLastName, propertyNickname, propertyEmail, propertyCountry)
+	case OperationDelete:
+		return validateBlankOrEmptyWithMaxLen(op.ID, "ID", s.settings.IDMaxLen)()
+	default:
+		return ValidationError{
+			Cause:   internal.ErrBadInput,
+			Details: map[string]interface{}{"Type": fmt.Sprintf("unsupported operation: %q", op.Type)},
+		}
+	}
+}
+
+// applyBatch applies operations that passed validation and returns events about the modifications made.
+// Each step is executed with a runner provided by `exec`. If `stopOnFailure` is set
+// no operations are applied after the first failed one.
+func (s *Service) applyBatch(
+	ctx context.Context,
+	ops []Operation,
+	results []OperationResult,
+	exec func(context.Context, func(storage.Runner) error) error,
+	stopOnFailure bool,
+) []Event {
+	var events []Event
+	for i := 0; i < len(ops); {
+		if results[i].Err != nil {
+			i++
+			continue
+		}
+
+		var err error
+		switch op := ops[i]; op.Type {
+		case OperationCreate:
+			// consecutive creations are persisted together
+			chunk := []int{i}
+			for i++; i < len(ops) && ops[i].Type == OperationCreate && len(chunk) < persistChunkSize; i++ {
+				if results[i].Err == nil {
+					chunk = append(chunk, i)
+				}
+			}
+
+			var created []Event
+			created, err = s.createMany(ctx, ops, results, chunk, exec, stopOnFailure)
+			events = append(events, created...)
+		case OperationUpdate:
+			var changes Changes
+			err = exec(ctx, func(runner storage.Runner) (err error) {
+				changes, err = s.update(ctx, runner, op.ID, op.User)
+				return err
+			})
+			results[i].Err = err
+			if err == nil && len(changes) > 0 {
+				events = append(events, Event{Type: EventUpdated, UserID: op.ID, Changes: changes, OccurredAt: time.Now().UTC()})
+			}
+			i++
+		case OperationDelete:
+			err = exec(ctx, func(runner storage.Runner) error {
+				return s.storage.Delete(ctx, runner, op.ID)
+			})
+			if err != nil {
+				err = fmt.Errorf("delete user %q: %w", op.ID, err)
+			}
+			results[i].Err = err
+			if err == nil {
+				events = append(events, Event{Type: EventDeleted, UserID: op.ID, OccurredAt: time.Now().UTC()})
+			}
+			i++
+		}
+
+		if err != nil && stopOnFailure {
+			break
+		}
+	}
+
+	return events
+}
+
+// createMany persists users of the operations referenced by `chunk` indexes with a single statement.
+// If the statement fails and `stopOnFailure` is not set the users are persisted one by one
+// to find out the failed ones.
+func (s *Service) createMany(
+	ctx context.Context,
+	ops []Operation,
+	results []OperationResult,
+	chunk []int,
+	exec func(context.Context, func(storage.Runner) error) error,
+	stopOnFailure bool,
+) ([]Event, error) {
+	now := time.Now().UTC()
+	users := make([]storage.User, len(chunk))
+	for i, idx := range chunk {
+		users[i] = entityUser(ops[idx].User)
+		users[i].Password = ops[idx].User.Password
+		users[i].CreatedAt = now
+		users[i].UpdatedAt = now
+	}
+
+	var ids []string
+	if err := exec(ctx, func(runner storage.Runner) (err error) {
+		ids, err = s.storage.PersistMany(ctx, runner, users)
+		return err
+	}); err != nil {
+		if stopOnFailure {
+			for _, idx := range chunk {
+				results[idx].Err = fmt.Errorf("persist users: %w", err)
+			}
+			return nil, err
+		}
+
+		ids = make([]string, len(chunk))
+		for i, idx := range chunk {
+			if err := exec(ctx, func(runner storage.Runner) (err error) {
+				ids[i], err = s.storage.Persist(ctx, runner, users[i])
+				return err
+			}); err != nil {
+				results[idx].Err = fmt.Errorf("persist user: %w", err)
+			}
+		}
+	}
+
+	var events []Event
+	var failed error
+	for i, idx := range chunk {
+		switch {
+		case results[idx].Err != nil:
+			failed = results[idx].Err
+		case ids[i] == "":
+			results[idx].Err = fmt.Errorf("persist user: %w", internal.ErrNotUnique)
+			failed = results[idx].Err
+		default:
+			results[idx].ID = ids[i]
+			events = append(events, Event{Type: EventCreated, UserID: ids[i], OccurredAt: now})
+		}
+	}
+
+	return events, failed
+}
+
+// abortBatch marks all operations without errors as aborted.
+func abortBatch(ops []Operation, results []OperationResult) []OperationResult {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = ErrAborted
+			if ops[i].Type == OperationCreate {
+				// creation was rolled back
+				results[i].ID = ""
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,231 @@
+package user
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewBroker returns initialized broker of user events.
+// It keeps up to `replaySize` most recent events, so subscribers could resume
+// the stream from the last event they have seen.
+// The broker delivers events to the subscribers of this instance only, the Replicator
+// passes it events of all instances.
+func NewBroker(replaySize int) *Broker {
+	if replaySize < 1 {
+		replaySize = 1
+	}
+
+	return &Broker{
+		epoch:       newEpoch(),
+		replay:      make([]Record, replaySize),
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// newEpoch returns a prefix of the event identifiers that distinguishes sequences of different runs of the process,
+// so identifiers issued before restart or reset are not mixed with the new ones.
+func newEpoch() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// Broker fans out user events to the subscribers.
+// It never blocks on delivery: subscriber that can't keep up with the stream is dropped.
+type Broker struct {
+	mtx         sync.Mutex
+	epoch       string
+	seq         uint64
+	replay      []Record // ring buffer of the recent events
+	start, size int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// Record is an event with an identifier assigned by the broker.
+type Record struct {
+	// ID is a unique identifier of the event, it can be used to resume the stream.
+	ID string
+	Event
+
+	seq uint64
+}
+
+// Notify assigns identifier to the event, stores it for replay and delivers to all subscribers.
+func (b *Broker) Notify(_ context.Context, event Event) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.seq++
+	rec := Record{ID: b.epoch + "-" + strconv.FormatUint(b.seq, 10), Event: event, seq: b.seq}
+
+	if b.size < len(b.replay) {
+		b.replay[(b.start+b.size)%len(b.replay)] = rec
+		b.size++
+	} else {
+		b.replay[b.start] = rec
+		b.start = (b.start + 1) % len(b.replay)
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter(event) {
+			continue
+		}
+
+		select {
+		case sub.events <- rec:
+		default:
+			// the subscriber is too slow, it should reconnect and resume from the last seen event
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events accepted by `filter`.
+// If `lastEventID` is not empty the events that follow it are returned as a backlog of the subscription.
+// `bufferSize` is a number of events that could be queued for the subscriber before it is dropped.
+func (b *Broker) Subscribe(lastEventID string, bufferSize int, filter func(Event) bool) *Subscription {
+	if filter == nil {
+		filter = func(Event) bool { return true }
+	}
+
+	sub := &Subscription{
+		broker:  b,
+		filter:  filter,
+		events:  make(chan Record, bufferSize),
+		dropped: make(chan struct{}),
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.closed {
+		close(sub.dropped)
+		return sub
+	}
+
+	if lastEventID != "" {
+		sub.backlog, sub.gap = b.since(lastEventID, filter)
+	}
+
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// since returns events stored after the event with provided identifier.
+// `gap` is set if some events that follow it are not available anymore.
+func (b *Broker) since(lastEventID string, filter func(Event) bool) (backlog []Record, gap bool) {
+	var lastSeq uint64
+	epoch, seq, found := splitEventID(lastEventID)
+	if found && epoch == b.epoch {
+		var err error
+		lastSeq, err = strconv.ParseUint(seq, 10, 64)
+		gap = err != nil || lastSeq > b.seq
+	} else {
+		gap = true
+	}
+
+	if gap {
+		lastSeq = 0
+	}
+
+	for i := 0; i < b.size; i++ {
+		rec := b.replay[(b.start+i)%len(b.replay)]
+		if i == 0 && rec.seq > lastSeq+1 {
+			gap = true
+		}
+
+		if rec.seq > lastSeq && filter(rec.Event) {
+			backlog = append(backlog, rec)
+		}
+	}
+
+	return backlog, gap
+}
+
+func splitEventID(id string) (epoch, seq string, found bool) {
+	i := strings.LastIndexByte(id, '-')
+	if i < 0 {
+		return "", "", false
+	}
+	return id[:i], id[i+1:], true
+}
+
+// Reset forgets the stored events and drops all subscriptions, it is used when some events could be lost.
+// Subscribers that resume the stream afterwards are told about the gap.
+func (b *Broker) Reset() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.epoch = newEpoch()
+	b.start, b.size = 0, 0
+	for sub := range b.subscribers {
+		b.drop(sub)
+	}
+}
+
+// Close drops all subscriptions and prevents new ones from receiving events.
+func (b *Broker) Close() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.drop(sub)
+	}
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		b.drop(sub)
+	}
+}
+
+func (b *Broker) drop(sub *Subscription) {
+	delete(b.subscribers, sub)
+	close(sub.dropped)
+}
+
+// Subscription is a stream of events delivered by the broker.
+type Subscription struct {
+	broker  *Broker
+	filter  func(Event) bool
+	backlog []Record
+	gap     bool
+	events  chan Record
+	dropped chan struct{}
+}
+
+// Backlog returns events that happened after the one used to resume the stream.
+func (s *Subscription) Backlog() []Record {
+	return s.backlog
+}
+
+// Gap reports if some of the events preceding the backlog are lost and can't be replayed.
+func (s *Subscription) Gap() bool {
+	return s.gap
+}
+
+// Events returns channel of the new events.
+func (s *Subscription) Events() <-chan Record {
+	return s.events
+}
+
+// Dropped returns channel that is closed once the subscription stops receiving events.
+// It happens if subscriber can't keep up with the stream or broker is closed.
+func (s *Subscription) Dropped() <-chan struct{} {
+	return s.dropped
+}
+
+// Close releases the subscription.
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
@@ -0,0 +1,92 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_Subscribe(t *testing.T) {
+	t.Run("resume", func(t *testing.T) {
+		broker := NewBroker(10)
+		broker.Notify(Context(), Event{Type: EventCreated, UserID: "1"})
+		broker.Notify(Context(), Event{Type: EventCreated, UserID: "2"})
+
+		first := broker.Subscribe("", 1, nil)
+		defer first.Close()
+		require.Empty(t, first.Backlog())
+
+		broker.Notify(Context(), Event{Type: EventUpdated, UserID: "2"})
+		rec := <-first.Events()
+		require.Equal(t, "2", rec.UserID)
+
+		resumed := broker.Subscribe(rec.ID, 1, nil)
+		defer resumed.Close()
+		require.False(t, resumed.Gap())
+		require.Empty(t, resumed.Backlog())
+
+		broker.Notify(Context(), Event{Type: EventDeleted, UserID: "1"})
+		resumed = broker.Subscribe(rec.ID, 1, func(event Event) bool { return event.UserID == "1" })
+		defer resumed.Close()
+		require.False(t, resumed.Gap())
+		require.Len(t, resumed.Backlog(), 1)
+		require.Equal(t, EventDeleted, resumed.Backlog()[0].Type)
+	})
+
+	t.Run("gap", func(t *testing.T) {
+		broker := NewBroker(2)
+		broker.Notify(Context(), Event{Type: EventCreated, UserID: "1"})
+		sub := broker.Subscribe("", 5, nil)
+		broker.Notify(Context(), Event{Type: EventCreated, UserID: "2"})
+		lastSeen := (<-sub.Events()).ID
+		sub.Close()
+
+		for _, id := range []string{"3", "4", "5"} {
+			broker.Notify(Context(), Event{Type: EventCreated, UserID: id})
+		}
+
+		resumed := broker.Subscribe(lastSeen, 1, nil)
+		defer resumed.Close()
+		require.True(t, resumed.Gap())
+		require.Len(t, resumed.Backlog(), 2)
+
+		unknown := broker.Subscribe("unknown-1", 1, nil)
+		defer unknown.Close()
+		require.True(t, unknown.Gap())
+	})
+
+	t.Run("slow subscriber is dropped", func(t *testing.T) {
+		broker := NewBroker(10)
+		sub := broker.Subscribe("", 1, nil)
+		broker.Notify(Context(), Event{Type: EventCreated, UserID: "1"})
+		broker.Notify(Context(), Event{Type: EventCreated, UserID: "2"})
+
+		select {
+		case <-sub.Dropped():
+		default:
+			t.Fatal("subscription expected to be dropped")
+		}
+		sub.Close() // must be safe to call after drop
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		broker := NewBroker(10)
+		sub := broker.Subscribe("", 5, nil)
+		broker.Notify(Context(), Event{Type: EventCreated, UserID: "1"})
+		lastSeen := (<-sub.Events()).ID
+
+		broker.Reset()
+		select {
+		case <-sub.Dropped():
+		default:
+			t.Fatal("subscription expected to be dropped")
+		}
+
+		broker.Notify(Context(), Event{Type: EventCreated, UserID: "2"})
+		resumed := broker.Subscribe(lastSeen, 1, nil)
+		defer resumed.Close()
+		require.True(t, resumed.Gap(), "events could be lost before reset")
+		require.Len(t, resumed.Backlog(), 1)
+		require.Equal(t, "2", resumed.Backlog()[0].UserID)
+	})
+}
@@ -0,0 +1,185 @@
+package user
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/pavelmemory/faceit-users/internal/cache"
+	"github.com/pavelmemory/faceit-users/internal/storage"
+)
+
+var (
+	cacheRequestsDesc = prometheus.NewDesc("users_cache_requests_total",
+		"Number of user retrievals from the cache partitioned by result.", []string{"result"}, nil)
+	cacheEvictionsDesc = prometheus.NewDesc("users_cache_evictions_total",
+		"Number of users evicted from the cache to not exceed its size.", nil, nil)
+	cacheFlushesDesc = prometheus.NewDesc("users_cache_flushes_total",
+		"Number of times the cache was flushed because of missed change notifications.", nil, nil)
+	cacheEntriesDesc = prometheus.NewDesc("users_cache_entries",
+		"Number of users in the cache.", nil, nil)
+)
+
+// loadTimeout limits retrieval of the user shared by concurrent callers, as it outlives the caller started it.
+const loadTimeout = 10 * time.Second
+
+// NewCachedStorage returns a storage that caches up to `size` users retrieved from `storage` for `ttl`.
+func NewCachedStorage(storage Storage, size int, ttl time.Duration) *CachedStorage {
+	return &CachedStorage{Storage: storage, cache: cache.NewLRU(size, ttl)}
+}
+
+// CachedStorage is a read-through cache of the users.
+// Only users retrieved outside of the transactions are cached, so the cache never holds uncommitted changes.
+// Modified users are removed from the cache once the transaction of the modification is committed.
+// Users modified by other instances of the service are removed on notifications, see storage.Postgres.ListenUserChanges.
+type CachedStorage struct {
+	// counters are accessed atomically, so they are first to be 64-bit aligned
+	hits    uint64
+	misses  uint64
+	flushes uint64
+
+	Storage
+	cache *cache.LRU
+	loads cache.Group
+}
+
+// cachedTxRunner runs statements of the transaction and collects users modified by them.
+type cachedTxRunner struct {
+	storage.Runner
+	modified *[]string
+}
+
+// WithTx executes provided callback inside of the transaction.
+// The users modified by the callback are removed from the cache after the commit.
+func (cs *CachedStorage) WithTx(ctx context.Context, action func(runner storage.Runner) error) error {
+	var modified []string
+	if err := cs.Storage.WithTx(ctx, func(runner storage.Runner) error {
+		return action(cachedTxRunner{Runner: runner, modified: &modified})
+	}); err != nil {
+		return err
+	}
+
+	for _, id := range modified {
+		cs.cache.Remove(id)
+	}
+	return nil
+}
+
+// Retrieve returns the cached user or retrieves it from the storage.
+// Concurrent retrievals of the same user that is not cached are collapsed into a single one,
+// it is not cancelled with the caller that started it as the others wait for it as well.
+func (cs *CachedStorage) Retrieve(ctx context.Context, run storage.Runner, id string, forUpdate bool) (storage.User, error) {
+	if _, inTx := run.(cachedTxRunner); inTx || forUpdate {
+		return cs.Storage.Retrieve(ctx, run, id, forUpdate)
+	}
+
+	if u, ok := cs.cache.Get(id); ok {
+		atomic.AddUint64(&cs.hits, 1)
+		return u.(storage.User), nil
+	}
+	atomic.AddUint64(&cs.misses, 1)
+
+	u, err, _ := cs.loads.Do(id, func() (interface{}, error) {
+		load := cs.cache.BeginLoad(id)
+		defer load.Abort()
+
+		ctx, cancel := context.WithTimeout(detached{parent: ctx}, loadTimeout)
+		defer cancel()
+
+		u, err := cs.Storage.Retrieve(ctx, run, id, false)
+		if err != nil {
+			return storage.User{}, err
+		}
+
+		load.Add(u)
+		return u, nil
+	})
+	return u.(storage.User), err
+}
+
+// Update updates properties of the existing user and invalidates its cached value.
+func (cs *CachedStorage) Update(ctx context.Context, run storage.Runner, id string, user storage.User) (storage.User, error) {
+	defer cs.invalidate(run, id)
+	return cs.Storage.Update(ctx, run, id, user)
+}
+
+// Delete deletes user entity by its identifier and invalidates its cached value.
+func (cs *CachedStorage) Delete(ctx context.Context, run storage.Runner, id string) error {
+	defer cs.invalidate(run, id)
+	return cs.Storage.Delete(ctx, run, id)
+}
+
+// ChangePassword replaces the password of the user and invalidates its cached value.
+func (cs *CachedStorage) ChangePassword(ctx context.Context, run storage.Runner, id, current, password string, updatedAt time.Time) error {
+	defer cs.invalidate(run, id)
+	return cs.Storage.ChangePassword(ctx, run, id, current, password, updatedAt)
+}
+
+// Purge removes all users from the cache.
+func (cs *CachedStorage) Purge() {
+	cs.cache.Purge()
+}
+
+// UserChanged removes the user modified by any instance of the service from the cache
+// unless the cached one is already of the same or newer version.
+func (cs *CachedStorage) UserChanged(change storage.UserChange) {
+	cs.cache.RemoveIf(change.ID, func(value interface{}) bool {
+		return value.(storage.User).Version < change.Version
+	})
+}
+
+// ChangesMissed removes all users from the cache as any of them could be modified.
+func (cs *CachedStorage) ChangesMissed() {
+	atomic.AddUint64(&cs.flushes, 1)
+	cs.Purge()
+}
+
+// Describe sends descriptions of the cache metrics.
+func (cs *CachedStorage) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheRequestsDesc
+	ch <- cacheEvictionsDesc
+	ch <- cacheFlushesDesc
+	ch <- cacheEntriesDesc
+}
+
+// Collect sends statistics of the cache.
+func (cs *CachedStorage) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(cacheRequestsDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&cs.hits)), "hit")
+	ch <- prometheus.MustNewConstMetric(cacheRequestsDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&cs.misses)), "miss")
+	ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(cs.cache.Evictions()))
+	ch <- prometheus.MustNewConstMetric(cacheFlushesDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&cs.flushes)))
+	ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(cs.cache.Len()))
+}
+
+// invalidate removes the user from the cache. The removal is postponed till the commit
+// if the user is modified inside of the transaction.
+func (cs *CachedStorage) invalidate(run storage.Runner, id string) {
+	if txRunner, inTx := run.(cachedTxRunner); inTx {
+		*txRunner.modified = append(*txRunner.modified, id)
+		return
+	}
+	cs.cache.Remove(id)
+}
+
+// detached is a context with the values of the parent, but without its deadline and cancellation.
+type detached struct {
+	parent context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
+
+func (d detached) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
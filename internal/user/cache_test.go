@@ -0,0 +1,203 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/storage"
+)
+
+func TestCachedStorage(t *testing.T) {
+	setup := func(t *testing.T) (*CachedStorage, *MockStorage, func()) {
+		ctrl := gomock.NewController(t)
+		mockStorage := NewMockStorage(ctrl)
+		cached := NewCachedStorage(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, 10, time.Minute)
+		return cached, mockStorage, ctrl.Finish
+	}
+
+	retrieve := func(t *testing.T, cs *CachedStorage) storage.User {
+		var u storage.User
+		require.NoError(t, cs.WithoutTx(Context(), func(runner storage.Runner) (err error) {
+			u, err = cs.Retrieve(Context(), runner, "1", false)
+			return err
+		}))
+		return u
+	}
+
+	t.Run("read through", func(t *testing.T) {
+		cs, mockStorage, finish := setup(t)
+		defer finish()
+
+		mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).Return(storage.User{ID: "1", Nickname: "nn"}, nil).Times(1)
+
+		require.Equal(t, "nn", retrieve(t, cs).Nickname)
+		require.Equal(t, "nn", retrieve(t, cs).Nickname, "cached user is returned")
+		require.Equal(t, uint64(1), atomic.LoadUint64(&cs.hits))
+		require.Equal(t, uint64(1), atomic.LoadUint64(&cs.misses))
+	})
+
+	t.Run("transaction bypasses cache", func(t *testing.T) {
+		cs, mockStorage, finish := setup(t)
+		defer finish()
+
+		mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).Return(storage.User{ID: "1"}, nil).Times(2)
+
+		for i := 0; i < 2; i++ {
+			require.NoError(t, cs.WithTx(Context(), func(runner storage.Runner) error {
+				_, err := cs.Retrieve(Context(), runner, "1", false)
+				return err
+			}))
+		}
+	})
+
+	t.Run("invalidated after commit", func(t *testing.T) {
+		cs, mockStorage, finish := setup(t)
+		defer finish()
+
+		gomock.InOrder(
+			mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).Return(storage.User{ID: "1", Nickname: "old"}, nil),
+			mockStorage.EXPECT().Update(gomock.Any(), gomock.Any(), "1", gomock.Any()).Return(storage.User{}, nil),
+			mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).Return(storage.User{ID: "1", Nickname: "new"}, nil),
+		)
+
+		require.Equal(t, "old", retrieve(t, cs).Nickname)
+		require.NoError(t, cs.WithTx(Context(), func(runner storage.Runner) error {
+			if _, err := cs.Update(Context(), runner, "1", storage.User{Nickname: "new"}); err != nil {
+				return err
+			}
+			require.Equal(t, "old", retrieve(t, cs).Nickname, "uncommitted change is not visible")
+			return nil
+		}))
+		require.Equal(t, "new", retrieve(t, cs).Nickname)
+	})
+
+	t.Run("not invalidated after rollback", func(t *testing.T) {
+		cs, mockStorage, finish := setup(t)
+		defer finish()
+
+		mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).Return(storage.User{ID: "1", Nickname: "old"}, nil).Times(1)
+		mockStorage.EXPECT().Delete(gomock.Any(), gomock.Any(), "1").Return(nil)
+
+		require.Equal(t, "old", retrieve(t, cs).Nickname)
+		err := cs.WithTx(Context(), func(runner storage.Runner) error {
+			require.NoError(t, cs.Delete(Context(), runner, "1"))
+			return assert.AnError
+		})
+		require.Equal(t, assert.AnError, err)
+		require.Equal(t, "old", retrieve(t, cs).Nickname)
+	})
+
+	t.Run("changed by other instance", func(t *testing.T) {
+		cs, mockStorage, finish := setup(t)
+		defer finish()
+
+		gomock.InOrder(
+			mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).Return(storage.User{ID: "1", Version: 2, Nickname: "old"}, nil),
+			mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).Return(storage.User{ID: "1", Version: 3, Nickname: "new"}, nil),
+		)
+
+		require.Equal(t, "old", retrieve(t, cs).Nickname)
+		cs.UserChanged(storage.UserChange{ID: "1", Version: 2})
+		require.Equal(t, "old", retrieve(t, cs).Nickname, "cached version is not older")
+		cs.UserChanged(storage.UserChange{ID: "1", Version: 3})
+		require.Equal(t, "new", retrieve(t, cs).Nickname)
+	})
+
+	t.Run("flushed after missed changes", func(t *testing.T) {
+		cs, mockStorage, finish := setup(t)
+		defer finish()
+
+		mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).Return(storage.User{ID: "1"}, nil).Times(2)
+
+		retrieve(t, cs)
+		cs.ChangesMissed()
+		retrieve(t, cs)
+		require.Equal(t, uint64(1), atomic.LoadUint64(&cs.flushes))
+	})
+
+	t.Run("load is detached from the caller", func(t *testing.T) {
+		cs, mockStorage, finish := setup(t)
+		defer finish()
+
+		type key struct{}
+		ctx, cancel := context.WithCancel(context.WithValue(Context(), key{}, "value"))
+		cancel()
+		mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).
+			DoAndReturn(func(ctx context.Context, _ storage.Runner, _ string, _ bool) (storage.User, error) {
+				require.NoError(t, ctx.Err(), "not cancelled with the caller")
+				_, ok := ctx.Deadline()
+				require.True(t, ok, "limited with its own timeout")
+				require.Equal(t, "value", ctx.Value(key{}), "values of the caller are kept")
+				return storage.User{ID: "1"}, nil
+			})
+
+		require.NoError(t, cs.WithoutTx(ctx, func(runner storage.Runner) error {
+			_, err := cs.Retrieve(ctx, runner, "1", false)
+			return err
+		}))
+	})
+
+	t.Run("changes of other users don't affect the load", func(t *testing.T) {
+		cs, mockStorage, finish := setup(t)
+		defer finish()
+
+		mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).
+			DoAndReturn(func(context.Context, storage.Runner, string, bool) (storage.User, error) {
+				cs.UserChanged(storage.UserChange{ID: "2", Version: 2})
+				return storage.User{ID: "1"}, nil
+			}).Times(1)
+
+		retrieve(t, cs)
+		retrieve(t, cs)
+	})
+
+	t.Run("changed while loaded", func(t *testing.T) {
+		cs, mockStorage, finish := setup(t)
+		defer finish()
+
+		mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).
+			DoAndReturn(func(context.Context, storage.Runner, string, bool) (storage.User, error) {
+				cs.UserChanged(storage.UserChange{ID: "1", Version: 2})
+				return storage.User{ID: "1", Version: 1}, nil
+			})
+		mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).Return(storage.User{ID: "1", Version: 2}, nil)
+
+		require.Equal(t, int64(1), retrieve(t, cs).Version)
+		require.Equal(t, int64(2), retrieve(t, cs).Version, "stale user is not cached")
+		require.Equal(t, int64(2), retrieve(t, cs).Version)
+	})
+
+	t.Run("concurrent misses are collapsed", func(t *testing.T) {
+		cs, mockStorage, finish := setup(t)
+		defer finish()
+
+		release := make(chan struct{})
+		mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", false).
+			DoAndReturn(func(context.Context, storage.Runner, string, bool) (storage.User, error) {
+				<-release
+				return storage.User{ID: "1"}, nil
+			}).Times(1)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				retrieve(t, cs)
+			}()
+		}
+		require.Eventually(t, func() bool {
+			return atomic.LoadUint64(&cs.misses) == 5
+		}, time.Second, time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+	})
+}
@@ -0,0 +1,36 @@
+package user
+
+import (
+	"context"
+	"time"
+)
+
+// EventType is a kind of modification made to the user entity.
+type EventType string
+
+const (
+	EventCreated = EventType("created")
+	EventUpdated = EventType("updated")
+	EventDeleted = EventType("deleted")
+)
+
+// Event is a notification about modification of the user entity.
+type Event struct {
+	Type EventType
+	// UserID is a unique identifier of the modified user.
+	UserID string
+	// Changes holds properties that were changed by the update (empty for other types).
+	Changes Changes
+	// OccurredAt is a moment when modification was made.
+	OccurredAt time.Time
+}
+
+// Notifier delivers notifications about modifications of the user entities.
+type Notifier interface {
+	// Notify sends event to the interested parties. It must not block the caller.
+	Notify(ctx context.Context, event Event)
+}
+
+type nopNotifier struct{}
+
+func (nopNotifier) Notify(context.Context, Event) {}
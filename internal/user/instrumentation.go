@@ -0,0 +1,80 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/metrics"
+	"github.com/pavelmemory/faceit-users/internal/tracing"
+)
+
+var (
+	operationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "users_service_operations_total",
+		Help: "Number of user service operations partitioned by outcome.",
+	}, []string{"operation", "outcome"})
+	operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "users_service_operation_duration_seconds",
+		Help:    "Duration of user service operations.",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"operation"})
+)
+
+func init() {
+	metrics.Default.MustRegister(operationsTotal, operationDuration)
+}
+
+// instrument starts a span of the operation and returns a function that ends it
+// and records outcome and duration of the operation.
+// The function is designed to be deferred, so it accepts a pointer to the named error result.
+func instrument(ctx context.Context, operation string) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "user.Service/"+operation, tracing.KindInternal)
+	return ctx, func(err *error) {
+		span.SetError(*err)
+		span.End()
+		operationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		operationsTotal.WithLabelValues(operation, outcome(*err)).Inc()
+	}
+}
+
+// outcome returns a label value that describes the error.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, internal.ErrBadInput):
+		return "bad_input"
+	case errors.Is(err, internal.ErrNotUnique):
+		return "not_unique"
+	case errors.Is(err, internal.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrAborted):
+		return "aborted"
+	default:
+		return "error"
+	}
+}
+
+// instrumentBatch is the same as instrument, but records outcome of each operation of the batch.
+func instrumentBatch(ctx context.Context, ops []Operation) (context.Context, func(results *[]OperationResult)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "user.Service/batch", tracing.KindInternal, tracing.Int("batch.size", len(ops)))
+	return ctx, func(results *[]OperationResult) {
+		span.End()
+		operationDuration.WithLabelValues("batch").Observe(time.Since(start).Seconds())
+		for i, result := range *results {
+			operation := "batch_unknown"
+			switch ops[i].Type {
+			case OperationCreate, OperationUpdate, OperationDelete:
+				// type of the operation comes from the client, so only known values are used as labels
+				operation = "batch_" + string(ops[i].Type)
+			}
+			operationsTotal.WithLabelValues(operation, outcome(result.Err)).Inc()
+		}
+	}
+}
@@ -6,11 +6,14 @@ import (
 
 func userEntity(u storage.User) Entity {
 	return Entity{
+		ID:        u.ID,
 		FirstName: u.FirstName,
 		LastName:  u.LastName,
 		Nickname:  u.Nickname,
 		Email:     u.Email,
 		Country:   u.Country,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
 	}
 }
 
@@ -7,6 +7,7 @@ package user
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 
@@ -130,6 +131,21 @@ func (mr *MockStorageMockRecorder) Persist(ctx, run, user interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Persist", reflect.TypeOf((*MockStorage)(nil).Persist), ctx, run, user)
 }
 
+// PersistMany mocks base method
+func (m *MockStorage) PersistMany(ctx context.Context, run storage.Runner, users []storage.User) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PersistMany", ctx, run, users)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PersistMany indicates an expected call of PersistMany
+func (mr *MockStorageMockRecorder) PersistMany(ctx, run, users interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PersistMany", reflect.TypeOf((*MockStorage)(nil).PersistMany), ctx, run, users)
+}
+
 // Retrieve mocks base method
 func (m *MockStorage) Retrieve(ctx context.Context, run storage.Runner, id string, forUpdate bool) (storage.User, error) {
 	m.ctrl.T.Helper()
@@ -173,3 +189,61 @@ func (mr *MockStorageMockRecorder) Delete(ctx, runner, id interface{}) *gomock.C
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStorage)(nil).Delete), ctx, runner, id)
 }
+
+// Scan mocks base method
+func (m *MockStorage) Scan(ctx context.Context, runner storage.Runner, batchSize int, fn func(storage.User) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Scan", ctx, runner, batchSize, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Scan indicates an expected call of Scan
+func (mr *MockStorageMockRecorder) Scan(ctx, runner, batchSize, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockStorage)(nil).Scan), ctx, runner, batchSize, fn)
+}
+
+// Import mocks base method
+func (m *MockStorage) Import(ctx context.Context, runner storage.Runner, users func(func(int, storage.User) error) error) ([]string, []int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", ctx, runner, users)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].([]int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Import indicates an expected call of Import
+func (mr *MockStorageMockRecorder) Import(ctx, runner, users interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockStorage)(nil).Import), ctx, runner, users)
+}
+
+// ChangePassword mocks base method
+func (m *MockStorage) ChangePassword(ctx context.Context, runner storage.Runner, id, current, password string, updatedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, runner, id, current, password, updatedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword
+func (mr *MockStorageMockRecorder) ChangePassword(ctx, runner, id, current, password, updatedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockStorage)(nil).ChangePassword), ctx, runner, id, current, password, updatedAt)
+}
+
+// RevokeSessions mocks base method
+func (m *MockStorage) RevokeSessions(ctx context.Context, runner storage.Runner, userID string, revokedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSessions", ctx, runner, userID, revokedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSessions indicates an expected call of RevokeSessions
+func (mr *MockStorageMockRecorder) RevokeSessions(ctx, runner, userID, revokedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSessions", reflect.TypeOf((*MockStorage)(nil).RevokeSessions), ctx, runner, userID, revokedAt)
+}
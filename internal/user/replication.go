@@ -0,0 +1,90 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// publishQueueSize is a max number of the events waiting to be published.
+const publishQueueSize = 1024
+
+// EventPublisher delivers the encoded events to all instances of the service, including the publishing one.
+type EventPublisher interface {
+	PublishUserEvent(ctx context.Context, payload []byte) error
+}
+
+// NewReplicator returns a notifier that publishes events with `publisher`, so subscribers of all instances receive them.
+// The events published by any of the instances are passed to the `local` broker once received with EventPublished.
+// `onError` is called with failures of the publishing, it is allowed to be nil.
+func NewReplicator(publisher EventPublisher, local *Broker, onError func(error)) *Replicator {
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	return &Replicator{
+		publisher: publisher,
+		local:     local,
+		onError:   onError,
+		queue:     make(chan Event, publishQueueSize),
+	}
+}
+
+// Replicator fans out user events to the subscribers of all instances of the service.
+type Replicator struct {
+	publisher EventPublisher
+	local     *Broker
+	onError   func(error)
+	queue     chan Event
+}
+
+// Notify queues the event for publishing, it never blocks.
+// If the queue is full the event is delivered only to the subscribers of this instance.
+func (r *Replicator) Notify(ctx context.Context, event Event) {
+	select {
+	case r.queue <- event:
+	default:
+		r.onError(errors.New("publish queue is full, the event is delivered locally"))
+		r.local.Notify(ctx, event)
+	}
+}
+
+// Run publishes queued events in order until the `ctx` is cancelled.
+// The event that can't be published is delivered only to the subscribers of this instance.
+func (r *Replicator) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-r.queue:
+			if err := r.publish(ctx, event); err != nil {
+				r.onError(fmt.Errorf("publish %s event of user %s, it is delivered locally: %w", event.Type, event.UserID, err))
+				r.local.Notify(ctx, event)
+			}
+		}
+	}
+}
+
+func (r *Replicator) publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return r.publisher.PublishUserEvent(ctx, payload)
+}
+
+// EventPublished delivers the event published by any of the instances to the subscribers of this instance.
+func (r *Replicator) EventPublished(payload []byte) {
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		r.onError(fmt.Errorf("decode published event: %w", err))
+		return
+	}
+	r.local.Notify(context.Background(), event)
+}
+
+// EventsMissed resets the local broker, as the subscribers need to re-read the state once some events are lost.
+func (r *Replicator) EventsMissed() {
+	r.local.Reset()
+}
@@ -0,0 +1,106 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// loopbackPublisher delivers published events back to the replicator, the same way as the database does.
+type loopbackPublisher struct {
+	replicator *Replicator
+	err        error
+}
+
+func (lp *loopbackPublisher) PublishUserEvent(_ context.Context, payload []byte) error {
+	if lp.err != nil {
+		return lp.err
+	}
+	lp.replicator.EventPublished(payload)
+	return nil
+}
+
+func TestReplicator(t *testing.T) {
+	receive := func(t *testing.T, sub *Subscription) Record {
+		t.Helper()
+		select {
+		case rec := <-sub.Events():
+			return rec
+		case <-time.After(time.Second):
+			t.Fatal("event expected")
+			return Record{}
+		}
+	}
+
+	t.Run("published", func(t *testing.T) {
+		broker := NewBroker(10)
+		publisher := &loopbackPublisher{}
+		replicator := NewReplicator(publisher, broker, func(err error) { t.Error(err) })
+		publisher.replicator = replicator
+
+		ctx, cancel := context.WithCancel(Context())
+		defer cancel()
+		go replicator.Run(ctx)
+
+		sub := broker.Subscribe("", 5, nil)
+		defer sub.Close()
+
+		occurredAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		changes := Changes{}
+		changes.Add("Nickname", "old", "new")
+		replicator.Notify(Context(), Event{Type: EventUpdated, UserID: "1", Changes: changes, OccurredAt: occurredAt})
+
+		rec := receive(t, sub)
+		require.Equal(t, Event{Type: EventUpdated, UserID: "1", Changes: changes, OccurredAt: occurredAt}, rec.Event)
+	})
+
+	t.Run("publishing failed", func(t *testing.T) {
+		broker := NewBroker(10)
+		var errs []error
+		replicator := NewReplicator(&loopbackPublisher{err: errors.New("connection refused")}, broker, func(err error) {
+			errs = append(errs, err)
+		})
+
+		sub := broker.Subscribe("", 5, nil)
+		defer sub.Close()
+
+		ctx, cancel := context.WithCancel(Context())
+		replicator.Notify(Context(), Event{Type: EventCreated, UserID: "1"})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			replicator.Run(ctx)
+		}()
+
+		rec := receive(t, sub)
+		require.Equal(t, "1", rec.UserID, "event is delivered locally")
+		cancel()
+		<-done
+		require.Len(t, errs, 1)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		broker := NewBroker(10)
+		var errs []error
+		replicator := NewReplicator(&loopbackPublisher{}, broker, func(err error) { errs = append(errs, err) })
+
+		replicator.EventPublished([]byte("{"))
+		require.Len(t, errs, 1)
+	})
+
+	t.Run("missed", func(t *testing.T) {
+		broker := NewBroker(10)
+		replicator := NewReplicator(&loopbackPublisher{}, broker, nil)
+		sub := broker.Subscribe("", 5, nil)
+
+		replicator.EventsMissed()
+		select {
+		case <-sub.Dropped():
+		default:
+			t.Fatal("subscription expected to be dropped")
+		}
+	})
+}
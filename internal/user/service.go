@@ -2,19 +2,31 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/featureflags"
 	"github.com/pavelmemory/faceit-users/internal/storage"
 )
 
+// FlagStrictValidation is a feature flag that enables stricter validation of the users:
+// the country must be an ISO 3166-1 alpha-2 code in upper case and the nickname must not contain whitespaces.
+const FlagStrictValidation = "users.strict_validation"
+
 type Entity struct {
+	// ID is a unique identifier of the user, it is assigned on creation.
+	ID        string
 	FirstName string
 	LastName  string
 	Nickname  string
 	Email     string
 	Password  string
 	Country   string
+	// CreatedAt and UpdatedAt are maintained by the service and ignored on input.
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 //go:generate mockgen -source=service.go -destination mock.go -package user Storage
@@ -34,6 +46,9 @@ type Storage interface {
 	// Persist saves the user and returns it's unique generated ID.
 	// It returns an error in case email or nickname is not unique.
 	Persist(ctx context.Context, run storage.Runner, user storage.User) (string, error)
+	// PersistMany saves users and returns their unique generated IDs in the same order.
+	// Users with not unique email or nickname are skipped and have an empty ID.
+	PersistMany(ctx context.Context, run storage.Runner, users []storage.User) ([]string, error)
 	// Retrieve returns user by supplied 'id'.
 	// If user doesn't exist it returns an error.
 	Retrieve(ctx context.Context, run storage.Runner, id string, forUpdate bool) (storage.User, error)
@@ -42,22 +57,79 @@ type Storage interface {
 	Update(ctx context.Context, runner storage.Runner, id string, user storage.User) (storage.User, error)
 	// Delete deletes user entity by its identifier.
 	Delete(ctx context.Context, runner storage.Runner, id string) error
+	// Scan calls `fn` for each user fetching them by `batchSize` at a time.
+	// It must be used inside of the transaction.
+	Scan(ctx context.Context, runner storage.Runner, batchSize int, fn func(storage.User) error) error
+	// Import bulk saves users produced by `users`, each user is identified by `line` number.
+	// It returns identifiers of the imported users, users that are not unique are skipped and their line numbers are returned.
+	// It must be used inside of the transaction.
+	Import(ctx context.Context, runner storage.Runner, users func(yield func(line int, user storage.User) error) error) ([]string, []int, error)
+	// ChangePassword replaces the password of the user if the `current` one matches.
+	// It returns an error if the `current` password doesn't match.
+	ChangePassword(ctx context.Context, runner storage.Runner, id, current, password string, updatedAt time.Time) error
+	// RevokeSessions revokes all sessions of the user.
+	RevokeSessions(ctx context.Context, runner storage.Runner, userID string, revokedAt time.Time) error
+}
+
+// Settings limit the properties of the users, the lengths are measured in characters.
+type Settings struct {
+	FirstNameMaxLen int
+	LastNameMaxLen  int
+	NicknameMaxLen  int
+	PasswordMaxLen  int
+	CountryMaxLen   int
+	IDMaxLen        int
+}
+
+// DefaultSettings returns the limits that fit the columns of the users table.
+func DefaultSettings() Settings {
+	return Settings{
+		FirstNameMaxLen: 50,
+		LastNameMaxLen:  50,
+		NicknameMaxLen:  30,
+		PasswordMaxLen:  20,
+		CountryMaxLen:   2,
+		IDMaxLen:        36,
+	}
+}
+
+// Option allows to customize the service.
+type Option func(s *Service)
+
+// WithSettings replaces DefaultSettings of the service.
+func WithSettings(settings Settings) Option {
+	return func(s *Service) {
+		s.settings = settings
+	}
 }
 
 // NewService returns initialized user service.
-func NewService(storage Storage) *Service {
-	return &Service{storage: storage}
+// `notifier` receives notifications about user modifications, it is allowed to be nil.
+func NewService(storage Storage, notifier Notifier, options ...Option) *Service {
+	if notifier == nil {
+		notifier = nopNotifier{}
+	}
+	s := &Service{storage: storage, notifier: notifier, settings: DefaultSettings()}
+	for _, option := range options {
+		option(s)
+	}
+	return s
 }
 
 // Service allows to CRUD user entity.
 // On each user modification it sends a notification about changes made to user entity.
 type Service struct {
-	storage Storage
+	storage  Storage
+	notifier Notifier
+	settings Settings
 }
 
 // Create creates a new user entity and returns back its unique ID.
-func (s *Service) Create(ctx context.Context, user Entity) (string, error) {
-	if err := s.validate(user, propertyFirstName, propertyLastName, propertyNickname, propertyEmail, propertyCountry, propertyPassword); err != nil {
+func (s *Service) Create(ctx context.Context, user Entity) (_ string, err error) {
+	ctx, done := instrument(ctx, "create")
+	defer done(&err)
+
+	if err := s.validate(ctx, user, propertyFirstName, propertyLastName, propertyNickname, propertyEmail, propertyCountry, propertyPassword); err != nil {
 		return "", err
 	}
 
@@ -70,35 +142,44 @@ func (s *Service) Create(ctx context.Context, user Entity) (string, error) {
 		newUser.UpdatedAt = now.UTC()
 
 		id, err = s.storage.Persist(ctx, runner, newUser)
-		if err == nil {
-			// TODO: send notification about creation of the user
-		}
 		return err
 	}); err != nil {
 		return "", fmt.Errorf("persist user: %w", err)
 	}
 
+	s.notifier.Notify(ctx, Event{Type: EventCreated, UserID: id, OccurredAt: time.Now().UTC()})
 	return id, nil
 }
 
-func (s *Service) validate(user Entity, validateProperties ...validationProperty) error {
+// validate checks the properties of the user. Stricter rules are applied if FlagStrictValidation is on for the caller.
+func (s *Service) validate(ctx context.Context, user Entity, validateProperties ...validationProperty) error {
+	strict := featureflags.Enabled(ctx, FlagStrictValidation)
+
 	var validations []func() error
 	for _, validateProperty := range validateProperties {
 		var validation func() error
 
 		switch validateProperty {
 		case propertyFirstName:
-			validation = validateBlankOrEmptyWithMaxLen(user.FirstName, validateProperty.String(), 50)
+			validation = validateBlankOrEmptyWithMaxLen(user.FirstName, validateProperty.String(), s.settings.FirstNameMaxLen)
 		case propertyLastName:
-			validation = validateBlankOrEmptyWithMaxLen(user.LastName, validateProperty.String(), 50)
+			validation = validateBlankOrEmptyWithMaxLen(user.LastName, validateProperty.String(), s.settings.LastNameMaxLen)
 		case propertyNickname:
-			validation = validateBlankOrEmptyWithMaxLen(user.Nickname, validateProperty.String(), 30)
+			validation = validateBlankOrEmptyWithMaxLen(user.Nickname, validateProperty.String(), s.settings.NicknameMaxLen)
+			if strict {
+				validations = append(validations, validation)
+				validation = validateNoWhitespace(user.Nickname, validateProperty.String())
+			}
 		case propertyEmail:
 			validation = validateEmailFormat(user.Email, validateProperty.String())
 		case propertyPassword:
-			validation = validateBlankOrEmptyWithMaxLen(user.Password, validateProperty.String(), 20)
+			validation = validateBlankOrEmptyWithMaxLen(user.Password, validateProperty.String(), s.settings.PasswordMaxLen)
 		case propertyCountry:
-			validation = validateBlankOrEmptyWithMaxLen(user.Country, validateProperty.String(), 2)
+			validation = validateBlankOrEmptyWithMaxLen(user.Country, validateProperty.String(), s.settings.CountryMaxLen)
+			if strict {
+				validations = append(validations, validation)
+				validation = validateCountryCode(user.Country, validateProperty.String())
+			}
 		default:
 			continue
 		}
@@ -115,7 +196,10 @@ func (s *Service) validate(user Entity, validateProperties ...validationProperty
 	return nil
 }
 
-func (s *Service) Get(ctx context.Context, id string) (Entity, error) {
+func (s *Service) Get(ctx context.Context, id string) (_ Entity, err error) {
+	ctx, done := instrument(ctx, "get")
+	defer done(&err)
+
 	var u storage.User
 	if err := s.storage.WithoutTx(ctx, func(runner storage.Runner) (err error) {
 		u, err = s.storage.Retrieve(ctx, runner, id, false)
@@ -127,68 +211,114 @@ func (s *Service) Get(ctx context.Context, id string) (Entity, error) {
 	return userEntity(u), nil
 }
 
-func (s *Service) Update(ctx context.Context, id string, user Entity) error {
-	if err := s.validate(user, propertyFirstName, propertyLastName, propertyNickname, propertyEmail, propertyCountry); err != nil {
+func (s *Service) Update(ctx context.Context, id string, user Entity) (err error) {
+	ctx, done := instrument(ctx, "update")
+	defer done(&err)
+
+	if err := s.validate(ctx, user, propertyFirstName, propertyLastName, propertyNickname, propertyEmail, propertyCountry); err != nil {
 		return err
 	}
 
-	changes := Changes{}
-	err := s.storage.WithTx(ctx, func(runner storage.Runner) error {
-		newUser := entityUser(user)
-		newUser.UpdatedAt = time.Now().UTC()
+	var changes Changes
+	if err := s.storage.WithTx(ctx, func(runner storage.Runner) (err error) {
+		changes, err = s.update(ctx, runner, id, user)
+		return err
+	}); err != nil {
+		return err
+	}
 
-		oldUser, err := s.storage.Update(ctx, runner, id, newUser)
-		if err != nil {
-			return fmt.Errorf("update user %q: %w", id, err)
-		}
+	// update without changes is not considered as an actual update
+	if len(changes) > 0 {
+		s.notifier.Notify(ctx, Event{Type: EventUpdated, UserID: id, Changes: changes, OccurredAt: time.Now().UTC()})
+	}
 
-		// TODO: could be done via reflection magic, generated code, mapping lib, etc.
-		if oldUser.FirstName != newUser.FirstName {
-			changes.Add("FirstName", oldUser.FirstName, newUser.FirstName)
-			oldUser.FirstName = newUser.FirstName
-		}
+	return nil
+}
 
-		if oldUser.LastName != newUser.LastName {
-			changes.Add("LastName", oldUser.LastName, newUser.LastName)
-			oldUser.LastName = newUser.LastName
-		}
+// update updates the user and returns changes made to its properties.
+func (s *Service) update(ctx context.Context, runner storage.Runner, id string, user Entity) (Changes, error) {
+	newUser := entityUser(user)
+	newUser.UpdatedAt = time.Now().UTC()
 
-		if oldUser.Nickname != newUser.Nickname {
-			changes.Add("Nickname", oldUser.Nickname, newUser.Nickname)
-			oldUser.Nickname = newUser.Nickname
-		}
+	oldUser, err := s.storage.Update(ctx, runner, id, newUser)
+	if err != nil {
+		return nil, fmt.Errorf("update user %q: %w", id, err)
+	}
 
-		if oldUser.Email != newUser.Email {
-			changes.Add("Email", oldUser.Email, newUser.Email)
-			oldUser.Email = newUser.Email
-		}
+	changes := Changes{}
+	// TODO: could be done via reflection magic, generated code, mapping lib, etc.
+	if oldUser.FirstName != newUser.FirstName {
+		changes.Add("FirstName", oldUser.FirstName, newUser.FirstName)
+	}
 
-		if oldUser.Country != newUser.Country {
-			changes.Add("Country", oldUser.Country, newUser.Country)
-			oldUser.Country = newUser.Country
-		}
-		// TODO: do we interested in change of updated_at value?
-		// TODO: do we consider update without changes as an actual update?
+	if oldUser.LastName != newUser.LastName {
+		changes.Add("LastName", oldUser.LastName, newUser.LastName)
+	}
 
-		// TODO: send notification about update of the user
-		return nil
-	})
+	if oldUser.Nickname != newUser.Nickname {
+		changes.Add("Nickname", oldUser.Nickname, newUser.Nickname)
+	}
+
+	if oldUser.Email != newUser.Email {
+		changes.Add("Email", oldUser.Email, newUser.Email)
+	}
 
-	return err
+	if oldUser.Country != newUser.Country {
+		changes.Add("Country", oldUser.Country, newUser.Country)
+	}
+	// TODO: do we interested in change of updated_at value?
+
+	return changes, nil
 }
 
-func (s *Service) Delete(ctx context.Context, id string) error {
+func (s *Service) Delete(ctx context.Context, id string) (err error) {
+	ctx, done := instrument(ctx, "delete")
+	defer done(&err)
+
 	if err := s.storage.WithoutTx(ctx, func(runner storage.Runner) error {
 		return s.storage.Delete(ctx, runner, id)
 	}); err != nil {
 		return fmt.Errorf("delete user %q: %w", id, err)
 	}
 
-	// TODO: send notification about deletion of the user
-
+	s.notifier.Notify(ctx, Event{Type: EventDeleted, UserID: id, OccurredAt: time.Now().UTC()})
 	return nil
 }
 
+// ChangePassword replaces the password of the user if the `current` one matches.
+// All sessions of the user are revoked, so the new password must be used to login again.
+func (s *Service) ChangePassword(ctx context.Context, id, current, password string) (err error) {
+	ctx, done := instrument(ctx, "change_password")
+	defer done(&err)
+
+	if err := s.validate(ctx, Entity{Password: password}, propertyPassword); err != nil {
+		return err
+	}
+
+	return s.storage.WithTx(ctx, func(runner storage.Runner) error {
+		// existence of the user is checked first, so the mismatch of the password is not confused with it
+		if _, err := s.storage.Retrieve(ctx, runner, id, true); err != nil {
+			return fmt.Errorf("retrieve user %q: %w", id, err)
+		}
+
+		now := time.Now().UTC()
+		if err := s.storage.ChangePassword(ctx, runner, id, current, password, now); err != nil {
+			if errors.Is(err, internal.ErrNotFound) {
+				return ValidationError{
+					Cause:   internal.ErrBadInput,
+					Details: map[string]interface{}{"CurrentPassword": "doesn't match"},
+				}
+			}
+			return fmt.Errorf("change password of user %q: %w", id, err)
+		}
+
+		if err := s.storage.RevokeSessions(ctx, runner, id, now); err != nil {
+			return fmt.Errorf("revoke sessions of user %q: %w", id, err)
+		}
+		return nil
+	})
+}
+
 type Changes map[string]Change
 
 type Change struct {
@@ -3,6 +3,8 @@ package user
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"testing"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/featureflags"
 	"github.com/pavelmemory/faceit-users/internal/storage"
 )
 
@@ -50,7 +53,7 @@ func TestService_Create(t *testing.T) {
 				return "1-2-3-4", nil
 			})
 
-		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage})
+		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, nil)
 		id, err := srv.Create(Context(), userEntity)
 
 		require.NoError(t, err)
@@ -66,7 +69,7 @@ func TestService_Create(t *testing.T) {
 			Persist(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return("", assert.AnError)
 
-		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage})
+		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, nil)
 		_, err := srv.Create(Context(), userEntity)
 
 		require.Error(t, err)
@@ -106,7 +109,7 @@ func TestService_Create(t *testing.T) {
 			},
 		} {
 			t.Run(title, func(t *testing.T) {
-				srv := NewService(nil)
+				srv := NewService(nil, nil)
 				_, err := srv.Create(Context(), tc.user)
 				require.Error(t, err)
 				var verr ValidationError
@@ -116,6 +119,307 @@ func TestService_Create(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("settings", func(t *testing.T) {
+		settings := DefaultSettings()
+		settings.NicknameMaxLen = 5
+		srv := NewService(nil, nil, WithSettings(settings))
+		_, err := srv.Create(Context(), changeUserEntity(func(entity *Entity) {
+			entity.Nickname = "nick-X"
+		}))
+		var verr ValidationError
+		require.True(t, errors.As(err, &verr))
+		require.Equal(t, map[string]interface{}{"Nickname": "exceeds max length: 5"}, verr.Details)
+	})
+
+	t.Run("strict validation", func(t *testing.T) {
+		flags, err := featureflags.New([]featureflags.Flag{{
+			Name:  FlagStrictValidation,
+			Rules: []featureflags.Rule{{Countries: []string{"DE"}, Value: featureflags.On}},
+		}})
+		require.NoError(t, err)
+
+		strict := featureflags.ToContext(Context(), flags, featureflags.Target{Country: "DE"})
+		lenient := featureflags.ToContext(Context(), flags, featureflags.Target{Country: "FR"})
+
+		srv := NewService(nil, nil)
+		for title, tc := range map[string]struct {
+			user    Entity
+			details map[string]interface{}
+		}{
+			"country is not upper case": {
+				user:    changeUserEntity(func(entity *Entity) { entity.Country = "xx" }),
+				details: map[string]interface{}{"Country": "not an ISO 3166-1 alpha-2 code"},
+			},
+			"nickname with whitespaces": {
+				user:    changeUserEntity(func(entity *Entity) { entity.Nickname = "john doe" }),
+				details: map[string]interface{}{"Nickname": "contains whitespaces"},
+			},
+		} {
+			t.Run(title, func(t *testing.T) {
+				_, err := srv.Create(strict, tc.user)
+				var verr ValidationError
+				require.True(t, errors.As(err, &verr))
+				require.Equal(t, tc.details, verr.Details)
+
+				require.NoError(t, srv.validate(lenient, tc.user, propertyNickname, propertyCountry), "flag is off")
+			})
+		}
+	})
+}
+
+func TestService_Batch(t *testing.T) {
+	create := Operation{Type: OperationCreate, User: Entity{
+		FirstName: "John",
+		LastName:  "Doe",
+		Nickname:  "johndoe",
+		Email:     "johndoe@mail.com",
+		Password:  "secret",
+		Country:   "XX",
+	}}
+
+	t.Run("atomic", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := NewMockStorage(ctrl)
+		mockStorage.EXPECT().
+			PersistMany(gomock.Any(), gomock.Any(), gomock.Len(2)).
+			Return([]string{"1", ""}, nil)
+
+		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, nil)
+		results := srv.Batch(Context(), []Operation{create, create, {Type: OperationDelete, ID: "3"}}, true)
+
+		require.Len(t, results, 3)
+		require.True(t, errors.Is(results[0].Err, ErrAborted))
+		require.Empty(t, results[0].ID)
+		require.True(t, errors.Is(results[1].Err, internal.ErrNotUnique))
+		require.True(t, errors.Is(results[2].Err, ErrAborted))
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := NewMockStorage(ctrl)
+		mockStorage.EXPECT().
+			PersistMany(gomock.Any(), gomock.Any(), gomock.Len(1)).
+			Return([]string{"1"}, nil)
+		mockStorage.EXPECT().
+			Delete(gomock.Any(), gomock.Any(), "3").
+			Return(internal.ErrNotFound)
+
+		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, nil)
+		results := srv.Batch(Context(), []Operation{create, {Type: OperationUpdate, ID: "2"}, {Type: OperationDelete, ID: "3"}}, false)
+
+		require.Len(t, results, 3)
+		require.NoError(t, results[0].Err)
+		require.Equal(t, "1", results[0].ID)
+		var verr ValidationError
+		require.True(t, errors.As(results[1].Err, &verr))
+		require.True(t, errors.Is(results[2].Err, internal.ErrNotFound))
+	})
+}
+
+func TestService_ChangePassword(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := NewMockStorage(ctrl)
+		gomock.InOrder(
+			mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", true).Return(storage.User{ID: "1"}, nil),
+			mockStorage.EXPECT().ChangePassword(gomock.Any(), gomock.Any(), "1", "old", "new", gomock.Any()).Return(nil),
+			mockStorage.EXPECT().RevokeSessions(gomock.Any(), gomock.Any(), "1", gomock.Any()).Return(nil),
+		)
+
+		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, nil)
+		require.NoError(t, srv.ChangePassword(Context(), "1", "old", "new"))
+	})
+
+	t.Run("current password doesn't match", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := NewMockStorage(ctrl)
+		mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", true).Return(storage.User{ID: "1"}, nil)
+		mockStorage.EXPECT().ChangePassword(gomock.Any(), gomock.Any(), "1", "wrong", "new", gomock.Any()).Return(internal.ErrNotFound)
+
+		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, nil)
+		err := srv.ChangePassword(Context(), "1", "wrong", "new")
+		require.True(t, errors.Is(err, internal.ErrBadInput))
+		require.False(t, errors.Is(err, internal.ErrNotFound))
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := NewMockStorage(ctrl)
+		mockStorage.EXPECT().Retrieve(gomock.Any(), gomock.Any(), "1", true).Return(storage.User{}, internal.ErrNotFound)
+
+		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, nil)
+		err := srv.ChangePassword(Context(), "1", "old", "new")
+		require.True(t, errors.Is(err, internal.ErrNotFound))
+	})
+
+	t.Run("invalid password", func(t *testing.T) {
+		srv := NewService(testStorage{Transactioner: testTransactioner{}}, nil)
+		err := srv.ChangePassword(Context(), "1", "old", " ")
+		require.True(t, errors.Is(err, internal.ErrBadInput))
+	})
+}
+
+func TestService_Export(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	mockStorage.EXPECT().
+		Scan(gomock.Any(), gomock.Any(), exportBatchSize, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ storage.Runner, _ int, fn func(storage.User) error) error {
+			for _, id := range []string{"1", "2", "3"} {
+				if err := fn(storage.User{ID: id, Nickname: "n" + id}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).
+		Times(2)
+
+	srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, nil)
+
+	var exported []Entity
+	require.NoError(t, srv.Export(Context(), func(entity Entity) error {
+		exported = append(exported, entity)
+		return nil
+	}))
+	require.Equal(t, []Entity{{ID: "1", Nickname: "n1"}, {ID: "2", Nickname: "n2"}, {ID: "3", Nickname: "n3"}}, exported)
+
+	err := srv.Export(Context(), func(entity Entity) error {
+		if entity.ID == "2" {
+			return assert.AnError
+		}
+		return nil
+	})
+	require.True(t, errors.Is(err, assert.AnError), "export stops on the first failure")
+}
+
+func TestService_Import(t *testing.T) {
+	valid := Entity{FirstName: "John", LastName: "Doe", Nickname: "johndoe", Email: "johndoe@mail.com", Password: "secret", Country: "XX"}
+	changed := func(change func(entity *Entity)) Entity {
+		entity := valid
+		change(&entity)
+		return entity
+	}
+
+	t.Run("rejections", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		source := &testEntitySource{lines: []testLine{
+			{line: 2, entity: valid},
+			{line: 3, err: fmt.Errorf("decode: %w", internal.ErrBadInput)},
+			{line: 4, entity: changed(func(e *Entity) { e.Email = "invalid" })},
+			{line: 5, entity: changed(func(e *Entity) { e.Nickname = "janedoe"; e.Email = "janedoe@mail.com" })},
+		}}
+
+		mockStorage := NewMockStorage(ctrl)
+		mockStorage.EXPECT().
+			Import(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ storage.Runner, users func(yield func(int, storage.User) error) error) ([]string, []int, error) {
+				var lines []int
+				err := users(func(line int, u storage.User) error {
+					require.Equal(t, "secret", u.Password, "password is imported")
+					require.False(t, u.CreatedAt.IsZero())
+					lines = append(lines, line)
+					return nil
+				})
+				require.NoError(t, err)
+				require.Equal(t, []int{2, 5}, lines, "only valid users are saved")
+				// the last user is a duplicate of the existing one
+				return []string{"1-2-3-4"}, []int{5}, nil
+			})
+
+		notifier := &testNotifier{}
+		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, notifier)
+		report, err := srv.Import(Context(), source)
+		require.NoError(t, err)
+
+		require.Len(t, notifier.events, 1, "imported users are notified about")
+		require.Equal(t, EventCreated, notifier.events[0].Type)
+		require.Equal(t, "1-2-3-4", notifier.events[0].UserID)
+
+		require.Equal(t, int64(1), report.Imported)
+		require.Equal(t, int64(3), report.Rejected)
+		require.Len(t, report.Rejections, 3)
+		require.Equal(t, 3, report.Rejections[0].Line)
+		require.True(t, errors.Is(report.Rejections[0].Err, internal.ErrBadInput))
+		require.Equal(t, 4, report.Rejections[1].Line)
+		var verr ValidationError
+		require.True(t, errors.As(report.Rejections[1].Err, &verr))
+		require.Equal(t, 5, report.Rejections[2].Line)
+		require.True(t, errors.Is(report.Rejections[2].Err, internal.ErrNotUnique))
+	})
+
+	t.Run("rejections limit", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		// invalid lines are odd, duplicates are even, so the kept rejections interleave
+		var lines []testLine
+		var duplicates []int
+		for line := 1; line <= 2*maxRejections+10; line++ {
+			if line%2 == 1 {
+				lines = append(lines, testLine{line: line, entity: changed(func(e *Entity) { e.Email = "invalid" })})
+				continue
+			}
+			lines = append(lines, testLine{line: line, entity: valid})
+			duplicates = append(duplicates, line)
+		}
+
+		mockStorage := NewMockStorage(ctrl)
+		mockStorage.EXPECT().
+			Import(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ storage.Runner, users func(yield func(int, storage.User) error) error) ([]string, []int, error) {
+				require.NoError(t, users(func(int, storage.User) error { return nil }))
+				return nil, duplicates, nil
+			})
+
+		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, nil)
+		report, err := srv.Import(Context(), &testEntitySource{lines: lines})
+		require.NoError(t, err)
+
+		require.Equal(t, int64(2*maxRejections+10), report.Rejected)
+		require.Len(t, report.Rejections, maxRejections)
+		for i, rejection := range report.Rejections {
+			require.Equal(t, i+1, rejection.Line, "the first rejected lines are kept")
+			if rejection.Line%2 == 1 {
+				var verr ValidationError
+				require.True(t, errors.As(rejection.Err, &verr))
+				continue
+			}
+			require.True(t, errors.Is(rejection.Err, internal.ErrNotUnique))
+		}
+	})
+
+	t.Run("source failure", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := NewMockStorage(ctrl)
+		mockStorage.EXPECT().
+			Import(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ storage.Runner, users func(yield func(int, storage.User) error) error) ([]string, []int, error) {
+				return nil, nil, users(func(int, storage.User) error { return nil })
+			})
+
+		source := &testEntitySource{lines: []testLine{{line: 2, entity: valid}, {line: 3, err: assert.AnError}}}
+		srv := NewService(testStorage{Transactioner: testTransactioner{}, Storage: mockStorage}, nil)
+		_, err := srv.Import(Context(), source)
+		require.True(t, errors.Is(err, assert.AnError), "import is aborted")
+		require.Contains(t, err.Error(), "read line 3")
+	})
 }
 
 func Context() context.Context {
@@ -144,3 +448,32 @@ func (ts testStorage) WithTx(ctx context.Context, call func(runner storage.Runne
 func (ts testStorage) WithoutTx(ctx context.Context, call func(runner storage.Runner) error) error {
 	return ts.Transactioner.WithoutTx(ctx, call)
 }
+
+type testLine struct {
+	line   int
+	entity Entity
+	err    error
+}
+
+// testEntitySource produces the predefined lines.
+type testEntitySource struct {
+	lines []testLine
+}
+
+func (s *testEntitySource) Next() (int, Entity, error) {
+	if len(s.lines) == 0 {
+		return 0, Entity{}, io.EOF
+	}
+	line := s.lines[0]
+	s.lines = s.lines[1:]
+	return line.line, line.entity, line.err
+}
+
+// testNotifier records the events.
+type testNotifier struct {
+	events []Event
+}
+
+func (n *testNotifier) Notify(_ context.Context, event Event) {
+	n.events = append(n.events, event)
+}
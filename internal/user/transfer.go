@@ -0,0 +1,143 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/storage"
+)
+
+// exportBatchSize is a number of users fetched from the storage at a time during export.
+const exportBatchSize = 500
+
+// maxRejections limits a number of rejections described in the import report.
+const maxRejections = 1000
+
+// Export calls `fn` for each user. Users are read from the storage in batches,
+// so the whole set of users is never held in memory.
+func (s *Service) Export(ctx context.Context, fn func(Entity) error) (err error) {
+	ctx, done := instrument(ctx, "export")
+	defer done(&err)
+
+	if err := s.storage.WithTx(ctx, func(runner storage.Runner) error {
+		return s.storage.Scan(ctx, runner, exportBatchSize, func(u storage.User) error {
+			return fn(userEntity(u))
+		})
+	}); err != nil {
+		return fmt.Errorf("export users: %w", err)
+	}
+
+	return nil
+}
+
+// EntitySource produces users to import.
+type EntitySource interface {
+	// Next returns the next user and the line number it was read from.
+	// It returns io.EOF when there are no more users.
+	// Errors caused by malformed data must wrap internal.ErrBadInput, so the line is rejected
+	// while the rest of the lines are still imported.
+	Next() (int, Entity, error)
+}
+
+// ImportReport describes an outcome of the import.
+type ImportReport struct {
+	// Imported is a number of imported users.
+	Imported int64
+	// Rejected is a number of lines that were not imported.
+	Rejected int64
+	// Rejections describe why lines were rejected, only first 1000 of them are kept.
+	Rejections []Rejection
+}
+
+// Rejection describes why the line was not imported.
+type Rejection struct {
+	Line int
+	Err  error
+}
+
+// reject records the rejection, the lines must be rejected in ascending order.
+func (ir *ImportReport) reject(line int, err error) {
+	ir.Rejected++
+	if len(ir.Rejections) < maxRejections {
+		ir.Rejections = append(ir.Rejections, Rejection{Line: line, Err: err})
+	}
+}
+
+// merge adds rejections of the not unique lines, only the first of all rejections are kept.
+func (ir *ImportReport) merge(skipped []int) {
+	ir.Rejected += int64(len(skipped))
+	for _, line := range skipped {
+		ir.Rejections = append(ir.Rejections, Rejection{Line: line, Err: fmt.Errorf("persist user: %w", internal.ErrNotUnique)})
+	}
+	sort.Slice(ir.Rejections, func(i, j int) bool {
+		return ir.Rejections[i].Line < ir.Rejections[j].Line
+	})
+	if len(ir.Rejections) > maxRejections {
+		ir.Rejections = ir.Rejections[:maxRejections]
+	}
+}
+
+// Import validates and saves all users produced by `source` in a single transaction.
+// Malformed, invalid and not unique users are rejected and described in the report.
+// Once the transaction is committed the `created` event is sent for each imported user.
+func (s *Service) Import(ctx context.Context, source EntitySource) (_ ImportReport, err error) {
+	ctx, done := instrument(ctx, "import")
+	defer done(&err)
+
+	var report ImportReport
+	var imported []string
+	err = s.storage.WithTx(ctx, func(runner storage.Runner) error {
+		now := time.Now().UTC()
+
+		ids, skipped, err := s.storage.Import(ctx, runner, func(yield func(int, storage.User) error) error {
+			for {
+				line, entity, err := source.Next()
+				switch {
+				case err == io.EOF:
+					return nil
+				case errors.Is(err, internal.ErrBadInput):
+					report.reject(line, err)
+					continue
+				case err != nil:
+					return fmt.Errorf("read line %d: %w", line, err)
+				}
+
+				if err := s.validate(ctx, entity, propertyFirstName, propertyLastName, propertyNickname, propertyEmail, propertyCountry, propertyPassword); err != nil {
+					report.reject(line, err)
+					continue
+				}
+
+				u := entityUser(entity)
+				u.Password = entity.Password
+				u.CreatedAt = now
+				u.UpdatedAt = now
+				if err := yield(line, u); err != nil {
+					return err
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		imported = ids
+		report.Imported = int64(len(ids))
+		report.merge(skipped)
+		return nil
+	})
+	if err != nil {
+		return ImportReport{}, fmt.Errorf("import users: %w", err)
+	}
+
+	occurredAt := time.Now().UTC()
+	for _, id := range imported {
+		s.notifier.Notify(ctx, Event{Type: EventCreated, UserID: id, OccurredAt: occurredAt})
+	}
+
+	return report, nil
+}
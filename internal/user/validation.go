@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/goware/emailx"
@@ -83,3 +84,29 @@ func validateEmailFormat(value, filed string) func() error {
 		return nil
 	}
 }
+
+func validateNoWhitespace(value, field string) func() error {
+	return func() error {
+		if strings.IndexFunc(value, unicode.IsSpace) >= 0 {
+			return ValidationError{
+				Cause:   internal.ErrBadInput,
+				Details: map[string]interface{}{field: "contains whitespaces"},
+			}
+		}
+
+		return nil
+	}
+}
+
+func validateCountryCode(value, field string) func() error {
+	return func() error {
+		if len(value) != 2 || value[0] < 'A' || value[0] > 'Z' || value[1] < 'A' || value[1] > 'Z' {
+			return ValidationError{
+				Cause:   internal.ErrBadInput,
+				Details: map[string]interface{}{field: "not an ISO 3166-1 alpha-2 code"},
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,269 @@
+package webhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/requestid"
+)
+
+// Formats of the access log.
+const (
+	// AccessLogJSON writes each request as a JSON object on a separate line with all recorded fields.
+	AccessLogJSON = "json"
+	// AccessLogCommon writes requests in Common Log Format.
+	AccessLogCommon = "common"
+	// AccessLogCombined writes requests in Combined Log Format: Common Log Format with referer and user agent.
+	AccessLogCombined = "combined"
+)
+
+// NewAccessLogger returns a logger that writes handled requests into `w` in the `format`,
+// AccessLogJSON is used if the format is not known.
+func NewAccessLogger(w io.Writer, format string, options ...AccessLoggerOption) *AccessLogger {
+	al := &AccessLogger{w: w, format: format, successPercent: 100, random: rand.Intn}
+	for _, option := range options {
+		option(al)
+	}
+	return al
+}
+
+// AccessLoggerOption allows to customize behaviour of the AccessLogger.
+type AccessLoggerOption func(al *AccessLogger)
+
+// WithSuccessSampling writes only `percent` of the requests with 1xx and 2xx status codes chosen randomly,
+// the rest are always written.
+func WithSuccessSampling(percent int) AccessLoggerOption {
+	return func(al *AccessLogger) {
+		al.successPercent = percent
+	}
+}
+
+// WithTrustedProxies sets networks of the proxies the client address is taken from 'X-Forwarded-For' header of.
+func WithTrustedProxies(proxies []*net.IPNet) AccessLoggerOption {
+	return func(al *AccessLogger) {
+		al.trustedProxies = proxies
+	}
+}
+
+// AccessLogger writes a line per handled request into a sink separate from the service logs.
+type AccessLogger struct {
+	format         string
+	successPercent int
+	trustedProxies []*net.IPNet
+	random         func(n int) int
+
+	// mu serializes writes, so the lines of concurrent requests don't interleave
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// AccessLogEntry is a record of the handled request.
+type AccessLogEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	RemoteIP  string    `json:"remote_ip"`
+	Method    string    `json:"method"`
+	// Route is a pattern of the matched route, e.g. '/users/{id}', it is empty if no route matches.
+	Route      string  `json:"route"`
+	Path       string  `json:"path"`
+	Proto      string  `json:"proto"`
+	Status     int     `json:"status"`
+	DurationMS float64 `json:"duration_ms"`
+	BytesIn    int64   `json:"bytes_in"`
+	BytesOut   int     `json:"bytes_out"`
+	// Principal is a subject of the authenticated caller.
+	Principal string `json:"principal,omitempty"`
+	Referer   string `json:"referer,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// uri is a request target used in the request line of Common Log Format
+	uri string
+}
+
+// LogAccess returns a middleware function that writes each request into the access log once it is handled.
+// It must be applied before the routing, so the route pattern is known once the request is handled.
+func LogAccess(al *AccessLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			record := &accessRecord{}
+			body := &countingReader{ReadCloser: r.Body}
+			if r.Body != nil {
+				r.Body = body
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), accessRecordKey{}, record)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if !al.sampled(status) {
+				return
+			}
+
+			entry := AccessLogEntry{
+				Time:       start,
+				RequestID:  requestid.FromContext(r.Context()),
+				RemoteIP:   ClientIP(r, al.trustedProxies),
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Proto:      r.Proto,
+				Status:     status,
+				DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+				BytesIn:    body.n,
+				BytesOut:   ww.BytesWritten(),
+				Principal:  record.principal,
+				Referer:    r.Referer(),
+				UserAgent:  r.UserAgent(),
+				uri:        r.RequestURI,
+			}
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				entry.Route = rctx.RoutePattern()
+			}
+
+			if err := al.Write(entry); err != nil {
+				logging.FromContext(r.Context()).WithError(err).Error("write access log")
+			}
+		})
+	}
+}
+
+func (al *AccessLogger) sampled(status int) bool {
+	return status >= http.StatusMultipleChoices || al.successPercent >= 100 ||
+		al.successPercent > 0 && al.random(100) < al.successPercent
+}
+
+// Write writes the entry as a single line in the format of the logger.
+func (al *AccessLogger) Write(entry AccessLogEntry) error {
+	var line bytes.Buffer
+	switch al.format {
+	case AccessLogCommon, AccessLogCombined:
+		writeCommonLog(&line, entry, al.format == AccessLogCombined)
+	default:
+		if err := json.NewEncoder(&line).Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	_, err := al.w.Write(line.Bytes())
+	return err
+}
+
+// writeCommonLog writes the entry as '<host> - <user> [<time>] "<request line>" <status> <bytes>',
+// combined format appends '"<referer>" "<user agent>"'.
+func writeCommonLog(w *bytes.Buffer, entry AccessLogEntry, combined bool) {
+	w.WriteString(entry.RemoteIP)
+	w.WriteString(" - ")
+	w.WriteString(orDash(entry.Principal))
+	w.WriteString(" [")
+	w.WriteString(entry.Time.Format("02/Jan/2006:15:04:05 -0700"))
+	w.WriteString("] ")
+	w.WriteString(strconv.Quote(entry.Method + " " + entry.uri + " " + entry.Proto))
+	w.WriteString(" ")
+	w.WriteString(strconv.Itoa(entry.Status))
+	w.WriteString(" ")
+	if entry.BytesOut > 0 {
+		w.WriteString(strconv.Itoa(entry.BytesOut))
+	} else {
+		w.WriteString("-")
+	}
+	if combined {
+		w.WriteString(" ")
+		w.WriteString(strconv.Quote(orDash(entry.Referer)))
+		w.WriteString(" ")
+		w.WriteString(strconv.Quote(orDash(entry.UserAgent)))
+	}
+	w.WriteString("\n")
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// ClientIP returns an IP address of the client. If the peer is one of the `trustedProxies`, the address is taken
+// from 'X-Forwarded-For' header: it is the rightmost address that is not a trusted proxy, as the addresses
+// on the left could be forged by the client.
+func ClientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !trusted(ip, trustedProxies) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("x-forwarded-for"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			// the chain is broken, so the rest of addresses can't be trusted
+			break
+		}
+		ip = hop
+		if !trusted(hop, trustedProxies) {
+			break
+		}
+	}
+	return ip
+}
+
+func trusted(ip string, proxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range proxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+type accessRecordKey struct{}
+
+// accessRecord collects attributes of the request known only to the inner handlers.
+type accessRecord struct {
+	principal string
+}
+
+// recordPrincipal adds the authenticated principal to the access log entry of the request.
+func recordPrincipal(ctx context.Context, subject string) {
+	if record, ok := ctx.Value(accessRecordKey{}).(*accessRecord); ok {
+		record.principal = subject
+	}
+}
+
+// countingReader counts bytes read from the body of the request.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
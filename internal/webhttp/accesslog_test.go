@@ -0,0 +1,114 @@
+package webhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+)
+
+func TestLogAccess(t *testing.T) {
+	authenticator := auth.AuthenticatorFunc(func(r *http.Request) (auth.Principal, error) {
+		return auth.Principal{Subject: "user-1", Method: auth.MethodJWT}, nil
+	})
+
+	var out bytes.Buffer
+	al := NewAccessLogger(&out, AccessLogJSON)
+	r := NewRouter(logging.NewTestLogger(), WithAccessLog(al))
+	r.With(Authenticate(authenticator)).Put("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/users/1?fields=id", strings.NewReader(`{"nickname":"n"}`))
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("x-request-id", "req-1")
+	req.Header.Set("user-agent", "test")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusCreated, resp.Code)
+
+	var entry AccessLogEntry
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	require.Equal(t, "req-1", entry.RequestID)
+	require.Equal(t, "192.0.2.1", entry.RemoteIP)
+	require.Equal(t, http.MethodPut, entry.Method)
+	require.Equal(t, "/users/{id}", entry.Route)
+	require.Equal(t, "/users/1", entry.Path)
+	require.Equal(t, http.StatusCreated, entry.Status)
+	require.Equal(t, int64(16), entry.BytesIn)
+	require.Equal(t, 10, entry.BytesOut)
+	require.Equal(t, "user-1", entry.Principal)
+	require.Equal(t, "test", entry.UserAgent)
+
+	t.Run("sampling", func(t *testing.T) {
+		var out bytes.Buffer
+		al := NewAccessLogger(&out, AccessLogJSON, WithSuccessSampling(10))
+		al.random = func(int) int { return 50 }
+		r := NewRouter(logging.NewTestLogger(), WithAccessLog(al))
+		r.Get("/ok", func(w http.ResponseWriter, _ *http.Request) {})
+		r.Get("/fail", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusInternalServerError) })
+
+		for _, path := range []string{"/ok", "/fail", "/missing"} {
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 2, "successful request is not sampled")
+		require.Contains(t, lines[0], `"status":500`)
+		require.Contains(t, lines[1], `"status":501`)
+	})
+}
+
+func TestAccessLogger_Write(t *testing.T) {
+	entry := AccessLogEntry{
+		Time:      time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC),
+		RemoteIP:  "192.0.2.1",
+		Method:    http.MethodGet,
+		Proto:     "HTTP/1.1",
+		Status:    http.StatusOK,
+		BytesOut:  42,
+		Principal: "user-1",
+		UserAgent: "test",
+		uri:       "/users?limit=1",
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, NewAccessLogger(&out, AccessLogCommon).Write(entry))
+	require.Equal(t, `192.0.2.1 - user-1 [04/Mar/2020:05:06:07 +0000] "GET /users?limit=1 HTTP/1.1" 200 42`+"\n", out.String())
+
+	out.Reset()
+	entry.Principal, entry.BytesOut = "", 0
+	require.NoError(t, NewAccessLogger(&out, AccessLogCombined).Write(entry))
+	require.Equal(t, `192.0.2.1 - - [04/Mar/2020:05:06:07 +0000] "GET /users?limit=1 HTTP/1.1" 200 - "-" "test"`+"\n", out.String())
+}
+
+func TestClientIP(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	clientIP := func(remoteAddr string, forwarded ...string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		for _, value := range forwarded {
+			req.Header.Add("x-forwarded-for", value)
+		}
+		return ClientIP(req, []*net.IPNet{proxies})
+	}
+
+	require.Equal(t, "192.0.2.1", clientIP("192.0.2.1:1234", "198.51.100.1"), "header of untrusted peer is ignored")
+	require.Equal(t, "198.51.100.1", clientIP("10.0.0.1:1234", "198.51.100.1"))
+	require.Equal(t, "198.51.100.2", clientIP("10.0.0.1:1234", "198.51.100.1, 198.51.100.2", "10.0.0.2"), "forged addresses are skipped")
+	require.Equal(t, "10.0.0.1", clientIP("10.0.0.1:1234", "garbage"))
+}
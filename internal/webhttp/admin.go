@@ -0,0 +1,228 @@
+package webhttp
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+// NewAdminHandler returns a handler of administrative requests.
+// Each request must be authorized with the `token` sent as 'Authorization: Bearer <token>' header.
+func NewAdminHandler(token *secret.Value, levels *logging.LevelController) *AdminHandler {
+	return &AdminHandler{token: token, levels: levels}
+}
+
+// AdminHandler handles requests that change behaviour of the service at runtime.
+type AdminHandler struct {
+	token  *secret.Value
+	levels *logging.LevelController
+}
+
+// Register creates a binding between method handlers and endpoints.
+func (ah *AdminHandler) Register(router chi.Router) {
+	router = router.With(LogRequest(), RequireBearerToken(ah.token), ProducesJSON)
+	router.Method(http.MethodGet, "/-/admin/logging", http.HandlerFunc(ah.GetLogging))
+	router.With(AcceptsJSON).Method(http.MethodPut, "/-/admin/logging", http.HandlerFunc(ah.SetLogLevel))
+	router.With(AcceptsJSON).Method(http.MethodPut, "/-/admin/logging/routes", http.HandlerFunc(ah.SetRouteLogLevel))
+	router.Method(http.MethodDelete, "/-/admin/logging/routes", http.HandlerFunc(ah.RemoveRouteLogLevel))
+	router.With(AcceptsJSON).Method(http.MethodPost, "/-/admin/logging/debug-token", http.HandlerFunc(ah.CreateDebugToken))
+}
+
+// RequireBearerToken returns a middleware function that rejects requests without 'Authorization: Bearer <token>' header.
+// The current value of the `token` is used for each request, so the token could be rotated.
+// All requests are rejected if the `token` is empty.
+func RequireBearerToken(token *secret.Value) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			const prefix = "bearer "
+			auth := r.Header.Get("authorization")
+			token := token.Get()
+			if token == "" || len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) ||
+				subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(token)) != 1 {
+				w.Header().Set("www-authenticate", "Bearer")
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetLogging returns the global logging level and all active overrides for routes.
+func (ah *AdminHandler) GetLogging(w http.ResponseWriter, r *http.Request) {
+	logger := ah.logger(r.Context(), "GetLogging")
+
+	resp := LoggingResp{Level: ah.levels.Level(), Routes: []RouteLogLevelResp{}}
+	for _, override := range ah.levels.Overrides() {
+		route := RouteLogLevelResp{Route: override.Route, Level: override.Level}
+		if !override.ExpiresAt.IsZero() {
+			expiresAt := override.ExpiresAt.UTC()
+			route.ExpiresAt = &expiresAt
+		}
+		resp.Routes = append(resp.Routes, route)
+	}
+
+	if err := Encode(w, resp); err != nil {
+		logger.WithError(err).Error("encode response")
+	}
+}
+
+// SetLogLevel changes the global logging level.
+func (ah *AdminHandler) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	logger := ah.logger(r.Context(), "SetLogLevel")
+
+	var req LogLevelReq
+	if err := Decode(r, &req); err != nil {
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	if err := ah.levels.SetLevel(req.Level); err != nil {
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	logger.WithString("level", req.Level).Info("global logging level changed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetRouteLogLevel overrides logging level for requests of the route, optionally for a limited time.
+func (ah *AdminHandler) SetRouteLogLevel(w http.ResponseWriter, r *http.Request) {
+	logger := ah.logger(r.Context(), "SetRouteLogLevel")
+
+	var req RouteLogLevelReq
+	if err := Decode(r, &req); err != nil {
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	ttl, err := parseTTL(req.TTL)
+	if err != nil {
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	if req.Route == "" {
+		ErrorResponse{Cause: fmt.Errorf("route is required: %w", internal.ErrBadInput), StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	if err := ah.levels.SetOverride(req.Route, req.Level, ttl); err != nil {
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	logger.WithString("route", req.Route).WithString("level", req.Level).WithString("ttl", ttl.String()).
+		Info("logging level of the route overridden")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveRouteLogLevel reverts logging level of the route passed as 'route' query parameter to the global one.
+func (ah *AdminHandler) RemoveRouteLogLevel(w http.ResponseWriter, r *http.Request) {
+	logger := ah.logger(r.Context(), "RemoveRouteLogLevel")
+
+	route := r.URL.Query().Get("route")
+	ah.levels.RemoveOverride(route)
+
+	logger.WithString("route", route).Info("logging level override of the route removed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateDebugToken issues a token that forces debug logging level for requests sent with 'X-Debug-Token' header.
+func (ah *AdminHandler) CreateDebugToken(w http.ResponseWriter, r *http.Request) {
+	logger := ah.logger(r.Context(), "CreateDebugToken")
+
+	var req DebugTokenReq
+	if err := Decode(r, &req); err != nil {
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	ttl, err := parseTTL(req.TTL)
+	switch {
+	case err != nil:
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	case ttl <= 0 || ttl > maxDebugTokenTTL:
+		err := fmt.Errorf("ttl must be positive and not greater than %s", maxDebugTokenTTL)
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	token, err := ah.levels.DebugToken(ttl)
+	if err != nil {
+		ErrorResponse{Cause: err, StatusCode: http.StatusConflict}.Write(logger, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := Encode(w, DebugTokenResp{Header: DebugTokenHeader, Token: token}); err != nil {
+		logger.WithError(err).Error("encode response")
+	}
+}
+
+// maxDebugTokenTTL limits lifetime of the debug tokens, as they can't be revoked.
+const maxDebugTokenTTL = 24 * time.Hour
+
+func parseTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, fmt.Errorf("ttl: %v: %w", err, internal.ErrBadInput)
+	}
+	return d, nil
+}
+
+func (ah *AdminHandler) logger(ctx context.Context, method string) logging.Logger {
+	return logging.FromContext(ctx).WithString("component", "AdminHandler").WithString("method", method)
+}
+
+// LoggingResp describes current logging levels.
+type LoggingResp struct {
+	Level  string              `json:"level"`
+	Routes []RouteLogLevelResp `json:"routes"`
+}
+
+// RouteLogLevelResp is a logging level overridden for the route.
+type RouteLogLevelResp struct {
+	Route     string     `json:"route"`
+	Level     string     `json:"level"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// LogLevelReq changes the global logging level.
+type LogLevelReq struct {
+	Level string `json:"level"`
+}
+
+// RouteLogLevelReq overrides logging level for the route, e.g. '/users/{id}'.
+// TTL is a duration in Go format, e.g. '15m', the override never expires if it is empty.
+type RouteLogLevelReq struct {
+	Route string `json:"route"`
+	Level string `json:"level"`
+	TTL   string `json:"ttl"`
+}
+
+// DebugTokenReq requests a debug token valid for TTL, e.g. '15m'.
+type DebugTokenReq struct {
+	TTL string `json:"ttl"`
+}
+
+// DebugTokenResp is a debug token and a header it should be sent with.
+type DebugTokenResp struct {
+	Header string `json:"header"`
+	Token  string `json:"token"`
+}
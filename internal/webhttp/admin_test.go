@@ -0,0 +1,148 @@
+package webhttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+type stubLevel struct {
+	level string
+}
+
+func (sl *stubLevel) Level() string {
+	return sl.level
+}
+
+func (sl *stubLevel) SetLevel(level string) error {
+	sl.level = level
+	return nil
+}
+
+func TestRequireBearerToken(t *testing.T) {
+	current := "admin-token"
+	token := secret.NewValue(current, func(context.Context) (string, error) { return current, nil })
+	handler := RequireBearerToken(token)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(value string) int {
+		req := httptest.NewRequest(http.MethodGet, "/-/admin/logging", nil)
+		req.Header.Set("authorization", "Bearer "+value)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	require.Equal(t, http.StatusOK, do("admin-token"))
+
+	current = "rotated"
+	_, err := token.Refresh(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, do("admin-token"), "previous token is rejected")
+	require.Equal(t, http.StatusOK, do("rotated"))
+
+	current = ""
+	_, err = token.Refresh(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, do(""), "all requests are rejected")
+}
+
+func TestAdminHandler(t *testing.T) {
+	global := &stubLevel{level: "info"}
+	levels := logging.NewLevelController(global, secret.NewValue("secret", nil))
+	logger := logging.NewTestLogger()
+
+	r := NewRouter(logger, WithLogLevels(levels))
+	NewAdminHandler(secret.NewValue("admin-token", nil), levels).Register(r)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Info("get user")
+	})
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("content-type", "application/json")
+		if token != "" {
+			req.Header.Set("authorization", "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// the last entry with the message, as the test logger accumulates entries of all requests
+	loggedLevel := func(msg string) interface{} {
+		entries := logger.Entries()
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i]["msg"] == msg {
+				return entries[i]["logger_level"]
+			}
+		}
+		return nil
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		resp := do(http.MethodGet, "/-/admin/logging", "", "")
+		require.Equal(t, http.StatusUnauthorized, resp.Code)
+		require.Equal(t, "Bearer", resp.Header().Get("www-authenticate"))
+
+		resp = do(http.MethodGet, "/-/admin/logging", "wrong", "")
+		require.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("global level", func(t *testing.T) {
+		resp := do(http.MethodPut, "/-/admin/logging", "admin-token", `{"level":"debug"}`)
+		require.Equal(t, http.StatusNoContent, resp.Code)
+		require.Equal(t, "debug", global.level)
+
+		resp = do(http.MethodPut, "/-/admin/logging", "admin-token", `{"level":"verbose"}`)
+		require.Equal(t, http.StatusBadRequest, resp.Code)
+		require.Equal(t, "debug", global.level)
+	})
+
+	t.Run("route level", func(t *testing.T) {
+		resp := do(http.MethodPut, "/-/admin/logging/routes", "admin-token", `{"route":"/users/{id}","level":"error","ttl":"1h"}`)
+		require.Equal(t, http.StatusNoContent, resp.Code)
+
+		resp = do(http.MethodGet, "/-/admin/logging", "admin-token", "")
+		require.Equal(t, http.StatusOK, resp.Code)
+		var logging LoggingResp
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &logging))
+		require.Equal(t, "debug", logging.Level)
+		require.Len(t, logging.Routes, 1)
+		require.Equal(t, "/users/{id}", logging.Routes[0].Route)
+		require.Equal(t, "error", logging.Routes[0].Level)
+		require.NotNil(t, logging.Routes[0].ExpiresAt)
+
+		require.Equal(t, http.StatusOK, do(http.MethodGet, "/users/1", "", "").Code)
+		require.Equal(t, "error", loggedLevel("get user"))
+
+		resp = do(http.MethodDelete, "/-/admin/logging/routes?route=/users/{id}", "admin-token", "")
+		require.Equal(t, http.StatusNoContent, resp.Code)
+		_, ok := levels.Override("/users/{id}")
+		require.False(t, ok)
+	})
+
+	t.Run("debug token", func(t *testing.T) {
+		resp := do(http.MethodPost, "/-/admin/logging/debug-token", "admin-token", `{"ttl":"48h"}`)
+		require.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = do(http.MethodPost, "/-/admin/logging/debug-token", "admin-token", `{"ttl":"5m"}`)
+		require.Equal(t, http.StatusCreated, resp.Code)
+		var token DebugTokenResp
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &token))
+		require.Equal(t, DebugTokenHeader, token.Header)
+
+		require.NoError(t, levels.SetOverride("/users/{id}", "error", 0))
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		req.Header.Set(token.Header, token.Token)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		require.Equal(t, "debug", loggedLevel("get user"))
+	})
+}
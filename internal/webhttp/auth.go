@@ -0,0 +1,51 @@
+package webhttp
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/tracing"
+)
+
+// Authenticate returns a middleware function that rejects requests without valid credentials
+// with `401` status code. The authenticated principal is injected into request's context,
+// it is also added to the logger, the span and the access log of the request, so the actions could be attributed to the caller.
+// Requests are rejected with `503` status code if the credentials can't be verified at the moment,
+// e.g. the keys of the tokens are not available.
+func Authenticate(authenticator auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := logging.FromContext(r.Context())
+
+			principal, err := authenticator.Authenticate(r)
+			switch {
+			case errors.Is(err, auth.ErrNoCredentials):
+				w.Header().Set("www-authenticate", "Bearer")
+				ErrorResponse{Cause: auth.ErrNoCredentials, StatusCode: http.StatusUnauthorized}.Write(logger, w)
+				return
+			case errors.Is(err, auth.ErrInvalidCredentials):
+				// details are not sent back to not help to forge the credentials
+				logger.WithError(err).Debug("authentication failed")
+				w.Header().Set("www-authenticate", `Bearer error="invalid_token"`)
+				ErrorResponse{Cause: auth.ErrInvalidCredentials, StatusCode: http.StatusUnauthorized}.Write(logger, w)
+				return
+			case err != nil:
+				logger.WithError(err).Error("authentication")
+				ErrorResponse{StatusCode: http.StatusServiceUnavailable}.Write(logger, w)
+				return
+			}
+
+			tracing.SpanFromContext(r.Context()).SetAttributes(
+				tracing.String("enduser.id", principal.Subject),
+				tracing.String("enduser.auth_method", principal.Method),
+			)
+			logger = logger.WithString("principal", principal.Subject).WithString("auth_method", principal.Method)
+			recordPrincipal(r.Context(), principal.Subject)
+
+			ctx := auth.ToContext(logging.ToContext(r.Context(), logger), principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package webhttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+)
+
+func TestAuthenticate(t *testing.T) {
+	authenticator := auth.AuthenticatorFunc(func(r *http.Request) (auth.Principal, error) {
+		switch r.Header.Get("authorization") {
+		case "":
+			return auth.Principal{}, auth.ErrNoCredentials
+		case "Bearer valid":
+			return auth.Principal{Subject: "user-1", Method: auth.MethodJWT}, nil
+		case "Bearer unverifiable":
+			return auth.Principal{}, errors.New("keys are not available")
+		default:
+			return auth.Principal{}, fmt.Errorf("signature mismatch: %w", auth.ErrInvalidCredentials)
+		}
+	})
+
+	logger := logging.NewTestLogger()
+	r := NewRouter(logger)
+	var principal auth.Principal
+	r.With(Authenticate(authenticator)).Get("/users", func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = auth.FromContext(r.Context())
+	})
+
+	do := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		if authorization != "" {
+			req.Header.Set("authorization", authorization)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := do("")
+	require.Equal(t, http.StatusUnauthorized, resp.Code)
+	require.Equal(t, "Bearer", resp.Header().Get("www-authenticate"))
+
+	resp = do("Bearer forged")
+	require.Equal(t, http.StatusUnauthorized, resp.Code)
+	require.Equal(t, `Bearer error="invalid_token"`, resp.Header().Get("www-authenticate"))
+	require.NotContains(t, resp.Body.String(), "signature mismatch", "details are not revealed")
+
+	resp = do("Bearer unverifiable")
+	require.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+	resp = do("Bearer valid")
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, auth.Principal{Subject: "user-1", Method: auth.MethodJWT}, principal)
+
+	r.Get("/public", func(http.ResponseWriter, *http.Request) {})
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/public", nil))
+	require.Equal(t, http.StatusOK, resp.Code, "routes registered without the middleware are not authenticated")
+}
@@ -0,0 +1,102 @@
+package webhttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/policy"
+	"github.com/pavelmemory/faceit-users/internal/user"
+)
+
+// Authorizer decides if the principal is allowed to perform the action.
+type Authorizer interface {
+	Evaluate(req policy.Request) policy.Decision
+}
+
+// WithAuthorization requires each action of the handler to be allowed by `authorizer`.
+// The principal of the request is expected to be injected by Authenticate middleware.
+func WithAuthorization(authorizer Authorizer) UserHandlerOption {
+	return func(uh *UserHandler) {
+		uh.authorizer = authorizer
+	}
+}
+
+// WithEventsAuthorization requires each subscription to the events to be allowed by `authorizer`.
+// The principal of the request is expected to be injected by Authenticate middleware.
+func WithEventsAuthorization(authorizer Authorizer) EventsHandlerOption {
+	return func(eh *EventsHandler) {
+		eh.authorizer = authorizer
+	}
+}
+
+func (uh *UserHandler) authorize(ctx context.Context, logger logging.Logger, reqs ...policy.Request) (ErrorResponse, bool) {
+	return authorize(ctx, logger, uh.authorizer, reqs...)
+}
+
+// authorize evaluates the requests of the principal of the context and logs the decision for audit.
+// It returns an error response to send back if any of the requests is denied, all requests are allowed if `authorizer` is nil.
+// Each of the denial reasons is prefixed with a description of the request if there are many of them.
+func authorize(ctx context.Context, logger logging.Logger, authorizer Authorizer, reqs ...policy.Request) (ErrorResponse, bool) {
+	if authorizer == nil {
+		return ErrorResponse{}, true
+	}
+
+	principal, _ := auth.FromContext(ctx)
+
+	var reasons []string
+	for i, req := range reqs {
+		req.Principal = principal
+		decision := authorizer.Evaluate(req)
+
+		audit := logger.WithString("log_type", "audit").
+			WithString("principal", principal.Subject).
+			WithString("action", req.Action).
+			WithString("owner", req.Owner).
+			WithString("fields", strings.Join(req.Fields, ","))
+		if decision.Allowed {
+			audit.WithString("decision", "allow").WithString("rules", strings.Join(decision.Rules, ",")).Info("authorization decision")
+			continue
+		}
+		audit.WithString("decision", "deny").WithString("reasons", strings.Join(decision.Reasons, "; ")).Info("authorization decision")
+
+		for _, reason := range decision.Reasons {
+			if len(reqs) > 1 {
+				reason = fmt.Sprintf("operation #%d: %s", i, reason)
+			}
+			reasons = append(reasons, reason)
+		}
+	}
+
+	if len(reasons) > 0 {
+		return ErrorResponse{Cause: policy.ErrDenied, Reasons: reasons, StatusCode: http.StatusForbidden}, false
+	}
+	return ErrorResponse{}, true
+}
+
+// userFields are names of the user fields referred by the policy rules.
+var userFields = []string{"first_name", "last_name", "nickname", "email", "country"}
+
+// changedFields returns names of the fields that differ between the users.
+func changedFields(old, new user.Entity) []string {
+	var fields []string
+	if old.FirstName != new.FirstName {
+		fields = append(fields, "first_name")
+	}
+	if old.LastName != new.LastName {
+		fields = append(fields, "last_name")
+	}
+	if old.Nickname != new.Nickname {
+		fields = append(fields, "nickname")
+	}
+	if old.Email != new.Email {
+		fields = append(fields, "email")
+	}
+	if old.Country != new.Country {
+		fields = append(fields, "country")
+	}
+	return fields
+}
@@ -0,0 +1,113 @@
+package webhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/policy"
+	"github.com/pavelmemory/faceit-users/internal/user"
+)
+
+func TestUserHandler_Authorization(t *testing.T) {
+	engine, err := policy.Parse([]byte(policy.Default))
+	require.NoError(t, err)
+
+	// the principal is defined by the test request as 'X-Subject' and 'X-Role' headers
+	authenticator := auth.AuthenticatorFunc(func(r *http.Request) (auth.Principal, error) {
+		return auth.Principal{Subject: r.Header.Get("x-subject"), Roles: r.Header.Values("x-role")}, nil
+	})
+
+	setup := func(t *testing.T) (http.Handler, *MockUserService, *logging.TestLogger, func()) {
+		ctrl := gomock.NewController(t)
+		logger := logging.NewTestLogger()
+		r := NewRouter(logger)
+		mockUserService := NewMockUserService(ctrl)
+		NewUsersHandler(mockUserService, WithAuthorization(engine)).Register(r.With(Authenticate(authenticator)))
+		return r, mockUserService, logger, ctrl.Finish
+	}
+
+	do := func(h http.Handler, method, path, subject, role, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("content-type", "application/json")
+		req.Header.Set("x-subject", subject)
+		if role != "" {
+			req.Header.Set("x-role", role)
+		}
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	reasons := func(t *testing.T, resp *httptest.ResponseRecorder) []string {
+		var errResp ErrorResp
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &errResp))
+		require.Equal(t, policy.ErrDenied.Error(), errResp.Error)
+		return errResp.Reasons
+	}
+
+	t.Run("get", func(t *testing.T) {
+		h, mockUserService, logger, finish := setup(t)
+		defer finish()
+
+		mockUserService.EXPECT().Get(gomock.Any(), "1").Return(user.Entity{FirstName: "fn"}, nil)
+		require.Equal(t, http.StatusOK, do(h, http.MethodGet, "/users/1", "1", "", "").Code)
+
+		resp := do(h, http.MethodGet, "/users/2", "1", "", "")
+		require.Equal(t, http.StatusForbidden, resp.Code)
+		require.Equal(t, []string{"no rule allows users.get on resources of other subjects"}, reasons(t, resp))
+
+		var audited bool
+		for _, entry := range logger.Entries() {
+			if entry["log_type"] == "audit" && entry["decision"] == "deny" && entry["action"] == policy.ActionUsersGet {
+				audited = true
+			}
+		}
+		require.True(t, audited, "decision is logged")
+	})
+
+	t.Run("update fields", func(t *testing.T) {
+		h, mockUserService, _, finish := setup(t)
+		defer finish()
+
+		current := user.Entity{FirstName: "fn", LastName: "ln", Nickname: "nn", Email: "e@mail.com", Country: "XX"}
+		mockUserService.EXPECT().Get(gomock.Any(), "1").Return(current, nil).Times(2)
+		mockUserService.EXPECT().Update(gomock.Any(), "1", gomock.Any()).Return(nil)
+
+		resp := do(h, http.MethodPut, "/users/1", "2", "support",
+			`{"first_name":"fn","last_name":"ln","nickname":"nn2","email":"e@mail.com","country":"XY"}`)
+		require.Equal(t, http.StatusNoContent, resp.Code)
+
+		resp = do(h, http.MethodPut, "/users/1", "2", "support",
+			`{"first_name":"fn","last_name":"ln","nickname":"nn","email":"other@mail.com","country":"XX"}`)
+		require.Equal(t, http.StatusForbidden, resp.Code)
+		require.Equal(t, []string{"field email is not allowed to be changed"}, reasons(t, resp))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		h, mockUserService, _, finish := setup(t)
+		defer finish()
+
+		require.Equal(t, http.StatusForbidden, do(h, http.MethodDelete, "/users/1", "1", "", "").Code)
+
+		mockUserService.EXPECT().Delete(gomock.Any(), "1").Return(nil)
+		require.Equal(t, http.StatusNoContent, do(h, http.MethodDelete, "/users/1", "3", "admin", "").Code)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		h, _, _, finish := setup(t)
+		defer finish()
+
+		resp := do(h, http.MethodPost, "/users:batch", "1", "",
+			`{"operations":[{"op":"update","id":"1","user":{}},{"op":"delete","id":"1"}]}`)
+		require.Equal(t, http.StatusForbidden, resp.Code)
+		require.Equal(t, []string{"operation #1: no rule allows users.delete"}, reasons(t, resp))
+	})
+}
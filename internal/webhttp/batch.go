@@ -0,0 +1,126 @@
+package webhttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pavelmemory/faceit-users/internal/policy"
+	"github.com/pavelmemory/faceit-users/internal/tracing"
+	"github.com/pavelmemory/faceit-users/internal/user"
+)
+
+const defaultBatchLimit = 1000
+
+const (
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best_effort"
+)
+
+// Batch applies a list of create/update/delete operations and responds with an outcome of each of them.
+// In 'atomic' mode (default) either all operations are applied or none of them,
+// in 'best_effort' mode each operation is applied independently.
+func (uh *UserHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "webhttp.UserHandler/Batch", tracing.KindInternal)
+	defer span.End()
+	logger := uh.logger(ctx, "Batch")
+
+	logger.Debug("start")
+	defer logger.Debug("end")
+
+	var req BatchReq
+	if err := Decode(r, &req); err != nil {
+		logger.WithError(err).Error("decode payload")
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	var atomic bool
+	switch req.Mode {
+	case "", batchModeAtomic:
+		atomic = true
+	case batchModeBestEffort:
+	default:
+		err := fmt.Errorf("unsupported mode %q, expected %q or %q", req.Mode, batchModeAtomic, batchModeBestEffort)
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	switch {
+	case len(req.Operations) == 0:
+		ErrorResponse{Cause: errors.New("no operations"), StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	case len(req.Operations) > uh.batchLimit:
+		err := fmt.Errorf("too many operations: %d, max allowed: %d", len(req.Operations), uh.batchLimit)
+		ErrorResponse{Cause: err, StatusCode: http.StatusRequestEntityTooLarge}.Write(logger, w)
+		return
+	}
+
+	ops := uh.mapper.batchReq2Operations(req)
+	if resp, ok := uh.authorize(ctx, logger, batchPolicyRequests(ops)...); !ok {
+		resp.Write(logger, w)
+		return
+	}
+
+	results := uh.userService.Batch(ctx, ops, atomic)
+
+	resp := BatchResp{Results: make([]BatchOperationResp, len(results))}
+	for i, result := range results {
+		resp.Results[i] = uh.batchOperationResp(ops[i], result)
+		if result.Err != nil && !errors.Is(result.Err, user.ErrAborted) {
+			logger.WithError(result.Err).WithInt("operation", i).Debug("batch operation failed")
+		}
+	}
+
+	if err := Encode(w, resp); err != nil {
+		logger.WithError(err).Error("encode response")
+		ErrorResponse{Cause: err, StatusCode: http.StatusInternalServerError}.Write(logger, w)
+		return
+	}
+}
+
+// batchPolicyRequests returns a request to authorize for each of the operations.
+// Updates are authorized as changes of all fields, as the batch doesn't load the current state of the users.
+func batchPolicyRequests(ops []user.Operation) []policy.Request {
+	reqs := make([]policy.Request, len(ops))
+	for i, op := range ops {
+		switch op.Type {
+		case user.OperationCreate:
+			reqs[i] = policy.Request{Action: policy.ActionUsersCreate}
+		case user.OperationUpdate:
+			reqs[i] = policy.Request{Action: policy.ActionUsersUpdate, Owner: op.ID, Fields: userFields}
+		case user.OperationDelete:
+			reqs[i] = policy.Request{Action: policy.ActionUsersDelete, Owner: op.ID}
+		default:
+			// unknown operations are rejected by the service
+			reqs[i] = policy.Request{Action: "users." + string(op.Type)}
+		}
+	}
+	return reqs
+}
+
+func (uh *UserHandler) batchOperationResp(op user.Operation, result user.OperationResult) BatchOperationResp {
+	resp := BatchOperationResp{ID: result.ID}
+	if result.Err == nil {
+		resp.Status = http.StatusNoContent
+		if op.Type == user.OperationCreate {
+			resp.Status = http.StatusCreated
+		}
+		return resp
+	}
+
+	resp.Status, resp.Error, resp.Details = describeError(result.Err)
+	return resp
+}
+
+// describeError returns HTTP status code, its description and validation details (if any) of the error.
+func describeError(err error) (int, string, map[string]interface{}) {
+	status := errorStatusCode(err)
+
+	var verr user.ValidationError
+	if errors.As(err, &verr) {
+		return status, http.StatusText(status), verr.Details
+	}
+
+	return status, http.StatusText(status), nil
+}
@@ -0,0 +1,109 @@
+package webhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CacheControl returns a middleware function that sets `directives` as a 'Cache-Control' header
+// of the successful and not modified responses. Responses of the other statuses are not affected,
+// so errors are not cached with the directives intended for the resource.
+func CacheControl(directives string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if directives == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&cacheControlWrapper{ResponseWriter: w, directives: directives}, r)
+		})
+	}
+}
+
+type cacheControlWrapper struct {
+	http.ResponseWriter
+	directives  string
+	wroteHeader bool
+}
+
+func (w *cacheControlWrapper) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if (statusCode >= 200 && statusCode < 300 || statusCode == http.StatusNotModified) && w.Header().Get("cache-control") == "" {
+			w.Header().Set("cache-control", w.directives)
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *cacheControlWrapper) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// EncodeConditional encodes `src` the same way as Encode does and sends it with a strong 'ETag' computed
+// from the encoded representation and 'Last-Modified' set to `modifiedAt` (if it is not zero).
+// If the client already has the same representation according to 'If-None-Match' or 'If-Modified-Since'
+// headers of the request the response is sent with `304` status code and without the body.
+func EncodeConditional(w http.ResponseWriter, r *http.Request, src interface{}, modifiedAt time.Time) error {
+	var body bytes.Buffer
+	if err := responseCodec(w).Encode(&body, src); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("etag", etag)
+
+	// HTTP dates have a precision of a second
+	modifiedAt = modifiedAt.UTC().Truncate(time.Second)
+	if !modifiedAt.IsZero() {
+		w.Header().Set("last-modified", modifiedAt.Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, modifiedAt) {
+		// representation headers are not sent as there is no representation
+		w.Header().Del("content-type")
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err := body.WriteTo(w)
+	return err
+}
+
+// notModified evaluates preconditions of the GET request according to RFC 7232 section 6:
+// 'If-Modified-Since' is ignored if the request has 'If-None-Match' header.
+func notModified(r *http.Request, etag string, modifiedAt time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Values("if-none-match"); len(inm) > 0 {
+		for _, candidate := range strings.Split(strings.Join(inm, ","), ",") {
+			candidate = strings.TrimSpace(candidate)
+			// weak comparison is used for GET requests
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if modifiedAt.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("if-modified-since"))
+	if err != nil {
+		return false
+	}
+	return !modifiedAt.After(since)
+}
@@ -0,0 +1,93 @@
+package webhttp
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	httppprof "net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+// NewDiagnosticsHandler returns a handler of the runtime diagnostics requests.
+// Each request must be authorized with the `token` sent as 'Authorization: Bearer <token>' header.
+// Dumps of goroutines and heap are written into the `dumpDir` directory.
+func NewDiagnosticsHandler(token *secret.Value, dumpDir string) DiagnosticsHandler {
+	return DiagnosticsHandler{token: token, dumpDir: dumpDir, now: time.Now}
+}
+
+// DiagnosticsHandler exposes profiles and internal state of the Go runtime.
+// It must be registered only on the admin router, as it reveals details of the process.
+type DiagnosticsHandler struct {
+	token   *secret.Value
+	dumpDir string
+	now     func() time.Time
+}
+
+// Register creates a binding between method handlers and endpoints.
+func (dh DiagnosticsHandler) Register(router chi.Router) {
+	router = router.With(RequireBearerToken(dh.token))
+	router.HandleFunc("/debug/pprof/", httppprof.Index)
+	// named profiles, e.g. '/debug/pprof/heap', are served by the index handler
+	router.HandleFunc("/debug/pprof/*", httppprof.Index)
+	router.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	router.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	router.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	router.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	router.Method(http.MethodGet, "/debug/vars", expvar.Handler())
+	router.With(LogRequest(), ProducesJSON).Method(http.MethodPost, "/-/dumps", http.HandlerFunc(dh.Dump))
+}
+
+// Dump writes stacks of all goroutines and a heap profile into the files of the dump directory,
+// so they could be collected and analyzed later. Names of the files are returned in the response.
+func (dh DiagnosticsHandler) Dump(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context()).WithString("component", "DiagnosticsHandler").WithString("method", "Dump")
+
+	suffix := dh.now().UTC().Format("20060102T150405.000000000")
+	goroutines := filepath.Join(dh.dumpDir, "goroutine-"+suffix+".txt")
+	if err := writeProfile(goroutines, "goroutine", 2); err != nil {
+		ErrorResponse{Cause: err, StatusCode: http.StatusInternalServerError}.Write(logger, w)
+		return
+	}
+
+	// an up to date statistics of the heap is available only after garbage collection
+	runtime.GC()
+	heap := filepath.Join(dh.dumpDir, "heap-"+suffix+".pprof")
+	if err := writeProfile(heap, "heap", 0); err != nil {
+		ErrorResponse{Cause: err, StatusCode: http.StatusInternalServerError}.Write(logger, w)
+		return
+	}
+
+	logger.WithString("goroutines", goroutines).WithString("heap", heap).Info("dumps written")
+	w.WriteHeader(http.StatusCreated)
+	if err := Encode(w, DumpResp{Goroutines: goroutines, Heap: heap}); err != nil {
+		logger.WithError(err).Error("encode response")
+	}
+}
+
+func writeProfile(path, name string, debug int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s dump: %w", name, err)
+	}
+
+	if err := pprof.Lookup(name).WriteTo(f, debug); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write %s dump: %w", name, err)
+	}
+	return f.Close()
+}
+
+// DumpResp contains paths of the files dumps are written into.
+type DumpResp struct {
+	Goroutines string `json:"goroutines"`
+	Heap       string `json:"heap"`
+}
@@ -0,0 +1,57 @@
+package webhttp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+func TestDiagnosticsHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dumps")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	r := NewAdminRouter(logging.NewTestLogger())
+	NewDiagnosticsHandler(secret.NewValue("admin-token", nil), dir).Register(r)
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("authorization", "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap?debug=1", "/debug/pprof/cmdline", "/debug/vars"} {
+		require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, path, "").Code, path)
+		require.Equal(t, http.StatusOK, do(http.MethodGet, path, "admin-token").Code, path)
+	}
+
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/-/dumps", "wrong").Code)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files, "nothing is written for unauthorized request")
+
+	resp := do(http.MethodPost, "/-/dumps", "admin-token")
+	require.Equal(t, http.StatusCreated, resp.Code)
+
+	var dump DumpResp
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &dump))
+	for _, path := range []string{dump.Goroutines, dump.Heap} {
+		require.Equal(t, dir, filepath.Dir(path))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.NotZero(t, info.Size())
+	}
+}
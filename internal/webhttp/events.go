@@ -0,0 +1,165 @@
+package webhttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/policy"
+	"github.com/pavelmemory/faceit-users/internal/user"
+)
+
+// EventSource provides a stream of the user modification events.
+type EventSource interface {
+	// Subscribe returns subscription to the events accepted by `filter`, resumed after `lastEventID` if it is set.
+	Subscribe(lastEventID string, queueSize int, filter func(user.Event) bool) *user.Subscription
+}
+
+// NewEventsHandler returns HTTP handler that streams user events as Server-Sent Events.
+// `queueSize` limits number of events buffered for a single client,
+// `heartbeat` is an interval of keep-alive messages sent to the idle client.
+// Both of them must be positive.
+func NewEventsHandler(source EventSource, queueSize int, heartbeat time.Duration, options ...EventsHandlerOption) (*EventsHandler, error) {
+	if queueSize <= 0 {
+		return nil, fmt.Errorf("queue size must be positive, got %d", queueSize)
+	}
+	if heartbeat <= 0 {
+		return nil, fmt.Errorf("heartbeat must be positive, got %s", heartbeat)
+	}
+
+	eh := &EventsHandler{source: source, queueSize: queueSize, heartbeat: heartbeat}
+	for _, option := range options {
+		option(eh)
+	}
+	return eh, nil
+}
+
+// EventsHandlerOption allows to customize behaviour of the EventsHandler.
+type EventsHandlerOption func(eh *EventsHandler)
+
+// EventsHandler handles subscriptions to the user events.
+// The identifiers of the events used to resume the stream are issued by the EventSource of this instance only.
+type EventsHandler struct {
+	source     EventSource
+	queueSize  int
+	heartbeat  time.Duration
+	mapper     Mapper
+	authorizer Authorizer
+}
+
+// Register creates a binding between method handlers and endpoints.
+func (eh *EventsHandler) Register(router chi.Router) {
+	router = router.With(LogRequest())
+	router.Method(http.MethodGet, "/users/events", http.HandlerFunc(eh.All))
+	router.Method(http.MethodGet, "/users/{id}/events", http.HandlerFunc(eh.Single))
+}
+
+// All streams events of all users. The events reveal changes of any user, so the policy usually allows it only to admins.
+func (eh *EventsHandler) All(w http.ResponseWriter, r *http.Request) {
+	logger := eh.logger(r.Context(), "All")
+	if resp, ok := authorize(r.Context(), logger, eh.authorizer, policy.Request{Action: policy.ActionUsersEvents}); !ok {
+		resp.Write(logger, w)
+		return
+	}
+
+	eh.stream(w, r, logger, nil)
+}
+
+// Single streams events of the user with identifier taken from the path.
+func (eh *EventsHandler) Single(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	logger := eh.logger(r.Context(), "Single").WithString("id", id)
+	if resp, ok := authorize(r.Context(), logger, eh.authorizer, policy.Request{Action: policy.ActionUsersEvents, Owner: id}); !ok {
+		resp.Write(logger, w)
+		return
+	}
+
+	eh.stream(w, r, logger, func(event user.Event) bool {
+		return event.UserID == id
+	})
+}
+
+func (eh *EventsHandler) stream(w http.ResponseWriter, r *http.Request, logger logging.Logger, filter func(user.Event) bool) {
+	logger.Debug("start")
+	defer logger.Debug("end")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		logger.Error("response writer doesn't support flushing")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	sub := eh.source.Subscribe(r.Header.Get("last-event-id"), eh.queueSize, filter)
+	defer sub.Close()
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.Header().Set("x-accel-buffering", "no") // disables buffering on the reverse proxies
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eh.heartbeat.Milliseconds()); err != nil {
+		logger.WithError(err).Debug("write retry interval")
+		return
+	}
+
+	if sub.Gap() {
+		// client has to re-read the state as some of the events can't be replayed
+		if _, err := io.WriteString(w, "event: reset\ndata: {}\n\n"); err != nil {
+			logger.WithError(err).Debug("write reset")
+			return
+		}
+	}
+
+	for _, rec := range sub.Backlog() {
+		if err := eh.write(w, rec); err != nil {
+			logger.WithError(err).Debug("write backlog event")
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(eh.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Dropped():
+			logger.Debug("subscription dropped")
+			return
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				logger.WithError(err).Debug("write heartbeat")
+				return
+			}
+		case rec := <-sub.Events():
+			if err := eh.write(w, rec); err != nil {
+				logger.WithError(err).Debug("write event")
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func (eh *EventsHandler) write(w io.Writer, rec user.Record) error {
+	data, err := json.Marshal(eh.mapper.record2UserEventResp(rec))
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", rec.ID, rec.Type, data)
+	return err
+}
+
+func (eh *EventsHandler) logger(ctx context.Context, method string) logging.Logger {
+	return logging.FromContext(ctx).WithString("component", "EventsHandler").WithString("method", method)
+}
@@ -0,0 +1,188 @@
+package webhttp
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/policy"
+	"github.com/pavelmemory/faceit-users/internal/user"
+)
+
+func TestNewEventsHandler(t *testing.T) {
+	_, err := NewEventsHandler(user.NewBroker(10), 0, time.Minute)
+	require.Error(t, err, "queue size")
+
+	_, err = NewEventsHandler(user.NewBroker(10), 10, 0)
+	require.Error(t, err, "heartbeat")
+
+	_, err = NewEventsHandler(user.NewBroker(10), 10, -time.Second)
+	require.Error(t, err, "negative heartbeat")
+}
+
+func TestEventsHandler_Stream(t *testing.T) {
+	setup := func(t *testing.T, heartbeat time.Duration) (*user.Broker, string, func()) {
+		broker := user.NewBroker(10)
+		eh, err := NewEventsHandler(broker, 10, heartbeat)
+		require.NoError(t, err)
+
+		r := NewRouter(logging.NewTestLogger())
+		eh.Register(r)
+		srv := httptest.NewServer(r)
+		return broker, srv.URL, func() {
+			srv.Close()
+			broker.Close()
+		}
+	}
+
+	occurredAt := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	notify := func(broker *user.Broker, typ user.EventType, id string) {
+		broker.Notify(context.Background(), user.Event{Type: typ, UserID: id, OccurredAt: occurredAt})
+	}
+
+	t.Run("single user", func(t *testing.T) {
+		broker, url, teardown := setup(t, time.Minute)
+		defer teardown()
+
+		resp, body, cancel := subscribeEvents(t, url+"/users/1/events", nil)
+		defer cancel()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/event-stream", resp.Header.Get("content-type"))
+		require.Equal(t, []string{"retry: 60000"}, readFrame(t, body))
+
+		notify(broker, user.EventCreated, "2")
+		notify(broker, user.EventUpdated, "1")
+
+		frame := readFrame(t, body)
+		require.Len(t, frame, 3)
+		require.Equal(t, "event: updated", frame[1], "events of other users are filtered out")
+		require.JSONEq(t, `{"id":"1","type":"updated","occurred_at":"2020-03-04T05:06:07Z"}`, strings.TrimPrefix(frame[2], "data: "))
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		broker, url, teardown := setup(t, time.Minute)
+		defer teardown()
+
+		resp, body, cancel := subscribeEvents(t, url+"/users/events", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		readFrame(t, body)
+
+		notify(broker, user.EventCreated, "1")
+		notify(broker, user.EventUpdated, "1")
+		notify(broker, user.EventDeleted, "1")
+		var ids []string
+		for i := 0; i < 3; i++ {
+			ids = append(ids, strings.TrimPrefix(readFrame(t, body)[0], "id: "))
+		}
+		cancel()
+
+		resp, body, cancel = subscribeEvents(t, url+"/users/events", map[string]string{"last-event-id": ids[0]})
+		defer cancel()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		readFrame(t, body)
+		require.Equal(t, "id: "+ids[1], readFrame(t, body)[0], "events that follow the last seen one are replayed")
+		require.Equal(t, "id: "+ids[2], readFrame(t, body)[0])
+
+		notify(broker, user.EventCreated, "2")
+		require.Equal(t, "event: created", readFrame(t, body)[1], "new events follow the replayed ones")
+	})
+
+	t.Run("resume unknown", func(t *testing.T) {
+		broker, url, teardown := setup(t, time.Minute)
+		defer teardown()
+
+		notify(broker, user.EventCreated, "1")
+
+		resp, body, cancel := subscribeEvents(t, url+"/users/events", map[string]string{"last-event-id": "unknown-1"})
+		defer cancel()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		readFrame(t, body)
+		require.Equal(t, []string{"event: reset", "data: {}"}, readFrame(t, body), "client has to re-read the state")
+		require.Equal(t, "event: created", readFrame(t, body)[1], "all known events are replayed")
+	})
+
+	t.Run("heartbeat", func(t *testing.T) {
+		_, url, teardown := setup(t, 10*time.Millisecond)
+		defer teardown()
+
+		resp, body, cancel := subscribeEvents(t, url+"/users/events", nil)
+		defer cancel()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, []string{"retry: 10"}, readFrame(t, body))
+		require.Equal(t, []string{": heartbeat"}, readFrame(t, body))
+		require.Equal(t, []string{": heartbeat"}, readFrame(t, body))
+	})
+}
+
+func TestEventsHandler_Authorization(t *testing.T) {
+	engine, err := policy.Parse([]byte(policy.Default))
+	require.NoError(t, err)
+
+	// the principal is defined by the test request as 'X-Subject' and 'X-Role' headers
+	authenticator := auth.AuthenticatorFunc(func(r *http.Request) (auth.Principal, error) {
+		return auth.Principal{Subject: r.Header.Get("x-subject"), Roles: r.Header.Values("x-role")}, nil
+	})
+
+	r := NewRouter(logging.NewTestLogger())
+	eh, err := NewEventsHandler(user.NewBroker(10), 10, time.Minute, WithEventsAuthorization(engine))
+	require.NoError(t, err)
+	eh.Register(r.With(Authenticate(authenticator)))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	subscribe := func(path, subject, role string) int {
+		resp, _, cancel := subscribeEvents(t, srv.URL+path, map[string]string{"x-subject": subject, "x-role": role})
+		defer cancel()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, subscribe("/users/1/events", "1", ""), "owner")
+	require.Equal(t, http.StatusForbidden, subscribe("/users/2/events", "1", ""), "other user")
+	require.Equal(t, http.StatusForbidden, subscribe("/users/2/events", "1", "support"), "support")
+	require.Equal(t, http.StatusOK, subscribe("/users/2/events", "1", "admin"), "admin")
+
+	require.Equal(t, http.StatusForbidden, subscribe("/users/events", "1", ""), "all events of user")
+	require.Equal(t, http.StatusOK, subscribe("/users/events", "1", "admin"), "all events of admin")
+}
+
+// readFrame returns lines of the next frame of the event stream.
+func readFrame(t *testing.T, r *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+// subscribeEvents starts a stream of the events, the stream is closed by the returned function.
+// Empty header values are not sent.
+func subscribeEvents(t *testing.T, url string, headers map[string]string) (*http.Response, *bufio.Reader, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for name, value := range headers {
+		if value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp, bufio.NewReader(resp.Body), func() {
+		cancel()
+		_ = resp.Body.Close()
+	}
+}
@@ -0,0 +1,190 @@
+package webhttp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/featureflags"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+// FeatureFlags returns a middleware function that injects the feature flags evaluated for the caller into request's context.
+// The caller is identified by the user ID of the authenticated principal and by the country code
+// from the `countryHeader`, e.g. set by the CDN or load balancer.
+func FeatureFlags(flags *featureflags.Flags, countryHeader string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := featureflags.ToContext(r.Context(), flags, FeatureFlagsTarget(r, countryHeader))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// FeatureFlagsTarget returns a target the feature flags are evaluated for the request.
+// Only the subjects of the access tokens are users, the API keys are not bound to any user.
+func FeatureFlagsTarget(r *http.Request, countryHeader string) featureflags.Target {
+	var target featureflags.Target
+	if principal, ok := auth.FromContext(r.Context()); ok && principal.Method == auth.MethodJWT {
+		target.UserID = principal.Subject
+	}
+	if countryHeader != "" {
+		target.Country = strings.ToUpper(strings.TrimSpace(r.Header.Get(countryHeader)))
+	}
+	return target
+}
+
+// NewFeatureFlagsHandler returns a handler of the requests to inspect and override feature flags at runtime.
+// Each request must be authorized with the `token` sent as 'Authorization: Bearer <token>' header.
+func NewFeatureFlagsHandler(token *secret.Value, flags *featureflags.Flags) *FeatureFlagsHandler {
+	return &FeatureFlagsHandler{token: token, flags: flags}
+}
+
+// FeatureFlagsHandler handles administrative requests of the feature flags.
+type FeatureFlagsHandler struct {
+	token *secret.Value
+	flags *featureflags.Flags
+}
+
+// Register creates a binding between method handlers and endpoints.
+func (fh *FeatureFlagsHandler) Register(router chi.Router) {
+	router = router.With(LogRequest(), RequireBearerToken(fh.token), ProducesJSON)
+	router.Method(http.MethodGet, "/-/admin/flags", http.HandlerFunc(fh.GetFlags))
+	router.With(AcceptsJSON).Method(http.MethodPut, "/-/admin/flags/{name}", http.HandlerFunc(fh.SetOverride))
+	router.Method(http.MethodDelete, "/-/admin/flags/{name}", http.HandlerFunc(fh.RemoveOverride))
+}
+
+// GetFlags returns definitions and overrides of all flags together with their values evaluated for the target
+// passed with 'user_id' and 'country' query parameters.
+func (fh *FeatureFlagsHandler) GetFlags(w http.ResponseWriter, r *http.Request) {
+	logger := fh.logger(r.Context(), "GetFlags")
+
+	query := r.URL.Query()
+	target := featureflags.Target{UserID: query.Get("user_id"), Country: strings.ToUpper(query.Get("country"))}
+
+	overrides := map[string]featureflags.Override{}
+	for _, override := range fh.flags.Overrides() {
+		overrides[override.Flag] = override
+	}
+
+	resp := FeatureFlagsResp{Flags: []FeatureFlagResp{}}
+	for _, flag := range fh.flags.Definitions() {
+		evaluation := fh.flags.Evaluate(flag.Name, target)
+		flagResp := FeatureFlagResp{
+			Name:        flag.Name,
+			Description: flag.Description,
+			Kind:        flag.Kind(),
+			Variants:    flag.Variants,
+			Default:     flag.Default,
+			Rules:       make([]FeatureFlagRuleResp, len(flag.Rules)),
+			Value:       evaluation.Value,
+			Reason:      evaluation.Reason,
+		}
+		for i, rule := range flag.Rules {
+			flagResp.Rules[i] = FeatureFlagRuleResp{Users: rule.Users, Countries: rule.Countries, Percentage: rule.Percentage, Value: rule.Value}
+		}
+		if evaluation.Reason == featureflags.ReasonRule {
+			flagResp.Rule = &evaluation.Rule
+		}
+		if override, ok := overrides[flag.Name]; ok {
+			flagResp.Override = &FeatureFlagOverrideResp{Value: override.Value}
+			if !override.ExpiresAt.IsZero() {
+				expiresAt := override.ExpiresAt.UTC()
+				flagResp.Override.ExpiresAt = &expiresAt
+			}
+		}
+		resp.Flags = append(resp.Flags, flagResp)
+	}
+
+	if err := Encode(w, resp); err != nil {
+		logger.WithError(err).Error("encode response")
+	}
+}
+
+// SetOverride overrides the value of the flag for all callers, optionally for a limited time.
+func (fh *FeatureFlagsHandler) SetOverride(w http.ResponseWriter, r *http.Request) {
+	logger := fh.logger(r.Context(), "SetOverride")
+
+	var req FeatureFlagOverrideReq
+	if err := Decode(r, &req); err != nil {
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	ttl, err := parseTTL(req.TTL)
+	if err != nil {
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := fh.flags.SetOverride(name, req.Value, ttl); err != nil {
+		ErrorResponse{Cause: err, StatusCode: errorStatusCode(err)}.Write(logger, w)
+		return
+	}
+
+	logger.WithString("flag", name).WithString("value", req.Value).WithString("ttl", ttl.String()).
+		Info("feature flag overridden")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveOverride reverts the value of the flag to the evaluated one.
+func (fh *FeatureFlagsHandler) RemoveOverride(w http.ResponseWriter, r *http.Request) {
+	logger := fh.logger(r.Context(), "RemoveOverride")
+
+	name := chi.URLParam(r, "name")
+	fh.flags.RemoveOverride(name)
+
+	logger.WithString("flag", name).Info("feature flag override removed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fh *FeatureFlagsHandler) logger(ctx context.Context, method string) logging.Logger {
+	return logging.FromContext(ctx).WithString("component", "FeatureFlagsHandler").WithString("method", method)
+}
+
+// FeatureFlagsResp describes all feature flags.
+type FeatureFlagsResp struct {
+	Flags []FeatureFlagResp `json:"flags"`
+}
+
+// FeatureFlagResp is a definition of the feature flag and its value evaluated for the requested target.
+type FeatureFlagResp struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description,omitempty"`
+	Kind        string                   `json:"kind"`
+	Variants    []string                 `json:"variants,omitempty"`
+	Default     string                   `json:"default,omitempty"`
+	Rules       []FeatureFlagRuleResp    `json:"rules"`
+	Override    *FeatureFlagOverrideResp `json:"override,omitempty"`
+	Value       string                   `json:"value"`
+	Reason      string                   `json:"reason"`
+	// Rule is an index of the rule that set the value.
+	Rule *int `json:"rule,omitempty"`
+}
+
+// FeatureFlagRuleResp is a targeting rule of the feature flag.
+type FeatureFlagRuleResp struct {
+	Users      []string `json:"users,omitempty"`
+	Countries  []string `json:"countries,omitempty"`
+	Percentage *float64 `json:"percentage,omitempty"`
+	Value      string   `json:"value"`
+}
+
+// FeatureFlagOverrideResp is a value of the feature flag set for all callers.
+type FeatureFlagOverrideResp struct {
+	Value     string     `json:"value"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// FeatureFlagOverrideReq overrides the value of the feature flag.
+// TTL is a duration in Go format, e.g. '15m', the override never expires if it is empty.
+type FeatureFlagOverrideReq struct {
+	Value string `json:"value"`
+	TTL   string `json:"ttl"`
+}
@@ -0,0 +1,96 @@
+package webhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/featureflags"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/secret"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	flags, err := featureflags.New([]featureflags.Flag{
+		{Name: "strict", Rules: []featureflags.Rule{{Users: []string{"1"}, Value: featureflags.On}}},
+		{Name: "layout", Variants: []string{"classic", "compact"}, Rules: []featureflags.Rule{{Countries: []string{"DE"}, Value: "compact"}}},
+	})
+	require.NoError(t, err)
+
+	authenticator := auth.AuthenticatorFunc(func(r *http.Request) (auth.Principal, error) {
+		return auth.Principal{Subject: r.Header.Get("x-subject"), Method: r.Header.Get("x-method")}, nil
+	})
+
+	r := NewRouter(logging.NewTestLogger())
+	r.With(Authenticate(authenticator), FeatureFlags(flags, "X-Country-Code")).Get("/flags", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"strict": featureflags.Enabled(r.Context(), "strict"),
+			"layout": featureflags.Variant(r.Context(), "layout"),
+		})
+	})
+	NewFeatureFlagsHandler(secret.NewValue("admin-token", nil), flags).Register(r)
+
+	do := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("content-type", "application/json")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+	admin := map[string]string{"authorization": "Bearer admin-token"}
+
+	t.Run("evaluation", func(t *testing.T) {
+		resp := do(http.MethodGet, "/flags", "", map[string]string{"x-subject": "1", "x-method": auth.MethodJWT, "x-country-code": "de"})
+		require.JSONEq(t, `{"strict":true,"layout":"compact"}`, resp.Body.String())
+
+		resp = do(http.MethodGet, "/flags", "", map[string]string{"x-subject": "1", "x-method": auth.MethodAPIKey})
+		require.JSONEq(t, `{"strict":false,"layout":"classic"}`, resp.Body.String(), "API key is not a user")
+	})
+
+	t.Run("inspect", func(t *testing.T) {
+		resp := do(http.MethodGet, "/-/admin/flags", "", nil)
+		require.Equal(t, http.StatusUnauthorized, resp.Code)
+
+		resp = do(http.MethodGet, "/-/admin/flags?user_id=1&country=de", "", admin)
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.JSONEq(t, `{"flags":[
+			{"name":"layout","kind":"variant","variants":["classic","compact"],"rules":[{"countries":["DE"],"value":"compact"}],"value":"compact","reason":"rule","rule":0},
+			{"name":"strict","kind":"boolean","rules":[{"users":["1"],"value":"true"}],"value":"true","reason":"rule","rule":0}
+		]}`, resp.Body.String())
+	})
+
+	t.Run("override", func(t *testing.T) {
+		resp := do(http.MethodPut, "/-/admin/flags/unknown", `{"value":"true"}`, admin)
+		require.Equal(t, http.StatusNotFound, resp.Code)
+
+		resp = do(http.MethodPut, "/-/admin/flags/strict", `{"value":"yes"}`, admin)
+		require.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = do(http.MethodPut, "/-/admin/flags/strict", `{"value":"true","ttl":"1h"}`, admin)
+		require.Equal(t, http.StatusNoContent, resp.Code)
+
+		resp = do(http.MethodGet, "/flags", "", map[string]string{"x-subject": "2", "x-method": auth.MethodJWT})
+		require.JSONEq(t, `{"strict":true,"layout":"classic"}`, resp.Body.String())
+
+		var flagsResp FeatureFlagsResp
+		resp = do(http.MethodGet, "/-/admin/flags", "", admin)
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &flagsResp))
+		require.Equal(t, featureflags.ReasonOverride, flagsResp.Flags[1].Reason)
+		require.Equal(t, "true", flagsResp.Flags[1].Override.Value)
+		require.NotNil(t, flagsResp.Flags[1].Override.ExpiresAt)
+
+		resp = do(http.MethodDelete, "/-/admin/flags/strict", "", admin)
+		require.Equal(t, http.StatusNoContent, resp.Code)
+
+		resp = do(http.MethodGet, "/flags", "", map[string]string{"x-subject": "2", "x-method": auth.MethodJWT})
+		require.JSONEq(t, `{"strict":false,"layout":"classic"}`, resp.Body.String())
+	})
+}
@@ -0,0 +1,178 @@
+package webhttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/storage"
+)
+
+// IdempotencyStore keeps outcomes of the requests made with idempotency keys.
+type IdempotencyStore interface {
+	// Reserve creates a record for the key if it doesn't exist yet or already expired.
+	// The reservation expires after `lease` unless it is completed.
+	// It returns `true` if the key was reserved by this call and the record holds the token of the reservation,
+	// otherwise an existing record is returned.
+	Reserve(ctx context.Context, key, fingerprint string, lease time.Duration) (storage.IdempotencyRecord, bool, error)
+	// Complete saves the outcome of the request made with the key reserved with the `token` for `ttl`.
+	Complete(ctx context.Context, key, token string, status int, location string, ttl time.Duration) error
+	// Release removes reservation of the key made with the `token`, so the request could be retried.
+	Release(ctx context.Context, key, token string) error
+}
+
+const (
+	idempotencyKeyHeader = "idempotency-key"
+	idempotencyKeyMaxLen = 255
+	// maxIdempotentBodySize limits the size of the request body used to calculate its fingerprint.
+	maxIdempotentBodySize = 1 << 20
+)
+
+// Idempotent returns a middleware function that honors 'Idempotency-Key' request header.
+// The response status code and 'location' header of the first request made with the key are stored
+// for `ttl` and replayed for all subsequent requests with the same key and payload.
+// The key reused with a different payload results into `422` status code.
+// Keys are scoped by the authenticated principal and the route, so the keys of different callers never collide.
+// If the original request failed with a server error or panicked the key is released, so it could be retried.
+// The key is reserved only for `lease` while the request is in progress, so the key is not blocked for long
+// if the outcome can't be saved, e.g. the process crashed.
+// The request that outlived its lease can't complete or release the key reserved again by a retry.
+func Idempotent(store IdempotencyStore, ttl, lease time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logger := logging.FromContext(r.Context()).WithString("idempotency_key", key)
+			if len(key) > idempotencyKeyMaxLen {
+				ErrorResponse{Cause: errors.New("idempotency key is too long"), StatusCode: http.StatusBadRequest}.Write(logger, w)
+				return
+			}
+
+			body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+			if err != nil {
+				logger.WithError(err).Error("read payload")
+				ErrorResponse{Cause: err, StatusCode: http.StatusRequestEntityTooLarge}.Write(logger, w)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			scoped := scopedIdempotencyKey(r, key)
+			fingerprint := requestFingerprint(r, body)
+			rec, reserved, err := store.Reserve(r.Context(), scoped, fingerprint, lease)
+			if err != nil {
+				logger.WithError(err).Error("reserve idempotency key")
+				WriteError(w, logger, err)
+				return
+			}
+
+			if !reserved {
+				replay(w, logger, rec, fingerprint)
+				return
+			}
+
+			// the outcome has to be saved even if the client has gone already
+			release := func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				if err := store.Release(ctx, scoped, rec.Token); err != nil {
+					logger.WithError(err).Error("release idempotency key")
+				}
+			}
+
+			defer func() {
+				if p := recover(); p != nil {
+					release()
+					panic(p)
+				}
+			}()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			if status >= http.StatusInternalServerError {
+				release()
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := store.Complete(ctx, scoped, rec.Token, status, ww.Header().Get("location"), ttl); err != nil {
+				// the reservation expires after the lease, then the request could be retried
+				logger.WithError(err).Error("complete idempotency key")
+			}
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, logger logging.Logger, rec storage.IdempotencyRecord, fingerprint string) {
+	switch {
+	case rec.Fingerprint != fingerprint:
+		logger.Debug("idempotency key reused with a different payload")
+		ErrorResponse{
+			Cause:      errors.New("idempotency key was already used with a different payload"),
+			StatusCode: http.StatusUnprocessableEntity,
+		}.Write(logger, w)
+	case rec.Status == 0:
+		logger.Debug("request with the same idempotency key is in progress")
+		w.Header().Set("retry-after", "1")
+		ErrorResponse{
+			Cause:      errors.New("request with the same idempotency key is in progress"),
+			StatusCode: http.StatusConflict,
+		}.Write(logger, w)
+	default:
+		logger.WithInt("status", rec.Status).Debug("replay of the stored response")
+		if rec.Location != "" {
+			w.Header().Set("location", rec.Location)
+		}
+		w.Header().Set("idempotent-replayed", "true")
+		w.WriteHeader(rec.Status)
+	}
+}
+
+// scopedIdempotencyKey returns the key unique for the principal and the route of the request.
+func scopedIdempotencyKey(r *http.Request, key string) string {
+	principal, _ := auth.FromContext(r.Context())
+	var route string
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		route = rctx.RoutePattern()
+	}
+
+	h := sha256.New()
+	for _, part := range []string{principal.Subject, r.Method, route, key} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// requestFingerprint returns a digest that identifies request's target and payload.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
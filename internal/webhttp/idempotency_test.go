@@ -0,0 +1,175 @@
+package webhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/storage"
+)
+
+func TestIdempotent(t *testing.T) {
+	logger := logging.NewTestLogger()
+	r := NewRouter(logger)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := NewMockUserService(ctrl)
+	mockUserService.EXPECT().Create(gomock.Any(), gomock.Any()).Return("1-2-3-4", nil).Times(1)
+
+	store := &testIdempotencyStore{}
+	userHandler := NewUsersHandler(mockUserService, WithIdempotency(store, time.Hour, time.Minute))
+	userHandler.Register(r)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/users", strings.NewReader(body))
+		req.Header.Set("content-type", "application/json")
+		req.Header.Set("idempotency-key", "key-1")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	first := send(`{"nickname":"n"}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+	require.Equal(t, "/users/1-2-3-4", first.Header().Get("location"))
+
+	retry := send(`{"nickname":"n"}`)
+	require.Equal(t, http.StatusCreated, retry.Code)
+	require.Equal(t, "/users/1-2-3-4", retry.Header().Get("location"))
+	require.Equal(t, "true", retry.Header().Get("idempotent-replayed"))
+
+	changed := send(`{"nickname":"x"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, changed.Code)
+
+	require.Equal(t, []time.Duration{time.Minute}, store.leases, "key is reserved for the lease")
+	require.Equal(t, []time.Duration{time.Hour}, store.ttls, "outcome is kept for ttl")
+
+	t.Run("scoped", func(t *testing.T) {
+		store := &testIdempotencyStore{}
+		var created int
+		r := NewRouter(logging.NewTestLogger())
+		authenticate := Authenticate(auth.AuthenticatorFunc(func(r *http.Request) (auth.Principal, error) {
+			return auth.Principal{Subject: r.Header.Get("x-subject")}, nil
+		}))
+		handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			created++
+			w.WriteHeader(http.StatusCreated)
+		})
+		r.With(authenticate, Idempotent(store, time.Hour, time.Minute)).Post("/users", handler)
+		r.With(authenticate, Idempotent(store, time.Hour, time.Minute)).Post("/users/{id}/password", handler)
+
+		send := func(path, subject string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+			req.Header.Set("idempotency-key", "key-1")
+			req.Header.Set("x-subject", subject)
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			return resp
+		}
+
+		require.Equal(t, http.StatusCreated, send("/users", "user-1").Code)
+		require.Equal(t, "true", send("/users", "user-1").Header().Get("idempotent-replayed"))
+		require.Equal(t, 1, created)
+
+		require.Equal(t, http.StatusCreated, send("/users", "user-2").Code)
+		require.Equal(t, 2, created, "the same key of other principal doesn't collide")
+
+		require.Equal(t, http.StatusCreated, send("/users/1/password", "user-1").Code)
+		require.Equal(t, 3, created, "the same key on other route doesn't collide")
+		require.Equal(t, http.StatusUnprocessableEntity, send("/users/2/password", "user-1").Code,
+			"the route is scoped by its pattern, other resource is a different payload")
+	})
+
+	t.Run("released on panic", func(t *testing.T) {
+		store := &testIdempotencyStore{}
+		r := NewRouter(logging.NewTestLogger())
+		r.With(Idempotent(store, time.Hour, time.Minute)).Post("/users", func(http.ResponseWriter, *http.Request) {
+			panic("boom")
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{}`))
+		req.Header.Set("idempotency-key", "key-1")
+		require.Panics(t, func() { r.ServeHTTP(httptest.NewRecorder(), req) })
+		require.Empty(t, store.records, "key could be retried")
+	})
+
+	t.Run("outlived lease", func(t *testing.T) {
+		store := &testIdempotencyStore{}
+		r := NewRouter(logging.NewTestLogger())
+		r.With(Idempotent(store, time.Hour, time.Minute)).Post("/users", func(w http.ResponseWriter, r *http.Request) {
+			// the lease passes while the request is in progress and the retry reserves the key again
+			key := scopedIdempotencyKey(r, "key-1")
+			store.expire(key)
+			_, reserved, err := store.Reserve(r.Context(), key, "retry", time.Minute)
+			require.NoError(t, err)
+			require.True(t, reserved)
+			w.WriteHeader(http.StatusCreated)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{}`))
+		req.Header.Set("idempotency-key", "key-1")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		require.Len(t, store.records, 1)
+		for _, rec := range store.records {
+			require.Equal(t, "retry", rec.Fingerprint)
+			require.Zero(t, rec.Status, "outcome of the retry is not overwritten")
+		}
+	})
+}
+
+type testIdempotencyStore struct {
+	records map[string]storage.IdempotencyRecord
+	leases  []time.Duration
+	ttls    []time.Duration
+}
+
+func (s *testIdempotencyStore) Reserve(_ context.Context, key, fingerprint string, lease time.Duration) (storage.IdempotencyRecord, bool, error) {
+	if s.records == nil {
+		s.records = map[string]storage.IdempotencyRecord{}
+	}
+
+	if rec, ok := s.records[key]; ok {
+		rec.Token = ""
+		return rec, false, nil
+	}
+
+	s.leases = append(s.leases, lease)
+	rec := storage.IdempotencyRecord{Key: key, Fingerprint: fingerprint, Token: "token-" + strconv.Itoa(len(s.leases))}
+	s.records[key] = rec
+	return rec, true, nil
+}
+
+// expire drops the reservation as if its lease has passed.
+func (s *testIdempotencyStore) expire(key string) {
+	delete(s.records, key)
+}
+
+func (s *testIdempotencyStore) Complete(_ context.Context, key, token string, status int, location string, ttl time.Duration) error {
+	s.ttls = append(s.ttls, ttl)
+	rec, ok := s.records[key]
+	if !ok || rec.Token != token || rec.Status != 0 {
+		return nil
+	}
+	rec.Status, rec.Location = status, location
+	s.records[key] = rec
+	return nil
+}
+
+func (s *testIdempotencyStore) Release(_ context.Context, key, token string) error {
+	if rec, ok := s.records[key]; ok && rec.Token == token && rec.Status == 0 {
+		delete(s.records, key)
+	}
+	return nil
+}
@@ -3,35 +3,118 @@ package webhttp
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
 
 	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/health"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/metrics"
 )
 
+// NewInfoHandler returns a handler of the service status requests.
+// `registry` provides the state of the service and its dependencies.
+func NewInfoHandler(registry *health.Registry) InfoHandler {
+	return InfoHandler{health: registry, metrics: metrics.Handler(metrics.Default)}
+}
+
 // InfoHandler handles requests about service status.
-type InfoHandler struct{}
+type InfoHandler struct {
+	health  *health.Registry
+	metrics http.Handler
+}
 
 // Register creates a binding between method handlers and endpoints.
 func (ih InfoHandler) Register(router chi.Router) {
-	router.Method(http.MethodGet, "/-/liveness", http.HandlerFunc(ih.Readiness))
-	router.Method(http.MethodGet, "/-/readiness", http.HandlerFunc(ih.Liveness))
+	ih.RegisterProbes(router)
+	router.With(ProducesJSON).Method(http.MethodGet, "/-/health", http.HandlerFunc(ih.Health))
 	router.With(ProducesJSON).Method(http.MethodGet, "/-/version", http.HandlerFunc(ih.Version))
+	router.Method(http.MethodGet, "/-/metrics", http.HandlerFunc(ih.Metrics))
+}
+
+// RegisterProbes creates a binding only for the liveness and readiness probes. They must be registered
+// on the public router too, as the orchestrator and the load balancer probe the address the clients use,
+// while the admin server may be reachable only locally.
+func (ih InfoHandler) RegisterProbes(router chi.Router) {
+	router.Method(http.MethodGet, "/-/liveness", http.HandlerFunc(ih.Liveness))
+	router.Method(http.MethodGet, "/-/readiness", http.HandlerFunc(ih.Readiness))
 }
 
 // Liveness returns HTTP status `200` for each request.
-// It is used to determine if the service is healthy.
+// It is used to determine if the process is alive, so it doesn't depend on the state of dependencies:
+// an unavailable database must not cause restarts of the service.
 func (InfoHandler) Liveness(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// Readiness returns HTTP status `200` for each request.
-// It is used to determine if the service is ready to start receiving requests.
-func (InfoHandler) Readiness(w http.ResponseWriter, _ *http.Request) {
+// Readiness returns HTTP status `200` if the service is ready to start receiving requests:
+// it is not starting or draining and all critical checks of the dependencies are passed.
+// Otherwise it returns HTTP status `503`.
+func (ih InfoHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	if err := ih.health.Ready(r.Context()); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Debug("service is not ready")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
+// Health returns a detailed report on the state of the service and each of its checks.
+// HTTP status is `200` if the service is ready and `503` otherwise.
+func (ih InfoHandler) Health(w http.ResponseWriter, r *http.Request) {
+	report := ih.health.Report(r.Context())
+
+	resp := HealthResp{Status: string(report.Status), State: report.State.String(), Checks: []CheckResp{}}
+	for _, result := range report.Results {
+		check := CheckResp{
+			Name:      result.Name,
+			Status:    string(result.Status),
+			Critical:  result.Critical,
+			LatencyMS: float64(result.Latency) / float64(time.Millisecond),
+			CheckedAt: result.CheckedAt.UTC(),
+		}
+		if result.Err != nil {
+			check.Error = result.Err.Error()
+		}
+		resp.Checks = append(resp.Checks, check)
+	}
+
+	status := http.StatusOK
+	if report.Status != health.StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	w.WriteHeader(status)
+
+	if err := Encode(w, resp); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("send health report")
+	}
+}
+
+// HealthResp is a report on the state of the service.
+type HealthResp struct {
+	Status string      `json:"status"`
+	State  string      `json:"state"`
+	Checks []CheckResp `json:"checks"`
+}
+
+// CheckResp is an outcome of the single check.
+type CheckResp struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	LatencyMS float64   `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
 // Version return information about binary: version, commit sha, timestamp of compilation
 func (InfoHandler) Version(w http.ResponseWriter, _ *http.Request) {
 	_ = internal.WriteVersion(json.NewEncoder(w))
 }
+
+// Metrics returns current state of all registered metrics in Prometheus exposition format.
+func (ih InfoHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	ih.metrics.ServeHTTP(w, r)
+}
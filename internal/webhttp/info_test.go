@@ -0,0 +1,72 @@
+package webhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/health"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+)
+
+func TestInfoHandler(t *testing.T) {
+	registry := health.NewRegistry(0)
+	var dbErr error
+	registry.Register(health.Check{Name: "db", Critical: true, Func: func(context.Context) error { return dbErr }})
+
+	r := NewAdminRouter(logging.NewTestLogger())
+	NewInfoHandler(registry).Register(r)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		return resp
+	}
+
+	require.Equal(t, http.StatusOK, get("/-/liveness").Code)
+	require.Equal(t, http.StatusServiceUnavailable, get("/-/readiness").Code, "service is starting")
+
+	registry.SetState(health.StateReady)
+	require.Equal(t, http.StatusOK, get("/-/readiness").Code)
+
+	resp := get("/-/health")
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	dbErr = errors.New("connection refused")
+	require.Equal(t, http.StatusServiceUnavailable, get("/-/readiness").Code)
+	require.Equal(t, http.StatusOK, get("/-/liveness").Code, "liveness doesn't depend on dependencies")
+
+	resp = get("/-/health")
+	require.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+	var report HealthResp
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &report))
+	require.Equal(t, "down", report.Status)
+	require.Equal(t, "ready", report.State)
+	require.Len(t, report.Checks, 1)
+	require.Equal(t, "db", report.Checks[0].Name)
+	require.Equal(t, "down", report.Checks[0].Status)
+	require.Equal(t, "connection refused", report.Checks[0].Error)
+}
+
+func TestInfoHandler_RegisterProbes(t *testing.T) {
+	registry := health.NewRegistry(0)
+	r := NewRouter(logging.NewTestLogger())
+	NewInfoHandler(registry).RegisterProbes(r)
+
+	get := func(path string) int {
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		return resp.Code
+	}
+
+	require.Equal(t, http.StatusOK, get("/-/liveness"))
+	registry.SetState(health.StateDraining)
+	require.Equal(t, http.StatusServiceUnavailable, get("/-/readiness"), "draining is visible on the public router")
+	require.Equal(t, http.StatusNotImplemented, get("/-/health"), "detailed report is available only on the admin router")
+}
@@ -4,29 +4,83 @@ import (
 	"mime"
 	"net/http"
 	"strings"
-	"sync/atomic"
 
+	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 
+	"github.com/pavelmemory/faceit-users/internal/codec"
 	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/requestid"
 )
 
+// RequestID returns a middleware function that identifies each request with a unique ID.
+// The ID is taken from the 'X-Request-ID' header of the request if it is valid, otherwise a new one is generated.
+// The ID is injected into request's context and returned back with the 'X-Request-ID' response header.
+func RequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(requestid.Header)
+			if !requestid.Valid(id) {
+				id = requestid.New()
+			}
+
+			w.Header().Set(requestid.Header, id)
+			ctx := requestid.ToContext(r.Context(), id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 // InjectLogger returns a middleware function that injects a logger into request's context.
-// It also propagates logger with a request unique sequence number, so all the logs
-// for a particular request could be grouped together.
+// It also propagates logger with a request ID injected with RequestID middleware,
+// so all the logs for a particular request could be grouped together.
 func InjectLogger(logger logging.Logger) func(http.Handler) http.Handler {
-	var reqSeq = new(int64)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			logger := logger.WithInt64("req_seq", atomic.AddInt64(reqSeq, 1))
+			logger := logger.WithString("request_id", requestid.FromContext(r.Context()))
 			ctx := logging.ToContext(r.Context(), logger)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// DebugTokenHeader is a name of the header with a signed token that forces debug logging level for the request.
+const DebugTokenHeader = "X-Debug-Token"
+
+// AdjustLogLevel returns a middleware function that replaces the logger of the request's context
+// with a logger of the level overridden for the route of the request. The route is resolved with `routes`
+// in advance, as the middleware is executed before the request is routed.
+// A valid token sent with 'X-Debug-Token' header forces debug level regardless of the overrides.
+func AdjustLogLevel(routes chi.Routes, levels *logging.LevelController) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var level string
+			if levels.VerifyDebugToken(r.Header.Get(DebugTokenHeader)) {
+				level = "debug"
+			} else {
+				path := r.URL.RawPath
+				if path == "" {
+					path = r.URL.Path
+				}
+
+				rctx := chi.NewRouteContext()
+				if routes.Match(rctx, r.Method, path) {
+					level, _ = levels.Override(rctx.RoutePattern())
+				}
+			}
+
+			if level != "" {
+				logger := logging.FromContext(r.Context()).WithLevel(level)
+				r = r.WithContext(logging.ToContext(r.Context(), logger))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // LogRequest returns a middleware function that logs each incoming request.
-// TODO: make logging level configurable so we could control log severity for each handler
+// Severity of the logs could be changed for particular routes with AdjustLogLevel middleware.
 func LogRequest() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -59,13 +113,38 @@ func LogRequest() func(http.Handler) http.Handler {
 // AcceptsJSON verifies request has a 'content-type' header with 'application/json' mime type.
 var AcceptsJSON = RequestContentType("application/json; charset=utf-8")
 
+// Accepts verifies request has a 'content-type' header with mime type of one of the registered codecs.
+var Accepts = RequestContentType(codec.Default.ContentTypes()...)
+
 // RequestContentType returns a middleware function that verifies request has
-// `content-type` header and its media type is equal to passed in value.
-func RequestContentType(contentType string) func(http.Handler) http.Handler {
-	wantMediaType, wantParams, err := mime.ParseMediaType(contentType)
-	if err != nil {
-		// this is fair enough as it will blow up at startup time
-		panic(err)
+// `content-type` header and its media type is equal to one of passed in values.
+func RequestContentType(contentTypes ...string) func(http.Handler) http.Handler {
+	type contentType struct {
+		mediaType string
+		params    map[string]string
+	}
+
+	var wants []contentType
+	for _, ct := range contentTypes {
+		wantMediaType, wantParams, err := mime.ParseMediaType(ct)
+		if err != nil {
+			// this is fair enough as it will blow up at startup time
+			panic(err)
+		}
+		wants = append(wants, contentType{mediaType: wantMediaType, params: wantParams})
+	}
+
+	matches := func(want contentType, mediaType string, params map[string]string) bool {
+		if !strings.EqualFold(want.mediaType, mediaType) {
+			return false
+		}
+
+		for k, v := range params {
+			if !strings.EqualFold(v, want.params[k]) {
+				return false
+			}
+		}
+		return true
 	}
 
 	return func(next http.Handler) http.Handler {
@@ -77,19 +156,14 @@ func RequestContentType(contentType string) func(http.Handler) http.Handler {
 				return
 			}
 
-			if !strings.EqualFold(wantMediaType, mediaType) {
-				w.WriteHeader(http.StatusUnsupportedMediaType)
-				return
-			}
-
-			for k, v := range params {
-				if !strings.EqualFold(v, wantParams[k]) {
-					w.WriteHeader(http.StatusUnsupportedMediaType)
+			for _, want := range wants {
+				if matches(want, mediaType, params) {
+					next.ServeHTTP(w, r)
 					return
 				}
 			}
 
-			next.ServeHTTP(w, r)
+			w.WriteHeader(http.StatusUnsupportedMediaType)
 		})
 	}
 }
@@ -114,6 +188,34 @@ func ResponseContentType(contentType string) func(http.Handler) http.Handler {
 	}
 }
 
+// Produces negotiates format of the response with the client using all registered codecs.
+var Produces = ResponseCodec(codec.Default)
+
+// ResponseCodec returns a middleware function that chooses a codec of the response
+// based on the `accept` header of the request and sets its content type as a `content-type`
+// header of the HTTP response. The default codec of the registry is used if client has no preferences.
+// If none of the codecs is acceptable by the client the request is rejected with `406` status code.
+func ResponseCodec(registry *codec.Registry) func(http.Handler) http.Handler {
+	mediaTypes := registry.MediaTypes()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType := negotiate(r.Header.Get("accept"), mediaTypes...)
+			c, ok := registry.Lookup(mediaType)
+			if !ok {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+
+			// the header is set in advance, so the response could be encoded with the chosen codec
+			w.Header().Set("content-type", c.ContentType())
+			w.Header().Add("vary", "accept")
+			ww := &responseContentTypeWrapper{ResponseWriter: w, contentType: c.ContentType()}
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
 type responseContentTypeWrapper struct {
 	contentType string
 	http.ResponseWriter
@@ -128,10 +230,18 @@ func (rw *responseContentTypeWrapper) Write(d []byte) (int, error) {
 }
 
 func (rw *responseContentTypeWrapper) WriteHeader(statusCode int) {
-	// keep plain response content-type for redirects (status: 300-308)
-	if statusCode >= http.StatusMultipleChoices && statusCode <= http.StatusPermanentRedirect {
+	// keep plain response content-type for redirects (status: 300-308) and responses without content
+	if statusCode >= http.StatusMultipleChoices && statusCode <= http.StatusPermanentRedirect || statusCode == http.StatusNoContent {
+		if rw.contentType != "" && rw.Header().Get("content-type") == rw.contentType {
+			rw.Header().Del("content-type")
+		}
 		rw.contentType = ""
 	}
 
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
+
+// passThrough is a middleware function that does nothing.
+func passThrough(next http.Handler) http.Handler {
+	return next
+}
@@ -0,0 +1,39 @@
+package webhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/requestid"
+)
+
+func TestRequestID(t *testing.T) {
+	var got string
+	handler := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = requestid.FromContext(r.Context())
+	}))
+
+	for header, keep := range map[string]bool{
+		"":                  false,
+		"req-1":             true,
+		"req 1":             false,
+		"req-1*/; DROP /**": false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("x-request-id", header)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+
+		require.Equal(t, got, resp.Header().Get("x-request-id"), header)
+		if keep {
+			require.Equal(t, header, got)
+		} else {
+			require.True(t, requestid.Valid(got), header)
+			require.NotEqual(t, header, got)
+		}
+	}
+}
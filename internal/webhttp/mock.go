@@ -93,3 +93,60 @@ func (mr *MockUserServiceMockRecorder) Delete(ctx, id interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserService)(nil).Delete), ctx, id)
 }
+
+// ChangePassword mocks base method
+func (m *MockUserService) ChangePassword(ctx context.Context, id, current, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, id, current, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword
+func (mr *MockUserServiceMockRecorder) ChangePassword(ctx, id, current, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockUserService)(nil).ChangePassword), ctx, id, current, password)
+}
+
+// Export mocks base method
+func (m *MockUserService) Export(ctx context.Context, fn func(user.Entity) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Export indicates an expected call of Export
+func (mr *MockUserServiceMockRecorder) Export(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockUserService)(nil).Export), ctx, fn)
+}
+
+// Import mocks base method
+func (m *MockUserService) Import(ctx context.Context, source user.EntitySource) (user.ImportReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", ctx, source)
+	ret0, _ := ret[0].(user.ImportReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Import indicates an expected call of Import
+func (mr *MockUserServiceMockRecorder) Import(ctx, source interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockUserService)(nil).Import), ctx, source)
+}
+
+// Batch mocks base method
+func (m *MockUserService) Batch(ctx context.Context, ops []user.Operation, atomic bool) []user.OperationResult {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Batch", ctx, ops, atomic)
+	ret0, _ := ret[0].([]user.OperationResult)
+	return ret0
+}
+
+// Batch indicates an expected call of Batch
+func (mr *MockUserServiceMockRecorder) Batch(ctx, ops, atomic interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Batch", reflect.TypeOf((*MockUserService)(nil).Batch), ctx, ops, atomic)
+}
@@ -0,0 +1,86 @@
+package webhttp
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// negotiate returns one of the offered media types that is the most preferred according to
+// the value of the 'accept' header. The order of offers is used to break ties.
+// If `accept` is empty the first offer is returned. If none of the offers is acceptable
+// an empty string is returned.
+func negotiate(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	ranges := parseAccept(accept)
+
+	var best string
+	var bestQuality float64
+	for _, offer := range offers {
+		quality := acceptQuality(ranges, offer)
+		if quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+
+	return best
+}
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+	}
+
+	return ranges
+}
+
+// acceptQuality returns a quality of the media type defined by the most specific matching range.
+func acceptQuality(ranges []mediaRange, mediaType string) float64 {
+	mediaType = strings.ToLower(mediaType)
+	slash := strings.IndexByte(mediaType, '/')
+
+	quality, specificity := 0.0, -1
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.mediaType == mediaType:
+			s = 2
+		case slash > 0 && r.mediaType == mediaType[:slash]+"/*":
+			s = 1
+		case r.mediaType == "*/*":
+			s = 0
+		default:
+			continue
+		}
+
+		if s > specificity {
+			quality, specificity = r.quality, s
+		}
+	}
+
+	return quality
+}
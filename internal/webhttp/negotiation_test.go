@@ -0,0 +1,26 @@
+package webhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{mediaTypeNDJSON, mediaTypeCSV}
+	for accept, exp := range map[string]string{
+		"":                                     mediaTypeNDJSON,
+		"*/*":                                  mediaTypeNDJSON,
+		"text/csv":                             mediaTypeCSV,
+		"TEXT/CSV":                             mediaTypeCSV,
+		"text/*":                               mediaTypeCSV,
+		"application/x-ndjson;q=0.5, text/csv": mediaTypeCSV,
+		"text/csv;q=0.5, */*;q=0.9":            mediaTypeNDJSON,
+		"text/*;q=0, */*":                      mediaTypeNDJSON,
+		"text/csv;q=0, */*":                    mediaTypeNDJSON,
+		"text/csv;q=invalid, application/xml":  "",
+		"application/xml":                      "",
+	} {
+		require.Equal(t, exp, negotiate(accept, offers...), accept)
+	}
+}
@@ -0,0 +1,34 @@
+package webhttp
+
+import (
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pavelmemory/faceit-users/internal/webhttp/pb"
+)
+
+// Payloads of the users API are encoded as the messages defined in 'api/proto/users.proto'
+// when the 'application/x-protobuf' format is negotiated, all others as `google.protobuf.Value` messages.
+
+//go:generate protoc --proto_path=../../api/proto --go_out=../.. --go_opt=module=github.com/pavelmemory/faceit-users users.proto
+
+func (CreateUserReq) NewProtoMessage() proto.Message { return &pb.CreateUserRequest{} }
+
+func (UpdateUserReq) NewProtoMessage() proto.Message { return &pb.UpdateUserRequest{} }
+
+func (GetUserResp) NewProtoMessage() proto.Message { return &pb.User{} }
+
+func (ChangePasswordReq) NewProtoMessage() proto.Message { return &pb.ChangePasswordRequest{} }
+
+func (BatchReq) NewProtoMessage() proto.Message { return &pb.BatchRequest{} }
+
+func (BatchResp) NewProtoMessage() proto.Message { return &pb.BatchResponse{} }
+
+func (ImportResp) NewProtoMessage() proto.Message { return &pb.ImportResponse{} }
+
+func (LoginReq) NewProtoMessage() proto.Message { return &pb.LoginRequest{} }
+
+func (RefreshTokenReq) NewProtoMessage() proto.Message { return &pb.RefreshTokenRequest{} }
+
+func (SessionResp) NewProtoMessage() proto.Message { return &pb.Session{} }
+
+func (ErrorResp) NewProtoMessage() proto.Message { return &pb.Error{} }
@@ -0,0 +1,41 @@
+package webhttp
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/codec"
+)
+
+func TestProtoMessages(t *testing.T) {
+	base := UserBase{FirstName: "fn", LastName: "ln", Nickname: "nn", Email: "e@mail.com", Country: "XX"}
+	details := map[string]interface{}{"email": "invalid format"}
+
+	// all properties are set, so the payloads don't match messages if any field is missing
+	for _, src := range []codec.ProtoMapped{
+		CreateUserReq{UserBase: base, Password: "password"},
+		UpdateUserReq{UserBase: base},
+		GetUserResp{UserBase: base},
+		ChangePasswordReq{CurrentPassword: "current", NewPassword: "new"},
+		BatchReq{Mode: "atomic", Operations: []BatchOperationReq{{Op: "create", ID: "1", User: CreateUserReq{UserBase: base, Password: "password"}}}},
+		BatchResp{Results: []BatchOperationResp{{Status: 400, ID: "1", Error: "invalid", Details: details}}},
+		ImportResp{Imported: 1 << 40, Rejected: 1, Rejections: []RejectionResp{{Line: 2, Status: 400, Error: "invalid", Details: details}}},
+		LoginReq{Email: "e@mail.com", Password: "password"},
+		RefreshTokenReq{RefreshToken: "token"},
+		SessionResp{AccessToken: "access", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "refresh", RefreshExpiresAt: time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)},
+		ErrorResp{Error: "invalid", Reasons: []string{"reason"}, RequestID: "request"},
+	} {
+		t.Run(reflect.TypeOf(src).Name(), func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, codec.Protobuf{}.Encode(&buf, src))
+
+			dst := reflect.New(reflect.TypeOf(src))
+			require.NoError(t, codec.Protobuf{}.Decode(&buf, dst.Interface()))
+			require.Equal(t, src, dst.Elem().Interface())
+		})
+	}
+}
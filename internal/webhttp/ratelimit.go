@@ -0,0 +1,95 @@
+package webhttp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/ratelimit"
+)
+
+// ErrRateLimited is returned if the client made too many requests.
+var ErrRateLimited = errors.New("too many requests")
+
+// RateLimiter decides if the request made with the key is allowed.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (ratelimit.Result, error)
+}
+
+// RateLimitKey returns a part of the key the requests are limited by.
+type RateLimitKey func(r *http.Request) string
+
+// RateLimitByIP limits requests of each client IP address, see ClientIP for how it is found behind the `trustedProxies`.
+func RateLimitByIP(trustedProxies []*net.IPNet) RateLimitKey {
+	return func(r *http.Request) string {
+		return "ip:" + ClientIP(r, trustedProxies)
+	}
+}
+
+// RateLimitByPrincipal limits requests of each authenticated principal,
+// requests without a principal are limited by client IP address.
+func RateLimitByPrincipal(trustedProxies []*net.IPNet) RateLimitKey {
+	byIP := RateLimitByIP(trustedProxies)
+	return func(r *http.Request) string {
+		if principal, ok := auth.FromContext(r.Context()); ok {
+			return "principal:" + principal.Subject
+		}
+		return byIP(r)
+	}
+}
+
+// RateLimitByRoute limits requests to each route, the limit is shared by all clients.
+func RateLimitByRoute(r *http.Request) string {
+	return "route:" + r.Method + " " + chi.RouteContext(r.Context()).RoutePattern()
+}
+
+// RateLimit returns a middleware function that rejects requests over the limit with `429` status code.
+// The requests are limited by the combination of the `keys`. The state of the limit is sent back in
+// 'RateLimit-Limit', 'RateLimit-Remaining' and 'RateLimit-Reset' headers, rejected requests get
+// 'Retry-After' header. The requests are allowed if the limiter fails, so the service stays available.
+// The middleware must be applied to the routes, as the route is known only after routing.
+func RateLimit(limiter RateLimiter, keys ...RateLimitKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parts := make([]string, len(keys))
+			for i, key := range keys {
+				parts[i] = key(r)
+			}
+			key := strings.Join(parts, "|")
+
+			logger := logging.FromContext(r.Context())
+			res, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				logger.WithError(err).WithString("rate_limit_key", key).Error("rate limit")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("ratelimit-limit", strconv.Itoa(res.Limit))
+			w.Header().Set("ratelimit-remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("ratelimit-reset", seconds(res.Reset))
+			if !res.Allowed {
+				logger.WithString("rate_limit_key", key).Info("rate limit exceeded")
+				w.Header().Set("retry-after", seconds(res.RetryAfter))
+				ErrorResponse{Cause: ErrRateLimited, StatusCode: http.StatusTooManyRequests}.Write(logger, w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds returns the duration rounded up to the whole seconds.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,109 @@
+package webhttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/auth"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/ratelimit"
+)
+
+type rateLimiterFunc func(ctx context.Context, key string) (ratelimit.Result, error)
+
+func (f rateLimiterFunc) Allow(ctx context.Context, key string) (ratelimit.Result, error) {
+	return f(ctx, key)
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("limited", func(t *testing.T) {
+		r := NewRouter(logging.NewTestLogger())
+		limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Every(time.Minute, 1))
+		_, proxies, err := net.ParseCIDR("192.0.2.0/24")
+		require.NoError(t, err)
+		r.With(RateLimit(limiter, RateLimitByIP([]*net.IPNet{proxies}))).Get("/users", func(http.ResponseWriter, *http.Request) {})
+
+		do := func(remoteAddr string, forwardedFor ...string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.RemoteAddr = remoteAddr
+			for _, addr := range forwardedFor {
+				req.Header.Add("x-forwarded-for", addr)
+			}
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := do("10.0.0.1:1234")
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.Equal(t, "1", resp.Header().Get("ratelimit-limit"))
+		require.Equal(t, "0", resp.Header().Get("ratelimit-remaining"))
+		require.Equal(t, "60", resp.Header().Get("ratelimit-reset"))
+
+		resp = do("10.0.0.1:4321")
+		require.Equal(t, http.StatusTooManyRequests, resp.Code, "port of the client is ignored")
+		require.Equal(t, "60", resp.Header().Get("retry-after"))
+		require.Contains(t, resp.Body.String(), ErrRateLimited.Error())
+
+		resp = do("10.0.0.2:1234")
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		resp = do("192.0.2.1:1234", "10.0.0.1")
+		require.Equal(t, http.StatusTooManyRequests, resp.Code, "client behind the trusted proxy")
+
+		resp = do("192.0.2.1:1234", "10.0.0.3")
+		require.Equal(t, http.StatusOK, resp.Code, "clients behind the same proxy don't share the limit")
+
+		resp = do("10.0.0.4:1234", "10.0.0.5")
+		require.Equal(t, http.StatusOK, resp.Code)
+		resp = do("10.0.0.4:1234", "10.0.0.6")
+		require.Equal(t, http.StatusTooManyRequests, resp.Code, "forwarded address is ignored for untrusted peer")
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		var key string
+		limiter := rateLimiterFunc(func(_ context.Context, k string) (ratelimit.Result, error) {
+			key = k
+			return ratelimit.Result{Allowed: true}, nil
+		})
+
+		r := NewRouter(logging.NewTestLogger())
+		authenticate := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if subject := r.Header.Get("x-subject"); subject != "" {
+					r = r.WithContext(auth.ToContext(r.Context(), auth.Principal{Subject: subject}))
+				}
+				next.ServeHTTP(w, r)
+			})
+		}
+		r.With(authenticate, RateLimit(limiter, RateLimitByPrincipal(nil), RateLimitByRoute)).Get("/users/{id}", func(http.ResponseWriter, *http.Request) {})
+
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		require.Equal(t, "ip:10.0.0.1|route:GET /users/{id}", key)
+
+		req.Header.Set("x-subject", "user-1")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		require.Equal(t, "principal:user-1|route:GET /users/{id}", key)
+	})
+
+	t.Run("limiter failure", func(t *testing.T) {
+		limiter := rateLimiterFunc(func(context.Context, string) (ratelimit.Result, error) {
+			return ratelimit.Result{}, context.DeadlineExceeded
+		})
+
+		r := NewRouter(logging.NewTestLogger())
+		r.With(RateLimit(limiter, RateLimitByIP(nil))).Get("/users", func(http.ResponseWriter, *http.Request) {})
+
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/users", nil))
+		require.Equal(t, http.StatusOK, resp.Code)
+	})
+}
@@ -1,26 +1,48 @@
 package webhttp
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/go-chi/chi"
 
 	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/codec"
 	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/requestid"
+	"github.com/pavelmemory/faceit-users/internal/user"
 )
 
 // NewRouter returns initialized HTTP router.
 // It sets up all required middlewares and bindings for endpoints.
-func NewRouter(logger logging.Logger) chi.Router {
+func NewRouter(logger logging.Logger, options ...RouterOption) chi.Router {
+	var opts routerOptions
+	for _, option := range options {
+		option(&opts)
+	}
+
 	router := chi.NewRouter()
-	router.Use(InjectLogger(logger)) // TODO: CORS, caching, tracing, metrics, etc.
+	router.Use(RequestID(), InjectLogger(logger))
+	if opts.levels != nil {
+		router.Use(AdjustLogLevel(router, opts.levels))
+	}
+	router.Use(Trace(), Measure()) // TODO: CORS, etc.
+	if opts.accessLog != nil {
+		router.Use(LogAccess(opts.accessLog))
+	}
+
+	router.With(LogRequest()).NotFound(undefined)
+	router.With(LogRequest()).MethodNotAllowed(undefined)
+
+	return router
+}
 
-	infoHandler := InfoHandler{}
-	infoHandler.Register(router)
+// NewAdminRouter returns initialized HTTP router for the administrative endpoints: health, metrics, diagnostics, etc.
+// Requests are not traced and measured, so frequent probes and scrapes don't pollute observability data.
+func NewAdminRouter(logger logging.Logger) chi.Router {
+	router := chi.NewRouter()
+	router.Use(RequestID(), InjectLogger(logger))
 
 	router.With(LogRequest()).NotFound(undefined)
 	router.With(LogRequest()).MethodNotAllowed(undefined)
@@ -28,6 +50,28 @@ func NewRouter(logger logging.Logger) chi.Router {
 	return router
 }
 
+// RouterOption allows to customize behaviour of the router.
+type RouterOption func(opts *routerOptions)
+
+type routerOptions struct {
+	levels    *logging.LevelController
+	accessLog *AccessLogger
+}
+
+// WithLogLevels enables runtime control of the logging level of the requests.
+func WithLogLevels(levels *logging.LevelController) RouterOption {
+	return func(opts *routerOptions) {
+		opts.levels = levels
+	}
+}
+
+// WithAccessLog writes each request into the access log.
+func WithAccessLog(accessLog *AccessLogger) RouterOption {
+	return func(opts *routerOptions) {
+		opts.accessLog = accessLog
+	}
+}
+
 func undefined(w http.ResponseWriter, r *http.Request) {
 	logging.FromContext(r.Context()).Debug("request is not implemented")
 	w.WriteHeader(http.StatusNotImplemented)
@@ -35,22 +79,29 @@ func undefined(w http.ResponseWriter, r *http.Request) {
 
 // WriteError sends an error response back to the client.
 func WriteError(w http.ResponseWriter, logger logging.Logger, err error) {
-	resp := ErrorResponse{StatusCode: http.StatusInternalServerError}
+	resp := ErrorResponse{StatusCode: errorStatusCode(err)}
 	if logger.IsDebug() {
 		// sends error details back to the client only in debugging mode
 		resp.Cause = err
 	}
 
+	resp.Write(logger, w)
+}
+
+// errorStatusCode returns HTTP status code that corresponds to the error.
+func errorStatusCode(err error) int {
 	switch {
 	case errors.Is(err, internal.ErrBadInput):
-		resp.StatusCode = http.StatusBadRequest
+		return http.StatusBadRequest
 	case errors.Is(err, internal.ErrNotUnique):
-		resp.StatusCode = http.StatusConflict
+		return http.StatusConflict
 	case errors.Is(err, internal.ErrNotFound):
-		resp.StatusCode = http.StatusNotFound
+		return http.StatusNotFound
+	case errors.Is(err, user.ErrAborted):
+		return http.StatusFailedDependency
+	default:
+		return http.StatusInternalServerError
 	}
-
-	resp.Write(logger, w)
 }
 
 // ErrorResponse aggregates error information into the struct and know how to send it back to the client.
@@ -59,30 +110,62 @@ type ErrorResponse struct {
 	StatusCode int
 	// Cause is an error that needs to be placed as a message describing what was wrong.
 	Cause error
+	// Reasons explain the cause in details, e.g. why access is denied.
+	Reasons []string
 }
 
 func (er ErrorResponse) Write(logger logging.Logger, w http.ResponseWriter) {
-	w.WriteHeader(er.StatusCode)
+	// request ID is sent back, so the client could refer to the failed request
+	resp := ErrorResp{RequestID: w.Header().Get(requestid.Header), Reasons: er.Reasons}
+	if er.Cause != nil {
+		resp.Error = er.Cause.Error()
+	}
 
-	if er.Cause == nil {
+	if resp.Error == "" && resp.RequestID == "" && len(resp.Reasons) == 0 {
+		w.WriteHeader(er.StatusCode)
 		return
 	}
 
-	if err := Encode(w, er.Cause.Error()); err != nil {
+	if w.Header().Get("content-type") == "" {
+		w.Header().Set("content-type", codec.Default.Default().ContentType())
+	}
+	w.WriteHeader(er.StatusCode)
+
+	if err := Encode(w, resp); err != nil {
 		logger.WithError(err).Error("send response")
 	}
 }
 
-func Decode(reader io.Reader, dst interface{}) error {
-	if err := json.NewDecoder(reader).Decode(dst); err != nil {
-		return fmt.Errorf("decode into %T: %w", dst, err)
+// ErrorResp is a payload of the error response.
+type ErrorResp struct {
+	Error     string   `json:"error,omitempty"`
+	Reasons   []string `json:"reasons,omitempty"`
+	RequestID string   `json:"request_id,omitempty"`
+}
+
+// Decode decodes request payload into `dst` with a codec that matches request's content type.
+// JSON codec is used if there is no matching codec.
+func Decode(r *http.Request, dst interface{}) error {
+	c, ok := codec.Default.Lookup(r.Header.Get("content-type"))
+	if !ok {
+		c = codec.Default.Default()
 	}
-	return nil
+	return c.Decode(r.Body, dst)
 }
 
+// Encode encodes `src` into the `writer` with a codec that matches response's content type
+// if the `writer` is a http.ResponseWriter. JSON codec is used if there is no matching codec.
 func Encode(writer io.Writer, src interface{}) error {
-	if err := json.NewEncoder(writer).Encode(src); err != nil {
-		return fmt.Errorf("encode %T: %w", src, err)
+	if w, ok := writer.(http.ResponseWriter); ok {
+		return responseCodec(w).Encode(writer, src)
+	}
+	return codec.Default.Default().Encode(writer, src)
+}
+
+// responseCodec returns a codec that matches response's content type or JSON codec if there is no matching codec.
+func responseCodec(w http.ResponseWriter) codec.Codec {
+	if found, ok := codec.Default.Lookup(w.Header().Get("content-type")); ok {
+		return found
 	}
-	return nil
+	return codec.Default.Default()
 }
@@ -2,6 +2,7 @@ package webhttp
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 	"strconv"
@@ -10,14 +11,47 @@ import (
 	"github.com/pavelmemory/faceit-users/internal/logging"
 )
 
-func NewServer(hl http.Handler) *http.Server {
-	return &http.Server{
+// DefaultGracePeriod is a time the server is given to finish in-flight requests if Binding doesn't set one.
+const DefaultGracePeriod = time.Minute
+
+func NewServer(hl http.Handler, options ...ServerOption) *http.Server {
+	srv := &http.Server{
 		Handler: hl,
 	}
+	for _, option := range options {
+		option(srv)
+	}
+	return srv
+}
+
+// ServerOption allows to customize behaviour of the server.
+type ServerOption func(srv *http.Server)
+
+// WithTimeouts limits a time to read headers of the request and a time the keep-alive connection waits for the next one.
+// There is no limit on time of the whole request or response as event streams are endless.
+func WithTimeouts(readHeader, idle time.Duration) ServerOption {
+	return func(srv *http.Server) {
+		srv.ReadHeaderTimeout = readHeader
+		srv.IdleTimeout = idle
+	}
 }
 
+// WithTLS makes the server accept only TLS connections, HTTP/2 is enabled for them.
+func WithTLS(config *tls.Config) ServerOption {
+	return func(srv *http.Server) {
+		srv.TLSConfig = config
+	}
+}
+
+// StartServer serves connections accepted by the listener, they are TLS ones if the server has TLS configuration.
 func StartServer(l net.Listener, srv *http.Server) error {
-	err := srv.Serve(l)
+	var err error
+	if srv.TLSConfig != nil {
+		// certificates are provided by the configuration
+		err = srv.ServeTLS(l, "", "")
+	} else {
+		err = srv.Serve(l)
+	}
 	if err == http.ErrServerClosed {
 		return nil
 	}
@@ -49,26 +83,80 @@ func StopServer(logger logging.Logger, srv *http.Server, gracePeriod time.Durati
 	return err
 }
 
+// Binding is a server with an address it listens on.
+type Binding struct {
+	// Name identifies the server in logs, e.g. 'public' or 'admin'.
+	Name string
+	Addr string
+	// Listener is used instead of listening on Addr if it is set, e.g. to know the address before the server starts.
+	Listener net.Listener
+	Server   *http.Server
+	// GracePeriod is a time to finish in-flight requests before the server is forced to stop, DefaultGracePeriod if zero.
+	GracePeriod time.Duration
+	// AfterStop is called once the server is stopped before the next one is stopped, e.g. to stop background
+	// workers the in-flight requests could depend on. It is allowed to be nil.
+	AfterStop func()
+}
+
+// Serve listens for connections on the `port` of all interfaces and serves them with `srv`
+// until context is cancelled.
 func Serve(ctx context.Context, logger logging.Logger, srv *http.Server, port int) error {
-	lis, err := net.Listen("tcp", ":"+strconv.Itoa(port))
-	if err != nil {
-		logger.WithError(err).Error("listener instantiation")
-		return err
-	}
+	return ServeAll(ctx, logger, Binding{Name: "public", Addr: ":" + strconv.Itoa(port), Server: srv})
+}
 
-	srv.Addr = lis.Addr().String()
+// ServeAll starts all servers and stops them when context is cancelled or any of them fails.
+// The servers are stopped one by one in the order of bindings, so the ones that come last (e.g. with
+// health and metrics endpoints) stay available while the previous ones finish in-flight requests.
+// No server is started if any of the addresses can't be listened on, the provided listeners are closed in that case.
+// The servers are not modified, so the bound addresses are known only from the listeners.
+func ServeAll(ctx context.Context, logger logging.Logger, bindings ...Binding) error {
+	listeners := make([]net.Listener, 0, len(bindings))
+	for _, binding := range bindings {
+		lis := binding.Listener
+		if lis == nil {
+			var err error
+			if lis, err = net.Listen("tcp", binding.Addr); err != nil {
+				logger.WithString("server", binding.Name).WithError(err).Error("listener instantiation")
+				for _, binding := range bindings {
+					if binding.Listener != nil {
+						_ = binding.Listener.Close()
+					}
+				}
+				for _, lis := range listeners {
+					_ = lis.Close()
+				}
+				return err
+			}
+		}
+		listeners = append(listeners, lis)
+	}
 
-	logger = logger.WithString("addr", lis.Addr().String())
-	logger.Info("server is starting listening")
+	startErr := make(chan error, len(bindings))
+	for i, binding := range bindings {
+		logger.WithString("server", binding.Name).WithString("addr", listeners[i].Addr().String()).Info("server is starting listening")
 
-	startErr := make(chan error)
-	go func() { startErr <- StartServer(lis, srv) }()
+		go func(lis net.Listener, srv *http.Server) { startErr <- StartServer(lis, srv) }(listeners[i], binding.Server)
+	}
 
+	var err error
 	select {
-	case err := <-startErr:
+	case err = <-startErr:
 		logger.WithError(err).Error("server start")
-		return err
 	case <-ctx.Done():
-		return StopServer(logger, srv, time.Minute)
 	}
+
+	for i, binding := range bindings {
+		logger := logger.WithString("server", binding.Name).WithString("addr", listeners[i].Addr().String())
+		gracePeriod := binding.GracePeriod
+		if gracePeriod == 0 {
+			gracePeriod = DefaultGracePeriod
+		}
+		if stopErr := StopServer(logger, binding.Server, gracePeriod); err == nil {
+			err = stopErr
+		}
+		if binding.AfterStop != nil {
+			binding.AfterStop()
+		}
+	}
+	return err
 }
@@ -0,0 +1,125 @@
+package webhttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/logging"
+)
+
+func TestServeAll(t *testing.T) {
+	t.Run("stopped together", func(t *testing.T) {
+		ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+		public, admin := listen(t), listen(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error)
+		go func() {
+			served <- ServeAll(ctx, logging.NewTestLogger(),
+				Binding{Name: "public", Listener: public, Server: NewServer(ok)},
+				Binding{Name: "admin", Listener: admin, Server: NewServer(ok)},
+			)
+		}()
+
+		for _, lis := range []net.Listener{public, admin} {
+			addr := lis.Addr().String()
+			require.Eventually(t, func() bool {
+				resp, err := http.Get("http://" + addr)
+				if err != nil {
+					return false
+				}
+				_ = resp.Body.Close()
+				return resp.StatusCode == http.StatusOK
+			}, time.Second, 10*time.Millisecond)
+		}
+
+		cancel()
+		require.NoError(t, <-served)
+		for _, lis := range []net.Listener{public, admin} {
+			_, err := http.Get("http://" + lis.Addr().String())
+			require.Error(t, err, "server is stopped")
+		}
+	})
+
+	t.Run("stopped in order", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		// only the in-flight request blocks, probes could reach the server before it stops accepting connections
+		public := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/in-flight" {
+				close(started)
+				<-release
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		admin := NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }))
+		publicLis, adminLis := listen(t), listen(t)
+		publicAddr, adminAddr := publicLis.Addr().String(), adminLis.Addr().String()
+
+		var stopped []string
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error)
+		go func() {
+			served <- ServeAll(ctx, logging.NewTestLogger(),
+				Binding{Name: "public", Listener: publicLis, Server: public, AfterStop: func() { stopped = append(stopped, "public") }},
+				Binding{Name: "admin", Listener: adminLis, Server: admin, AfterStop: func() { stopped = append(stopped, "admin") }},
+			)
+		}()
+
+		inFlight := make(chan int)
+		go func() {
+			resp, err := http.Get("http://" + publicAddr + "/in-flight")
+			if err != nil {
+				inFlight <- 0
+				return
+			}
+			_ = resp.Body.Close()
+			inFlight <- resp.StatusCode
+		}()
+		<-started
+
+		cancel()
+		require.Eventually(t, func() bool {
+			resp, err := http.Get("http://" + publicAddr)
+			if err != nil {
+				return true
+			}
+			_ = resp.Body.Close()
+			return false
+		}, time.Second, 10*time.Millisecond, "public server doesn't accept new requests")
+
+		resp, err := http.Get("http://" + adminAddr)
+		require.NoError(t, err, "admin server is available while public one finishes in-flight requests")
+		_ = resp.Body.Close()
+
+		close(release)
+		require.Equal(t, http.StatusOK, <-inFlight)
+		require.NoError(t, <-served)
+		require.Equal(t, []string{"public", "admin"}, stopped)
+	})
+
+	t.Run("address in use", func(t *testing.T) {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer lis.Close()
+
+		public := listen(t)
+		err = ServeAll(context.Background(), logging.NewTestLogger(),
+			Binding{Name: "public", Listener: public, Server: NewServer(http.NotFoundHandler())},
+			Binding{Name: "admin", Addr: lis.Addr().String(), Server: NewServer(http.NotFoundHandler())},
+		)
+		require.Error(t, err)
+		_, err = public.Accept()
+		require.Error(t, err, "provided listener is closed")
+	})
+}
+
+func listen(t *testing.T) net.Listener {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return lis
+}
@@ -0,0 +1,254 @@
+package webhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/session"
+	"github.com/pavelmemory/faceit-users/internal/tracing"
+)
+
+// SessionService starts, prolongs and ends sessions of the users.
+type SessionService interface {
+	// Login verifies credentials of the user and issues tokens of a new session.
+	Login(ctx context.Context, email, password string) (session.Tokens, error)
+	// Refresh exchanges the refresh token for new tokens of the same session.
+	Refresh(ctx context.Context, refreshToken string) (session.Tokens, error)
+	// Logout revokes the session the refresh token belongs to.
+	Logout(ctx context.Context, refreshToken string) error
+}
+
+// LoginLimiter slows down and locks out login attempts after failures.
+type LoginLimiter interface {
+	// Attempt registers the attempt as failed if it is allowed, so the concurrent attempts are limited as well.
+	// It returns a time until the next attempt is allowed and if the key is locked.
+	Attempt(ctx context.Context, key string) (time.Duration, bool, error)
+	// Release forgets the attempt that ended for another reason than invalid credentials.
+	Release(ctx context.Context, key string) error
+	// Reset forgets the failures of the key.
+	Reset(ctx context.Context, key string) error
+}
+
+// ErrLoginLocked is returned if the account is locked after too many failed login attempts.
+var ErrLoginLocked = errors.New("account is temporarily locked after too many failed login attempts")
+
+// ErrLoginDelayed is returned if the login attempt is made too soon after the failed one.
+var ErrLoginDelayed = errors.New("too many failed login attempts, retry later")
+
+// NewSessionHandler returns HTTP handler of the user sessions.
+// The endpoints are public, they must not be protected by the authentication.
+func NewSessionHandler(sessionService SessionService, options ...SessionHandlerOption) *SessionHandler {
+	sh := &SessionHandler{sessionService: sessionService}
+	for _, option := range options {
+		option(sh)
+	}
+	return sh
+}
+
+// SessionHandlerOption allows to customize behaviour of the SessionHandler.
+type SessionHandlerOption func(sh *SessionHandler)
+
+// WithLoginLimiter protects the accounts from brute-force attacks: failed login attempts with the same email
+// are slowed down by `limiter` and the account is locked out after too many of them.
+func WithLoginLimiter(limiter LoginLimiter) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		sh.loginLimiter = limiter
+	}
+}
+
+// SessionHandler handles requests for the user sessions.
+type SessionHandler struct {
+	sessionService SessionService
+	loginLimiter   LoginLimiter
+}
+
+type LoginReq struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type RefreshTokenReq struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type SessionResp struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is a lifetime of the access token in seconds.
+	ExpiresIn        int64     `json:"expires_in"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// Register creates a binding between method handlers and endpoints.
+func (sh *SessionHandler) Register(router chi.Router) {
+	router = router.With(LogRequest())
+	router.With(Produces, Accepts).Method(http.MethodPost, "/sessions", http.HandlerFunc(sh.Login))
+	router.With(Produces, Accepts).Method(http.MethodPost, "/sessions:refresh", http.HandlerFunc(sh.Refresh))
+	router.With(Produces, Accepts).Method(http.MethodPost, "/sessions:revoke", http.HandlerFunc(sh.Revoke))
+}
+
+func (sh *SessionHandler) Login(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "webhttp.SessionHandler/Login", tracing.KindInternal)
+	defer span.End()
+	logger := sh.logger(ctx, "Login")
+
+	logger.Debug("start")
+	defer logger.Debug("end")
+
+	var req LoginReq
+	if err := Decode(r, &req); err != nil {
+		logger.WithError(err).Error("decode payload")
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	// emails are case-insensitive, so the failures are counted regardless of the case
+	limitKey := "login:" + strings.ToLower(strings.TrimSpace(req.Email))
+	if !sh.checkLogin(ctx, w, logger, limitKey) {
+		return
+	}
+
+	tokens, err := sh.sessionService.Login(ctx, req.Email, req.Password)
+	if err != nil {
+		if !errors.Is(err, session.ErrInvalidCredentials) {
+			sh.releaseLogin(ctx, logger, limitKey)
+		}
+		sh.writeError(w, logger, err)
+		return
+	}
+
+	if sh.loginLimiter != nil {
+		if err := sh.loginLimiter.Reset(ctx, limitKey); err != nil {
+			logger.WithError(err).Error("reset login failures")
+		}
+	}
+
+	sh.writeTokens(w, logger, http.StatusCreated, tokens)
+}
+
+// checkLogin writes an error response and returns false if the login attempt is not allowed yet.
+// The allowed attempt is registered as failed until it succeeds, so the concurrent attempts with the same key
+// can't bypass the limit. The attempts are allowed if the limiter fails, so the users are not locked out by the outage.
+func (sh *SessionHandler) checkLogin(ctx context.Context, w http.ResponseWriter, logger logging.Logger, key string) bool {
+	if sh.loginLimiter == nil {
+		return true
+	}
+
+	wait, locked, err := sh.loginLimiter.Attempt(ctx, key)
+	switch {
+	case err != nil:
+		logger.WithError(err).Error("register login attempt")
+		return true
+	case wait <= 0:
+		return true
+	}
+
+	cause := ErrLoginDelayed
+	if locked {
+		cause = ErrLoginLocked
+	}
+	logger.WithString("rate_limit_key", key).WithError(cause).Info("login attempt rejected")
+	w.Header().Set("retry-after", seconds(wait))
+	ErrorResponse{Cause: cause, StatusCode: http.StatusTooManyRequests}.Write(logger, w)
+	return false
+}
+
+// releaseLogin forgets the login attempt that failed for another reason than invalid credentials.
+func (sh *SessionHandler) releaseLogin(ctx context.Context, logger logging.Logger, key string) {
+	if sh.loginLimiter == nil {
+		return
+	}
+
+	if err := sh.loginLimiter.Release(ctx, key); err != nil {
+		logger.WithError(err).Error("release login attempt")
+	}
+}
+
+func (sh *SessionHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "webhttp.SessionHandler/Refresh", tracing.KindInternal)
+	defer span.End()
+	logger := sh.logger(ctx, "Refresh")
+
+	logger.Debug("start")
+	defer logger.Debug("end")
+
+	var req RefreshTokenReq
+	if err := Decode(r, &req); err != nil {
+		logger.WithError(err).Error("decode payload")
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	tokens, err := sh.sessionService.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		sh.writeError(w, logger, err)
+		return
+	}
+
+	sh.writeTokens(w, logger, http.StatusOK, tokens)
+}
+
+func (sh *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "webhttp.SessionHandler/Revoke", tracing.KindInternal)
+	defer span.End()
+	logger := sh.logger(ctx, "Revoke")
+
+	logger.Debug("start")
+	defer logger.Debug("end")
+
+	var req RefreshTokenReq
+	if err := Decode(r, &req); err != nil {
+		logger.WithError(err).Error("decode payload")
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	if err := sh.sessionService.Logout(ctx, req.RefreshToken); err != nil {
+		sh.writeError(w, logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (sh *SessionHandler) writeTokens(w http.ResponseWriter, logger logging.Logger, statusCode int, tokens session.Tokens) {
+	// tokens are credentials, they must not be stored by any cache
+	w.Header().Set("cache-control", "no-store")
+	w.WriteHeader(statusCode)
+
+	resp := SessionResp{
+		AccessToken:      tokens.AccessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(time.Until(tokens.AccessExpiresAt).Round(time.Second).Seconds()),
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
+	}
+	if err := Encode(w, resp); err != nil {
+		logger.WithError(err).Error("encode tokens")
+	}
+}
+
+// writeError responds with `401` status code if the credentials or the token are not valid.
+func (sh *SessionHandler) writeError(w http.ResponseWriter, logger logging.Logger, err error) {
+	for _, cause := range []error{session.ErrInvalidCredentials, session.ErrInvalidToken, session.ErrTokenReused} {
+		if errors.Is(err, cause) {
+			logger.WithError(err).Info("session rejected")
+			ErrorResponse{Cause: cause, StatusCode: http.StatusUnauthorized}.Write(logger, w)
+			return
+		}
+	}
+
+	logger.WithError(err).Error("session")
+	WriteError(w, logger, err)
+}
+
+func (sh *SessionHandler) logger(ctx context.Context, method string) logging.Logger {
+	return logging.FromContext(ctx).WithString("component", "SessionHandler").WithString("method", method)
+}
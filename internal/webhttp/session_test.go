@@ -0,0 +1,210 @@
+package webhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/ratelimit"
+	"github.com/pavelmemory/faceit-users/internal/session"
+)
+
+// stubSessions accepts only '<any>@mail.com:secret' credentials and 'valid' refresh token.
+type stubSessions struct {
+	tokens session.Tokens
+}
+
+func (ss stubSessions) Login(_ context.Context, email, password string) (session.Tokens, error) {
+	if password == "broken" {
+		return session.Tokens{}, errors.New("connection refused")
+	}
+	if !strings.HasSuffix(email, "@mail.com") || password != "secret" {
+		return session.Tokens{}, fmt.Errorf("login: %w", session.ErrInvalidCredentials)
+	}
+	return ss.tokens, nil
+}
+
+func (ss stubSessions) Refresh(_ context.Context, refreshToken string) (session.Tokens, error) {
+	switch refreshToken {
+	case "valid":
+		return ss.tokens, nil
+	case "used":
+		return session.Tokens{}, fmt.Errorf("refresh: %w", session.ErrTokenReused)
+	case "broken":
+		return session.Tokens{}, errors.New("connection refused")
+	default:
+		return session.Tokens{}, fmt.Errorf("refresh: %w", session.ErrInvalidToken)
+	}
+}
+
+func (ss stubSessions) Logout(_ context.Context, refreshToken string) error {
+	if refreshToken != "valid" {
+		return fmt.Errorf("logout: %w", session.ErrInvalidToken)
+	}
+	return nil
+}
+
+func TestSessionHandler(t *testing.T) {
+	tokens := session.Tokens{
+		AccessToken:      "access",
+		AccessExpiresAt:  time.Now().Add(15 * time.Minute),
+		RefreshToken:     "refresh",
+		RefreshExpiresAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	r := NewRouter(logging.NewTestLogger())
+	NewSessionHandler(stubSessions{tokens: tokens}).Register(r)
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("content-type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("login", func(t *testing.T) {
+		resp := do("/sessions", `{"email":"john@mail.com","password":"secret"}`)
+		require.Equal(t, http.StatusCreated, resp.Code)
+		require.Equal(t, "no-store", resp.Header().Get("cache-control"))
+
+		var sessionResp SessionResp
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &sessionResp))
+		require.Equal(t, "access", sessionResp.AccessToken)
+		require.Equal(t, "Bearer", sessionResp.TokenType)
+		require.InDelta(t, 900, sessionResp.ExpiresIn, 1)
+		require.Equal(t, "refresh", sessionResp.RefreshToken)
+		require.Equal(t, tokens.RefreshExpiresAt, sessionResp.RefreshExpiresAt)
+
+		resp = do("/sessions", `{"email":"john@mail.com","password":"wrong"}`)
+		require.Equal(t, http.StatusUnauthorized, resp.Code)
+		require.Contains(t, resp.Body.String(), session.ErrInvalidCredentials.Error())
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		resp := do("/sessions:refresh", `{"refresh_token":"valid"}`)
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		resp = do("/sessions:refresh", `{"refresh_token":"used"}`)
+		require.Equal(t, http.StatusUnauthorized, resp.Code)
+		require.Contains(t, resp.Body.String(), session.ErrTokenReused.Error())
+
+		resp = do("/sessions:refresh", `{"refresh_token":"broken"}`)
+		require.Equal(t, http.StatusInternalServerError, resp.Code)
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		resp := do("/sessions:revoke", `{"refresh_token":"valid"}`)
+		require.Equal(t, http.StatusNoContent, resp.Code)
+
+		resp = do("/sessions:revoke", `{"refresh_token":"unknown"}`)
+		require.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+}
+
+func TestSessionHandler_LoginLimiter(t *testing.T) {
+	limiter := ratelimit.NewFailureLimiter(ratelimit.NewMemoryStore(), ratelimit.FailurePolicy{
+		Threshold:   1,
+		Delay:       time.Minute,
+		MaxDelay:    time.Minute,
+		MaxFailures: 3,
+		Lockout:     time.Hour,
+	})
+
+	r := NewRouter(logging.NewTestLogger())
+	NewSessionHandler(stubSessions{}, WithLoginLimiter(limiter)).Register(r)
+
+	login := func(email, password string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"email":%q,"password":%q}`, email, password)
+		req := httptest.NewRequest(http.MethodPost, "/sessions", strings.NewReader(body))
+		req.Header.Set("content-type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	require.Equal(t, http.StatusUnauthorized, login("john@mail.com", "wrong").Code)
+	require.Equal(t, http.StatusCreated, login("john@mail.com", "secret").Code, "failures under the threshold are not delayed")
+
+	require.Equal(t, http.StatusUnauthorized, login("john@mail.com", "wrong").Code)
+	require.Equal(t, http.StatusCreated, login("john@mail.com", "secret").Code, "success resets the failures")
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusInternalServerError, login("john@mail.com", "broken").Code, "attempts are released on errors")
+	}
+
+	require.Equal(t, http.StatusUnauthorized, login("john@mail.com", "wrong").Code)
+	require.Equal(t, http.StatusUnauthorized, login("JOHN@mail.com", "wrong").Code)
+
+	resp := login("john@mail.com", "secret")
+	require.Equal(t, http.StatusTooManyRequests, resp.Code, "next attempt is delayed")
+	require.Equal(t, "60", resp.Header().Get("retry-after"))
+	require.Contains(t, resp.Body.String(), ErrLoginDelayed.Error())
+
+	require.Equal(t, http.StatusCreated, login("jane@mail.com", "secret").Code, "other accounts are not affected")
+}
+
+// blockingSessions rejects all credentials once released, so the login attempts are in progress at the same time.
+type blockingSessions struct {
+	stubSessions
+	calls   *int32
+	release chan struct{}
+}
+
+func (bs blockingSessions) Login(context.Context, string, string) (session.Tokens, error) {
+	atomic.AddInt32(bs.calls, 1)
+	<-bs.release
+	return session.Tokens{}, fmt.Errorf("login: %w", session.ErrInvalidCredentials)
+}
+
+func TestSessionHandler_LoginLimiter_Concurrent(t *testing.T) {
+	limiter := ratelimit.NewFailureLimiter(ratelimit.NewMemoryStore(), ratelimit.FailurePolicy{
+		Threshold:   1,
+		Delay:       time.Minute,
+		MaxDelay:    time.Minute,
+		MaxFailures: 3,
+		Lockout:     time.Hour,
+	})
+
+	sessions := blockingSessions{calls: new(int32), release: make(chan struct{})}
+	r := NewRouter(logging.NewTestLogger())
+	NewSessionHandler(sessions, WithLoginLimiter(limiter)).Register(r)
+
+	const attempts = 10
+	codes := make(chan int, attempts)
+	for i := 0; i < attempts; i++ {
+		go func() {
+			req := httptest.NewRequest(http.MethodPost, "/sessions", strings.NewReader(`{"email":"john@mail.com","password":"guess"}`))
+			req.Header.Set("content-type", "application/json")
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			codes <- resp.Code
+		}()
+	}
+
+	// only the attempts under the threshold pass while the earlier ones are still in progress
+	for i := 0; i < attempts-2; i++ {
+		select {
+		case code := <-codes:
+			require.Equal(t, http.StatusTooManyRequests, code)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "concurrent attempts are not limited")
+		}
+	}
+
+	close(sessions.release)
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusUnauthorized, <-codes)
+	}
+	require.EqualValues(t, 2, atomic.LoadInt32(sessions.calls))
+}
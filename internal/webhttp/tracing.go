@@ -0,0 +1,61 @@
+package webhttp
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/requestid"
+	"github.com/pavelmemory/faceit-users/internal/tracing"
+)
+
+// Trace returns a middleware function that starts a server span for each request.
+// The span continues a trace propagated with W3C 'traceparent' header if it is present,
+// otherwise a new trace is started. Identifiers of the trace and the span are added to the logger,
+// so the logs could be correlated with the trace.
+func Trace() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), "HTTP "+r.Method, tracing.KindServer,
+				tracing.String("http.method", r.Method),
+				tracing.String("http.target", r.URL.Path),
+				tracing.String("http.user_agent", r.UserAgent()),
+				tracing.String("http.request_id", requestid.FromContext(r.Context())),
+			)
+			defer span.End()
+
+			sc := span.SpanContext()
+			logger := logging.FromContext(ctx).
+				WithString("trace_id", sc.TraceID().String()).
+				WithString("span_id", sc.SpanID().String())
+			ctx = logging.ToContext(ctx, logger)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			// the route is known only after the request is routed
+			if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+				span.SetName(r.Method + " " + rctx.RoutePattern())
+				span.SetAttributes(tracing.String("http.route", rctx.RoutePattern()))
+			}
+			span.SetAttributes(tracing.Int("http.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.SetError(errorStatus(status))
+			}
+		})
+	}
+}
+
+// errorStatus describes a failed request by its status code.
+type errorStatus int
+
+func (es errorStatus) Error() string {
+	return http.StatusText(int(es))
+}
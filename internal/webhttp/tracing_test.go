@@ -0,0 +1,39 @@
+package webhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/tracing"
+)
+
+func TestTrace(t *testing.T) {
+	var got trace.SpanContext
+	r := NewRouter(logging.NewTestLogger())
+	r.Get("/traced/{id}", func(w http.ResponseWriter, r *http.Request) {
+		got = tracing.SpanFromContext(r.Context()).SpanContext()
+	})
+
+	t.Run("continues propagated trace", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/traced/1", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", got.TraceID().String())
+		require.NotEqual(t, "00f067aa0ba902b7", got.SpanID().String())
+	})
+
+	t.Run("starts a new trace", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/traced/1", nil)
+		req.Header.Set("traceparent", "malformed")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		require.True(t, got.IsValid())
+		require.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", got.TraceID().String())
+	})
+}
@@ -0,0 +1,290 @@
+package webhttp
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/policy"
+	"github.com/pavelmemory/faceit-users/internal/tracing"
+	"github.com/pavelmemory/faceit-users/internal/user"
+)
+
+const (
+	mediaTypeNDJSON = "application/x-ndjson"
+	mediaTypeCSV    = "text/csv"
+)
+
+// exportFlushEvery is a number of exported users after which the data is flushed to the client.
+const exportFlushEvery = 100
+
+// maxNDJSONLineSize limits the size of the single line of the imported NDJSON payload.
+const maxNDJSONLineSize = 1 << 20
+
+var csvExportColumns = []string{"id", "first_name", "last_name", "nickname", "email", "country", "created_at", "updated_at"}
+
+var csvImportColumns = []string{"first_name", "last_name", "nickname", "email", "country", "password"}
+
+// Export streams all users as NDJSON or CSV depending on the 'accept' header (NDJSON by default).
+func (uh *UserHandler) Export(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "webhttp.UserHandler/Export", tracing.KindInternal)
+	defer span.End()
+	logger := uh.logger(ctx, "Export")
+
+	logger.Debug("start")
+	defer logger.Debug("end")
+
+	if resp, ok := uh.authorize(ctx, logger, policy.Request{Action: policy.ActionUsersExport}); !ok {
+		resp.Write(logger, w)
+		return
+	}
+
+	format := negotiate(r.Header.Get("accept"), mediaTypeNDJSON, mediaTypeCSV)
+	if format == "" {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	tw := &trackingWriter{Writer: w}
+
+	var write func(ExportUserResp) error
+	var flush func() error
+	switch format {
+	case mediaTypeNDJSON:
+		w.Header().Set("content-type", mediaTypeNDJSON+"; charset=utf-8")
+		bw := bufio.NewWriter(tw)
+		encoder := json.NewEncoder(bw)
+		write = func(u ExportUserResp) error { return encoder.Encode(u) }
+		flush = bw.Flush
+	case mediaTypeCSV:
+		w.Header().Set("content-type", mediaTypeCSV+"; charset=utf-8; header=present")
+		cw := csv.NewWriter(tw)
+		// the header is buffered until the first flush
+		_ = cw.Write(csvExportColumns)
+		write = func(u ExportUserResp) error {
+			return cw.Write([]string{
+				u.ID, u.FirstName, u.LastName, u.Nickname, u.Email, u.Country,
+				u.CreatedAt.Format(time.RFC3339Nano), u.UpdatedAt.Format(time.RFC3339Nano),
+			})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	}
+
+	var exported int
+	err := uh.userService.Export(ctx, func(entity user.Entity) error {
+		if err := write(uh.mapper.entity2ExportUserResp(entity)); err != nil {
+			return fmt.Errorf("write user: %w", err)
+		}
+
+		exported++
+		if exported%exportFlushEvery == 0 {
+			if err := flush(); err != nil {
+				return fmt.Errorf("flush: %w", err)
+			}
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+
+	if err != nil {
+		logger.WithError(err).WithInt("exported", exported).Error("export users")
+		if !tw.written {
+			// nothing was sent to the client yet
+			WriteError(w, logger, err)
+			return
+		}
+		// the response is already started, the only way to signal failure is to break the connection
+		panic(http.ErrAbortHandler)
+	}
+
+	logger.WithInt("exported", exported).Debug("users exported")
+}
+
+// Import saves users sent as NDJSON or CSV (with a header) payload and responds with a report
+// describing the lines that were rejected.
+func (uh *UserHandler) Import(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "webhttp.UserHandler/Import", tracing.KindInternal)
+	defer span.End()
+	logger := uh.logger(ctx, "Import")
+
+	logger.Debug("start")
+	defer logger.Debug("end")
+
+	if resp, ok := uh.authorize(ctx, logger, policy.Request{Action: policy.ActionUsersImport}); !ok {
+		resp.Write(logger, w)
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("content-type"))
+	if err != nil {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var source user.EntitySource
+	switch mediaType {
+	case mediaTypeNDJSON:
+		source = newNDJSONSource(r.Body, uh.mapper)
+	case mediaTypeCSV:
+		source, err = newCSVSource(r.Body, uh.mapper)
+		if err != nil {
+			logger.WithError(err).Error("read csv header")
+			ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	report, err := uh.userService.Import(ctx, source)
+	if err != nil {
+		logger.WithError(err).Error("import users")
+		if errors.Is(err, bufio.ErrTooLong) {
+			// the rest of the payload can't be read, so nothing is imported
+			ErrorResponse{Cause: err, StatusCode: http.StatusRequestEntityTooLarge}.Write(logger, w)
+			return
+		}
+		WriteError(w, logger, err)
+		return
+	}
+
+	logger.WithInt64("imported", report.Imported).WithInt64("rejected", report.Rejected).Info("users imported")
+
+	if err := Encode(w, uh.mapper.importReport2ImportResp(report)); err != nil {
+		logger.WithError(err).Error("encode response")
+		ErrorResponse{Cause: err, StatusCode: http.StatusInternalServerError}.Write(logger, w)
+		return
+	}
+}
+
+// trackingWriter records if anything was written through it.
+type trackingWriter struct {
+	io.Writer
+	written bool
+}
+
+func (tw *trackingWriter) Write(p []byte) (int, error) {
+	tw.written = true
+	return tw.Writer.Write(p)
+}
+
+func newNDJSONSource(r io.Reader, mapper Mapper) *ndjsonSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineSize)
+	return &ndjsonSource{scanner: scanner, mapper: mapper}
+}
+
+// ndjsonSource reads users from the newline delimited JSON payload.
+type ndjsonSource struct {
+	scanner *bufio.Scanner
+	mapper  Mapper
+	line    int
+}
+
+func (s *ndjsonSource) Next() (int, user.Entity, error) {
+	for s.scanner.Scan() {
+		s.line++
+		data := s.scanner.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+
+		var req CreateUserReq
+		if err := json.Unmarshal(data, &req); err != nil {
+			return s.line, user.Entity{}, fmt.Errorf("decode: %v: %w", err, internal.ErrBadInput)
+		}
+		return s.line, s.mapper.createUserReq2Entity(req), nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return s.line + 1, user.Entity{}, fmt.Errorf("line is longer than %d bytes: %w", maxNDJSONLineSize, err)
+		}
+		return s.line + 1, user.Entity{}, err
+	}
+	return s.line, user.Entity{}, io.EOF
+}
+
+func newCSVSource(r io.Reader, mapper Mapper) (*csvSource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	for _, name := range csvImportColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column: %q", name)
+		}
+	}
+
+	return &csvSource{reader: reader, columns: columns, mapper: mapper, line: 1}, nil
+}
+
+// csvSource reads users from the CSV payload with a header.
+type csvSource struct {
+	reader  *csv.Reader
+	columns map[string]int
+	mapper  Mapper
+	line    int
+}
+
+func (s *csvSource) Next() (int, user.Entity, error) {
+	record, err := s.reader.Read()
+	s.line++
+
+	var parseErr *csv.ParseError
+	switch {
+	case err == io.EOF:
+		return s.line, user.Entity{}, io.EOF
+	case errors.As(err, &parseErr):
+		s.line = parseErr.Line
+		return s.line, user.Entity{}, fmt.Errorf("parse: %v: %w", err, internal.ErrBadInput)
+	case err != nil:
+		return s.line, user.Entity{}, err
+	}
+
+	field := func(name string) string {
+		if i := s.columns[name]; i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	req := CreateUserReq{
+		UserBase: UserBase{
+			FirstName: field("first_name"),
+			LastName:  field("last_name"),
+			Nickname:  field("nickname"),
+			Email:     field("email"),
+			Country:   field("country"),
+		},
+		Password: field("password"),
+	}
+
+	return s.line, s.mapper.createUserReq2Entity(req), nil
+}
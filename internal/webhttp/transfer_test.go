@@ -0,0 +1,267 @@
+package webhttp
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavelmemory/faceit-users/internal"
+	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/user"
+)
+
+func TestUserHandler_Export(t *testing.T) {
+	createdAt := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	users := []user.Entity{
+		{ID: "1", FirstName: "John", LastName: "Doe", Nickname: "johndoe", Email: "johndoe@mail.com", Country: "XX", CreatedAt: createdAt, UpdatedAt: createdAt},
+		{ID: "2", FirstName: `Jane "J"`, LastName: "Doe, Jr.", Nickname: "janedoe", Email: "janedoe@mail.com", CreatedAt: createdAt, UpdatedAt: createdAt},
+	}
+
+	setup := func(t *testing.T) (http.Handler, *MockUserService, func()) {
+		ctrl := gomock.NewController(t)
+		r := NewRouter(logging.NewTestLogger())
+		mockUserService := NewMockUserService(ctrl)
+		NewUsersHandler(mockUserService).Register(r)
+		return r, mockUserService, ctrl.Finish
+	}
+
+	export := func(_ context.Context, fn func(user.Entity) error) error {
+		for _, u := range users {
+			if err := fn(u); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	do := func(h http.Handler, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users/export", nil)
+		if accept != "" {
+			req.Header.Set("accept", accept)
+		}
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("ndjson", func(t *testing.T) {
+		h, mockUserService, finish := setup(t)
+		defer finish()
+
+		mockUserService.EXPECT().Export(gomock.Any(), gomock.Any()).DoAndReturn(export)
+		resp := do(h, "")
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.Equal(t, "application/x-ndjson; charset=utf-8", resp.Header().Get("content-type"))
+
+		lines := strings.Split(strings.TrimSuffix(resp.Body.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		require.JSONEq(t, `{"id":"1","first_name":"John","last_name":"Doe","nickname":"johndoe","email":"johndoe@mail.com",
+			"country":"XX","created_at":"2020-03-04T05:06:07Z","updated_at":"2020-03-04T05:06:07Z"}`, lines[0])
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		h, mockUserService, finish := setup(t)
+		defer finish()
+
+		mockUserService.EXPECT().Export(gomock.Any(), gomock.Any()).DoAndReturn(export)
+		resp := do(h, "application/x-ndjson;q=0.5, text/*;q=0.8")
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.Equal(t, "text/csv; charset=utf-8; header=present", resp.Header().Get("content-type"))
+
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, [][]string{
+			csvExportColumns,
+			{"1", "John", "Doe", "johndoe", "johndoe@mail.com", "XX", "2020-03-04T05:06:07Z", "2020-03-04T05:06:07Z"},
+			{"2", `Jane "J"`, "Doe, Jr.", "janedoe", "janedoe@mail.com", "", "2020-03-04T05:06:07Z", "2020-03-04T05:06:07Z"},
+		}, records)
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		h, _, finish := setup(t)
+		defer finish()
+
+		require.Equal(t, http.StatusNotAcceptable, do(h, "application/xml").Code)
+	})
+
+	t.Run("failure before data is sent", func(t *testing.T) {
+		h, mockUserService, finish := setup(t)
+		defer finish()
+
+		mockUserService.EXPECT().Export(gomock.Any(), gomock.Any()).Return(assert.AnError)
+		require.Equal(t, http.StatusInternalServerError, do(h, "").Code)
+	})
+}
+
+func TestUserHandler_Import(t *testing.T) {
+	setup := func(t *testing.T) (http.Handler, *MockUserService, func()) {
+		ctrl := gomock.NewController(t)
+		r := NewRouter(logging.NewTestLogger())
+		mockUserService := NewMockUserService(ctrl)
+		NewUsersHandler(mockUserService).Register(r)
+		return r, mockUserService, ctrl.Finish
+	}
+
+	do := func(h http.Handler, contentType string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users/import", body)
+		req.Header.Set("content-type", contentType)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	johnDoe := user.Entity{FirstName: "John", LastName: "Doe", Nickname: "johndoe", Email: "johndoe@mail.com", Country: "XX", Password: "secret"}
+	janeDoe := user.Entity{FirstName: "Jane", LastName: "Doe, Jr.", Nickname: "janedoe", Email: "janedoe@mail.com", Password: "secret"}
+
+	t.Run("ndjson", func(t *testing.T) {
+		h, mockUserService, finish := setup(t)
+		defer finish()
+
+		var imported []user.Entity
+		mockUserService.EXPECT().Import(gomock.Any(), gomock.Any()).DoAndReturn(drainSource(&imported))
+
+		resp := do(h, "application/x-ndjson", strings.NewReader(
+			`{"first_name":"John","last_name":"Doe","nickname":"johndoe","email":"johndoe@mail.com","country":"XX","password":"secret"}`+"\n"+
+				"\n"+
+				`{"first_name":`+"\n"+
+				`{"first_name":"Jane","last_name":"Doe, Jr.","nickname":"janedoe","email":"janedoe@mail.com","password":"secret"}`+"\n",
+		))
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.Equal(t, []user.Entity{johnDoe, janeDoe}, imported)
+
+		var report ImportResp
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &report))
+		require.Equal(t, int64(2), report.Imported)
+		require.Equal(t, int64(1), report.Rejected)
+		require.Len(t, report.Rejections, 1)
+		require.Equal(t, 3, report.Rejections[0].Line, "empty lines are counted")
+		require.Equal(t, http.StatusBadRequest, report.Rejections[0].Status)
+		require.Contains(t, report.Rejections[0].Error, "decode")
+	})
+
+	t.Run("ndjson round trip", func(t *testing.T) {
+		h, mockUserService, finish := setup(t)
+		defer finish()
+
+		mockUserService.EXPECT().Export(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, fn func(user.Entity) error) error {
+			return fn(user.Entity{ID: "1", FirstName: "Jane", LastName: "Doe, Jr.", Nickname: "janedoe", Email: "janedoe@mail.com"})
+		})
+		exported := httptest.NewRecorder()
+		h.ServeHTTP(exported, httptest.NewRequest(http.MethodGet, "/users/export", nil))
+		require.Equal(t, http.StatusOK, exported.Code)
+
+		var imported []user.Entity
+		mockUserService.EXPECT().Import(gomock.Any(), gomock.Any()).DoAndReturn(drainSource(&imported))
+		require.Equal(t, http.StatusOK, do(h, "application/x-ndjson", exported.Body).Code)
+
+		expected := janeDoe
+		expected.Password = ""
+		require.Equal(t, []user.Entity{expected}, imported, "exported fields are imported, identifiers are ignored")
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		h, mockUserService, finish := setup(t)
+		defer finish()
+
+		var imported []user.Entity
+		mockUserService.EXPECT().Import(gomock.Any(), gomock.Any()).DoAndReturn(drainSource(&imported))
+
+		resp := do(h, "text/csv; charset=utf-8", strings.NewReader(
+			"id,password,email,nickname,last_name,first_name,country\n"+
+				"1,secret,johndoe@mail.com,johndoe,Doe,John,XX\n"+
+				`2,secret,"bad"quote,x,y,z,XX`+"\n"+
+				`3,secret,janedoe@mail.com,janedoe,"Doe, Jr.",Jane`+"\n",
+		))
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.Equal(t, []user.Entity{johnDoe, janeDoe}, imported, "columns are matched by the header, missing trailing fields are empty")
+
+		var report ImportResp
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &report))
+		require.Equal(t, int64(1), report.Rejected)
+		require.Equal(t, 3, report.Rejections[0].Line)
+		require.Contains(t, report.Rejections[0].Error, "parse")
+	})
+
+	t.Run("csv without required column", func(t *testing.T) {
+		h, _, finish := setup(t)
+		defer finish()
+
+		resp := do(h, "text/csv", strings.NewReader("first_name,last_name,nickname,email,country\nJohn,Doe,johndoe,johndoe@mail.com,XX\n"))
+		require.Equal(t, http.StatusBadRequest, resp.Code)
+		require.Contains(t, resp.Body.String(), "password")
+	})
+
+	t.Run("report", func(t *testing.T) {
+		h, mockUserService, finish := setup(t)
+		defer finish()
+
+		mockUserService.EXPECT().Import(gomock.Any(), gomock.Any()).Return(user.ImportReport{
+			Imported: 1,
+			Rejected: 2,
+			Rejections: []user.Rejection{
+				{Line: 2, Err: user.ValidationError{Cause: internal.ErrBadInput, Details: map[string]interface{}{"email": "invalid format"}}},
+				{Line: 3, Err: internal.ErrNotUnique},
+			},
+		}, nil)
+
+		resp := do(h, "application/x-ndjson", strings.NewReader(""))
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.JSONEq(t, `{"imported":1,"rejected":2,"rejections":[
+			{"line":2,"status":400,"error":"Bad Request","details":{"email":"invalid format"}},
+			{"line":3,"status":409,"error":"Conflict"}
+		]}`, resp.Body.String())
+	})
+
+	t.Run("unsupported media type", func(t *testing.T) {
+		h, _, finish := setup(t)
+		defer finish()
+
+		require.Equal(t, http.StatusUnsupportedMediaType, do(h, "application/json", strings.NewReader("[]")).Code)
+	})
+
+	t.Run("line too long", func(t *testing.T) {
+		h, mockUserService, finish := setup(t)
+		defer finish()
+
+		var imported []user.Entity
+		mockUserService.EXPECT().Import(gomock.Any(), gomock.Any()).DoAndReturn(drainSource(&imported))
+
+		line := `{"nickname":"` + strings.Repeat("n", maxNDJSONLineSize) + `"}`
+		resp := do(h, "application/x-ndjson", strings.NewReader(`{"nickname":"johndoe"}`+"\n"+line+"\n"))
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+	})
+}
+
+// drainSource returns a fake of the import that collects all valid users of the source and rejects malformed ones.
+func drainSource(imported *[]user.Entity) func(context.Context, user.EntitySource) (user.ImportReport, error) {
+	return func(_ context.Context, source user.EntitySource) (user.ImportReport, error) {
+		var report user.ImportReport
+		for {
+			line, entity, err := source.Next()
+			switch {
+			case err == io.EOF:
+				return report, nil
+			case errors.Is(err, internal.ErrBadInput):
+				report.Rejected++
+				report.Rejections = append(report.Rejections, user.Rejection{Line: line, Err: err})
+				continue
+			case err != nil:
+				return user.ImportReport{}, err
+			}
+
+			report.Imported++
+			*imported = append(*imported, entity)
+		}
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/go-chi/chi"
 
 	"github.com/pavelmemory/faceit-users/internal/logging"
+	"github.com/pavelmemory/faceit-users/internal/policy"
+	"github.com/pavelmemory/faceit-users/internal/tracing"
 	"github.com/pavelmemory/faceit-users/internal/user"
 )
 
@@ -23,37 +26,91 @@ type UserService interface {
 	Update(ctx context.Context, id string, user user.Entity) error
 	// Delete removes user entity by its unique identifier.
 	Delete(ctx context.Context, id string) error
+	// ChangePassword replaces the password of the user if the `current` one matches and revokes all user's sessions.
+	ChangePassword(ctx context.Context, id, current, password string) error
+	// Export calls `fn` for each user without loading all of them into memory.
+	Export(ctx context.Context, fn func(user.Entity) error) error
+	// Import validates and saves all users produced by `source`, invalid and not unique users are rejected.
+	// The `created` event is sent for each imported user.
+	Import(ctx context.Context, source user.EntitySource) (user.ImportReport, error)
+	// Batch applies operations in the provided order and returns an outcome for each of them.
+	// In `atomic` mode either all operations are applied or none of them.
+	Batch(ctx context.Context, ops []user.Operation, atomic bool) []user.OperationResult
 }
 
 // NewUsersHandler returns HTTP handler initialized with provided service abstraction.
-func NewUsersHandler(userService UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUsersHandler(userService UserService, options ...UserHandlerOption) *UserHandler {
+	uh := &UserHandler{userService: userService, idempotent: passThrough, batchLimit: defaultBatchLimit}
+	for _, option := range options {
+		option(uh)
+	}
+	return uh
+}
+
+// UserHandlerOption allows to customize behaviour of the UserHandler.
+type UserHandlerOption func(uh *UserHandler)
+
+// WithIdempotency makes creation of the user idempotent for requests with 'Idempotency-Key' header.
+// Outcomes of the requests are stored in `store` for `ttl`, the keys of requests in progress are reserved for `lease`.
+func WithIdempotency(store IdempotencyStore, ttl, lease time.Duration) UserHandlerOption {
+	return func(uh *UserHandler) {
+		uh.idempotent = Idempotent(store, ttl, lease)
+	}
+}
+
+// WithCacheControl sets 'Cache-Control' directives of the user resource responses.
+func WithCacheControl(directives string) UserHandlerOption {
+	return func(uh *UserHandler) {
+		uh.cacheControl = directives
+	}
+}
+
+// WithBatchLimit sets max number of operations allowed in a single batch request.
+func WithBatchLimit(limit int) UserHandlerOption {
+	return func(uh *UserHandler) {
+		uh.batchLimit = limit
+	}
 }
 
 // UserHandler handles request for the user entity(-ies).
 type UserHandler struct {
 	userService UserService
 	mapper      Mapper
+	idempotent  func(http.Handler) http.Handler
+	batchLimit  int
+	authorizer  Authorizer
+	// cacheControl holds 'Cache-Control' directives of the user resource
+	cacheControl string
 }
 
 // Register creates a binding between method handlers and endpoints.
 func (uh *UserHandler) Register(router chi.Router) {
 	router = router.With(LogRequest())
-	router.With(ProducesJSON, AcceptsJSON).Method(http.MethodPost, uh.urlPrefix(), http.HandlerFunc(uh.Create))
-	router.With(ProducesJSON).Method(http.MethodGet, uh.urlPrefix()+"/{id}", http.HandlerFunc(uh.Get))
-	router.With(AcceptsJSON).Method(http.MethodPut, uh.urlPrefix()+"/{id}", http.HandlerFunc(uh.Update))
-	router.Method(http.MethodDelete, uh.urlPrefix()+"/{id}", http.HandlerFunc(uh.Delete))
+	router.With(Produces, Accepts, uh.idempotent).Method(http.MethodPost, uh.urlPrefix(), http.HandlerFunc(uh.Create))
+	router.With(Produces, CacheControl(uh.cacheControl)).Method(http.MethodGet, uh.urlPrefix()+"/{id}", http.HandlerFunc(uh.Get))
+	router.With(Produces, Accepts).Method(http.MethodPut, uh.urlPrefix()+"/{id}", http.HandlerFunc(uh.Update))
+	router.With(Produces).Method(http.MethodDelete, uh.urlPrefix()+"/{id}", http.HandlerFunc(uh.Delete))
+	router.With(Produces, Accepts).Method(http.MethodPut, uh.urlPrefix()+"/{id}/password", http.HandlerFunc(uh.ChangePassword))
+	router.With(Produces, Accepts).Method(http.MethodPost, uh.urlPrefix()+":batch", http.HandlerFunc(uh.Batch))
+	router.Method(http.MethodGet, uh.urlPrefix()+"/export", http.HandlerFunc(uh.Export))
+	router.With(Produces).Method(http.MethodPost, uh.urlPrefix()+"/import", http.HandlerFunc(uh.Import))
 }
 
 func (uh *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx, span := tracing.Start(r.Context(), "webhttp.UserHandler/Create", tracing.KindInternal)
+	defer span.End()
 	logger := uh.logger(ctx, "Create")
 
 	logger.Debug("start")
 	defer logger.Debug("end")
 
+	if resp, ok := uh.authorize(ctx, logger, policy.Request{Action: policy.ActionUsersCreate}); !ok {
+		resp.Write(logger, w)
+		return
+	}
+
 	var req CreateUserReq
-	if err := Decode(r.Body, &req); err != nil {
+	if err := Decode(r, &req); err != nil {
 		logger.WithError(err).Error("decode payload")
 		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
 		return
@@ -71,13 +128,19 @@ func (uh *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 func (uh *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx, span := tracing.Start(r.Context(), "webhttp.UserHandler/Get", tracing.KindInternal)
+	defer span.End()
 	logger := uh.logger(ctx, "Get")
 
 	logger.Debug("start")
 	defer logger.Debug("end")
 
 	id := uh.pathParam(r, "id")
+	if resp, ok := uh.authorize(ctx, logger, policy.Request{Action: policy.ActionUsersGet, Owner: id}); !ok {
+		resp.Write(logger, w)
+		return
+	}
+
 	u, err := uh.userService.Get(ctx, id)
 	if err != nil {
 		logger.WithError(err).WithString("id", id).Error("get user by id")
@@ -85,7 +148,7 @@ func (uh *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := Encode(w, uh.mapper.entity2GetUserResp(u)); err != nil {
+	if err := EncodeConditional(w, r, uh.mapper.entity2GetUserResp(u), u.UpdatedAt); err != nil {
 		logger.WithError(err).Error("encode entity")
 		ErrorResponse{Cause: err, StatusCode: http.StatusInternalServerError}.Write(logger, w)
 		return
@@ -93,7 +156,8 @@ func (uh *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
 }
 
 func (uh *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx, span := tracing.Start(r.Context(), "webhttp.UserHandler/Update", tracing.KindInternal)
+	defer span.End()
 	logger := uh.logger(ctx, "Update")
 
 	logger.Debug("start")
@@ -102,13 +166,18 @@ func (uh *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := uh.pathParam(r, "id")
 
 	var req UpdateUserReq
-	if err := Decode(r.Body, &req); err != nil {
+	if err := Decode(r, &req); err != nil {
 		logger.WithError(err).Error("decode payload")
 		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
 		return
 	}
 
-	if err := uh.userService.Update(ctx, id, uh.mapper.updateUserReq2Entity(req)); err != nil {
+	entity := uh.mapper.updateUserReq2Entity(req)
+	if !uh.authorizeUpdate(ctx, logger, w, id, entity) {
+		return
+	}
+
+	if err := uh.userService.Update(ctx, id, entity); err != nil {
 		logger.WithError(err).WithString("id", id).Error("update user")
 		WriteError(w, logger, err)
 		return
@@ -118,13 +187,18 @@ func (uh *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 }
 
 func (uh *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx, span := tracing.Start(r.Context(), "webhttp.UserHandler/Delete", tracing.KindInternal)
+	defer span.End()
 	logger := uh.logger(ctx, "Delete")
 
 	logger.Debug("start")
 	defer logger.Debug("end")
 
 	id := uh.pathParam(r, "id")
+	if resp, ok := uh.authorize(ctx, logger, policy.Request{Action: policy.ActionUsersDelete, Owner: id}); !ok {
+		resp.Write(logger, w)
+		return
+	}
 
 	if err := uh.userService.Delete(ctx, id); err != nil {
 		logger.WithError(err).WithString("id", id).Error("delete user")
@@ -135,6 +209,70 @@ func (uh *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (uh *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "webhttp.UserHandler/ChangePassword", tracing.KindInternal)
+	defer span.End()
+	logger := uh.logger(ctx, "ChangePassword")
+
+	logger.Debug("start")
+	defer logger.Debug("end")
+
+	id := uh.pathParam(r, "id")
+	if resp, ok := uh.authorize(ctx, logger, policy.Request{Action: policy.ActionUsersChangePassword, Owner: id}); !ok {
+		resp.Write(logger, w)
+		return
+	}
+
+	var req ChangePasswordReq
+	if err := Decode(r, &req); err != nil {
+		logger.WithError(err).Error("decode payload")
+		ErrorResponse{Cause: err, StatusCode: http.StatusBadRequest}.Write(logger, w)
+		return
+	}
+
+	if err := uh.userService.ChangePassword(ctx, id, req.CurrentPassword, req.NewPassword); err != nil {
+		logger.WithError(err).WithString("id", id).Error("change password")
+		WriteError(w, logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeUpdate checks if the principal is allowed to update the user and to change each of the modified fields.
+// It writes an error response and returns false if the update is not allowed.
+func (uh *UserHandler) authorizeUpdate(ctx context.Context, logger logging.Logger, w http.ResponseWriter, id string, entity user.Entity) bool {
+	if uh.authorizer == nil {
+		return true
+	}
+
+	// the action is checked on its own first, so existence of the user is not revealed to the principal
+	// that is not allowed to update it at all
+	if resp, ok := uh.authorize(ctx, logger, policy.Request{Action: policy.ActionUsersUpdate, Owner: id}); !ok {
+		resp.Write(logger, w)
+		return false
+	}
+
+	current, err := uh.userService.Get(ctx, id)
+	if err != nil {
+		logger.WithError(err).WithString("id", id).Error("get user to authorize update")
+		WriteError(w, logger, err)
+		return false
+	}
+
+	fields := changedFields(current, entity)
+	if len(fields) == 0 {
+		return true
+	}
+
+	req := policy.Request{Action: policy.ActionUsersUpdate, Owner: id, Fields: fields}
+	if resp, ok := uh.authorize(ctx, logger, req); !ok {
+		resp.Write(logger, w)
+		return false
+	}
+	return true
+}
+
 func (uh *UserHandler) urlPrefix() string {
 	return "/users"
 }
@@ -1,6 +1,9 @@
 package webhttp
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/pavelmemory/faceit-users/internal/user"
 )
 
@@ -13,6 +16,11 @@ type UpdateUserReq struct {
 	UserBase
 }
 
+type ChangePasswordReq struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
 type GetUserResp struct {
 	UserBase
 }
@@ -58,3 +66,114 @@ func (Mapper) entity2GetUserResp(entity user.Entity) GetUserResp {
 		},
 	}
 }
+
+type UserEventResp struct {
+	ID         string                `json:"id"`
+	Type       string                `json:"type"`
+	Changes    map[string]ChangeResp `json:"changes,omitempty"`
+	OccurredAt time.Time             `json:"occurred_at"`
+}
+
+type ChangeResp struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+func (Mapper) record2UserEventResp(rec user.Record) UserEventResp {
+	resp := UserEventResp{
+		ID:         rec.UserID,
+		Type:       string(rec.Type),
+		OccurredAt: rec.OccurredAt,
+	}
+
+	if len(rec.Changes) > 0 {
+		resp.Changes = make(map[string]ChangeResp, len(rec.Changes))
+		for property, change := range rec.Changes {
+			resp.Changes[property] = ChangeResp{Old: change.Old, New: change.New}
+		}
+	}
+
+	return resp
+}
+
+type BatchReq struct {
+	Mode       string              `json:"mode,omitempty"`
+	Operations []BatchOperationReq `json:"operations"`
+}
+
+type BatchOperationReq struct {
+	Op   string        `json:"op"`
+	ID   string        `json:"id,omitempty"`
+	User CreateUserReq `json:"user"`
+}
+
+type BatchResp struct {
+	Results []BatchOperationResp `json:"results"`
+}
+
+type BatchOperationResp struct {
+	Status  int                    `json:"status"`
+	ID      string                 `json:"id,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (m Mapper) batchReq2Operations(req BatchReq) []user.Operation {
+	ops := make([]user.Operation, len(req.Operations))
+	for i, op := range req.Operations {
+		ops[i] = user.Operation{
+			Type: user.OperationType(op.Op),
+			ID:   op.ID,
+			User: m.createUserReq2Entity(op.User),
+		}
+	}
+	return ops
+}
+
+type ExportUserResp struct {
+	ID string `json:"id"`
+	UserBase
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ImportResp struct {
+	Imported   int64           `json:"imported"`
+	Rejected   int64           `json:"rejected"`
+	Rejections []RejectionResp `json:"rejections,omitempty"`
+}
+
+type RejectionResp struct {
+	Line    int                    `json:"line"`
+	Status  int                    `json:"status"`
+	Error   string                 `json:"error"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (m Mapper) entity2ExportUserResp(entity user.Entity) ExportUserResp {
+	return ExportUserResp{
+		ID:        entity.ID,
+		UserBase:  m.entity2GetUserResp(entity).UserBase,
+		CreatedAt: entity.CreatedAt,
+		UpdatedAt: entity.UpdatedAt,
+	}
+}
+
+func (Mapper) importReport2ImportResp(report user.ImportReport) ImportResp {
+	resp := ImportResp{Imported: report.Imported, Rejected: report.Rejected}
+	for _, rejection := range report.Rejections {
+		status, text, details := describeError(rejection.Err)
+		if status == http.StatusBadRequest && details == nil {
+			// malformed data is described by the error itself
+			text = rejection.Err.Error()
+		}
+
+		resp.Rejections = append(resp.Rejections, RejectionResp{
+			Line:    rejection.Line,
+			Status:  status,
+			Error:   text,
+			Details: details,
+		})
+	}
+	return resp
+}
@@ -5,11 +5,13 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/pavelmemory/faceit-users/internal/user"
 	"github.com/stretchr/testify/require"
 
+	"github.com/pavelmemory/faceit-users/internal"
 	"github.com/pavelmemory/faceit-users/internal/logging"
 )
 
@@ -63,7 +65,232 @@ func TestUserHandler_Get(t *testing.T) {
 		require.JSONEq(t, `{"first_name":"fn"}`, resp.Body.String())
 	})
 
+	t.Run("not found", func(t *testing.T) {
+		logger := logging.NewTestLogger()
+		r := NewRouter(logger)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockUserService := NewMockUserService(ctrl)
+		mockUserService.EXPECT().Get(gomock.Any(), "1-2-3-4").Return(user.Entity{}, internal.ErrNotFound)
+
+		userHandler := NewUsersHandler(mockUserService)
+		userHandler.Register(r)
+
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/users/1-2-3-4", nil)
+		req.Header.Set("x-request-id", "req-1")
+		resp := httptest.NewRecorder()
+
+		r.ServeHTTP(resp, req)
+
+		require.Equal(t, http.StatusNotFound, resp.Code)
+		require.Equal(t, "req-1", resp.Header().Get("x-request-id"))
+		require.JSONEq(t, `{"error":"not found","request_id":"req-1"}`, resp.Body.String())
+	})
+
+	t.Run("negotiated format", func(t *testing.T) {
+		logger := logging.NewTestLogger()
+		r := NewRouter(logger)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockUserService := NewMockUserService(ctrl)
+		mockUserService.EXPECT().Get(gomock.Any(), "1-2-3-4").Return(user.Entity{FirstName: "fn"}, nil).AnyTimes()
+
+		userHandler := NewUsersHandler(mockUserService)
+		userHandler.Register(r)
+
+		for accept, exp := range map[string]struct {
+			status      int
+			contentType string
+		}{
+			"application/xml":                      {status: http.StatusOK, contentType: "application/xml; charset=utf-8"},
+			"application/msgpack, */*;q=0.1":       {status: http.StatusOK, contentType: "application/msgpack"},
+			"text/html, application/json;q=0.5":    {status: http.StatusOK, contentType: "application/json; charset=utf-8"},
+			"text/html":                            {status: http.StatusNotAcceptable},
+			"application/x-protobuf, text/*;q=0.1": {status: http.StatusOK, contentType: "application/x-protobuf"},
+		} {
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/users/1-2-3-4", nil)
+			req.Header.Set("accept", accept)
+			resp := httptest.NewRecorder()
+
+			r.ServeHTTP(resp, req)
+
+			require.Equal(t, exp.status, resp.Code, accept)
+			if exp.status == http.StatusOK {
+				require.Equal(t, exp.contentType, resp.Header().Get("content-type"), accept)
+			}
+		}
+	})
+
+	t.Run("conditional", func(t *testing.T) {
+		logger := logging.NewTestLogger()
+		r := NewRouter(logger)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		updatedAt := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+		mockUserService := NewMockUserService(ctrl)
+		mockUserService.EXPECT().Get(gomock.Any(), "1-2-3-4").Return(user.Entity{FirstName: "fn", UpdatedAt: updatedAt}, nil).AnyTimes()
+
+		userHandler := NewUsersHandler(mockUserService, WithCacheControl("private, no-cache"))
+		userHandler.Register(r)
+
+		get := func(headers map[string]string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/users/1-2-3-4", nil)
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := get(nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.JSONEq(t, `{"first_name":"fn"}`, resp.Body.String())
+		require.Equal(t, "Thu, 02 Jan 2020 03:04:05 GMT", resp.Header().Get("last-modified"))
+		require.Equal(t, "private, no-cache", resp.Header().Get("cache-control"))
+		etag := resp.Header().Get("etag")
+		require.Regexp(t, `^"[^"]+"$`, etag)
+
+		for name, exp := range map[string]struct {
+			headers map[string]string
+			status  int
+		}{
+			"matching etag":                {headers: map[string]string{"if-none-match": `"other", ` + etag}, status: http.StatusNotModified},
+			"weak matching etag":           {headers: map[string]string{"if-none-match": "W/" + etag}, status: http.StatusNotModified},
+			"any etag":                     {headers: map[string]string{"if-none-match": "*"}, status: http.StatusNotModified},
+			"other etag":                   {headers: map[string]string{"if-none-match": `"other"`}, status: http.StatusOK},
+			"not modified since":           {headers: map[string]string{"if-modified-since": "Thu, 02 Jan 2020 03:04:05 GMT"}, status: http.StatusNotModified},
+			"modified since":               {headers: map[string]string{"if-modified-since": "Thu, 02 Jan 2020 03:04:04 GMT"}, status: http.StatusOK},
+			"etag takes precedence":        {headers: map[string]string{"if-none-match": `"other"`, "if-modified-since": "Thu, 02 Jan 2020 03:04:05 GMT"}, status: http.StatusOK},
+			"invalid date is ignored":      {headers: map[string]string{"if-modified-since": "yesterday"}, status: http.StatusOK},
+			"other representation differs": {headers: map[string]string{"if-none-match": etag, "accept": "application/xml"}, status: http.StatusOK},
+		} {
+			resp := get(exp.headers)
+			require.Equal(t, exp.status, resp.Code, name)
+			require.Equal(t, "private, no-cache", resp.Header().Get("cache-control"), name)
+			if exp.status == http.StatusNotModified {
+				require.Empty(t, resp.Body.String(), name)
+				require.Equal(t, etag, resp.Header().Get("etag"), name)
+			}
+		}
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		logger := logging.NewTestLogger()
+		r := NewRouter(logger)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockUserService := NewMockUserService(ctrl)
+		mockUserService.EXPECT().Get(gomock.Any(), "1-2-3-4").Return(user.Entity{}, internal.ErrNotFound)
+
+		userHandler := NewUsersHandler(mockUserService, WithCacheControl("public, max-age=60"))
+		userHandler.Register(r)
+
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/users/1-2-3-4", nil)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+
+		require.Equal(t, http.StatusNotFound, resp.Code)
+		require.Empty(t, resp.Header().Get("cache-control"))
+	})
+
 	// TODO: other scenarios of input as well as response from the 'mockUserService'
 }
 
+func TestUserHandler_ChangePassword(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		logger := logging.NewTestLogger()
+		r := NewRouter(logger)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockUserService := NewMockUserService(ctrl)
+		mockUserService.EXPECT().ChangePassword(gomock.Any(), "1-2-3-4", "old", "new").Return(nil)
+
+		userHandler := NewUsersHandler(mockUserService)
+		userHandler.Register(r)
+
+		req := httptest.NewRequest(http.MethodPut, "http://localhost/users/1-2-3-4/password",
+			strings.NewReader(`{"current_password":"old","new_password":"new"}`))
+		req.Header.Set("content-type", "application/json")
+		resp := httptest.NewRecorder()
+
+		r.ServeHTTP(resp, req)
+
+		require.Equal(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func TestUserHandler_Batch(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		logger := logging.NewTestLogger()
+		r := NewRouter(logger)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockUserService := NewMockUserService(ctrl)
+		mockUserService.EXPECT().
+			Batch(gomock.Any(), []user.Operation{
+				{Type: user.OperationCreate, User: user.Entity{Nickname: "nn", Password: "pwd"}},
+				{Type: user.OperationDelete, ID: "1-2-3-5"},
+			}, false).
+			Return([]user.OperationResult{
+				{ID: "1-2-3-4"},
+				{ID: "1-2-3-5", Err: internal.ErrNotFound},
+			})
+
+		userHandler := NewUsersHandler(mockUserService)
+		userHandler.Register(r)
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/users:batch", strings.NewReader(`{
+			"mode": "best_effort",
+			"operations": [
+				{"op": "create", "user": {"nickname": "nn", "password": "pwd"}},
+				{"op": "delete", "id": "1-2-3-5"}
+			]
+		}`))
+		req.Header.Set("content-type", "application/json")
+		resp := httptest.NewRecorder()
+
+		r.ServeHTTP(resp, req)
+
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.JSONEq(t, `{"results":[
+			{"status":201,"id":"1-2-3-4"},
+			{"status":404,"id":"1-2-3-5","error":"Not Found"}
+		]}`, resp.Body.String())
+	})
+
+	t.Run("too many operations", func(t *testing.T) {
+		logger := logging.NewTestLogger()
+		r := NewRouter(logger)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		userHandler := NewUsersHandler(NewMockUserService(ctrl), WithBatchLimit(1))
+		userHandler.Register(r)
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/users:batch", strings.NewReader(`{
+			"operations": [{"op": "delete", "id": "1"}, {"op": "delete", "id": "2"}]
+		}`))
+		req.Header.Set("content-type", "application/json")
+		resp := httptest.NewRecorder()
+
+		r.ServeHTTP(resp, req)
+
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+	})
+}
+
 // TODO: other endpoints should be covered as well
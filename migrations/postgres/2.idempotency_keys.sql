@@ -0,0 +1,12 @@
+-- TODO: this should be part of the database automatic migration flow
+
+CREATE TABLE idempotency_keys (
+    key         VARCHAR(255) PRIMARY KEY,
+    fingerprint CHAR(64) NOT NULL,
+    status      INTEGER, -- is not set while the original request is in progress
+    location    TEXT,
+    created_at  TIMESTAMP NOT NULL,
+    expires_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
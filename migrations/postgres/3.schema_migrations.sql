@@ -0,0 +1,10 @@
+-- TODO: this should be part of the database automatic migration flow
+
+-- versions of the applied migrations, each migration must register its own version
+-- the service verifies the latest one as a part of the readiness check
+CREATE TABLE schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+INSERT INTO schema_migrations (version) VALUES (1), (2), (3);
@@ -0,0 +1,19 @@
+-- TODO: this should be part of the database automatic migration flow
+
+-- refresh tokens of the user sessions, only hashes of the tokens are stored
+-- each refresh replaces the token with a new one of the same family (session),
+-- reuse of the replaced token revokes the whole family as the token could be stolen
+CREATE TABLE refresh_tokens (
+    token_hash CHAR(64) PRIMARY KEY,
+    family_id  CHAR(32) NOT NULL,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at    TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+INSERT INTO schema_migrations (version) VALUES (4);
@@ -0,0 +1,14 @@
+-- TODO: this should be part of the database automatic migration flow
+
+-- state of the rate limiters shared by all instances of the service
+-- `value` and `updated_at` are interpreted by the limiter, the state is considered absent after `expires_at`
+CREATE TABLE rate_limits (
+    key        VARCHAR(255) PRIMARY KEY,
+    value      DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX rate_limits_expires_at_idx ON rate_limits (expires_at);
+
+INSERT INTO schema_migrations (version) VALUES (5);
@@ -0,0 +1,35 @@
+-- TODO: this should be part of the database automatic migration flow
+
+-- version of the user is increased on each modification
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+CREATE FUNCTION users_increase_version() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_increase_version
+    BEFORE UPDATE ON users
+    FOR EACH ROW EXECUTE PROCEDURE users_increase_version();
+
+-- instances of the service are notified about modified and deleted users to invalidate their caches
+-- notifications are delivered only when the transaction is committed
+-- deleted user is reported with the version next to its last one
+CREATE FUNCTION users_notify_changed() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('users_changed', json_build_object('id', OLD.id, 'version', OLD.version + 1)::TEXT);
+        RETURN OLD;
+    END IF;
+    PERFORM pg_notify('users_changed', json_build_object('id', NEW.id, 'version', NEW.version)::TEXT);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_notify_changed
+    AFTER UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE PROCEDURE users_notify_changed();
+
+INSERT INTO schema_migrations (version) VALUES (6);
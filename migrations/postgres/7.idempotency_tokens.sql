@@ -0,0 +1,7 @@
+-- TODO: this should be part of the database automatic migration flow
+
+-- token identifies the reservation, so only the request that holds it can complete or release the key
+-- reservations made before the column existed have no token and just expire after their lease
+ALTER TABLE idempotency_keys ADD COLUMN token CHAR(32);
+
+INSERT INTO schema_migrations (version) VALUES (7);